@@ -28,7 +28,11 @@ import (
 	"time"
 
 	"github.com/blackducksoftware/perceivers/pkg/annotator"
+	"github.com/blackducksoftware/perceivers/pkg/checkpoint"
 	"github.com/blackducksoftware/perceivers/pkg/controller"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/election"
+	"github.com/blackducksoftware/perceivers/pkg/utils"
 	"github.com/blackducksoftware/perceivers/pkg/webhook"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
@@ -39,10 +43,13 @@ type ArtifactoryPerceiver struct {
 	controller         *controller.ArtifactoryController
 	annotator          *annotator.ArtifactoryAnnotator
 	webhook            *webhook.ArtifactoryWebhook
+	registration       *webhook.ArtifactoryRegistration
 	annotationInterval time.Duration
 	dumpInterval       time.Duration
+	registerInterval   time.Duration
 	metricsURL         string
 	dumper             bool
+	elector            *election.Elector
 }
 
 // NewArtifactoryPerceiver creates a new ArtifactoryPerceiver object
@@ -64,15 +71,52 @@ func NewArtifactoryPerceiver(configPath string) (*ArtifactoryPerceiver, error) {
 	}
 	log.SetLevel(level)
 
+	elector, err := election.NewElector(config.Perceiver.LeaderElection, "artifactory-perceiver", nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create leader elector: %v", err)
+	}
+
+	matcher, err := docker.NewMatcher(config.Perceiver.ImageMatching)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image matching: %v", err)
+	}
+
+	err = config.Perceiver.Webhook.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook authentication: %v", err)
+	}
+
+	err = config.Perceiver.Artifactory.Repositories.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository filter: %v", err)
+	}
+
+	// An empty filter is nil so the repositories aren't listed to apply it
+	var filter *utils.ArtifactoryFilter
+	if !config.Perceiver.Artifactory.Repositories.IsEmpty() {
+		filter = &config.Perceiver.Artifactory.Repositories
+	}
+
+	checkpoints, err := checkpoint.NewCheckpoint(config.Perceiver.Artifactory.Checkpoint, "artifactory-perceiver", nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create checkpoint: %v", err)
+	}
+
 	perceptorURL := fmt.Sprintf("http://%s:%d", config.Perceptor.Host, config.Perceptor.Port)
 	ap := ArtifactoryPerceiver{
-		controller:         controller.NewArtifactoryController(perceptorURL, config.PrivateDockerRegistries),
-		annotator:          annotator.NewArtifactoryAnnotator(perceptorURL, config.PrivateDockerRegistries),
-		webhook:            webhook.NewArtifactoryWebhook(perceptorURL, config.PrivateDockerRegistries, config.Perceiver.Certificate, config.Perceiver.CertificateKey),
+		controller:         controller.NewArtifactoryController(perceptorURL, config.PrivateDockerRegistries, checkpoints, filter),
+		annotator:          annotator.NewArtifactoryAnnotator(perceptorURL, config.PrivateDockerRegistries, matcher, filter),
+		webhook:            webhook.NewArtifactoryWebhook(perceptorURL, config.PrivateDockerRegistries, config.Perceiver.Certificate, config.Perceiver.CertificateKey, config.Perceiver.Webhook, filter),
 		annotationInterval: time.Second * time.Duration(config.Perceiver.AnnotationIntervalSeconds),
 		dumpInterval:       time.Minute * time.Duration(config.Perceiver.DumpIntervalMinutes),
+		registerInterval:   config.Perceiver.Registration.Interval(),
 		metricsURL:         fmt.Sprintf(":%d", config.Perceiver.Port),
 		dumper:             config.Perceiver.Artifactory.Dumper,
+		elector:            elector,
+	}
+	// The webhook is only registered in the registries when they can reach it
+	if len(config.Perceiver.Registration.URL) > 0 {
+		ap.registration = webhook.NewArtifactoryRegistration(config.PrivateDockerRegistries, config.Perceiver.Registration.URL, config.Perceiver.Webhook.Secret)
 	}
 	return &ap, nil
 }
@@ -80,11 +124,17 @@ func NewArtifactoryPerceiver(configPath string) (*ArtifactoryPerceiver, error) {
 // Run starts the ArtifactoryPerceiver watching and annotating Images
 func (ap *ArtifactoryPerceiver) Run(stopCh <-chan struct{}) {
 	log.Infof("starting artifactory controllers")
-	// Only run if config set
-	if ap.dumper {
-		go ap.controller.Run(ap.dumpInterval, stopCh)
-	}
-	go ap.annotator.Run(ap.annotationInterval, stopCh)
+	// Every replica receives webhooks, only the leader polls and annotates
 	go ap.webhook.Run()
+	go ap.elector.Run(stopCh, func(leaderStopCh <-chan struct{}) {
+		// Only run if config set
+		if ap.dumper {
+			go ap.controller.Run(ap.dumpInterval, leaderStopCh)
+		}
+		go ap.annotator.Run(ap.annotationInterval, leaderStopCh)
+		if ap.registration != nil {
+			go ap.registration.Run(ap.registerInterval, leaderStopCh)
+		}
+	})
 	<-stopCh
 }
@@ -26,7 +26,11 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/blackducksoftware/perceivers/pkg/checkpoint"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/election"
 	"github.com/blackducksoftware/perceivers/pkg/utils"
+	"github.com/blackducksoftware/perceivers/pkg/webhook"
 	"github.com/fsnotify/fsnotify"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -38,9 +42,13 @@ type PerceptorConfig struct {
 	Port int
 }
 
-// ArtifactoryPerceiverConfig contains config specific to pod perceivers
+// ArtifactoryPerceiverConfig contains config specific to artifactory
+// perceivers.  The checkpoint keeps how far the dumper got, the repositories
+// select what is scanned and annotated
 type ArtifactoryPerceiverConfig struct {
-	Dumper bool
+	Dumper       bool
+	Checkpoint   checkpoint.Config
+	Repositories utils.ArtifactoryFilter
 }
 
 // PerceiverConfig contains general Perceiver config
@@ -51,6 +59,10 @@ type PerceiverConfig struct {
 	DumpIntervalMinutes       int
 	Port                      int
 	Artifactory               ArtifactoryPerceiverConfig
+	LeaderElection            election.Config
+	Webhook                   webhook.AuthConfig
+	Registration              webhook.RegistrationConfig
+	ImageMatching             docker.MatchConfig
 }
 
 // Config contains the ArtifactoryPerceiver configurations
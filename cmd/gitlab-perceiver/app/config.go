@@ -0,0 +1,80 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+
+	"github.com/blackducksoftware/perceivers/pkg/perceiver"
+	"github.com/blackducksoftware/perceivers/pkg/utils"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// GitLabPerceiverConfig contains config specific to gitlab perceivers.  The
+// insecure registries are the container registries reached over plain http
+type GitLabPerceiverConfig struct {
+	Dumper             bool
+	InsecureRegistries []string
+	TimeoutSeconds     int
+}
+
+// PerceiverConfig contains general Perceiver config
+type PerceiverConfig struct {
+	perceiver.Config `mapstructure:",squash"`
+	GitLab           GitLabPerceiverConfig
+}
+
+// Config contains the GitLabPerceiver configurations
+type Config struct {
+	LogLevel                string
+	Perceptor               perceiver.PerceptorConfig
+	Perceiver               PerceiverConfig
+	PrivateDockerRegistries []*utils.RegistryAuth
+}
+
+// GetConfig returns a configuration object to configure a GitLabPerceiver.
+// The private docker registries are the GitLab instances and their
+// credentials.  The token is a personal or group access token, a password
+// is taken as one
+func GetConfig(configPath string) (*Config, error) {
+	var cfg *Config
+
+	err := perceiver.ReadConfig(configPath, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.PrivateDockerRegistries, err = perceiver.PrivateDockerRegistries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find private docker repo credentials: %v", err)
+	}
+
+	return cfg, nil
+}
+
+// StartWatch will start watching the GitLabPerceiver configuration file and
+// call the passed handler function when the configuration file has changed
+func (config *Config) StartWatch(handler func(fsnotify.Event)) {
+	viper.WatchConfig()
+	viper.OnConfigChange(handler)
+}
@@ -24,6 +24,9 @@ package app
 import (
 	"fmt"
 
+	"github.com/blackducksoftware/perceivers/pkg/cluster"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/election"
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
@@ -39,6 +42,9 @@ type PerceiverConfig struct {
 	AnnotationIntervalSeconds int
 	DumpIntervalMinutes       int
 	Port                      int
+	LeaderElection            election.Config
+	Cluster                   cluster.Config
+	ImageMatching             docker.MatchConfig
 }
 
 // Config contains all configuration for a PodPerceiver
@@ -30,9 +30,11 @@ import (
 	"github.com/blackducksoftware/perceivers/pkg/annotations"
 	"github.com/blackducksoftware/perceivers/pkg/annotator"
 	"github.com/blackducksoftware/perceivers/pkg/controller"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
 	"github.com/blackducksoftware/perceivers/pkg/dumper"
+	"github.com/blackducksoftware/perceivers/pkg/election"
 
-	"k8s.io/client-go/rest"
+	"k8s.io/client-go/kubernetes"
 
 	imagev1 "github.com/openshift/client-go/image/clientset/versioned/typed/image/v1"
 
@@ -54,6 +56,8 @@ type ImagePerceiver struct {
 	dumpInterval time.Duration
 
 	metricsURL string
+
+	elector *election.Elector
 }
 
 // NewImagePerceiver creates a new ImagePerceiver object
@@ -63,8 +67,8 @@ func NewImagePerceiver(handler annotations.ImageAnnotatorHandler, configPath str
 		return nil, fmt.Errorf("failed to read config: %v", err)
 	}
 
-	// Create a kube client from in cluster configuration
-	clusterConfig, err := rest.InClusterConfig()
+	// Create a kube client from the in cluster configuration or kubeconfig
+	clusterConfig, err := config.Perceiver.Cluster.RestConfig()
 	if err != nil {
 		return nil, fmt.Errorf("unable to build config from cluster: %v", err)
 	}
@@ -73,6 +77,20 @@ func NewImagePerceiver(handler annotations.ImageAnnotatorHandler, configPath str
 		return nil, fmt.Errorf("unable to create image client: %v", err)
 	}
 
+	clientset, err := kubernetes.NewForConfig(clusterConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create kubernetes client: %v", err)
+	}
+	elector, err := election.NewElector(config.Perceiver.LeaderElection, "image-perceiver", clientset)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create leader elector: %v", err)
+	}
+
+	matcher, err := docker.NewMatcher(config.Perceiver.ImageMatching)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image matching: %v", err)
+	}
+
 	// Configure prometheus for metrics
 	prometheus.Unregister(prometheus.NewProcessCollector(os.Getpid(), ""))
 	prometheus.Unregister(prometheus.NewGoCollector())
@@ -80,12 +98,13 @@ func NewImagePerceiver(handler annotations.ImageAnnotatorHandler, configPath str
 
 	perceptorURL := fmt.Sprintf("http://%s:%d", config.Perceptor.Host, config.Perceptor.Port)
 	p := ImagePerceiver{
-		ImageController:    controller.NewImageController(imageClient, perceptorURL, handler),
-		ImageAnnotator:     annotator.NewImageAnnotator(imageClient, perceptorURL, handler),
+		ImageController:    controller.NewImageController(imageClient, perceptorURL, handler, config.Perceiver.Cluster.Name),
+		ImageAnnotator:     annotator.NewImageAnnotator(imageClient, perceptorURL, handler, matcher),
 		annotationInterval: time.Second * time.Duration(config.Perceiver.AnnotationIntervalSeconds),
-		ImageDumper:        dumper.NewImageDumper(imageClient, perceptorURL),
+		ImageDumper:        dumper.NewImageDumper(imageClient, perceptorURL, config.Perceiver.Cluster.Name),
 		dumpInterval:       time.Minute * time.Duration(config.Perceiver.DumpIntervalMinutes),
 		metricsURL:         fmt.Sprintf(":%d", config.Perceiver.Port),
+		elector:            elector,
 	}
 
 	return &p, nil
@@ -93,10 +112,13 @@ func NewImagePerceiver(handler annotations.ImageAnnotatorHandler, configPath str
 
 // Run starts the ImagePerceiver watching and annotating Images
 func (ip *ImagePerceiver) Run(stopCh <-chan struct{}) {
-	log.Infof("starting image controllers")
-	go ip.ImageController.Run(5, stopCh)
-	go ip.ImageAnnotator.Run(ip.annotationInterval, stopCh)
-	go ip.ImageDumper.Run(ip.dumpInterval, stopCh)
+	// Only the leader watches, dumps and annotates images
+	go ip.elector.RunOnce(stopCh, func(leaderStopCh <-chan struct{}) {
+		log.Infof("starting image controllers")
+		go ip.ImageController.Run(5, leaderStopCh)
+		go ip.ImageAnnotator.Run(ip.annotationInterval, leaderStopCh)
+		go ip.ImageDumper.Run(ip.dumpInterval, leaderStopCh)
+	})
 
 	log.Infof("starting prometheus on %s", ip.metricsURL)
 	http.ListenAndServe(ip.metricsURL, nil)
@@ -0,0 +1,74 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+
+	"github.com/blackducksoftware/perceivers/pkg/perceiver"
+	"github.com/blackducksoftware/perceivers/pkg/utils"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// NexusPerceiverConfig contains config specific to nexus perceivers
+type NexusPerceiverConfig struct {
+	Dumper bool
+}
+
+// PerceiverConfig contains general Perceiver config
+type PerceiverConfig struct {
+	perceiver.Config `mapstructure:",squash"`
+	Nexus            NexusPerceiverConfig
+}
+
+// Config contains the NexusPerceiver configurations
+type Config struct {
+	LogLevel                string
+	Perceptor               perceiver.PerceptorConfig
+	Perceiver               PerceiverConfig
+	PrivateDockerRegistries []*utils.RegistryAuth
+}
+
+// GetConfig returns a configuration object to configure a NexusPerceiver
+func GetConfig(configPath string) (*Config, error) {
+	var cfg *Config
+
+	err := perceiver.ReadConfig(configPath, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.PrivateDockerRegistries, err = perceiver.PrivateDockerRegistries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find private docker repo credentials: %v", err)
+	}
+
+	return cfg, nil
+}
+
+// StartWatch will start watching the NexusPerceiver configuration file and
+// call the passed handler function when the configuration file has changed
+func (config *Config) StartWatch(handler func(fsnotify.Event)) {
+	viper.WatchConfig()
+	viper.OnConfigChange(handler)
+}
@@ -0,0 +1,62 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+
+	"github.com/blackducksoftware/perceivers/pkg/annotator"
+	"github.com/blackducksoftware/perceivers/pkg/controller"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/perceiver"
+	"github.com/blackducksoftware/perceivers/pkg/webhook"
+)
+
+// NexusPerceiver handles watching and annotating Images
+type NexusPerceiver struct {
+	*perceiver.Perceiver
+}
+
+// NewNexusPerceiver creates a new NexusPerceiver object
+func NewNexusPerceiver(configPath string) (*NexusPerceiver, error) {
+	config, err := GetConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	perceiver.Setup(config.LogLevel)
+
+	matcher, err := docker.NewMatcher(config.Perceiver.ImageMatching)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image matching: %v", err)
+	}
+
+	perceptorURL := config.Perceptor.URL()
+	p, err := perceiver.NewPerceiver("nexus-perceiver", &config.Perceiver.Config,
+		controller.NewNexusController(perceptorURL, config.PrivateDockerRegistries),
+		annotator.NewNexusAnnotator(perceptorURL, config.PrivateDockerRegistries, matcher),
+		webhook.NewNexusWebhook(perceptorURL, config.PrivateDockerRegistries, config.Perceiver.Certificate, config.Perceiver.CertificateKey),
+		config.Perceiver.Nexus.Dumper)
+	if err != nil {
+		return nil, err
+	}
+	return &NexusPerceiver{Perceiver: p}, nil
+}
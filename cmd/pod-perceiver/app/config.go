@@ -24,6 +24,11 @@ package app
 import (
 	"fmt"
 
+	"github.com/blackducksoftware/perceivers/pkg/cluster"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/election"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	"github.com/blackducksoftware/perceivers/pkg/sharding"
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/viper"
 )
@@ -45,6 +50,19 @@ type PerceiverConfig struct {
 	DumpIntervalMinutes       int
 	Port                      int
 	Pod                       PodPerceiverConfig
+	LeaderElection            election.Config
+	Sharding                  sharding.Config
+	// Cluster is where leader election and sharding state is kept, and the
+	// cluster that is watched when Clusters is empty.  Its name is the
+	// cluster ID sent to perceptor
+	Cluster cluster.Config
+	// Clusters lists the clusters to watch from a single perceiver
+	Clusters []cluster.Config
+	// ImageMatching decides which scan results belong to an image
+	ImageMatching docker.MatchConfig
+	// Registry resolves the images of pods that don't run yet through the
+	// registry API
+	Registry registry.Config
 }
 
 // Config contains all configuration for a PodPerceiver
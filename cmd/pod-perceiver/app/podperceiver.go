@@ -29,28 +29,39 @@ import (
 
 	"github.com/blackducksoftware/perceivers/pkg/annotations"
 	"github.com/blackducksoftware/perceivers/pkg/annotator"
+	"github.com/blackducksoftware/perceivers/pkg/cluster"
 	"github.com/blackducksoftware/perceivers/pkg/controller"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
 	"github.com/blackducksoftware/perceivers/pkg/dumper"
+	"github.com/blackducksoftware/perceivers/pkg/election"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	"github.com/blackducksoftware/perceivers/pkg/sharding"
 
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-// PodPerceiver handles watching and annotating pods
+// PodPerceiver handles watching and annotating pods.  There is one
+// controller, annotator and dumper per watched cluster
 type PodPerceiver struct {
-	podController *controller.PodController
+	podControllers []*controller.PodController
 
-	podAnnotator       *annotator.PodAnnotator
+	podAnnotators      []*annotator.PodAnnotator
 	annotationInterval time.Duration
 
-	podDumper    *dumper.PodDumper
+	podDumpers   []*dumper.PodDumper
 	dumpInterval time.Duration
 
+	resolvers []*registry.Resolver
+
 	metricsURL string
+
+	elector *election.Elector
+	shard   *sharding.Shard
+	matcher *docker.Matcher
 }
 
 // NewPodPerceiver creates a new PodPerceiver object
@@ -60,14 +71,35 @@ func NewPodPerceiver(handler annotations.PodAnnotatorHandler, configPath string)
 		panic(fmt.Errorf("failed to read config: %v", err))
 	}
 
-	// Create a kube client from in cluster configuration
-	clusterConfig, err := rest.InClusterConfig()
+	// Create a kube client for the cluster the perceiver keeps its state in
+	clientset, err := newClientset(config.Perceiver.Cluster)
 	if err != nil {
-		return nil, fmt.Errorf("unable to build config from cluster: %v", err)
+		return nil, err
 	}
-	clientset, err := kubernetes.NewForConfig(clusterConfig)
+	err = cluster.ValidateClusters(config.Perceiver.Clusters)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create kubernetes client: %v", err)
+		return nil, fmt.Errorf("invalid clusters: %v", err)
+	}
+
+	// A sharded replica is active and owns part of the namespaces, so it
+	// can't also wait as a standby for the leadership of all of them
+	if config.Perceiver.LeaderElection.Enabled && config.Perceiver.Sharding.Enabled {
+		return nil, fmt.Errorf("leader election and sharding can't both be enabled")
+	}
+
+	elector, err := election.NewElector(config.Perceiver.LeaderElection, "pod-perceiver", clientset)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create leader elector: %v", err)
+	}
+
+	shard, err := sharding.NewShard(config.Perceiver.Sharding, "pod-perceiver", clientset)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create shard: %v", err)
+	}
+
+	matcher, err := docker.NewMatcher(config.Perceiver.ImageMatching)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image matching: %v", err)
 	}
 
 	// Configure prometheus for metrics
@@ -77,23 +109,72 @@ func NewPodPerceiver(handler annotations.PodAnnotatorHandler, configPath string)
 
 	perceptorURL := fmt.Sprintf("http://%s:%d", config.Perceptor.Host, config.Perceptor.Port)
 	p := PodPerceiver{
-		podController:      controller.NewPodController(clientset, perceptorURL, config.Perceiver.Pod.NamespaceFilter, handler),
-		podAnnotator:       annotator.NewPodAnnotator(clientset.CoreV1(), perceptorURL, handler),
 		annotationInterval: time.Second * time.Duration(config.Perceiver.AnnotationIntervalSeconds),
-		podDumper:          dumper.NewPodDumper(clientset.CoreV1(), perceptorURL, config.Perceiver.Pod.NamespaceFilter),
 		dumpInterval:       time.Minute * time.Duration(config.Perceiver.DumpIntervalMinutes),
 		metricsURL:         fmt.Sprintf(":%d", config.Perceiver.Port),
+		elector:            elector,
+		shard:              shard,
+		matcher:            matcher,
+	}
+
+	// Without a list of clusters only the perceiver's own cluster is watched
+	if len(config.Perceiver.Clusters) == 0 {
+		p.addCluster(clientset, config.Perceiver.Cluster.Name, perceptorURL, config.Perceiver.Pod.NamespaceFilter, handler, config.Perceiver.Registry)
+	}
+	for _, c := range config.Perceiver.Clusters {
+		client, err := newClientset(c)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %v", c.Name, err)
+		}
+		p.addCluster(client, c.Name, perceptorURL, config.Perceiver.Pod.NamespaceFilter, handler, config.Perceiver.Registry)
 	}
 
 	return &p, nil
 }
 
+func newClientset(c cluster.Config) (*kubernetes.Clientset, error) {
+	clusterConfig, err := c.RestConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable to build config from cluster: %v", err)
+	}
+	clientset, err := kubernetes.NewForConfig(clusterConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create kubernetes client: %v", err)
+	}
+	return clientset, nil
+}
+
+func (pp *PodPerceiver) addCluster(client kubernetes.Interface, clusterName string, perceptorURL string, nsFilter string, handler annotations.PodAnnotatorHandler, registryConfig registry.Config) {
+	// Pull secrets live in the cluster of the pod, so each cluster gets its
+	// own resolver
+	resolver := registry.NewResolver(registryConfig, client, nsFilter)
+	pp.resolvers = append(pp.resolvers, resolver)
+	pp.podControllers = append(pp.podControllers, controller.NewPodController(client, perceptorURL, nsFilter, handler, pp.shard, clusterName, resolver))
+	pp.podAnnotators = append(pp.podAnnotators, annotator.NewPodAnnotator(client.CoreV1(), perceptorURL, handler, pp.shard, clusterName, pp.matcher, resolver))
+	pp.podDumpers = append(pp.podDumpers, dumper.NewPodDumper(client.CoreV1(), perceptorURL, nsFilter, pp.shard, clusterName, resolver))
+}
+
 // Run starts the PodPerceiver watching and annotating pods
 func (pp *PodPerceiver) Run(stopCh <-chan struct{}) {
-	log.Infof("starting pod controllers")
-	go pp.podController.Run(5, stopCh)
-	go pp.podAnnotator.Run(pp.annotationInterval, stopCh)
-	go pp.podDumper.Run(pp.dumpInterval, stopCh)
+	if pp.shard != nil {
+		// Until it has joined the shard owns no namespace
+		err := pp.shard.Join()
+		if err != nil {
+			log.Errorf("unable to join shard membership: %v", err)
+		}
+		go pp.shard.Run(stopCh)
+	}
+
+	// Only the leader watches, dumps and annotates pods
+	go pp.elector.RunOnce(stopCh, func(leaderStopCh <-chan struct{}) {
+		log.Infof("starting pod controllers")
+		for i := range pp.podControllers {
+			go pp.resolvers[i].Run(leaderStopCh)
+			go pp.podControllers[i].Run(5, leaderStopCh)
+			go pp.podAnnotators[i].Run(pp.annotationInterval, leaderStopCh)
+			go pp.podDumpers[i].Run(pp.dumpInterval, leaderStopCh)
+		}
+	})
 
 	log.Infof("starting prometheus on %s", pp.metricsURL)
 	http.ListenAndServe(pp.metricsURL, nil)
@@ -26,7 +26,11 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/blackducksoftware/perceivers/pkg/annotator"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/election"
 	"github.com/blackducksoftware/perceivers/pkg/utils"
+	"github.com/blackducksoftware/perceivers/pkg/webhook"
 	"github.com/fsnotify/fsnotify"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -38,6 +42,16 @@ type PerceptorConfig struct {
 	Port int
 }
 
+// QuayPerceiverConfig contains config specific to quay perceivers.  The
+// repositories are matched by namespace/name
+type QuayPerceiverConfig struct {
+	Dumper       bool
+	Repositories utils.RepositoryFilter
+	Paging       utils.QuayPaging
+	Summaries    annotator.QuaySummaryConfig
+	Enforcement  annotator.QuayEnforcementConfig
+}
+
 // PerceiverConfig contains general Perceiver config
 type PerceiverConfig struct {
 	Certificate               string
@@ -45,6 +59,11 @@ type PerceiverConfig struct {
 	AnnotationIntervalSeconds int
 	DumpIntervalMinutes       int
 	Port                      int
+	Quay                      QuayPerceiverConfig
+	LeaderElection            election.Config
+	Webhook                   webhook.AuthConfig
+	Registration              webhook.RegistrationConfig
+	ImageMatching             docker.MatchConfig
 }
 
 // Config return the Artifactory Perceiver configurations
@@ -28,6 +28,9 @@ import (
 	"time"
 
 	"github.com/blackducksoftware/perceivers/pkg/annotator"
+	"github.com/blackducksoftware/perceivers/pkg/controller"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/election"
 	"github.com/blackducksoftware/perceivers/pkg/webhook"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
@@ -35,11 +38,16 @@ import (
 
 // QuayPerceiver handles watching and annotating Images
 type QuayPerceiver struct {
+	controller         *controller.QuayController
 	annotator          *annotator.QuayAnnotator
 	webhook            *webhook.QuayWebhook
+	registration       *webhook.QuayRegistration
 	annotationInterval time.Duration
 	dumpInterval       time.Duration
+	registerInterval   time.Duration
 	metricsURL         string
+	dumper             bool
+	elector            *election.Elector
 }
 
 // NewQuayPerceiver creates a new ImagePerceiver object
@@ -61,13 +69,46 @@ func NewQuayPerceiver(configPath string) (*QuayPerceiver, error) {
 	}
 	log.SetLevel(level)
 
+	elector, err := election.NewElector(config.Perceiver.LeaderElection, "quay-perceiver", nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create leader elector: %v", err)
+	}
+
+	matcher, err := docker.NewMatcher(config.Perceiver.ImageMatching)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image matching: %v", err)
+	}
+
+	err = config.Perceiver.Webhook.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook authentication: %v", err)
+	}
+
+	err = config.Perceiver.Quay.Repositories.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository filter: %v", err)
+	}
+
+	err = config.Perceiver.Quay.Enforcement.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid enforcement policy: %v", err)
+	}
+
 	perceptorURL := fmt.Sprintf("http://%s:%d", config.Perceptor.Host, config.Perceptor.Port)
 	qp := QuayPerceiver{
-		annotator:          annotator.NewQuayAnnotator(perceptorURL, config.PrivateDockerRegistries),
-		webhook:            webhook.NewQuayWebhook(perceptorURL, config.PrivateDockerRegistries, config.Perceiver.Certificate, config.Perceiver.CertificateKey),
+		controller:         controller.NewQuayController(perceptorURL, config.PrivateDockerRegistries, &config.Perceiver.Quay.Repositories, &config.Perceiver.Quay.Paging),
+		annotator:          annotator.NewQuayAnnotator(perceptorURL, config.PrivateDockerRegistries, matcher, &config.Perceiver.Quay.Paging, config.Perceiver.Quay.Summaries, &config.Perceiver.Quay.Enforcement),
+		webhook:            webhook.NewQuayWebhook(perceptorURL, config.PrivateDockerRegistries, config.Perceiver.Certificate, config.Perceiver.CertificateKey, &config.Perceiver.Quay.Paging, config.Perceiver.Webhook, &config.Perceiver.Quay.Repositories),
 		annotationInterval: time.Second * time.Duration(config.Perceiver.AnnotationIntervalSeconds),
 		dumpInterval:       time.Minute * time.Duration(config.Perceiver.DumpIntervalMinutes),
+		registerInterval:   config.Perceiver.Registration.Interval(),
 		metricsURL:         fmt.Sprintf(":%d", config.Perceiver.Port),
+		dumper:             config.Perceiver.Quay.Dumper,
+		elector:            elector,
+	}
+	// The webhook is only registered in the registries when they can reach it
+	if len(config.Perceiver.Registration.URL) > 0 {
+		qp.registration = webhook.NewQuayRegistration(config.PrivateDockerRegistries, config.Perceiver.Registration.URL, config.Perceiver.Webhook.Secret, &config.Perceiver.Quay.Repositories, &config.Perceiver.Quay.Paging)
 	}
 	return &qp, nil
 }
@@ -75,7 +116,17 @@ func NewQuayPerceiver(configPath string) (*QuayPerceiver, error) {
 // Run starts the QuayPerceiver watching and annotating Images
 func (qp *QuayPerceiver) Run(stopCh <-chan struct{}) {
 	log.Infof("starting quay controllers")
-	go qp.annotator.Run(qp.annotationInterval, stopCh)
+	// Every replica receives webhooks, only the leader lists and annotates
 	go qp.webhook.Run()
+	go qp.elector.Run(stopCh, func(leaderStopCh <-chan struct{}) {
+		// Only run if config set
+		if qp.dumper {
+			go qp.controller.Run(qp.dumpInterval, leaderStopCh)
+		}
+		go qp.annotator.Run(qp.annotationInterval, leaderStopCh)
+		if qp.registration != nil {
+			go qp.registration.Run(qp.registerInterval, leaderStopCh)
+		}
+	})
 	<-stopCh
 }
@@ -0,0 +1,104 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/controller"
+	"github.com/blackducksoftware/perceivers/pkg/election"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	"github.com/blackducksoftware/perceivers/pkg/webhook"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// RegistryPerceiver handles discovering the images of registries that
+// implement the docker registry API
+type RegistryPerceiver struct {
+	controller   *controller.RegistryController
+	webhook      *webhook.RegistryWebhook
+	dumpInterval time.Duration
+	metricsURL   string
+	dumper       bool
+	elector      *election.Elector
+}
+
+// NewRegistryPerceiver creates a new RegistryPerceiver object
+func NewRegistryPerceiver(configPath string) (*RegistryPerceiver, error) {
+	config, err := GetConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+
+	// Configure prometheus for metrics
+	prometheus.Unregister(prometheus.NewProcessCollector(os.Getpid(), ""))
+	prometheus.Unregister(prometheus.NewGoCollector())
+	http.Handle("/metrics", prometheus.Handler())
+
+	// Set log level
+	level, err := config.GetLogLevel()
+	if err != nil {
+		level = log.DebugLevel
+	}
+	log.SetLevel(level)
+
+	elector, err := election.NewElector(config.Perceiver.LeaderElection, "registry-perceiver", nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create leader elector: %v", err)
+	}
+
+	timeout := time.Duration(config.Perceiver.Registry.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	client := registry.NewClient(config.Perceiver.Registry.InsecureRegistries, timeout)
+
+	perceptorURL := fmt.Sprintf("http://%s:%d", config.Perceptor.Host, config.Perceptor.Port)
+	rp := RegistryPerceiver{
+		controller:   controller.NewRegistryController(perceptorURL, config.PrivateDockerRegistries, client),
+		webhook:      webhook.NewRegistryWebhook(perceptorURL, config.PrivateDockerRegistries, config.Perceiver.Certificate, config.Perceiver.CertificateKey, client),
+		dumpInterval: time.Minute * time.Duration(config.Perceiver.DumpIntervalMinutes),
+		metricsURL:   fmt.Sprintf(":%d", config.Perceiver.Port),
+		dumper:       config.Perceiver.Registry.Dumper,
+		elector:      elector,
+	}
+	return &rp, nil
+}
+
+// Run starts the RegistryPerceiver receiving notifications and listing
+// the images of the registries
+func (rp *RegistryPerceiver) Run(stopCh <-chan struct{}) {
+	log.Infof("starting registry controllers")
+	// Every replica receives webhooks, only the leader lists the registries
+	go rp.webhook.Run()
+	go rp.elector.Run(stopCh, func(leaderStopCh <-chan struct{}) {
+		// Only run if config set
+		if rp.dumper {
+			go rp.controller.Run(rp.dumpInterval, leaderStopCh)
+		}
+	})
+	<-stopCh
+}
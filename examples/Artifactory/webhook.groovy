@@ -19,6 +19,8 @@ import com.google.common.collect.ImmutableBiMap
 import groovy.json.JsonBuilder
 import groovy.json.JsonSlurper
 import java.util.regex.Pattern
+import javax.crypto.Mac
+import javax.crypto.spec.SecretKeySpec
 import java.util.concurrent.ExecutorService
 import java.util.concurrent.Executors
 /**
@@ -32,6 +34,11 @@ import java.util.concurrent.Executors
  * 1. Copy webhook.config.json.sample to webhook.config.json, configure and copy to ARTIFACTORY_HOME/etc/plugins
  * 2. add webook.groovy to <artifactory.home>/etc/plugins, artifactory should log: Script 'webhook' loaded.
  *
+ * A webhook with a secret signs its POSTs with it, in the X-JFrog-Event-Auth
+ * header as the hex HMAC-SHA256 of the body, like the webhooks built in to
+ * Artifactory.  The artifactory-perceiver rejects unsigned events when its
+ * Perceiver.Webhook.Secret is set, so the secret of its webhook must be the same.
+ *
  */
 
 /**
@@ -611,7 +618,7 @@ class WebHook {
                         if (webhookListener.isAsync()) {
                             if (eventPassedFilters(event, json, webhookListener))
                                 excutorService.execute(
-                                        new PostTask(webhookListener.url, getFormattedJSONString(json, event, webhookListener)))
+                                        new PostTask(webhookListener.url, getFormattedJSONString(json, event, webhookListener), webhookListener.secret))
                         }
                     } catch (Exception e) {
                         // We don't capture async results
@@ -623,7 +630,7 @@ class WebHook {
                     try {
                         if (!webhookListener.isAsync())
                             if (eventPassedFilters(event, json, webhookListener))
-                                callPost(webhookListener.url, getFormattedJSONString(json, event, webhookListener))
+                                callPost(webhookListener.url, getFormattedJSONString(json, event, webhookListener), webhookListener.secret)
                     } catch (Exception e) {
                         if (debug)
                             e.printStackTrace()
@@ -706,19 +713,23 @@ class WebHook {
      * Performs the actual POST request
      * @param urlString The remote site to POST to
      * @param content The JSON formatted body for the POST
+     * @param secret The secret the body is signed with, if any
      * @return The response/error code from the remote site
      */
-    private String callPost(String urlString, String content) {
+    private String callPost(String urlString, String content, String secret = null) {
+        def body = content.getBytes("UTF-8")
         def post = new URL(urlString).openConnection()
         post.method = "POST"
         post.doOutput = true
         post.setConnectTimeout(connectionTimeout)
         post.setReadTimeout(connectionTimeout)
         post.setRequestProperty("Content-Type", "application/json")
+        if (secret)
+            post.setRequestProperty("X-JFrog-Event-Auth", sign(body, secret))
         def writer = null, reader = null
         try {
             writer = post.outputStream
-            writer.write(content.getBytes("UTF-8"))
+            writer.write(body)
             writer.flush()
             def postRC = post.getResponseCode()
             def response = postRC
@@ -735,6 +746,18 @@ class WebHook {
         }
     }
 
+    /**
+     * Signs a body with a secret
+     * @param body The body of the POST
+     * @param secret The secret of the webhook
+     * @return The hex HMAC-SHA256 of the body
+     */
+    private static String sign(byte[] body, String secret) {
+        def mac = Mac.getInstance("HmacSHA256")
+        mac.init(new SecretKeySpec(secret.getBytes("UTF-8"), "HmacSHA256"))
+        return mac.doFinal(body).encodeHex().toString()
+    }
+
     /**
      * Reloads the configuration, discarding the previous one.
      */
@@ -805,6 +828,9 @@ class WebHook {
                     // Repositories
                     if (cfg.containsKey('format'))
                         webhookDetails.format = cfg.format
+                    // Secret the POSTs are signed with
+                    if (cfg.containsKey('secret'))
+                        webhookDetails.secret = cfg.secret
                     // Path filter
                     if (cfg.containsKey('path'))
                         webhookDetails.setPathFilter(cfg.path)
@@ -869,6 +895,7 @@ class WebHook {
         public static String ALL_REPOS = "*"
         def url
         def format = null // Default format
+        String secret = null // Unsigned
         def repositories = [ALL_REPOS] // All
         def async = true
         Pattern path = null
@@ -956,14 +983,16 @@ class WebHook {
     class PostTask implements Runnable {
         private String url
         private String content
+        private String secret
 
-        PostTask(String url, String content) {
+        PostTask(String url, String content, String secret) {
             this.url = url
             this.content = content
+            this.secret = secret
         }
 
         void run() {
-            callPost(url, content)
+            callPost(url, content, secret)
         }
     }
 }
@@ -26,10 +26,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"path"
 	"strings"
 	"time"
 
 	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
 	"github.com/blackducksoftware/perceivers/pkg/metrics"
 	"github.com/blackducksoftware/perceivers/pkg/utils"
 
@@ -50,16 +52,20 @@ type ArtifactoryAnnotator struct {
 	client         *http.Client
 	scanResultsURL string
 	registryAuths  []*utils.RegistryAuth
+	matcher        *docker.Matcher
+	filter         *utils.ArtifactoryFilter
 }
 
 // NewArtifactoryAnnotator creates a new ArtifactoryAnnotator object
-func NewArtifactoryAnnotator(perceptorURL string, registryAuths []*utils.RegistryAuth) *ArtifactoryAnnotator {
+func NewArtifactoryAnnotator(perceptorURL string, registryAuths []*utils.RegistryAuth, matcher *docker.Matcher, filter *utils.ArtifactoryFilter) *ArtifactoryAnnotator {
 	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
 	client := &http.Client{Transport: tr}
 	return &ArtifactoryAnnotator{
 		client:         client,
 		scanResultsURL: fmt.Sprintf("%s/%s", perceptorURL, perceptorapi.ScanResultsPath),
 		registryAuths:  registryAuths,
+		matcher:        matcher,
+		filter:         filter,
 	}
 }
 
@@ -127,12 +133,22 @@ func (ia *ArtifactoryAnnotator) addAnnotationsToImages(results perceptorapi.Scan
 			log.Debugf("Annotator: URL %s either not a valid Artifactory repository or incorrect credentials: %e", registry.URL, err)
 			continue
 		}
+		repoTypes := map[string]string{}
+		if ia.filter != nil {
+			repoTypes, err = utils.GetArtifactoryDockerRepos(cred)
+			if err != nil {
+				log.Errorf("Annotator: Error in getting docker repos: %e", err)
+				continue
+			}
+		}
+
 		regs = regs + 1
 		imgs := 0
 		for _, image := range results.Images {
 
-			// The base URL may contain something in thier instance, splitting has no loss
-			if !strings.Contains(image.Repository, strings.Split(registry.URL, "/")[0]) {
+			// Artifactory is searched by checksum, so when matching by digest
+			// the image is annotated wherever it was pushed
+			if ia.matcher.Mode() != docker.MatchDigest && !ia.matcher.InRegistry(image.Repository, registry.URL) {
 				log.Debugf("Annotator: Registry URL %s does not correspond to scan repo %s", registry.URL, image.Repository)
 				continue
 			}
@@ -149,6 +165,10 @@ func (ia *ArtifactoryAnnotator) addAnnotationsToImages(results perceptorapi.Scan
 
 			log.Debugf("Annotator: Total Repos for image %s in artifactory: %d", image.Repository, len(repos.Results))
 			for _, repo := range repos.Results {
+				if !ia.selected(repoTypes, repo.URI) {
+					log.Debugf("Annotator: %s is not in the selected repositories", repo.URI)
+					continue
+				}
 				uri := strings.Replace(repo.URI, "/manifest.json", "", -1)
 				ia.AnnotateImage(uri, &image, cred)
 				imgs = imgs + 1
@@ -156,12 +176,67 @@ func (ia *ArtifactoryAnnotator) addAnnotationsToImages(results perceptorapi.Scan
 
 		}
 
+		imgs = imgs + ia.annotateManifestLists(registry, cred, repoTypes, results.Images)
+
 		log.Infof("Annotator: Total scanned images found for Artifactory repo %s: %d", registry.URL, imgs)
 	}
 
 	log.Infof("Annotator: Total valid Artifactory Registries: %d", regs)
 }
 
+// annotateManifestLists annotates the manifest lists of the scanned tags in
+// the registry with the aggregate of the scans of their platforms and
+// returns how many were annotated
+func (ia *ArtifactoryAnnotator) annotateManifestLists(registry *utils.RegistryAuth, cred *utils.RegistryAuth, repoTypes map[string]string, images []perceptorapi.ScannedImage) int {
+	lists := 0
+	for key, scans := range scannedTags(images) {
+		// Images are sent to perceptor as <registry>/<repo key>/<image>
+		path := strings.TrimPrefix(key.repository, registry.URL+"/")
+		if path == key.repository || !strings.Contains(path, "/") {
+			continue
+		}
+		parts := strings.SplitN(path, "/", 2)
+		repoKey, image := parts[0], parts[1]
+		if !ia.filter.Matches(repoKey, repoTypes[repoKey], image) {
+			continue
+		}
+
+		url := fmt.Sprintf("%s/api/docker/%s/v2/%s/manifests/%s", cred.URL, repoKey, image, key.tag)
+		manifest, err := utils.GetManifest(url, cred, "")
+		if err != nil {
+			log.Errorf("Annotator: Error in getting manifest of %s:%s: %e", key.repository, key.tag, err)
+			continue
+		}
+		listScan, ok := manifestListScan(manifest, scans)
+		if !ok {
+			continue
+		}
+
+		ia.AnnotateImage(fmt.Sprintf("%s/api/storage/%s/%s/%s", cred.URL, repoKey, image, key.tag), listScan, cred)
+		lists = lists + 1
+	}
+	return lists
+}
+
+// selected returns whether the manifest at a URI of the storage API, like
+// <base>/api/storage/<repo key>/<image>/<tag>/manifest.json, is in the
+// repositories and images selected by the filter
+func (ia *ArtifactoryAnnotator) selected(repoTypes map[string]string, uri string) bool {
+	if ia.filter == nil {
+		return true
+	}
+	parts := strings.SplitN(uri, "/api/storage/", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	item := strings.SplitN(parts[1], "/", 2)
+	if len(item) != 2 {
+		return false
+	}
+	repoKey := utils.ArtDockerRepoKey(item[0], repoTypes)
+	return len(repoKey) > 0 && ia.filter.Matches(repoKey, repoTypes[repoKey], path.Dir(path.Dir(item[1])))
+}
+
 // AnnotateImage takes the specific Artifactory URL and applies the properties/annotations given by BD
 func (ia *ArtifactoryAnnotator) AnnotateImage(uri string, im *perceptorapi.ScannedImage, cred *utils.RegistryAuth) {
 	log.Infof("Annotator: Annotating image in artifactory %s with URI %s", im.Repository, uri)
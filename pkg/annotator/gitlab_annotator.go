@@ -0,0 +1,289 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package annotator
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	"github.com/blackducksoftware/perceivers/pkg/utils"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// GitLabAnnotator handles recording the vulnerability and policy issues of
+// the images in the container registry of GitLab as CI variables of their
+// projects, so pipelines can gate on them
+type GitLabAnnotator struct {
+	client         *http.Client
+	registryClient *registry.Client
+	scanResultsURL string
+	registryAuths  []*utils.RegistryAuth
+	matcher        *docker.Matcher
+}
+
+// NewGitLabAnnotator creates a new GitLabAnnotator object
+func NewGitLabAnnotator(perceptorURL string, registryAuths []*utils.RegistryAuth, registryClient *registry.Client, matcher *docker.Matcher) *GitLabAnnotator {
+	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	client := &http.Client{Transport: tr}
+	return &GitLabAnnotator{
+		client:         client,
+		registryClient: registryClient,
+		scanResultsURL: fmt.Sprintf("%s/%s", perceptorURL, perceptorapi.ScanResultsPath),
+		registryAuths:  registryAuths,
+		matcher:        matcher,
+	}
+}
+
+// Run starts a controller that will record the scan results of images
+func (ga *GitLabAnnotator) Run(interval time.Duration, stopCh <-chan struct{}) {
+	log.Infof("Annotator: starting gitlab annotator")
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		time.Sleep(interval)
+
+		err := ga.annotate()
+		if err != nil {
+			log.Errorf("Annotator: failed to annotate gitlab images: %v", err)
+		}
+	}
+}
+
+func (ga *GitLabAnnotator) annotate() error {
+	// Get all the scan results from the Perceptor
+	log.Infof("Annotator: attempting to GET %s for gitlab image annotation", ga.scanResultsURL)
+	scanResults, err := ga.getScanResults()
+	if err != nil {
+		metrics.RecordError("gitlab_annotator", "error getting scan results")
+		return fmt.Errorf("error getting scan results: %v", err)
+	}
+
+	// Process the scan results and record them in the projects of the images
+	log.Infof("Annotator: GET to %s succeeded, about to update variables of all gitlab projects", ga.scanResultsURL)
+	ga.addAnnotationsToImages(*scanResults)
+	return nil
+}
+
+func (ga *GitLabAnnotator) getScanResults() (*perceptorapi.ScanResults, error) {
+	var results perceptorapi.ScanResults
+
+	bytes, err := communicator.GetPerceptorScanResults(ga.scanResultsURL)
+	if err != nil {
+		metrics.RecordError("gitlab_annotator", "unable to get scan results")
+		return nil, fmt.Errorf("unable to get scan results: %v", err)
+	}
+
+	err = json.Unmarshal(bytes, &results)
+	if err != nil {
+		metrics.RecordError("gitlab_annotator", "unable to Unmarshal ScanResults")
+		return nil, fmt.Errorf("unable to Unmarshal ScanResults from url %s: %v", ga.scanResultsURL, err)
+	}
+
+	return &results, nil
+}
+
+// This method walks the container repositories of all the projects and
+// records the scans of their tags by matching their SHAs
+func (ga *GitLabAnnotator) addAnnotationsToImages(results perceptorapi.ScanResults) {
+	regs := 0
+	tags := ga.matchedTags(results.Images)
+
+	for _, auth := range ga.registryAuths {
+		cred, err := utils.PingGitLabServer("https://"+auth.URL, auth.User, auth.Password, auth.Token)
+		if err != nil {
+			log.Debugf("Annotator: URL %s either not a valid GitLab instance or incorrect token: %e", auth.URL, err)
+			continue
+		}
+
+		projects, err := utils.GetGitLabProjects(cred)
+		if err != nil {
+			log.Errorf("Annotator: Error in getting projects of gitlab instance %s: %e", auth.URL, err)
+			continue
+		}
+
+		regs = regs + 1
+		imgs := 0
+		for _, project := range projects {
+			repositories, err := utils.GetGitLabRepositories(cred, project.ID)
+			if err != nil {
+				log.Errorf("Annotator: Error in getting container repositories of gitlab project %s: %e", project.PathWithNamespace, err)
+				continue
+			}
+			keys := map[string]bool{}
+			for _, repository := range repositories {
+				for _, tag := range repository.Tags {
+					keys[utils.GitLabVariableKey(repository.Path, tag.Name)] = true
+					scans, ok := tags[scannedTag{repository: ga.matchName(repository.Location), tag: tag.Name}]
+					if !ok {
+						continue
+					}
+					if ga.annotateTag(cred, &repository, tag.Name, scans) {
+						imgs = imgs + 1
+					}
+				}
+			}
+			ga.removeVariables(cred, project.ID, keys)
+		}
+
+		log.Infof("Annotator: Total scanned images in GitLab with URL %s: %d", auth.URL, imgs)
+	}
+
+	log.Infof("Annotator: Total valid GitLab instances: %d", regs)
+}
+
+// matchedTags groups the scans by the tag and the name the matcher compares
+// their repository by
+func (ga *GitLabAnnotator) matchedTags(images []perceptorapi.ScannedImage) map[scannedTag][]perceptorapi.ScannedImage {
+	tags := map[scannedTag][]perceptorapi.ScannedImage{}
+	for key, scans := range scannedTags(images) {
+		key.repository = ga.matchName(key.repository)
+		tags[key] = append(tags[key], scans...)
+	}
+	return tags
+}
+
+// matchName returns the name the matcher compares a repository by: the
+// name as written, its canonical name, or none when only the digests are
+// compared
+func (ga *GitLabAnnotator) matchName(repository string) string {
+	switch ga.matcher.Mode() {
+	case docker.MatchCanonical:
+		return ga.matcher.CanonicalName(repository)
+	case docker.MatchDigest:
+		return ""
+	}
+	return repository
+}
+
+// annotateTag records the scan of the image a tag points to, or the
+// aggregate of the scans of the platforms of a manifest list, and returns
+// whether the tag is scanned
+func (ga *GitLabAnnotator) annotateTag(cred *utils.RegistryAuth, repository *utils.GitLabRepository, tag string, scans []perceptorapi.ScannedImage) bool {
+	manifest, err := utils.GetGitLabManifest(ga.registryClient, cred, repository, tag)
+	if err != nil {
+		log.Errorf("Annotator: Error in getting manifest of %s:%s: %v", repository.Location, tag, err)
+		return false
+	}
+
+	var scan *perceptorapi.ScannedImage
+	if manifest.IsList() {
+		listScan, ok := manifestListScan(manifest, scans)
+		if !ok {
+			log.Debugf("Annotator: Manifest list of %s:%s isn't fully scanned yet", repository.Location, tag)
+			return false
+		}
+		scan = listScan
+	} else {
+		_, sha, err := docker.ParseDigest(manifest.Digest)
+		if err != nil {
+			return false
+		}
+		for i := range scans {
+			if scans[i].Sha == sha {
+				scan = &scans[i]
+			}
+		}
+	}
+	if scan == nil {
+		log.Debugf("Annotator: Tag %s of %s was pushed again since it was scanned", tag, repository.Location)
+		return false
+	}
+
+	err = ga.setVariable(cred, repository.ProjectID, utils.GitLabVariableKey(repository.Path, tag), gitLabVariableValue(scan))
+	if err != nil {
+		log.Errorf("Annotator: Error in recording the scan of %s:%s: %v", repository.Location, tag, err)
+		return false
+	}
+	return true
+}
+
+// gitLabVariableValue returns the scan results as the json a CI variable
+// holds, with the same keys as the properties of artifactory
+func gitLabVariableValue(image *perceptorapi.ScannedImage) string {
+	value, _ := json.Marshal(map[string]string{
+		bdPolicy: fmt.Sprintf("%d", image.PolicyViolations),
+		bdVuln:   fmt.Sprintf("%d", image.Vulnerabilities),
+		bdSt:     image.OverallStatus,
+		bdComp:   image.ComponentsURL,
+	})
+	return string(value)
+}
+
+// setVariable creates the CI variable of a project with the key, or
+// updates it if its value changed
+func (ga *GitLabAnnotator) setVariable(cred *utils.RegistryAuth, projectID int64, key string, value string) error {
+	variablesURL := fmt.Sprintf("%s/api/v4/projects/%d/variables", cred.URL, projectID)
+	variableURL := fmt.Sprintf("%s/%s", variablesURL, url.PathEscape(key))
+
+	// GitLab answers for a missing variable with a message instead
+	variable := &utils.GitLabVariable{}
+	err := utils.GetResourceOfType(variableURL, nil, cred.Token, variable)
+	if err != nil {
+		return err
+	}
+	if variable.Key == key {
+		if variable.Value == value {
+			return nil
+		}
+		variable.Value = value
+		return utils.SendRequest(ga.client, http.MethodPut, variableURL, nil, cred.Token, variable, http.StatusOK)
+	}
+	return utils.SendRequest(ga.client, http.MethodPost, variablesURL, nil, cred.Token, &utils.GitLabVariable{Key: key, Value: value}, http.StatusCreated)
+}
+
+// removeVariables removes the CI variables of a project that hold the BD
+// results of tags that were deleted since.  keys are the keys of the tags
+// the project has
+func (ga *GitLabAnnotator) removeVariables(cred *utils.RegistryAuth, projectID int64, keys map[string]bool) {
+	variables, err := utils.GetGitLabVariables(cred, projectID)
+	if err != nil {
+		log.Errorf("Annotator: Error in getting CI variables of gitlab project %d: %e", projectID, err)
+		return
+	}
+	for _, variable := range variables {
+		if !utils.IsGitLabVariableKey(variable.Key) || keys[variable.Key] {
+			continue
+		}
+		variableURL := fmt.Sprintf("%s/api/v4/projects/%d/variables/%s", cred.URL, projectID, url.PathEscape(variable.Key))
+		err = utils.SendRequest(ga.client, http.MethodDelete, variableURL, nil, cred.Token, nil, http.StatusNoContent)
+		if err != nil {
+			log.Errorf("Annotator: Error in removing CI variable %s of gitlab project %d: %v", variable.Key, projectID, err)
+			continue
+		}
+		log.Infof("Annotator: Removed CI variable %s of a deleted tag of gitlab project %d", variable.Key, projectID)
+	}
+}
@@ -0,0 +1,230 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package annotator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	"github.com/blackducksoftware/perceivers/pkg/utils"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+// fakeGitLab is a stand-in for the gitlab API of a project with a tagged
+// container repository.  It records the CI variables set in the project
+type fakeGitLab struct {
+	utils.FakeAPI
+	variables map[string]string
+	requests  []string
+}
+
+func newFakeGitLab() *fakeGitLab {
+	fg := &fakeGitLab{FakeAPI: utils.FakeAPI{Token: "token"}, variables: map[string]string{}}
+	variables := "/api/v4/projects/7/variables"
+	fg.Reply(http.MethodGet, "/api/v4/projects/7/registry/repositories/3/tags/1.0", utils.GitLabTag{Name: "1.0", Digest: "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"})
+	fg.Handle(http.MethodGet, variables+"/*", func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Path[len(variables)+1:]
+		value, ok := fg.variables[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "404 Variable Not Found"})
+			return
+		}
+		json.NewEncoder(w).Encode(utils.GitLabVariable{Key: key, Value: value})
+	})
+	fg.Handle(http.MethodPost, variables, func(w http.ResponseWriter, r *http.Request) {
+		variable := utils.GitLabVariable{}
+		json.NewDecoder(r.Body).Decode(&variable)
+		fg.variables[variable.Key] = variable.Value
+		fg.requests = append(fg.requests, r.Method)
+		w.WriteHeader(http.StatusCreated)
+	})
+	fg.Handle(http.MethodPut, variables+"/*", func(w http.ResponseWriter, r *http.Request) {
+		variable := utils.GitLabVariable{}
+		json.NewDecoder(r.Body).Decode(&variable)
+		fg.variables[variable.Key] = variable.Value
+		fg.requests = append(fg.requests, r.Method)
+	})
+	fg.Handle(http.MethodGet, variables, func(w http.ResponseWriter, r *http.Request) {
+		list := []utils.GitLabVariable{}
+		for key, value := range fg.variables {
+			list = append(list, utils.GitLabVariable{Key: key, Value: value})
+		}
+		json.NewEncoder(w).Encode(list)
+	})
+	fg.Handle(http.MethodDelete, variables+"/*", func(w http.ResponseWriter, r *http.Request) {
+		delete(fg.variables, r.URL.Path[len(variables)+1:])
+		fg.requests = append(fg.requests, r.Method)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return fg
+}
+
+func TestGitLabAnnotateTag(t *testing.T) {
+	fg := newFakeGitLab()
+	server := httptest.NewServer(fg)
+	defer server.Close()
+
+	// The container registry has the tag as a single image
+	containerRegistry := &utils.FakeAPI{}
+	containerRegistry.Handle(http.MethodHead, "/v2/team/app/manifests/*", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", registry.MediaTypeManifest)
+		w.Header().Set("Docker-Content-Digest", "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	})
+	registryServer := httptest.NewServer(containerRegistry)
+	defer registryServer.Close()
+	registryHost := strings.TrimPrefix(registryServer.URL, "http://")
+
+	annotator := NewGitLabAnnotator("http://perceptor", nil, registry.NewClient([]string{registryHost}, time.Second), nil)
+	cred := &utils.RegistryAuth{URL: server.URL, Token: "token"}
+	repository := &utils.GitLabRepository{ID: 3, ProjectID: 7, Path: "team/app", Location: registryHost + "/team/app"}
+	scan := perceptorapi.ScannedImage{Repository: repository.Location, Tag: "1.0", Sha: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", PolicyViolations: 1, Vulnerabilities: 3, OverallStatus: inViolation}
+	key := utils.GitLabVariableKey("team/app", "1.0")
+
+	testcases := []struct {
+		description string
+		scans       []perceptorapi.ScannedImage
+		variable    string
+		requests    []string
+		shouldPass  bool
+	}{
+		{
+			description: "variable is created",
+			scans:       []perceptorapi.ScannedImage{scan},
+			variable:    gitLabVariableValue(&scan),
+			requests:    []string{http.MethodPost},
+			shouldPass:  true,
+		},
+		{
+			description: "unchanged variable isn't updated",
+			scans:       []perceptorapi.ScannedImage{scan},
+			variable:    gitLabVariableValue(&scan),
+			requests:    nil,
+			shouldPass:  true,
+		},
+		{
+			description: "changed variable is updated",
+			scans:       []perceptorapi.ScannedImage{{Repository: scan.Repository, Tag: scan.Tag, Sha: scan.Sha, OverallStatus: notInViolation}},
+			variable:    gitLabVariableValue(&perceptorapi.ScannedImage{OverallStatus: notInViolation}),
+			requests:    []string{http.MethodPut},
+			shouldPass:  true,
+		},
+		{
+			description: "tag was pushed again since the scan",
+			scans:       []perceptorapi.ScannedImage{{Repository: scan.Repository, Tag: scan.Tag, Sha: "5678"}},
+			variable:    gitLabVariableValue(&perceptorapi.ScannedImage{OverallStatus: notInViolation}),
+			requests:    nil,
+			shouldPass:  false,
+		},
+	}
+
+	for _, tc := range testcases {
+		fg.requests = nil
+		result := annotator.annotateTag(cred, repository, "1.0", tc.scans)
+		if result != tc.shouldPass {
+			t.Errorf("[%s] expected %t, got %t", tc.description, tc.shouldPass, result)
+		}
+		if fg.variables[key] != tc.variable {
+			t.Errorf("[%s] expected variable %s, got %s", tc.description, tc.variable, fg.variables[key])
+		}
+		if len(fg.requests) != len(tc.requests) || (len(tc.requests) > 0 && fg.requests[0] != tc.requests[0]) {
+			t.Errorf("[%s] expected requests %v, got %v", tc.description, tc.requests, fg.requests)
+		}
+	}
+}
+
+func TestGitLabRemoveVariables(t *testing.T) {
+	fg := newFakeGitLab()
+	server := httptest.NewServer(fg)
+	defer server.Close()
+	annotator := NewGitLabAnnotator("http://perceptor", nil, nil, nil)
+	cred := &utils.RegistryAuth{URL: server.URL, Token: "token"}
+	current := utils.GitLabVariableKey("team/app", "1.0")
+	deleted := utils.GitLabVariableKey("team/app", "0.9")
+
+	fg.variables = map[string]string{current: "{}", deleted: "{}", "BLACKDUCK_URL": "https://blackduck.example.com"}
+	annotator.removeVariables(cred, 7, map[string]bool{current: true})
+
+	expected := map[string]string{current: "{}", "BLACKDUCK_URL": "https://blackduck.example.com"}
+	if !reflect.DeepEqual(fg.variables, expected) {
+		t.Errorf("expected variables %v, got %v", expected, fg.variables)
+	}
+	if !reflect.DeepEqual(fg.requests, []string{http.MethodDelete}) {
+		t.Errorf("expected one DELETE, got %v", fg.requests)
+	}
+}
+
+func TestGitLabMatchedTags(t *testing.T) {
+	scan := perceptorapi.ScannedImage{Repository: "registry.gitlab.com/team/app", Tag: "1.0", Sha: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"}
+	aliases := map[string]string{"mirror.example.com": "registry.gitlab.com"}
+
+	testcases := []struct {
+		description string
+		mode        string
+		location    string
+		found       bool
+	}{
+		{
+			description: "exact name",
+			location:    "registry.gitlab.com/team/app",
+			found:       true,
+		},
+		{
+			description: "mirror with exact matching",
+			location:    "mirror.example.com/team/app",
+			found:       false,
+		},
+		{
+			description: "mirror with canonical matching",
+			mode:        "canonical",
+			location:    "mirror.example.com/team/app",
+			found:       true,
+		},
+		{
+			description: "other repository with digest matching",
+			mode:        "digest",
+			location:    "registry.example.com/team/copy",
+			found:       true,
+		},
+	}
+
+	for _, tc := range testcases {
+		matcher, err := docker.NewMatcher(docker.MatchConfig{Mode: tc.mode, RegistryAliases: aliases})
+		if err != nil {
+			t.Fatalf("[%s] unexpected error: %v", tc.description, err)
+		}
+		annotator := NewGitLabAnnotator("http://perceptor", nil, nil, matcher)
+		tags := annotator.matchedTags([]perceptorapi.ScannedImage{scan})
+		_, found := tags[scannedTag{repository: annotator.matchName(tc.location), tag: scan.Tag}]
+		if found != tc.found {
+			t.Errorf("[%s] expected found to be %t, got %t", tc.description, tc.found, found)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package annotator
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	"github.com/blackducksoftware/perceivers/pkg/utils"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// BlackDuck label names.  Harbor labels have no value, so the labels of an
+// artifact are named <name>=<value>, like blackduck.vulnerabilities=3
+const (
+	harborBDPrefix = "blackduck."
+	harborBDPolicy = "blackduck.policyviolations"
+	harborBDVuln   = "blackduck.vulnerabilities"
+	harborBDSt     = "blackduck.overallstatus"
+)
+
+// harborProjectScope is the scope of the labels of a project
+const harborProjectScope = "p"
+
+// harborStatusColors are the colors of the overall status labels
+var harborStatusColors = map[string]string{
+	inViolation:    "#C92100",
+	notInViolation: "#48960C",
+}
+
+// HarborAnnotator handles labelling harbor images with vulnerability and policy issues
+type HarborAnnotator struct {
+	client         *http.Client
+	scanResultsURL string
+	registryAuths  []*utils.RegistryAuth
+	matcher        *docker.Matcher
+
+	// labels caches the IDs of the BD labels by project and name
+	labels     map[string]int64
+	labelsLock sync.Mutex
+}
+
+// NewHarborAnnotator creates a new HarborAnnotator object
+func NewHarborAnnotator(perceptorURL string, registryAuths []*utils.RegistryAuth, matcher *docker.Matcher) *HarborAnnotator {
+	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	client := &http.Client{Transport: tr}
+	return &HarborAnnotator{
+		client:         client,
+		scanResultsURL: fmt.Sprintf("%s/%s", perceptorURL, perceptorapi.ScanResultsPath),
+		registryAuths:  registryAuths,
+		matcher:        matcher,
+		labels:         make(map[string]int64),
+	}
+}
+
+// Run starts a controller that will label images
+func (ha *HarborAnnotator) Run(interval time.Duration, stopCh <-chan struct{}) {
+	log.Infof("Annotator: starting harbor annotator")
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		time.Sleep(interval)
+
+		err := ha.annotate()
+		if err != nil {
+			log.Errorf("Annotator: failed to annotate harbor images: %v", err)
+		}
+	}
+}
+
+func (ha *HarborAnnotator) annotate() error {
+	// Get all the scan results from the Perceptor
+	log.Infof("Annotator: attempting to GET %s for harbor image annotation", ha.scanResultsURL)
+	scanResults, err := ha.getScanResults()
+	if err != nil {
+		metrics.RecordError("harbor_annotator", "error getting scan results")
+		return fmt.Errorf("error getting scan results: %v", err)
+	}
+
+	// Process the scan results and apply labels to images
+	log.Infof("Annotator: GET to %s succeeded, about to update labels on all harbor images", ha.scanResultsURL)
+	ha.addAnnotationsToImages(*scanResults)
+	return nil
+}
+
+func (ha *HarborAnnotator) getScanResults() (*perceptorapi.ScanResults, error) {
+	var results perceptorapi.ScanResults
+
+	bytes, err := communicator.GetPerceptorScanResults(ha.scanResultsURL)
+	if err != nil {
+		metrics.RecordError("harbor_annotator", "unable to get scan results")
+		return nil, fmt.Errorf("unable to get scan results: %v", err)
+	}
+
+	err = json.Unmarshal(bytes, &results)
+	if err != nil {
+		metrics.RecordError("harbor_annotator", "unable to Unmarshal ScanResults")
+		return nil, fmt.Errorf("unable to Unmarshal ScanResults from url %s: %v", ha.scanResultsURL, err)
+	}
+
+	return &results, nil
+}
+
+// This method tries to label all the Images found in BD by matching their SHAs
+func (ha *HarborAnnotator) addAnnotationsToImages(results perceptorapi.ScanResults) {
+	regs := 0
+
+	for _, registry := range ha.registryAuths {
+		cred, err := utils.PingHarborServer("https://"+registry.URL, registry.User, registry.Password)
+		if err != nil {
+			log.Debugf("Annotator: URL %s either not a valid Harbor registry or incorrect credentials: %e", registry.URL, err)
+			continue
+		}
+
+		regs = regs + 1
+		imgs := 0
+		for _, image := range results.Images {
+			if !ha.matcher.InRegistry(image.Repository, registry.URL) {
+				log.Debugf("Annotator: Registry URL %s does not correspond to scan repo %s", registry.URL, image.Repository)
+				continue
+			}
+
+			ref, err := docker.ParseReference(image.Repository)
+			if err != nil {
+				log.Errorf("Annotator: unable to parse scanned repo %s: %v", image.Repository, err)
+				continue
+			}
+			if ha.labelArtifact(cred, ref.Repository, fmt.Sprintf("sha256:%s", image.Sha), &image) {
+				imgs = imgs + 1
+			}
+		}
+
+		imgs = imgs + ha.labelManifestLists(registry, cred, results.Images)
+
+		log.Infof("Annotator: Total scanned images in Harbor with URL %s: %d", registry.URL, imgs)
+	}
+
+	log.Infof("Annotator: Total valid Harbor Registries: %d", regs)
+}
+
+// labelManifestLists labels the manifest lists of the scanned tags in the
+// registry with the aggregate of the scans of their platforms and returns
+// how many were labelled
+func (ha *HarborAnnotator) labelManifestLists(registry *utils.RegistryAuth, cred *utils.RegistryAuth, images []perceptorapi.ScannedImage) int {
+	lists := 0
+	for key, scans := range scannedTags(images) {
+		if !ha.matcher.InRegistry(key.repository, registry.URL) {
+			continue
+		}
+		ref, err := docker.ParseReference(key.repository)
+		if err != nil {
+			continue
+		}
+		artifactsURL, err := utils.HarborArtifactsURL(cred.URL, ref.Repository)
+		if err != nil {
+			continue
+		}
+
+		artifact := &utils.HarborArtifact{}
+		err = utils.GetResourceOfType(fmt.Sprintf("%s/%s", artifactsURL, key.tag), cred, "", artifact)
+		if err != nil || len(artifact.References) == 0 {
+			continue
+		}
+		listScan, ok := manifestListScan(artifact.Manifest(), scans)
+		if !ok {
+			log.Debugf("Annotator: Manifest list of %s:%s isn't fully scanned yet", key.repository, key.tag)
+			continue
+		}
+		if ha.labelArtifact(cred, ref.Repository, artifact.Digest, listScan) {
+			lists = lists + 1
+		}
+	}
+	return lists
+}
+
+// labelArtifact replaces the BD labels of the artifact with the digest in
+// a repository with the ones of the scan and returns whether the artifact
+// was found
+func (ha *HarborAnnotator) labelArtifact(cred *utils.RegistryAuth, repository string, digest string, image *perceptorapi.ScannedImage) bool {
+	artifactsURL, err := utils.HarborArtifactsURL(cred.URL, repository)
+	if err != nil {
+		log.Errorf("Annotator: %v", err)
+		return false
+	}
+	artifactURL := fmt.Sprintf("%s/%s", artifactsURL, digest)
+	artifact := &utils.HarborArtifact{}
+	err = utils.GetResourceOfType(fmt.Sprintf("%s?with_label=true", artifactURL), cred, "", artifact)
+	if err != nil || len(artifact.Digest) == 0 {
+		log.Debugf("Annotator: Artifact %s not found in harbor repository %s", digest, repository)
+		return false
+	}
+
+	wanted := map[string]bool{}
+	for _, name := range harborLabelNames(image) {
+		wanted[name] = true
+	}
+
+	for _, label := range artifact.Labels {
+		if !strings.HasPrefix(label.Name, harborBDPrefix) {
+			continue
+		}
+		if wanted[label.Name] {
+			delete(wanted, label.Name)
+			continue
+		}
+		err = utils.SendRequest(ha.client, http.MethodDelete, fmt.Sprintf("%s/labels/%d", artifactURL, label.ID), cred, "", nil, http.StatusOK)
+		if err != nil {
+			log.Errorf("Annotator: Error in removing label %s from %s: %v", label.Name, artifactURL, err)
+		}
+	}
+
+	project := strings.SplitN(repository, "/", 2)[0]
+	for name := range wanted {
+		id, err := ha.labelID(cred, project, name)
+		if err != nil {
+			log.Errorf("Annotator: Error in getting label %s of project %s: %v", name, project, err)
+			continue
+		}
+		err = utils.SendRequest(ha.client, http.MethodPost, fmt.Sprintf("%s/labels", artifactURL), cred, "", &utils.HarborLabel{ID: id}, http.StatusOK)
+		if err != nil {
+			log.Errorf("Annotator: Error in adding label %s to %s: %v", name, artifactURL, err)
+			continue
+		}
+		log.Infof("Annotator: Successfully labelled %s:%s with SHA %s with %s!", image.Repository, image.Tag, image.Sha, name)
+	}
+	return true
+}
+
+// harborLabelNames returns the names of the BD labels of a scan
+func harborLabelNames(image *perceptorapi.ScannedImage) []string {
+	names := []string{
+		fmt.Sprintf("%s=%d", harborBDPolicy, image.PolicyViolations),
+		fmt.Sprintf("%s=%d", harborBDVuln, image.Vulnerabilities),
+	}
+	if len(image.OverallStatus) > 0 {
+		names = append(names, fmt.Sprintf("%s=%s", harborBDSt, image.OverallStatus))
+	}
+	return names
+}
+
+// labelID returns the ID of the label of a project with the name, which
+// is created if the project doesn't have it yet
+func (ha *HarborAnnotator) labelID(cred *utils.RegistryAuth, projectName string, name string) (int64, error) {
+	key := fmt.Sprintf("%s/%s/%s", cred.URL, projectName, name)
+	ha.labelsLock.Lock()
+	id, ok := ha.labels[key]
+	ha.labelsLock.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	project := &utils.HarborProject{}
+	err := utils.GetResourceOfType(fmt.Sprintf("%s/api/v2.0/projects/%s", cred.URL, projectName), cred, "", project)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err = ha.findLabel(cred, project.ProjectID, name)
+	if err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		status := strings.TrimPrefix(name, harborBDSt+"=")
+		label := &utils.HarborLabel{
+			Name:        name,
+			Description: "Black Duck scan result",
+			Color:       harborStatusColors[status],
+			Scope:       harborProjectScope,
+			ProjectID:   project.ProjectID,
+		}
+		err = utils.SendRequest(ha.client, http.MethodPost, fmt.Sprintf("%s/api/v2.0/labels", cred.URL), cred, "", label, http.StatusCreated)
+		if err != nil {
+			return 0, err
+		}
+		id, err = ha.findLabel(cred, project.ProjectID, name)
+		if err != nil {
+			return 0, err
+		}
+		if id == 0 {
+			return 0, fmt.Errorf("label %s wasn't created", name)
+		}
+	}
+
+	ha.labelsLock.Lock()
+	ha.labels[key] = id
+	ha.labelsLock.Unlock()
+	return id, nil
+}
+
+// findLabel returns the ID of the label of a project with the name, or 0
+// if there is none.  Harbor matches the name of labels loosely
+func (ha *HarborAnnotator) findLabel(cred *utils.RegistryAuth, projectID int64, name string) (int64, error) {
+	labels := []utils.HarborLabel{}
+	labelsURL := fmt.Sprintf("%s/api/v2.0/labels?scope=%s&project_id=%d&name=%s&page_size=%d", cred.URL, harborProjectScope, projectID, url.QueryEscape(name), utils.HarborPageSize)
+	err := utils.GetResourceOfType(labelsURL, cred, "", &labels)
+	if err != nil {
+		return 0, err
+	}
+	for _, label := range labels {
+		if label.Name == name {
+			return label.ID, nil
+		}
+	}
+	return 0, nil
+}
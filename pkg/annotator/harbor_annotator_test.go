@@ -0,0 +1,115 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package annotator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/blackducksoftware/perceivers/pkg/utils"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+// fakeHarbor is a stand-in for the harbor API of a project with one
+// artifact.  It records the labels removed from and added to the artifact
+type fakeHarbor struct {
+	utils.FakeAPI
+	labels  []utils.HarborLabel
+	removed []string
+	added   []string
+}
+
+func newFakeHarbor(labels []utils.HarborLabel) *fakeHarbor {
+	fh := &fakeHarbor{labels: labels}
+	artifact := "/api/v2.0/projects/team/repositories/apps%252Fweb/artifacts/sha256:1234"
+	fh.Reply(http.MethodGet, artifact, utils.HarborArtifact{
+		Type:   utils.HarborImageType,
+		Digest: "sha256:1234",
+		Labels: []utils.HarborLabel{
+			{ID: 1, Name: "blackduck.vulnerabilities=5"},
+			{ID: 2, Name: "blackduck.policyviolations=0"},
+			{ID: 9, Name: "production"},
+		},
+	})
+	fh.Handle(http.MethodDelete, artifact+"/labels/1", func(w http.ResponseWriter, r *http.Request) {
+		fh.removed = append(fh.removed, "1")
+	})
+	fh.Handle(http.MethodDelete, "*", func(w http.ResponseWriter, r *http.Request) {
+		fh.removed = append(fh.removed, r.URL.Path)
+	})
+	fh.Handle(http.MethodPost, artifact+"/labels", func(w http.ResponseWriter, r *http.Request) {
+		label := utils.HarborLabel{}
+		json.NewDecoder(r.Body).Decode(&label)
+		fh.added = append(fh.added, fmt.Sprintf("%d", label.ID))
+	})
+	fh.Reply(http.MethodGet, "/api/v2.0/projects/team", utils.HarborProject{ProjectID: 7, Name: "team"})
+	fh.Handle(http.MethodGet, "/api/v2.0/labels", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("project_id") != "7" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(fh.labels)
+	})
+	fh.Handle(http.MethodPost, "/api/v2.0/labels", func(w http.ResponseWriter, r *http.Request) {
+		label := utils.HarborLabel{}
+		json.NewDecoder(r.Body).Decode(&label)
+		label.ID = int64(100 + len(fh.labels))
+		fh.labels = append(fh.labels, label)
+		w.WriteHeader(http.StatusCreated)
+	})
+	return fh
+}
+
+func TestHarborLabelArtifact(t *testing.T) {
+	fh := newFakeHarbor([]utils.HarborLabel{{ID: 3, Name: "blackduck.vulnerabilities=3", Scope: harborProjectScope, ProjectID: 7}})
+	server := httptest.NewServer(fh)
+	defer server.Close()
+
+	annotator := NewHarborAnnotator("http://perceptor", nil, nil)
+	cred := &utils.RegistryAuth{URL: server.URL, User: "robot", Password: "secret"}
+	image := &perceptorapi.ScannedImage{Repository: "harbor/team/apps/web", Sha: "1234", PolicyViolations: 0, Vulnerabilities: 3, OverallStatus: notInViolation}
+
+	if !annotator.labelArtifact(cred, "team/apps/web", "sha256:1234", image) {
+		t.Fatalf("expected the artifact to be found")
+	}
+	sort.Strings(fh.added)
+	// The status label is created, the vulnerabilities one already exists
+	if expected := []string{"101", "3"}; !reflect.DeepEqual(fh.added, expected) {
+		t.Errorf("expected labels %v to be added, got %v", expected, fh.added)
+	}
+	if expected := []string{"1"}; !reflect.DeepEqual(fh.removed, expected) {
+		t.Errorf("expected labels %v to be removed, got %v", expected, fh.removed)
+	}
+	if len(fh.labels) != 2 || fh.labels[1].Name != "blackduck.overallstatus=NOT_IN_VIOLATION" || fh.labels[1].Color != harborStatusColors[notInViolation] {
+		t.Errorf("unexpected project labels %+v", fh.labels)
+	}
+
+	if annotator.labelArtifact(cred, "team/apps/web", "sha256:5678", image) {
+		t.Errorf("expected a missing artifact not to be labelled")
+	}
+}
@@ -50,14 +50,17 @@ type ImageAnnotator struct {
 	client         *imageclient.ImageV1Client
 	scanResultsURL string
 	h              annotations.ImageAnnotatorHandler
+	matcher        *docker.Matcher
 }
 
-// NewImageAnnotator creates a new ImageAnnotator object
-func NewImageAnnotator(ic *imageclient.ImageV1Client, perceptorURL string, handler annotations.ImageAnnotatorHandler) *ImageAnnotator {
+// NewImageAnnotator creates a new ImageAnnotator object.  The matcher
+// decides whether a scan result belongs to the image with its digest
+func NewImageAnnotator(ic *imageclient.ImageV1Client, perceptorURL string, handler annotations.ImageAnnotatorHandler, matcher *docker.Matcher) *ImageAnnotator {
 	return &ImageAnnotator{
 		client:         ic,
 		scanResultsURL: fmt.Sprintf("%s/%s", perceptorURL, perceptorapi.ScanResultsPath),
 		h:              handler,
+		matcher:        matcher,
 	}
 }
 
@@ -143,17 +146,21 @@ func (ia *ImageAnnotator) addAnnotationsToImages(results perceptorapi.ScanResult
 		}
 
 		// Verify the sha of the scanned image matches that of the image we retrieved
-		_, imageSha, err := docker.ParseImageIDString(osImage.DockerImageReference)
+		ref, err := docker.ParseImageID(osImage.DockerImageReference)
 		if err != nil {
 			metrics.RecordError("image_annotator", "unable to parse openshift imageID")
 			log.Errorf("unable to parse openshift imageID from image %s: %v", imageName, err)
 			continue
 		}
-		if imageSha != image.Sha {
+		if imageSha := ref.Digest; imageSha != image.Sha {
 			metrics.RecordError("image_annotator", "image sha doesn't match")
 			log.Errorf("image sha doesn't match for image %s.  Got %s, expected %s", imageName, image.Sha, imageSha)
 			continue
 		}
+		if !ia.matcher.Matches(ref, image.Repository, image.Sha) {
+			log.Debugf("image %s doesn't match scanned repository %s", osImage.DockerImageReference, image.Repository)
+			continue
+		}
 
 		imageAnnotations := annotations.NewImageAnnotationData(image.PolicyViolations, image.Vulnerabilities, image.OverallStatus, image.ComponentsURL, "", "")
 
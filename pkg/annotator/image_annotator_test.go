@@ -43,7 +43,7 @@ import (
 var scannedImages = []perceptorapi.ScannedImage{
 	{
 		Name:             "image1",
-		Sha:              "ASDJ4FSF3FSFK3SF450",
+		Sha:              "d5cb7b41d6fe7876ef6a4fa9adb6e94d485e16dca4548b4979fd00cfb1c7b9ca",
 		PolicyViolations: 100,
 		Vulnerabilities:  5,
 		OverallStatus:    "STATUS3",
@@ -51,7 +51,7 @@ var scannedImages = []perceptorapi.ScannedImage{
 	},
 	{
 		Name:             "image2",
-		Sha:              "HAFGW2392FJGNE3FFK04",
+		Sha:              "007b4fab5780e0306d9b23395606fd68f01930a20f778c4ef487c36f604350d9",
 		PolicyViolations: 5,
 		Vulnerabilities:  15,
 		OverallStatus:    "STATUS4",
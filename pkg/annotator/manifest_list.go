@@ -0,0 +1,99 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package annotator
+
+import (
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+// The overall statuses black duck reports for an image
+const (
+	notInViolation = "NOT_IN_VIOLATION"
+	inViolation    = "IN_VIOLATION"
+)
+
+// manifestListScan aggregates the scans of the platforms of a manifest
+// list into a scan of the list itself: the worst counts and status of any
+// platform.  The list is only scanned once every platform is, so it never
+// looks cleaner than a platform that is still being scanned
+func manifestListScan(manifest *registry.Manifest, images []perceptorapi.ScannedImage) (*perceptorapi.ScannedImage, bool) {
+	_, sha, err := docker.ParseDigest(manifest.Digest)
+	if err != nil || !manifest.IsList() || len(manifest.Platforms) == 0 {
+		return nil, false
+	}
+
+	scans := map[string]perceptorapi.ScannedImage{}
+	for _, image := range images {
+		scans[image.Sha] = image
+	}
+
+	var result *perceptorapi.ScannedImage
+	for _, pm := range manifest.Platforms {
+		_, platformSha, err := docker.ParseDigest(pm.Digest)
+		if err != nil {
+			return nil, false
+		}
+		scan, ok := scans[platformSha]
+		if !ok {
+			return nil, false
+		}
+		if result == nil {
+			result = &perceptorapi.ScannedImage{Repository: scan.Repository, Tag: scan.Tag, Sha: sha, OverallStatus: notInViolation}
+		}
+		if scan.PolicyViolations > result.PolicyViolations || len(result.ComponentsURL) == 0 {
+			result.ComponentsURL = scan.ComponentsURL
+		}
+		if scan.PolicyViolations > result.PolicyViolations {
+			result.PolicyViolations = scan.PolicyViolations
+		}
+		if scan.Vulnerabilities > result.Vulnerabilities {
+			result.Vulnerabilities = scan.Vulnerabilities
+		}
+		if len(scan.OverallStatus) > 0 && scan.OverallStatus != notInViolation {
+			result.OverallStatus = inViolation
+		}
+	}
+	return result, true
+}
+
+// scannedTag is a repository and tag images were pushed to a registry as
+type scannedTag struct {
+	repository string
+	tag        string
+}
+
+// scannedTags groups the scans by the repository and tag they were pushed
+// as, so the platforms of a manifest list end up together
+func scannedTags(images []perceptorapi.ScannedImage) map[scannedTag][]perceptorapi.ScannedImage {
+	tags := map[scannedTag][]perceptorapi.ScannedImage{}
+	for _, image := range images {
+		if len(image.Tag) == 0 {
+			continue
+		}
+		key := scannedTag{repository: image.Repository, tag: image.Tag}
+		tags[key] = append(tags[key], image)
+	}
+	return tags
+}
@@ -0,0 +1,85 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package annotator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+func TestManifestListScan(t *testing.T) {
+	indexSha := "736804b23dd305d189bb2514eaa74063a818297616c7291aea6b9d4c7e143be8"
+	amdSha := "0b1e3b8f8b8c3a86d1f6d8d3d43ad2c0a3a6ab8d3c5e3c5b7f5f3e8b5b0a9c1d"
+	armSha := "5f0b1e0c4a6fd4ea4c6d8e2e6c2b3a1a8c5d7f6e4b2a9c8d7e6f5a4b3c2d1e0f"
+	list := &registry.Manifest{
+		MediaType: registry.MediaTypeManifestList,
+		Digest:    "sha256:" + indexSha,
+		Platforms: []registry.PlatformManifest{
+			{Digest: "sha256:" + amdSha, Platform: registry.Platform{OS: "linux", Architecture: "amd64"}},
+			{Digest: "sha256:" + armSha, Platform: registry.Platform{OS: "linux", Architecture: "arm64"}},
+		},
+	}
+	amd := perceptorapi.ScannedImage{Repository: "quay.io/team/app", Tag: "1.0", Sha: amdSha, PolicyViolations: 0, Vulnerabilities: 7, OverallStatus: notInViolation, ComponentsURL: "amd"}
+	arm := perceptorapi.ScannedImage{Repository: "quay.io/team/app", Tag: "1.0", Sha: armSha, PolicyViolations: 2, Vulnerabilities: 3, OverallStatus: inViolation, ComponentsURL: "arm"}
+
+	testcases := []struct {
+		description string
+		manifest    *registry.Manifest
+		images      []perceptorapi.ScannedImage
+		expected    *perceptorapi.ScannedImage
+		shouldPass  bool
+	}{
+		{
+			description: "every platform scanned",
+			manifest:    list,
+			images:      []perceptorapi.ScannedImage{amd, arm},
+			expected:    &perceptorapi.ScannedImage{Repository: "quay.io/team/app", Tag: "1.0", Sha: indexSha, PolicyViolations: 2, Vulnerabilities: 7, OverallStatus: inViolation, ComponentsURL: "arm"},
+			shouldPass:  true,
+		},
+		{
+			description: "platform not scanned yet",
+			manifest:    list,
+			images:      []perceptorapi.ScannedImage{amd},
+			shouldPass:  false,
+		},
+		{
+			description: "single manifest",
+			manifest:    &registry.Manifest{MediaType: registry.MediaTypeManifest, Digest: "sha256:" + amdSha},
+			images:      []perceptorapi.ScannedImage{amd},
+			shouldPass:  false,
+		},
+	}
+
+	for _, tc := range testcases {
+		result, ok := manifestListScan(tc.manifest, tc.images)
+		if ok != tc.shouldPass {
+			t.Errorf("[%s] expected %t, got %t", tc.description, tc.shouldPass, ok)
+		}
+		if tc.shouldPass && !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("[%s] expected %+v, got %+v", tc.description, tc.expected, result)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package annotator
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	"github.com/blackducksoftware/perceivers/pkg/utils"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// nexusTagPrefix starts the names of the tags that hold the BD results of a
+// manifest, which are named by its sha
+const nexusTagPrefix = "blackduck-"
+
+// NexusAnnotator handles annotating nexus images with vulnerability and policy issues
+type NexusAnnotator struct {
+	client         *http.Client
+	scanResultsURL string
+	registryAuths  []*utils.RegistryAuth
+	matcher        *docker.Matcher
+}
+
+// NewNexusAnnotator creates a new NexusAnnotator object
+func NewNexusAnnotator(perceptorURL string, registryAuths []*utils.RegistryAuth, matcher *docker.Matcher) *NexusAnnotator {
+	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	client := &http.Client{Transport: tr}
+	return &NexusAnnotator{
+		client:         client,
+		scanResultsURL: fmt.Sprintf("%s/%s", perceptorURL, perceptorapi.ScanResultsPath),
+		registryAuths:  registryAuths,
+		matcher:        matcher,
+	}
+}
+
+// Run starts a controller that will annotate images
+func (na *NexusAnnotator) Run(interval time.Duration, stopCh <-chan struct{}) {
+	log.Infof("Annotator: starting nexus annotator")
+
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		time.Sleep(interval)
+
+		err := na.annotate()
+		if err != nil {
+			log.Errorf("Annotator: failed to annotate images: %v", err)
+		}
+	}
+}
+
+func (na *NexusAnnotator) annotate() error {
+	// Get all the scan results from the Perceptor
+	log.Infof("Annotator: attempting to GET %s for nexus image annotation", na.scanResultsURL)
+	scanResults, err := na.getScanResults()
+	if err != nil {
+		metrics.RecordError("nexus_annotator", "error getting scan results")
+		return fmt.Errorf("Annotator: error getting scan results: %v", err)
+	}
+
+	// Process the scan results and apply tags to images
+	log.Infof("Annotator: GET to %s succeeded, about to update tags on all nexus images", na.scanResultsURL)
+	na.addAnnotationsToImages(*scanResults)
+	return nil
+}
+
+func (na *NexusAnnotator) getScanResults() (*perceptorapi.ScanResults, error) {
+	var results perceptorapi.ScanResults
+
+	bytes, err := communicator.GetPerceptorScanResults(na.scanResultsURL)
+	if err != nil {
+		metrics.RecordError("nexus_annotator", "unable to get scan results")
+		return nil, fmt.Errorf("Annotator: unable to get scan results: %v", err)
+	}
+
+	err = json.Unmarshal(bytes, &results)
+	if err != nil {
+		metrics.RecordError("nexus_annotator", "unable to Unmarshal ScanResults")
+		return nil, fmt.Errorf("Annotator: unable to Unmarshal ScanResults from url %s: %v", na.scanResultsURL, err)
+	}
+
+	return &results, nil
+}
+
+func (na *NexusAnnotator) addAnnotationsToImages(results perceptorapi.ScanResults) {
+	regs := 0
+
+	for _, registry := range na.registryAuths {
+
+		cred, err := utils.PingNexusServer("https://"+registry.URL, registry.User, registry.Password)
+		if err != nil {
+			log.Debugf("Annotator: URL %s either not a valid Nexus repository or incorrect credentials: %e", registry.URL, err)
+			continue
+		}
+		regs = regs + 1
+		imgs := 0
+		for _, image := range results.Images {
+
+			// Nexus is searched by checksum, so when matching by digest the
+			// image is annotated wherever it was pushed
+			if na.matcher.Mode() != docker.MatchDigest && !na.matcher.InRegistry(image.Repository, registry.URL) {
+				log.Debugf("Annotator: Registry URL %s does not correspond to scan repo %s", registry.URL, image.Repository)
+				continue
+			}
+
+			if na.AnnotateImage(&image, cred) {
+				imgs = imgs + 1
+			}
+		}
+
+		imgs = imgs + na.annotateManifestLists(registry, cred, results.Images)
+
+		log.Infof("Annotator: Total scanned images found for Nexus repo %s: %d", registry.URL, imgs)
+	}
+
+	log.Infof("Annotator: Total valid Nexus Registries: %d", regs)
+}
+
+// annotateManifestLists annotates the manifest lists of the scanned tags in
+// the registry with the aggregate of the scans of their platforms and
+// returns how many were annotated
+func (na *NexusAnnotator) annotateManifestLists(registry *utils.RegistryAuth, cred *utils.RegistryAuth, images []perceptorapi.ScannedImage) int {
+	lists := 0
+	for key, scans := range scannedTags(images) {
+		// Images are sent to perceptor as <registry>/<repository>/<name>
+		path := strings.TrimPrefix(key.repository, registry.URL+"/")
+		if path == key.repository || !strings.Contains(path, "/") {
+			continue
+		}
+		parts := strings.SplitN(path, "/", 2)
+
+		manifest, err := utils.GetManifest(utils.NexusManifestURL(cred.URL, parts[0], parts[1], key.tag), cred, "")
+		if err != nil {
+			log.Errorf("Annotator: Error in getting manifest of %s:%s: %e", key.repository, key.tag, err)
+			continue
+		}
+		listScan, ok := manifestListScan(manifest, scans)
+		if !ok {
+			continue
+		}
+		if na.AnnotateImage(listScan, cred) {
+			lists = lists + 1
+		}
+	}
+	return lists
+}
+
+// AnnotateImage sets the BD results as the attributes of the tag of the
+// sha of the image and associates the tag with the components whose
+// manifest has the sha.  It returns whether there were any
+func (na *NexusAnnotator) AnnotateImage(im *perceptorapi.ScannedImage, cred *utils.RegistryAuth) bool {
+	tag := &utils.NexusTag{
+		Name: nexusTagPrefix + im.Sha,
+		Attributes: map[string]string{
+			bdSt:     im.OverallStatus,
+			bdVuln:   fmt.Sprintf("%d", im.Vulnerabilities),
+			bdPolicy: fmt.Sprintf("%d", im.PolicyViolations),
+			bdComp:   im.ComponentsURL,
+		},
+	}
+
+	err := na.upsertTag(tag, cred)
+	if err != nil {
+		log.Errorf("Annotator: Error in updating tag %s: %v", tag.Name, err)
+		return false
+	}
+
+	url := fmt.Sprintf("%s/service/rest/v1/tags/associate/%s?sha256=%s", cred.URL, tag.Name, im.Sha)
+	err = utils.SendRequest(na.client, http.MethodPost, url, cred, "", nil, http.StatusOK)
+	if err != nil {
+		log.Debugf("Annotator: No nexus components of %s with SHA %s associated: %v", im.Repository, im.Sha, err)
+		return false
+	}
+
+	log.Infof("Annotator: Tags successfully added/updated for %s:%s", im.Repository, im.Tag)
+	return true
+}
+
+// upsertTag creates the tag, or updates its attributes if they changed
+func (na *NexusAnnotator) upsertTag(tag *utils.NexusTag, cred *utils.RegistryAuth) error {
+	tagURL := fmt.Sprintf("%s/service/rest/v1/tags/%s", cred.URL, tag.Name)
+	req, err := http.NewRequest(http.MethodGet, tagURL, nil)
+	if err != nil {
+		return fmt.Errorf("Error in creating get request %e", err)
+	}
+	req.SetBasicAuth(cred.User, cred.Password)
+	resp, err := na.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error in sending get request %e", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return utils.SendRequest(na.client, http.MethodPost, fmt.Sprintf("%s/service/rest/v1/tags", cred.URL), cred, "", tag, http.StatusOK)
+	case http.StatusOK:
+		existing := &utils.NexusTag{}
+		err = json.NewDecoder(resp.Body).Decode(existing)
+		if err == nil && reflect.DeepEqual(existing.Attributes, tag.Attributes) {
+			return nil
+		}
+		return utils.SendRequest(na.client, http.MethodPut, tagURL, cred, "", &utils.NexusTag{Attributes: tag.Attributes}, http.StatusOK)
+	default:
+		return fmt.Errorf("GET %s returned %d", tagURL, resp.StatusCode)
+	}
+}
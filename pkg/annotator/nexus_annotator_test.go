@@ -0,0 +1,113 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package annotator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/blackducksoftware/perceivers/pkg/utils"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+// fakeNexus is a stand-in for the tags API of nexus with components of
+// one manifest
+type fakeNexus struct {
+	utils.FakeAPI
+	tags       map[string]utils.NexusTag
+	saves      int
+	associated string
+}
+
+func newFakeNexus() *fakeNexus {
+	fn := &fakeNexus{tags: map[string]utils.NexusTag{}}
+	tags := "/service/rest/v1/tags"
+	fn.Handle(http.MethodGet, tags+"/*", func(w http.ResponseWriter, r *http.Request) {
+		tag, ok := fn.tags[r.URL.Path[len(tags)+1:]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(tag)
+	})
+	fn.Handle(http.MethodPost, tags, func(w http.ResponseWriter, r *http.Request) {
+		tag := utils.NexusTag{}
+		json.NewDecoder(r.Body).Decode(&tag)
+		fn.tags[tag.Name] = tag
+		fn.saves++
+	})
+	fn.Handle(http.MethodPut, tags+"/*", func(w http.ResponseWriter, r *http.Request) {
+		tag := utils.NexusTag{}
+		json.NewDecoder(r.Body).Decode(&tag)
+		tag.Name = r.URL.Path[len(tags)+1:]
+		fn.tags[tag.Name] = tag
+		fn.saves++
+	})
+	fn.Handle(http.MethodPost, tags+"/associate/blackduck-1234", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sha256") != "1234" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fn.associated = r.URL.Query().Get("sha256")
+	})
+	return fn
+}
+
+func TestNexusAnnotateImage(t *testing.T) {
+	fn := newFakeNexus()
+	server := httptest.NewServer(fn)
+	defer server.Close()
+
+	annotator := NewNexusAnnotator("http://perceptor", nil, nil)
+	cred := &utils.RegistryAuth{URL: server.URL, User: "user", Password: "secret"}
+	image := &perceptorapi.ScannedImage{Repository: "nexus/docker-hosted/app", Sha: "1234", PolicyViolations: 1, Vulnerabilities: 3, OverallStatus: inViolation, ComponentsURL: "http://hub/components"}
+
+	for i := 0; i < 2; i++ {
+		if !annotator.AnnotateImage(image, cred) {
+			t.Fatalf("expected the image to be annotated")
+		}
+	}
+	// The tag is only saved again when its attributes change
+	if fn.saves != 1 {
+		t.Errorf("expected the tag to be saved once, got %d", fn.saves)
+	}
+	if fn.associated != "1234" {
+		t.Errorf("expected the tag to be associated by sha, got %s", fn.associated)
+	}
+	tag := fn.tags["blackduck-1234"]
+	if tag.Attributes[bdVuln] != "3" || tag.Attributes[bdSt] != inViolation || tag.Attributes[bdComp] != image.ComponentsURL {
+		t.Errorf("unexpected tag attributes %v", tag.Attributes)
+	}
+
+	image.Vulnerabilities = 4
+	if !annotator.AnnotateImage(image, cred) || fn.saves != 2 || fn.tags["blackduck-1234"].Attributes[bdVuln] != "4" {
+		t.Errorf("expected the tag to be updated, got %v", fn.tags["blackduck-1234"])
+	}
+
+	image.Sha = "5678"
+	if annotator.AnnotateImage(image, cred) {
+		t.Errorf("expected an image without components not to be annotated")
+	}
+}
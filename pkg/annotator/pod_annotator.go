@@ -27,9 +27,12 @@ import (
 	"time"
 
 	"github.com/blackducksoftware/perceivers/pkg/annotations"
+	"github.com/blackducksoftware/perceivers/pkg/cluster"
 	"github.com/blackducksoftware/perceivers/pkg/communicator"
 	"github.com/blackducksoftware/perceivers/pkg/docker"
 	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	"github.com/blackducksoftware/perceivers/pkg/sharding"
 	"github.com/blackducksoftware/perceivers/pkg/utils"
 
 	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
@@ -48,14 +51,28 @@ type PodAnnotator struct {
 	coreV1         corev1.CoreV1Interface
 	scanResultsURL string
 	h              annotations.PodAnnotatorHandler
+	shard          *sharding.Shard
+	cluster        string
+	matcher        *docker.Matcher
+	resolver       *registry.Resolver
 }
 
-// NewPodAnnotator creates a new PodAnnotator object
-func NewPodAnnotator(pl corev1.CoreV1Interface, perceptorURL string, handler annotations.PodAnnotatorHandler) *PodAnnotator {
+// NewPodAnnotator creates a new PodAnnotator object.  If shard isn't nil
+// only pods in the namespaces owned by the shard are annotated.  Only the
+// scan results of pods whose namespace is qualified with clusterName are
+// applied, so every cluster's annotations are written back to that cluster.
+// The matcher decides which scan results belong to the images of a pod.  If
+// resolver isn't nil images that are manifest lists are matched by the
+// manifest for the platform of the pod's node, the way they are sent
+func NewPodAnnotator(pl corev1.CoreV1Interface, perceptorURL string, handler annotations.PodAnnotatorHandler, shard *sharding.Shard, clusterName string, matcher *docker.Matcher, resolver *registry.Resolver) *PodAnnotator {
 	return &PodAnnotator{
 		coreV1:         pl,
 		scanResultsURL: fmt.Sprintf("%s/%s", perceptorURL, perceptorapi.ScanResultsPath),
 		h:              handler,
+		shard:          shard,
+		cluster:        clusterName,
+		matcher:        matcher,
+		resolver:       resolver,
 	}
 }
 
@@ -114,9 +131,13 @@ func (pa *PodAnnotator) getScanResults() (*perceptorapi.ScanResults, error) {
 
 func (pa *PodAnnotator) addAnnotationsToPods(results perceptorapi.ScanResults) {
 	for _, pod := range results.Pods {
+		podCluster, namespace := cluster.SplitNamespace(pod.Namespace)
+		if podCluster != pa.cluster || !pa.shard.Owns(pod.Namespace) {
+			continue
+		}
 		podName := fmt.Sprintf("%s:%s", pod.Namespace, pod.Name)
 		getPodStart := time.Now()
-		kubePod, err := pa.coreV1.Pods(pod.Namespace).Get(pod.Name, metav1.GetOptions{})
+		kubePod, err := pa.coreV1.Pods(namespace).Get(pod.Name, metav1.GetOptions{})
 		metrics.RecordDuration("get pod", time.Now().Sub(getPodStart))
 		if err != nil {
 			metrics.RecordError("pod_annotator", "unable to get pod")
@@ -130,7 +151,7 @@ func (pa *PodAnnotator) addAnnotationsToPods(results perceptorapi.ScanResults) {
 		if pa.addPodAnnotations(kubePod, podAnnotations, results.Images) ||
 			pa.addPodLabels(kubePod, podAnnotations, results.Images) {
 			updatePodStart := time.Now()
-			_, err = pa.coreV1.Pods(pod.Namespace).Update(kubePod)
+			_, err = pa.coreV1.Pods(namespace).Update(kubePod)
 			metrics.RecordDuration("update pod", time.Now().Sub(updatePodStart))
 			if err != nil {
 				metrics.RecordError("pod_annotator", "unable to update annotations/labels for pod")
@@ -221,24 +242,28 @@ func (pa *PodAnnotator) getPodContainerMap(pod *v1.Pod, scannedImages []percepto
 	containerMap := make(map[string]string)
 
 	for cnt, container := range pod.Status.ContainerStatuses {
-		name, sha, err := docker.ParseImageIDString(container.ImageID)
+		ref, err := docker.ParseContainerImage(container.ImageID, container.Image)
 		if err != nil {
 			metrics.RecordError("pod_annotator", "unable to parse kubernetes imageID")
 			log.Errorf("unable to parse kubernetes imageID string %s from pod %s/%s: %v", container.ImageID, pod.Namespace, pod.Name, err)
 			continue
 		}
-		imageScanResults := pa.findImageAnnotations(name, sha, scannedImages)
+		platformRef, err := pa.resolver.PlatformImage(pod, ref)
+		if err != nil {
+			log.Warningf("unable to find the platform image of %s from pod %s/%s: %v", ref, pod.Namespace, pod.Name, err)
+		}
+		imageScanResults := pa.findImageAnnotations(platformRef, scannedImages)
 		if imageScanResults != nil {
 			imageAnnotations := pa.createImageAnnotationsFromImageScanResults(imageScanResults, hubVersion, scVersion)
-			containerMap = utils.MapMerge(containerMap, mapGenerator(imageAnnotations, name, cnt))
+			containerMap = utils.MapMerge(containerMap, mapGenerator(imageAnnotations, ref.Name(), cnt))
 		}
 	}
 	return containerMap
 }
 
-func (pa *PodAnnotator) findImageAnnotations(imageName string, imageSha string, imageList []perceptorapi.ScannedImage) *perceptorapi.ScannedImage {
+func (pa *PodAnnotator) findImageAnnotations(ref *docker.Reference, imageList []perceptorapi.ScannedImage) *perceptorapi.ScannedImage {
 	for _, image := range imageList {
-		if image.Repository == imageName && image.Sha == imageSha {
+		if pa.matcher.Matches(ref, image.Repository, image.Sha) {
 			return &image
 		}
 	}
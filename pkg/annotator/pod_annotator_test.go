@@ -30,6 +30,7 @@ import (
 	"testing"
 
 	"github.com/blackducksoftware/perceivers/pkg/annotations"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
 	"github.com/blackducksoftware/perceivers/pkg/utils"
 
 	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
@@ -42,7 +43,7 @@ import (
 var scannedImages = []perceptorapi.ScannedImage{
 	{
 		Repository:       "image1",
-		Sha:              "ASDJ4FSF3FSFK3SF450",
+		Sha:              "d5cb7b41d6fe7876ef6a4fa9adb6e94d485e16dca4548b4979fd00cfb1c7b9ca",
 		PolicyViolations: 100,
 		Vulnerabilities:  5,
 		OverallStatus:    "STATUS3",
@@ -50,7 +51,7 @@ var scannedImages = []perceptorapi.ScannedImage{
 	},
 	{
 		Repository:       "this.name.includes.registry.name/imagenameis/short/butthefulllengthwithregistryistoolong",
-		Sha:              "HAFGW2392FJGNE3FFK04",
+		Sha:              "007b4fab5780e0306d9b23395606fd68f01930a20f778c4ef487c36f604350d9",
 		PolicyViolations: 5,
 		Vulnerabilities:  15,
 		OverallStatus:    "STATUS4",
@@ -58,7 +59,7 @@ var scannedImages = []perceptorapi.ScannedImage{
 	},
 	{
 		Repository:       "this.name.includes.registry.name/and/many/directories/and/is/way/too/long/aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
-		Sha:              "HAFGW2392FJGNE3FFK04",
+		Sha:              "007b4fab5780e0306d9b23395606fd68f01930a20f778c4ef487c36f604350d9",
 		PolicyViolations: 0,
 		Vulnerabilities:  0,
 		OverallStatus:    "STATUS5",
@@ -66,15 +67,15 @@ var scannedImages = []perceptorapi.ScannedImage{
 	},
 	{
 		Repository:       "this/name/and/many/directories/and/is/way/too/long/tofitin/the63character/limitofalabel",
-		Sha:              "HAFGW2392FJGNE3FFK04",
+		Sha:              "007b4fab5780e0306d9b23395606fd68f01930a20f778c4ef487c36f604350d9",
 		PolicyViolations: 1,
 		Vulnerabilities:  40,
 		OverallStatus:    "STATUS6",
 		ComponentsURL:    "http://thisurlisreallylongtoo.com/andwouldfailthe63characterlimit/butshouldntbeneeded",
 	},
 	{
-		Repository:       "registry:port/imagenameis/short/butthefulllengthwithregistryistoolong",
-		Sha:              "HAFGW2392FJGNE3FFK04",
+		Repository:       "registry:5000/imagenameis/short/butthefulllengthwithregistryistoolong",
+		Sha:              "007b4fab5780e0306d9b23395606fd68f01930a20f778c4ef487c36f604350d9",
 		PolicyViolations: 10,
 		Vulnerabilities:  1,
 		OverallStatus:    "STATUS7",
@@ -298,7 +299,7 @@ func TestAddPodAnnotations(t *testing.T) {
 		},
 		{
 			description:         "pod with image that hasn't been scanned",
-			pod:                 makePodWithImage(0, "imageName", "234F8sdgj235jsdf923"),
+			pod:                 makePodWithImage(0, "imagename", "fc8887955fc1c5b342837dc2949ef76457e539006ed449608a41fdcee6aee7d6"),
 			position:            0,
 			existingAnnotations: make(map[string]string),
 			expectedAnnotations: podAnnotationSet(0),
@@ -306,7 +307,7 @@ func TestAddPodAnnotations(t *testing.T) {
 		},
 		{
 			description:         "pod with image that hasn't been scanned, existing pod annotations",
-			pod:                 makePodWithImage(0, "imageName", "234F8sdgj235jsdf923"),
+			pod:                 makePodWithImage(0, "imagename", "fc8887955fc1c5b342837dc2949ef76457e539006ed449608a41fdcee6aee7d6"),
 			position:            0,
 			existingAnnotations: podAnnotationSet(0),
 			expectedAnnotations: podAnnotationSet(0),
@@ -417,7 +418,7 @@ func TestAddPodLabels(t *testing.T) {
 		},
 		{
 			description:    "pod with no scanned images",
-			pod:            makePodWithImage(0, "imageName", "234F8sdgj235jsdf923"),
+			pod:            makePodWithImage(0, "imagename", "fc8887955fc1c5b342837dc2949ef76457e539006ed449608a41fdcee6aee7d6"),
 			position:       0,
 			existingLabels: make(map[string]string),
 			expectedLabels: podLabelSet(0),
@@ -425,7 +426,7 @@ func TestAddPodLabels(t *testing.T) {
 		},
 		{
 			description:    "pod with no scanned images, existing pod labels",
-			pod:            makePodWithImage(0, "imageName", "234F8sdgj235jsdf923"),
+			pod:            makePodWithImage(0, "imagename", "fc8887955fc1c5b342837dc2949ef76457e539006ed449608a41fdcee6aee7d6"),
 			position:       0,
 			existingLabels: podLabelSet(0),
 			expectedLabels: podLabelSet(0),
@@ -502,12 +503,12 @@ func TestGetPodContainerMap(t *testing.T) {
 	}
 	imageWithoutPrefix := v1.ContainerStatus{
 		Name:    "notscanned",
-		ImageID: "repository.com/notscanned@sha256:34545ngelkj235knegr",
+		ImageID: "repository.com/notscanned@sha256:c78d1262a31e303cc510aa2dacc4ad6a0d3f5e8f32cb82d9dbe6367cc37af4b2",
 	}
 
 	imageWithPrefix := v1.ContainerStatus{
 		Name:    "notscanned",
-		ImageID: "docker-pullable://repository.com/notscanned@sha256:j2345msdf9235nb834",
+		ImageID: "docker-pullable://repository.com/notscanned@sha256:31685b107e3f1c594fd326fc5731755db1bf6e9bd5638e6cf5955a7ee0d1f6a1",
 	}
 
 	testcases := []struct {
@@ -552,12 +553,13 @@ func TestFindImageAnnotations(t *testing.T) {
 		description string
 		name        string
 		sha         string
+		mode        string
 		result      *perceptorapi.ScannedImage
 	}{
 		{
 			description: "finds name and sha in scanned images",
 			name:        "image1",
-			sha:         "ASDJ4FSF3FSFK3SF450",
+			sha:         "d5cb7b41d6fe7876ef6a4fa9adb6e94d485e16dca4548b4979fd00cfb1c7b9ca",
 			result:      &scannedImages[0],
 		},
 		{
@@ -569,7 +571,7 @@ func TestFindImageAnnotations(t *testing.T) {
 		{
 			description: "correct sha, wrong name",
 			name:        "notfound",
-			sha:         "ASDJ4FSF3FSFK3SF450",
+			sha:         "d5cb7b41d6fe7876ef6a4fa9adb6e94d485e16dca4548b4979fd00cfb1c7b9ca",
 			result:      nil,
 		},
 		{
@@ -578,10 +580,33 @@ func TestFindImageAnnotations(t *testing.T) {
 			sha:         "asj23gadgk234",
 			result:      nil,
 		},
+		{
+			description: "canonical name of a scanned image",
+			name:        "docker.io/library/image1",
+			sha:         "d5cb7b41d6fe7876ef6a4fa9adb6e94d485e16dca4548b4979fd00cfb1c7b9ca",
+			result:      nil,
+		},
+		{
+			description: "canonical name of a scanned image with canonical matching",
+			name:        "docker.io/library/image1",
+			sha:         "d5cb7b41d6fe7876ef6a4fa9adb6e94d485e16dca4548b4979fd00cfb1c7b9ca",
+			mode:        "canonical",
+			result:      &scannedImages[0],
+		},
 	}
 
 	for _, tc := range testcases {
-		result := createPA().findImageAnnotations(tc.name, tc.sha, scannedImages)
+		ref, err := docker.ParseReference(tc.name)
+		if err != nil {
+			t.Fatalf("[%s] unable to parse %s: %v", tc.description, tc.name, err)
+		}
+		ref.DigestAlgorithm, ref.Digest = "sha256", tc.sha
+		pa := createPA()
+		pa.matcher, err = docker.NewMatcher(docker.MatchConfig{Mode: tc.mode})
+		if err != nil {
+			t.Fatalf("[%s] unexpected error: %v", tc.description, err)
+		}
+		result := pa.findImageAnnotations(ref, scannedImages)
 		if result != tc.result && !reflect.DeepEqual(*result, *tc.result) {
 			t.Errorf("[%s] expected %v got %v: name %s, sha %s", tc.description, tc.result, result, tc.name, tc.sha)
 		}
@@ -27,10 +27,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
 	"github.com/blackducksoftware/perceivers/pkg/metrics"
 	"github.com/blackducksoftware/perceivers/pkg/utils"
 
@@ -48,26 +48,6 @@ type QuayRepo struct {
 	UpdatedTags []string `json:"updated_tags"`
 }
 
-// QuayTagDigest contains Digest for a particular Quay image
-type QuayTagDigest struct {
-	HasAdditional bool      `json:"has_additional"`
-	Page          int       `json:"page"`
-	Tags          []QuayTag `json:"tags"`
-}
-
-// QuayTag contains individual tag info for an image version
-type QuayTag struct {
-	Name           string `json:"name"`
-	Reversion      bool   `json:"reversion"`
-	StartTs        int    `json:"start_ts"`
-	ImageID        string `json:"image_id"`
-	LastModified   string `json:"last_modified"`
-	ManifestDigest string `json:"manifest_digest"`
-	DockerImageID  string `json:"docker_image_id"`
-	IsManifestList bool   `json:"is_manifest_list"`
-	Size           int    `json:"size"`
-}
-
 // QuayLabels contains a list of returned Labels on an image
 type QuayLabels struct {
 	Labels []QuayLabel `json:"labels"`
@@ -103,16 +83,24 @@ type QuayAnnotator struct {
 	client         *http.Client
 	scanResultsURL string
 	registryAuths  []*utils.RegistryAuth
+	matcher        *docker.Matcher
+	paging         *utils.QuayPaging
+	summaries      QuaySummaryConfig
+	enforcement    *QuayEnforcementConfig
 }
 
 // NewQuayAnnotator creates a new QuayAnnotator object
-func NewQuayAnnotator(perceptorURL string, registryAuths []*utils.RegistryAuth) *QuayAnnotator {
+func NewQuayAnnotator(perceptorURL string, registryAuths []*utils.RegistryAuth, matcher *docker.Matcher, paging *utils.QuayPaging, summaries QuaySummaryConfig, enforcement *QuayEnforcementConfig) *QuayAnnotator {
 	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
 	client := &http.Client{Transport: tr}
 	return &QuayAnnotator{
 		client:         client,
 		scanResultsURL: fmt.Sprintf("%s/%s", perceptorURL, perceptorapi.ScanResultsPath),
 		registryAuths:  registryAuths,
+		matcher:        matcher,
+		paging:         paging,
+		summaries:      summaries,
+		enforcement:    enforcement,
 	}
 }
 
@@ -177,7 +165,7 @@ func (qa *QuayAnnotator) addAnnotationsToImages(results perceptorapi.ScanResults
 	imgs := 0
 
 	for _, registry := range qa.registryAuths {
-		auth, err := qa.PingQuayServer("https://"+registry.URL, registry.User, registry.Password, registry.Token)
+		auth, err := utils.PingQuayServer("https://"+registry.URL, registry.User, registry.Password, registry.Token)
 
 		if err != nil {
 			log.Debugf("Annotator: URL %s either not a valid quay repository or incorrect token: %e", registry.URL, err)
@@ -187,50 +175,28 @@ func (qa *QuayAnnotator) addAnnotationsToImages(results perceptorapi.ScanResults
 		regs = regs + 1
 		for _, image := range results.Images {
 
-			// The base URL may contain something in their instance/registry, splitting has no loss
-			if !strings.Contains(image.Repository, strings.Split(registry.URL, "/")[0]) {
+			if !qa.matcher.InRegistry(image.Repository, registry.URL) {
 				log.Debugf("Annotator: Registry URL %s does not correspond to scan repo %s", registry.URL, image.Repository)
 				continue
 			}
 
 			log.Infof("Scan %s corresponds to %s", image.Repository, registry.URL)
-			repoSlice := strings.Split(image.Repository, "/")[1:]
-			repo := strings.Join(repoSlice, "/")
-			labelList := &QuayLabels{}
-			// Look for SHA
-			url := fmt.Sprintf("%s/api/v1/repository/%s/manifest/%s/labels", auth.URL, repo, fmt.Sprintf("sha256:%s", image.Sha))
-			log.Infof("Getting labels from: %s", url)
-			err = utils.GetResourceOfType(url, nil, auth.Password, labelList)
+			ref, err := docker.ParseReference(image.Repository)
 			if err != nil {
-				log.Errorf("Error in getting labels for repo %s: %e", repo, err)
+				log.Errorf("Annotator: unable to parse scanned repo %s: %v", image.Repository, err)
 				continue
 			}
-
-			imgs = imgs + 1
-
-			// Create a map of BD tags and retrieved values
-			nt := make(map[string]string)
-			nt[quayBDComURL] = image.ComponentsURL
-			nt[quayBDPolicy] = fmt.Sprintf("%d", image.PolicyViolations)
-			nt[quayBDSt] = image.OverallStatus
-			nt[quayBDVuln] = fmt.Sprintf("%d", image.Vulnerabilities)
-
-			// Create a map of Quay tags and retrieved values
-			ot := make(map[string]string)
-			for _, label := range labelList.Labels {
-				ot[label.Key] = label.Value
-			}
-
-			// Merge them with updated BD values
-			tags := utils.MapMerge(ot, nt)
-			for key, value := range tags {
-				// Don't need to touch other tags apart form BD ones
-				if _, ok := nt[key]; ok {
-					imageInfo := fmt.Sprintf("%s:%s with SHA %s", image.Repository, image.Tag, image.Sha)
-					qa.UpdateAnnotation(url, key, value, imageInfo, registry.Token)
-				}
+			if qa.labelImage(auth, ref.Repository, &image, registry.Token) {
+				imgs = imgs + 1
 			}
+		}
 
+		imgs = imgs + qa.labelManifestLists(auth, registry, results.Images)
+		if qa.summaries.Repositories || qa.summaries.Namespaces {
+			qa.summarize(auth, registry, results.Images)
+		}
+		if qa.enforcement.enabled() {
+			qa.enforce(auth, registry, results.Images)
 		}
 
 		log.Infof("Total scanned images in Quay with URL %s: %d", registry.URL, imgs)
@@ -239,68 +205,116 @@ func (qa *QuayAnnotator) addAnnotationsToImages(results perceptorapi.ScanResults
 	log.Infof("Total valid Quay Registries: %d", regs)
 }
 
-// UpdateAnnotation takes the specific Quay URL and applies the properties/annotations given by BD
-func (qa *QuayAnnotator) UpdateAnnotation(url string, labelKey string, newValue string, imageInfo string, quayToken string) {
-
-	filterURL := fmt.Sprintf("%s?filter=%s", url, labelKey)
+// labelImage sets the BD labels of the manifest of a scanned image in a
+// repository and returns whether the manifest was found
+func (qa *QuayAnnotator) labelImage(auth *utils.RegistryAuth, repo string, image *perceptorapi.ScannedImage, quayToken string) bool {
 	labelList := &QuayLabels{}
-	err := utils.GetResourceOfType(filterURL, nil, quayToken, labelList)
+	// Look for SHA
+	url := fmt.Sprintf("%s/api/v1/repository/%s/manifest/%s/labels", auth.URL, repo, fmt.Sprintf("sha256:%s", image.Sha))
+	log.Infof("Getting labels from: %s", url)
+	err := utils.GetResourceOfType(url, nil, auth.Password, labelList)
 	if err != nil {
-		log.Errorf("Error in getting labels at URL %s for update: %e", url, err)
-		return
+		log.Errorf("Error in getting labels for repo %s: %e", repo, err)
+		return false
 	}
 
-	for _, label := range labelList.Labels {
-		deleteURL := fmt.Sprintf("%s/%s", url, label.ID)
-		err = qa.DeleteQuayLabel(deleteURL, quayToken, label.ID)
+	// Create a map of BD tags and retrieved values
+	nt := make(map[string]string)
+	nt[quayBDComURL] = image.ComponentsURL
+	nt[quayBDPolicy] = fmt.Sprintf("%d", image.PolicyViolations)
+	nt[quayBDSt] = image.OverallStatus
+	nt[quayBDVuln] = fmt.Sprintf("%d", image.Vulnerabilities)
+
+	imageInfo := fmt.Sprintf("%s:%s with SHA %s", image.Repository, image.Tag, image.Sha)
+	qa.updateLabels(url, labelList.Labels, nt, imageInfo, quayToken)
+	return true
+}
+
+// labelManifestLists labels the manifest lists of the scanned tags in the
+// registry with the aggregate of the scans of their platforms and returns
+// how many were labelled
+func (qa *QuayAnnotator) labelManifestLists(auth *utils.RegistryAuth, registry *utils.RegistryAuth, images []perceptorapi.ScannedImage) int {
+	lists := 0
+	for key, scans := range scannedTags(images) {
+		if !qa.matcher.InRegistry(key.repository, registry.URL) {
+			continue
+		}
+		ref, err := docker.ParseReference(key.repository)
 		if err != nil {
-			log.Errorf("Error in deleting label %s at URL %s: %e", label.Key, deleteURL, err)
-			log.Errorf("Images may contain duplicate labels!")
+			continue
 		}
-	}
 
-	err = qa.AddQuayLabel(url, quayToken, labelKey, newValue)
-	if err != nil {
-		log.Errorf("Error in adding label %s at URL %s after deleting: %e", labelKey, url, err)
-		return
-	}
+		repoURL := fmt.Sprintf("%s/api/v1/repository/%s", auth.URL, ref.Repository)
+		tags, err := utils.GetQuayTag(repoURL, key.tag, registry.Token, qa.paging)
+		if err != nil {
+			log.Errorf("Error in getting tag %s of repo %s: %e", key.tag, ref.Repository, err)
+			continue
+		}
 
-	labelInfo := fmt.Sprintf("%s:%s", labelKey, newValue)
-	log.Infof("Successfully annotated %s with %s!", imageInfo, labelInfo)
+		for _, tag := range tags {
+			if !tag.IsManifestList {
+				continue
+			}
+			manifest, err := utils.GetQuayManifest(repoURL, tag.ManifestDigest, registry.Token)
+			if err != nil {
+				log.Errorf("Error in getting manifest list of %s:%s: %v", key.repository, key.tag, err)
+				continue
+			}
+			listScan, ok := manifestListScan(manifest, scans)
+			if !ok {
+				log.Debugf("Manifest list of %s:%s isn't fully scanned yet", key.repository, key.tag)
+				continue
+			}
+			if qa.labelImage(auth, ref.Repository, listScan, registry.Token) {
+				lists = lists + 1
+			}
+		}
+	}
+	return lists
 }
 
-// PingQuayServer takes in the specified URL with access token and checks weather
-// it's a valid token for quay by pinging the server
-func (qa *QuayAnnotator) PingQuayServer(url string, user string, password string, accessToken string) (*utils.RegistryAuth, error) {
-	url = fmt.Sprintf("%s/api/v1/user", url)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("Error in creating ping request for quay server %e", err)
+// updateLabels gives the BD labels of a manifest the new values.  Only the
+// keys whose labels differ are touched, a manifest whose scan didn't change
+// is left alone.  A key with more than one label is replaced by a single one
+func (qa *QuayAnnotator) updateLabels(url string, labels []QuayLabel, newLabels map[string]string, imageInfo string, quayToken string) {
+	current := make(map[string][]QuayLabel)
+	for _, label := range labels {
+		// Don't need to touch other labels apart from BD ones
+		if _, ok := newLabels[label.Key]; ok {
+			current[label.Key] = append(current[label.Key], label)
+		}
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+accessToken)
-	resp, err := qa.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("Error in pinging quay server %+v, response: %+v", err, resp)
-	}
+	created, deleted, unchanged := 0, 0, 0
+	for key, value := range newLabels {
+		if len(current[key]) == 1 && current[key][0].Value == value {
+			unchanged = unchanged + 1
+			continue
+		}
 
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		// Making sure that http and https both return not OK
-		if strings.Contains(url, "https://") {
-			url = strings.Replace(url, "https://", "http://", -1)
-			// Reset to baseURL
-			url = strings.Replace(url, "/api/v1/user", "", -1)
-			return qa.PingQuayServer(url, user, password, accessToken)
+		for _, label := range current[key] {
+			deleteURL := fmt.Sprintf("%s/%s", url, label.ID)
+			err := qa.DeleteQuayLabel(deleteURL, quayToken, label.ID)
+			if err != nil {
+				log.Errorf("Error in deleting label %s at URL %s: %e", label.Key, deleteURL, err)
+				log.Errorf("Images may contain duplicate labels!")
+				continue
+			}
+			deleted = deleted + 1
 		}
 
-		return nil, fmt.Errorf("Error in pinging quay server supposed to get %d response code got %d", http.StatusOK, resp.StatusCode)
+		err := qa.AddQuayLabel(url, quayToken, key, value)
+		if err != nil {
+			log.Errorf("Error in adding label %s at URL %s after deleting: %e", key, url, err)
+			continue
+		}
+		created = created + 1
+		log.Infof("Successfully annotated %s with %s:%s!", imageInfo, key, value)
 	}
 
-	// Reset to baseURL
-	url = strings.Replace(url, "/api/v1/user", "", -1)
-	return &utils.RegistryAuth{URL: url, User: user, Password: password, Token: accessToken}, nil
+	metrics.RecordLabelChanges("quay_annotator", "created", created)
+	metrics.RecordLabelChanges("quay_annotator", "deleted", deleted)
+	metrics.RecordLabelChanges("quay_annotator", "unchanged", unchanged)
 }
 
 // AddQuayLabel takes the specific Quay URL and adds the properties/annotations given by BD
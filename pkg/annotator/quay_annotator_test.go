@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package annotator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/blackducksoftware/perceivers/pkg/utils"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+// fakeQuay is a stand-in for the quay API of a labelled manifest.  It
+// records the labels deleted from and added to the manifest
+type fakeQuay struct {
+	utils.FakeAPI
+	labels  []QuayLabel
+	deleted []string
+	added   []string
+}
+
+func newFakeQuay(labels []QuayLabel) *fakeQuay {
+	fq := &fakeQuay{labels: labels}
+	path := "/api/v1/repository/team/app/manifest/sha256:1234/labels"
+	fq.Handle(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(QuayLabels{Labels: fq.labels})
+	})
+	fq.Handle(http.MethodDelete, path+"/*", func(w http.ResponseWriter, r *http.Request) {
+		fq.deleted = append(fq.deleted, strings.TrimPrefix(r.URL.Path, path+"/"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	fq.Handle(http.MethodPost, path, func(w http.ResponseWriter, r *http.Request) {
+		label := QuayNewLabel{}
+		json.NewDecoder(r.Body).Decode(&label)
+		fq.added = append(fq.added, label.Key+"="+label.Value)
+		w.WriteHeader(http.StatusCreated)
+	})
+	return fq
+}
+
+func TestQuayLabelImage(t *testing.T) {
+	fq := newFakeQuay([]QuayLabel{
+		{ID: "1", Key: quayBDVuln, Value: "3"},
+		{ID: "2", Key: quayBDPolicy, Value: "0"},
+		{ID: "3", Key: quayBDSt, Value: notInViolation},
+		{ID: "4", Key: quayBDSt, Value: inViolation},
+		{ID: "5", Key: "team", Value: "apps"},
+	})
+	server := httptest.NewServer(fq)
+	defer server.Close()
+
+	annotator := NewQuayAnnotator("http://perceptor", nil, nil, nil, QuaySummaryConfig{}, nil)
+	auth := &utils.RegistryAuth{URL: server.URL, Password: "token", Token: "token"}
+	image := &perceptorapi.ScannedImage{Repository: "quay/team/app", Sha: "1234", PolicyViolations: 2, Vulnerabilities: 3, OverallStatus: inViolation, ComponentsURL: "https://blackduck/components"}
+
+	if !annotator.labelImage(auth, "team/app", image, "token") {
+		t.Fatalf("expected the manifest to be found")
+	}
+	sort.Strings(fq.deleted)
+	// The vulnerabilities are unchanged and the status had a duplicate
+	if expected := []string{"2", "3", "4"}; !reflect.DeepEqual(fq.deleted, expected) {
+		t.Errorf("expected labels %v to be deleted, got %v", expected, fq.deleted)
+	}
+	sort.Strings(fq.added)
+	expected := []string{quayBDComURL + "=https://blackduck/components", quayBDSt + "=" + inViolation, quayBDPolicy + "=2"}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(fq.added, expected) {
+		t.Errorf("expected labels %v to be added, got %v", expected, fq.added)
+	}
+
+	// A manifest whose labels are up to date isn't touched
+	fq.labels = []QuayLabel{
+		{ID: "1", Key: quayBDVuln, Value: "3"},
+		{ID: "2", Key: quayBDPolicy, Value: "2"},
+		{ID: "3", Key: quayBDSt, Value: inViolation},
+		{ID: "6", Key: quayBDComURL, Value: "https://blackduck/components"},
+	}
+	fq.deleted, fq.added = nil, nil
+	annotator.labelImage(auth, "team/app", image, "token")
+	if len(fq.deleted) > 0 || len(fq.added) > 0 {
+		t.Errorf("expected no changes, got %v deleted and %v added", fq.deleted, fq.added)
+	}
+}
@@ -0,0 +1,280 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package annotator
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	"github.com/blackducksoftware/perceivers/pkg/utils"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// Actions the quay annotator can take on a tag in violation
+const (
+	// QuayExpireTag sets an expiration on the tag
+	QuayExpireTag = "expire"
+	// QuayMoveTag moves the tag to a quarantine tag
+	QuayMoveTag = "move"
+	// QuayPrivateRepository makes the repository of the tag private
+	QuayPrivateRepository = "private"
+)
+
+// The labels that keep when a manifest was first seen in violation and which
+// action was taken on it
+const (
+	quayBDViolationSince = "blackduck.violationsince"
+	quayBDEnforcement    = "blackduck.enforcement"
+)
+
+const (
+	defaultQuayExpiration       = 24 * time.Hour
+	defaultQuayQuarantineSuffix = "-quarantined"
+)
+
+var quayTagSuffixRegexp = regexp.MustCompile(`^[\w.-]*$`)
+
+// QuayEnforcementConfig is the opt-in policy of the quay annotator for tags
+// in violation for longer than the grace period.  The action is reverted
+// once the tag is no longer in violation
+type QuayEnforcementConfig struct {
+	Action             string
+	GracePeriodMinutes int
+	ExpirationHours    int
+	QuarantineSuffix   string
+}
+
+// Validate returns an error if the policy can't be enforced
+func (c *QuayEnforcementConfig) Validate() error {
+	if !c.enabled() {
+		return nil
+	}
+	switch c.Action {
+	case QuayExpireTag, QuayMoveTag, QuayPrivateRepository:
+	default:
+		return fmt.Errorf("unknown action %s, expected one of %s, %s or %s", c.Action, QuayExpireTag, QuayMoveTag, QuayPrivateRepository)
+	}
+	if c.GracePeriodMinutes < 0 {
+		return fmt.Errorf("grace period can't be negative: %d", c.GracePeriodMinutes)
+	}
+	if c.ExpirationHours < 0 {
+		return fmt.Errorf("expiration can't be negative: %d", c.ExpirationHours)
+	}
+	if !quayTagSuffixRegexp.MatchString(c.QuarantineSuffix) {
+		return fmt.Errorf("quarantine suffix %s isn't valid in a tag", c.QuarantineSuffix)
+	}
+	return nil
+}
+
+func (c *QuayEnforcementConfig) enabled() bool {
+	return c != nil && len(c.Action) > 0
+}
+
+func (c *QuayEnforcementConfig) gracePeriod() time.Duration {
+	return time.Duration(c.GracePeriodMinutes) * time.Minute
+}
+
+func (c *QuayEnforcementConfig) expiration() time.Duration {
+	if c.ExpirationHours == 0 {
+		return defaultQuayExpiration
+	}
+	return time.Duration(c.ExpirationHours) * time.Hour
+}
+
+func (c *QuayEnforcementConfig) quarantineSuffix() string {
+	if len(c.QuarantineSuffix) == 0 {
+		return defaultQuayQuarantineSuffix
+	}
+	return c.QuarantineSuffix
+}
+
+// quarantineTag returns the tag a tag in violation is moved to
+func (c *QuayEnforcementConfig) quarantineTag(tag string) string {
+	return tag + c.quarantineSuffix()
+}
+
+// enforce applies the action to the tags of the registry in violation for
+// longer than the grace period, and reverts it from the tags no longer in
+// violation
+func (qa *QuayAnnotator) enforce(auth *utils.RegistryAuth, registry *utils.RegistryAuth, images []perceptorapi.ScannedImage) {
+	for name, summary := range qa.quaySummaries(registry.URL, images) {
+		// A repository is only made public again once none of its tags is in violation
+		repositoryInViolation := summary.violations() > 0
+		for tag, scan := range summary.tags {
+			// The quarantined copies are scanned too, they are never moved again
+			if strings.HasSuffix(tag, qa.enforcement.quarantineSuffix()) {
+				continue
+			}
+			err := qa.enforceTag(auth, name, tag, scan, repositoryInViolation, registry.Token)
+			if err != nil {
+				metrics.RecordError("quay_annotator", "unable to enforce policy")
+				log.Errorf("Error in enforcing the policy on %s:%s: %v", name, tag, err)
+			}
+		}
+	}
+}
+
+// enforceTag keeps the labels of the manifest of a tag and applies or
+// reverts the action
+func (qa *QuayAnnotator) enforceTag(auth *utils.RegistryAuth, repo string, tag string, scan *perceptorapi.ScannedImage, repositoryInViolation bool, quayToken string) error {
+	repoURL := fmt.Sprintf("%s/api/v1/repository/%s", auth.URL, repo)
+	digest, moved, err := qa.tagDigest(repoURL, tag, quayToken)
+	if err != nil {
+		return err
+	}
+	if len(digest) == 0 {
+		log.Debugf("Tag %s of quay repository %s no longer exists", tag, repo)
+		return nil
+	}
+
+	labelsURL := fmt.Sprintf("%s/manifest/%s/labels", repoURL, digest)
+	labelList := &QuayLabels{}
+	err = utils.GetResourceOfType(labelsURL, nil, quayToken, labelList)
+	if err != nil {
+		return fmt.Errorf("unable to get labels: %v", err)
+	}
+	var since, enforcement *QuayLabel
+	for i, label := range labelList.Labels {
+		switch label.Key {
+		case quayBDViolationSince:
+			since = &labelList.Labels[i]
+		case quayBDEnforcement:
+			enforcement = &labelList.Labels[i]
+		}
+	}
+
+	if scan.OverallStatus == inViolation {
+		if since == nil {
+			return qa.AddQuayLabel(labelsURL, quayToken, quayBDViolationSince, time.Now().UTC().Format(time.RFC3339))
+		}
+		started, err := time.Parse(time.RFC3339, since.Value)
+		if err != nil {
+			return fmt.Errorf("invalid label %s: %v", quayBDViolationSince, err)
+		}
+		if enforcement != nil || time.Since(started) < qa.enforcement.gracePeriod() {
+			return nil
+		}
+		applied, err := qa.applyEnforcement(repoURL, tag, digest, quayToken)
+		if err != nil || !applied {
+			return err
+		}
+		metrics.RecordEnforcement("quay_annotator", qa.enforcement.Action, "applied")
+		log.Infof("Applied %s to %s:%s in violation since %s", qa.enforcement.Action, repo, tag, since.Value)
+		return qa.AddQuayLabel(labelsURL, quayToken, quayBDEnforcement, qa.enforcement.Action)
+	}
+
+	if enforcement != nil {
+		if enforcement.Value == QuayPrivateRepository && repositoryInViolation {
+			return nil
+		}
+		// The action of the label is reverted, the configured one may have changed since
+		err = qa.revertEnforcement(enforcement.Value, repoURL, tag, digest, moved, quayToken)
+		if err != nil {
+			return err
+		}
+		metrics.RecordEnforcement("quay_annotator", enforcement.Value, "reverted")
+		log.Infof("Reverted %s from %s:%s", enforcement.Value, repo, tag)
+		err = qa.DeleteQuayLabel(fmt.Sprintf("%s/%s", labelsURL, enforcement.ID), quayToken, enforcement.ID)
+		if err != nil {
+			return err
+		}
+	}
+	if since != nil {
+		return qa.DeleteQuayLabel(fmt.Sprintf("%s/%s", labelsURL, since.ID), quayToken, since.ID)
+	}
+	return nil
+}
+
+// tagDigest returns the manifest digest of a tag, or of its quarantine tag
+// and true if it was moved
+func (qa *QuayAnnotator) tagDigest(repoURL string, tag string, quayToken string) (string, bool, error) {
+	tags, err := utils.GetQuayTag(repoURL, tag, quayToken, qa.paging)
+	if err != nil {
+		return "", false, err
+	}
+	if len(tags) > 0 {
+		return tags[0].ManifestDigest, false, nil
+	}
+	tags, err = utils.GetQuayTag(repoURL, qa.enforcement.quarantineTag(tag), quayToken, qa.paging)
+	if err != nil || len(tags) == 0 {
+		return "", false, err
+	}
+	return tags[0].ManifestDigest, true, nil
+}
+
+// applyEnforcement takes the action on a tag and returns whether anything
+// was changed
+func (qa *QuayAnnotator) applyEnforcement(repoURL string, tag string, digest string, quayToken string) (bool, error) {
+	tagURL := fmt.Sprintf("%s/tag/%s", repoURL, tag)
+	switch qa.enforcement.Action {
+	case QuayExpireTag:
+		expiration := map[string]interface{}{"expiration": time.Now().Add(qa.enforcement.expiration()).Unix()}
+		return true, utils.SendRequest(qa.client, http.MethodPut, tagURL, nil, quayToken, expiration, http.StatusCreated)
+	case QuayMoveTag:
+		quarantineURL := fmt.Sprintf("%s/tag/%s", repoURL, qa.enforcement.quarantineTag(tag))
+		err := utils.SendRequest(qa.client, http.MethodPut, quarantineURL, nil, quayToken, map[string]string{"manifest_digest": digest}, http.StatusCreated)
+		if err != nil {
+			return false, err
+		}
+		return true, utils.SendRequest(qa.client, http.MethodDelete, tagURL, nil, quayToken, nil, http.StatusNoContent)
+	case QuayPrivateRepository:
+		repository := &utils.QuayRepository{}
+		err := utils.GetResourceOfType(repoURL, nil, quayToken, repository)
+		if err != nil {
+			return false, err
+		}
+		// A repository that already is private is left alone, so it is never made public
+		if !repository.IsPublic {
+			return false, nil
+		}
+		return true, utils.SendRequest(qa.client, http.MethodPost, repoURL+"/changevisibility", nil, quayToken, map[string]string{"visibility": "private"}, http.StatusOK)
+	}
+	return false, fmt.Errorf("unknown action %s", qa.enforcement.Action)
+}
+
+// revertEnforcement undoes an action taken on a tag
+func (qa *QuayAnnotator) revertEnforcement(action string, repoURL string, tag string, digest string, moved bool, quayToken string) error {
+	tagURL := fmt.Sprintf("%s/tag/%s", repoURL, tag)
+	switch action {
+	case QuayExpireTag:
+		return utils.SendRequest(qa.client, http.MethodPut, tagURL, nil, quayToken, map[string]interface{}{"expiration": nil}, http.StatusCreated)
+	case QuayMoveTag:
+		if !moved {
+			return nil
+		}
+		err := utils.SendRequest(qa.client, http.MethodPut, tagURL, nil, quayToken, map[string]string{"manifest_digest": digest}, http.StatusCreated)
+		if err != nil {
+			return err
+		}
+		quarantineURL := fmt.Sprintf("%s/tag/%s", repoURL, qa.enforcement.quarantineTag(tag))
+		return utils.SendRequest(qa.client, http.MethodDelete, quarantineURL, nil, quayToken, nil, http.StatusNoContent)
+	case QuayPrivateRepository:
+		return utils.SendRequest(qa.client, http.MethodPost, repoURL+"/changevisibility", nil, quayToken, map[string]string{"visibility": "public"}, http.StatusOK)
+	}
+	return fmt.Errorf("unknown action %s", action)
+}
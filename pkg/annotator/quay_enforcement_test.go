@@ -0,0 +1,279 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package annotator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/utils"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+// fakeQuayEnforcement is a stand-in for the quay API of the tags, labels
+// and visibility of the repository team/app
+type fakeQuayEnforcement struct {
+	utils.FakeAPI
+	tags        map[string]string
+	expirations map[string]bool
+	labels      map[string][]QuayLabel
+	public      bool
+	nextLabel   int
+}
+
+func newFakeQuayEnforcement(tags map[string]string, labels map[string][]QuayLabel, public bool) *fakeQuayEnforcement {
+	fq := &fakeQuayEnforcement{tags: tags, expirations: map[string]bool{}, labels: labels, public: public}
+	repository := "/api/v1/repository/team/app"
+	fq.Handle(http.MethodGet, repository, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(utils.QuayRepository{Namespace: "team", Name: "app", IsPublic: fq.public})
+	})
+	fq.Handle(http.MethodPost, repository+"/changevisibility", func(w http.ResponseWriter, r *http.Request) {
+		visibility := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&visibility)
+		fq.public = visibility["visibility"] == "public"
+	})
+	fq.Handle(http.MethodGet, repository+"/tag/", func(w http.ResponseWriter, r *http.Request) {
+		tags := utils.QuayTagDigest{}
+		if digest, ok := fq.tags[r.URL.Query().Get("specificTag")]; ok {
+			tags.Tags = append(tags.Tags, utils.QuayTag{Name: r.URL.Query().Get("specificTag"), ManifestDigest: digest})
+		}
+		json.NewEncoder(w).Encode(tags)
+	})
+	fq.Handle(http.MethodPut, repository+"/tag/*", func(w http.ResponseWriter, r *http.Request) {
+		tag := strings.TrimPrefix(r.URL.Path, repository+"/tag/")
+		change := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&change)
+		if digest, ok := change["manifest_digest"]; ok {
+			fq.tags[tag] = digest.(string)
+		}
+		if expiration, ok := change["expiration"]; ok {
+			fq.expirations[tag] = expiration != nil
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	fq.Handle(http.MethodDelete, repository+"/tag/*", func(w http.ResponseWriter, r *http.Request) {
+		delete(fq.tags, strings.TrimPrefix(r.URL.Path, repository+"/tag/"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	// The labels of a manifest are at /manifest/<digest>/labels
+	manifest := func(r *http.Request) []string {
+		return strings.Split(strings.TrimPrefix(r.URL.Path, repository+"/manifest/"), "/")
+	}
+	fq.Handle(http.MethodGet, repository+"/manifest/*", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(QuayLabels{Labels: fq.labels[manifest(r)[0]]})
+	})
+	fq.Handle(http.MethodPost, repository+"/manifest/*", func(w http.ResponseWriter, r *http.Request) {
+		digest := manifest(r)[0]
+		label := QuayNewLabel{}
+		json.NewDecoder(r.Body).Decode(&label)
+		fq.nextLabel = fq.nextLabel + 1
+		fq.labels[digest] = append(fq.labels[digest], QuayLabel{ID: fmt.Sprintf("%d", fq.nextLabel), Key: label.Key, Value: label.Value})
+		w.WriteHeader(http.StatusCreated)
+	})
+	fq.Handle(http.MethodDelete, repository+"/manifest/*", func(w http.ResponseWriter, r *http.Request) {
+		parts := manifest(r)
+		if len(parts) != 3 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		kept := []QuayLabel{}
+		for _, label := range fq.labels[parts[0]] {
+			if label.ID != parts[2] {
+				kept = append(kept, label)
+			}
+		}
+		fq.labels[parts[0]] = kept
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return fq
+}
+
+func (fq *fakeQuayEnforcement) labelKeys(digest string) []string {
+	keys := []string{}
+	for _, label := range fq.labels[digest] {
+		keys = append(keys, label.Key+"="+label.Value)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestQuayEnforcementValidate(t *testing.T) {
+	testcases := []struct {
+		description string
+		config      *QuayEnforcementConfig
+		shouldPass  bool
+	}{
+		{description: "not configured", config: nil, shouldPass: true},
+		{description: "disabled", config: &QuayEnforcementConfig{}, shouldPass: true},
+		{description: "expire", config: &QuayEnforcementConfig{Action: QuayExpireTag, GracePeriodMinutes: 60}, shouldPass: true},
+		{description: "move", config: &QuayEnforcementConfig{Action: QuayMoveTag, QuarantineSuffix: "-blocked"}, shouldPass: true},
+		{description: "unknown action", config: &QuayEnforcementConfig{Action: "delete"}, shouldPass: false},
+		{description: "negative grace period", config: &QuayEnforcementConfig{Action: QuayExpireTag, GracePeriodMinutes: -1}, shouldPass: false},
+		{description: "invalid suffix", config: &QuayEnforcementConfig{Action: QuayMoveTag, QuarantineSuffix: ":blocked"}, shouldPass: false},
+	}
+
+	for _, tc := range testcases {
+		err := tc.config.Validate()
+		if err != nil && tc.shouldPass {
+			t.Errorf("[%s] unexpected error: %v", tc.description, err)
+		}
+		if err == nil && !tc.shouldPass {
+			t.Errorf("[%s] expected an error", tc.description)
+		}
+	}
+}
+
+func TestQuayEnforce(t *testing.T) {
+	digest := "sha256:1234"
+	old := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	recent := time.Now().UTC().Format(time.RFC3339)
+	testcases := []struct {
+		description  string
+		action       string
+		status       string
+		tags         map[string]string
+		labels       []QuayLabel
+		public       bool
+		expectedTags []string
+		expectedKeys []string
+		expired      bool
+		expectPublic bool
+	}{
+		{
+			description:  "first seen in violation",
+			action:       QuayExpireTag,
+			status:       inViolation,
+			tags:         map[string]string{"1.0": digest},
+			expectedTags: []string{"1.0"},
+			expectedKeys: []string{quayBDViolationSince},
+		},
+		{
+			description:  "in violation within the grace period",
+			action:       QuayExpireTag,
+			status:       inViolation,
+			tags:         map[string]string{"1.0": digest},
+			labels:       []QuayLabel{{ID: "a", Key: quayBDViolationSince, Value: recent}},
+			expectedTags: []string{"1.0"},
+			expectedKeys: []string{quayBDViolationSince},
+		},
+		{
+			description:  "expired after the grace period",
+			action:       QuayExpireTag,
+			status:       inViolation,
+			tags:         map[string]string{"1.0": digest},
+			labels:       []QuayLabel{{ID: "a", Key: quayBDViolationSince, Value: old}},
+			expectedTags: []string{"1.0"},
+			expectedKeys: []string{quayBDEnforcement + "=" + QuayExpireTag, quayBDViolationSince},
+			expired:      true,
+		},
+		{
+			description:  "moved after the grace period",
+			action:       QuayMoveTag,
+			status:       inViolation,
+			tags:         map[string]string{"1.0": digest},
+			labels:       []QuayLabel{{ID: "a", Key: quayBDViolationSince, Value: old}},
+			expectedTags: []string{"1.0" + defaultQuayQuarantineSuffix},
+			expectedKeys: []string{quayBDEnforcement + "=" + QuayMoveTag, quayBDViolationSince},
+		},
+		{
+			description:  "made private after the grace period",
+			action:       QuayPrivateRepository,
+			status:       inViolation,
+			tags:         map[string]string{"1.0": digest},
+			labels:       []QuayLabel{{ID: "a", Key: quayBDViolationSince, Value: old}},
+			public:       true,
+			expectedTags: []string{"1.0"},
+			expectedKeys: []string{quayBDEnforcement + "=" + QuayPrivateRepository, quayBDViolationSince},
+		},
+		{
+			description:  "already private repository is left alone",
+			action:       QuayPrivateRepository,
+			status:       inViolation,
+			tags:         map[string]string{"1.0": digest},
+			labels:       []QuayLabel{{ID: "a", Key: quayBDViolationSince, Value: old}},
+			expectedTags: []string{"1.0"},
+			expectedKeys: []string{quayBDViolationSince},
+		},
+		{
+			description:  "moved tag restored when compliant",
+			action:       QuayMoveTag,
+			status:       notInViolation,
+			tags:         map[string]string{"1.0" + defaultQuayQuarantineSuffix: digest},
+			labels:       []QuayLabel{{ID: "a", Key: quayBDViolationSince, Value: old}, {ID: "b", Key: quayBDEnforcement, Value: QuayMoveTag}},
+			expectedTags: []string{"1.0"},
+			expectedKeys: []string{},
+		},
+		{
+			description:  "repository made public when compliant",
+			action:       QuayExpireTag,
+			status:       notInViolation,
+			tags:         map[string]string{"1.0": digest},
+			labels:       []QuayLabel{{ID: "a", Key: quayBDViolationSince, Value: old}, {ID: "b", Key: quayBDEnforcement, Value: QuayPrivateRepository}},
+			expectedTags: []string{"1.0"},
+			expectedKeys: []string{},
+			expectPublic: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		fq := newFakeQuayEnforcement(tc.tags, map[string][]QuayLabel{digest: tc.labels}, tc.public)
+		server := httptest.NewServer(fq)
+
+		registryURL := strings.TrimPrefix(server.URL, "http://")
+		images := []perceptorapi.ScannedImage{{Repository: registryURL + "/team/app", Tag: "1.0", Sha: "1234", OverallStatus: tc.status}}
+		annotator := NewQuayAnnotator("http://perceptor", nil, nil, nil, QuaySummaryConfig{}, &QuayEnforcementConfig{Action: tc.action, GracePeriodMinutes: 60})
+		annotator.enforce(&utils.RegistryAuth{URL: server.URL}, &utils.RegistryAuth{URL: registryURL, Token: "token"}, images)
+		server.Close()
+
+		tags := []string{}
+		for tag := range fq.tags {
+			tags = append(tags, tag)
+		}
+		if !reflect.DeepEqual(tags, tc.expectedTags) {
+			t.Errorf("[%s] expected tags %v got %v", tc.description, tc.expectedTags, tags)
+		}
+		keys := fq.labelKeys(digest)
+		for i, key := range keys {
+			// The time a manifest was first seen in violation isn't compared
+			if strings.HasPrefix(key, quayBDViolationSince+"=") {
+				keys[i] = quayBDViolationSince
+			}
+		}
+		if !reflect.DeepEqual(keys, tc.expectedKeys) {
+			t.Errorf("[%s] expected labels %v got %v", tc.description, tc.expectedKeys, keys)
+		}
+		if fq.expirations["1.0"] != tc.expired {
+			t.Errorf("[%s] expected expiration %t got %t", tc.description, tc.expired, fq.expirations["1.0"])
+		}
+		if fq.public != tc.expectPublic {
+			t.Errorf("[%s] expected public %t got %t", tc.description, tc.expectPublic, fq.public)
+		}
+	}
+}
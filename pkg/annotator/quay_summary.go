@@ -0,0 +1,272 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package annotator
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/utils"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// The BD summary is kept between these in the description of a repository,
+// the rest of the description is left as it is
+const (
+	quaySummaryStart = "<!-- blackduck-summary -->"
+	quaySummaryEnd   = "<!-- /blackduck-summary -->"
+)
+
+// quayReportRepository is the repository of a namespace whose description
+// is the report of the namespace by default
+const quayReportRepository = "blackduck-report"
+
+// QuaySummaryConfig is which summaries of the scans the quay annotator keeps.
+// The summary of a repository is in its description.  Quay organizations
+// have no description, so the report of a namespace is the description of
+// a private repository of the namespace, which is created when missing
+type QuaySummaryConfig struct {
+	Repositories     bool
+	Namespaces       bool
+	ReportRepository string
+}
+
+// quayRepositorySummary is the scans of the tags of a repository
+type quayRepositorySummary struct {
+	name string
+	tags map[string]*perceptorapi.ScannedImage
+}
+
+// worst returns the worst scan of the tags of the repository
+func (s *quayRepositorySummary) worst() *perceptorapi.ScannedImage {
+	result := &perceptorapi.ScannedImage{OverallStatus: notInViolation}
+	for _, scan := range s.tags {
+		result = worseScan(result, scan)
+	}
+	return result
+}
+
+// violations returns how many tags of the repository are in violation
+func (s *quayRepositorySummary) violations() int {
+	violations := 0
+	for _, scan := range s.tags {
+		if scan.OverallStatus == inViolation {
+			violations = violations + 1
+		}
+	}
+	return violations
+}
+
+// markdown returns the summary as a table of the tags for the description
+// of the repository
+func (s *quayRepositorySummary) markdown() string {
+	worst := s.worst()
+	lines := []string{
+		"### Black Duck scan results",
+		"",
+		fmt.Sprintf("Overall status: **%s**, %d of %d tags in violation", worst.OverallStatus, s.violations(), len(s.tags)),
+		"",
+		"| Tag | Status | Policy violations | Vulnerabilities |",
+		"| --- | --- | --- | --- |",
+	}
+	tags := []string{}
+	for tag := range s.tags {
+		tags = append(tags, tag)
+	}
+	// Sorted so the description only changes when the scans do
+	sort.Strings(tags)
+	for _, tag := range tags {
+		scan := s.tags[tag]
+		lines = append(lines, fmt.Sprintf("| %s | %s | %d | %d |", tag, scan.OverallStatus, scan.PolicyViolations, scan.Vulnerabilities))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// quayNamespaceMarkdown returns the report of the repositories of a
+// namespace for the description of its report repository
+func quayNamespaceMarkdown(namespace string, summaries map[string]*quayRepositorySummary) string {
+	lines := []string{
+		fmt.Sprintf("### Black Duck scan results of %s", namespace),
+		"",
+		"| Repository | Status | Tags in violation | Policy violations | Vulnerabilities |",
+		"| --- | --- | --- | --- | --- |",
+	}
+	names := []string{}
+	for name := range summaries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		summary := summaries[name]
+		worst := summary.worst()
+		lines = append(lines, fmt.Sprintf("| %s | %s | %d of %d | %d | %d |", name, worst.OverallStatus, summary.violations(), len(summary.tags), worst.PolicyViolations, worst.Vulnerabilities))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// worseScan returns a scan with the worst counts and status of two scans
+func worseScan(a *perceptorapi.ScannedImage, b *perceptorapi.ScannedImage) *perceptorapi.ScannedImage {
+	result := *a
+	if b.PolicyViolations > result.PolicyViolations {
+		result.PolicyViolations = b.PolicyViolations
+	}
+	if b.Vulnerabilities > result.Vulnerabilities {
+		result.Vulnerabilities = b.Vulnerabilities
+	}
+	if len(b.OverallStatus) > 0 && b.OverallStatus != notInViolation {
+		result.OverallStatus = inViolation
+	}
+	return &result
+}
+
+// withSummary returns a description with its BD summary replaced, or the
+// summary added after it
+func withSummary(description string, summary string) string {
+	section := fmt.Sprintf("%s\n%s\n%s", quaySummaryStart, summary, quaySummaryEnd)
+	start := strings.Index(description, quaySummaryStart)
+	end := strings.Index(description, quaySummaryEnd)
+	if start >= 0 && end > start {
+		return description[:start] + section + description[end+len(quaySummaryEnd):]
+	}
+	if len(strings.TrimSpace(description)) == 0 {
+		return section
+	}
+	return strings.TrimRight(description, "\n") + "\n\n" + section
+}
+
+// quaySummaries groups the scans of the registry by repository, the tags of
+// a manifest list have the worst scan of their platforms
+func (qa *QuayAnnotator) quaySummaries(registryURL string, images []perceptorapi.ScannedImage) map[string]*quayRepositorySummary {
+	summaries := map[string]*quayRepositorySummary{}
+	for key, scans := range scannedTags(images) {
+		if !qa.matcher.InRegistry(key.repository, registryURL) {
+			continue
+		}
+		ref, err := docker.ParseReference(key.repository)
+		if err != nil {
+			continue
+		}
+		summary, ok := summaries[ref.Repository]
+		if !ok {
+			summary = &quayRepositorySummary{name: ref.Repository, tags: map[string]*perceptorapi.ScannedImage{}}
+			summaries[ref.Repository] = summary
+		}
+		scan := &scans[0]
+		for i := range scans[1:] {
+			scan = worseScan(scan, &scans[i+1])
+		}
+		summary.tags[key.tag] = scan
+	}
+	return summaries
+}
+
+// summarize keeps the summaries of the scans of the registry
+func (qa *QuayAnnotator) summarize(auth *utils.RegistryAuth, registry *utils.RegistryAuth, images []perceptorapi.ScannedImage) {
+	summaries := qa.quaySummaries(registry.URL, images)
+	namespaces := map[string]map[string]*quayRepositorySummary{}
+	for name, summary := range summaries {
+		if qa.summaries.Repositories {
+			err := qa.updateDescription(auth, name, summary.markdown(), registry.Token)
+			if err != nil {
+				log.Errorf("Error in updating the summary of quay repository %s: %v", name, err)
+			}
+		}
+		namespace := strings.SplitN(name, "/", 2)[0]
+		if namespaces[namespace] == nil {
+			namespaces[namespace] = map[string]*quayRepositorySummary{}
+		}
+		namespaces[namespace][name] = summary
+	}
+
+	if !qa.summaries.Namespaces {
+		return
+	}
+	for namespace, repositories := range namespaces {
+		err := qa.updateReport(auth, namespace, quayNamespaceMarkdown(namespace, repositories), registry.Token)
+		if err != nil {
+			log.Errorf("Error in updating the report of quay namespace %s: %v", namespace, err)
+		}
+	}
+}
+
+// updateDescription replaces the BD summary in the description of a
+// repository, if it changed
+func (qa *QuayAnnotator) updateDescription(auth *utils.RegistryAuth, name string, summary string, quayToken string) error {
+	repoURL := fmt.Sprintf("%s/api/v1/repository/%s", auth.URL, name)
+	repository := &utils.QuayRepository{}
+	err := utils.GetResourceOfType(repoURL, nil, quayToken, repository)
+	if err != nil {
+		return err
+	}
+	if len(repository.Name) == 0 {
+		return fmt.Errorf("repository %s not found", name)
+	}
+
+	description := withSummary(repository.Description, summary)
+	if description == repository.Description {
+		return nil
+	}
+	err = utils.SendRequest(qa.client, http.MethodPut, repoURL, nil, quayToken, map[string]string{"description": description}, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	log.Infof("Successfully updated the summary of quay repository %s", name)
+	return nil
+}
+
+// updateReport replaces the report of a namespace, the report repository
+// is created when the namespace doesn't have it
+func (qa *QuayAnnotator) updateReport(auth *utils.RegistryAuth, namespace string, report string, quayToken string) error {
+	reportRepository := qa.summaries.ReportRepository
+	if len(reportRepository) == 0 {
+		reportRepository = quayReportRepository
+	}
+	name := fmt.Sprintf("%s/%s", namespace, reportRepository)
+
+	repository := &utils.QuayRepository{}
+	err := utils.GetResourceOfType(fmt.Sprintf("%s/api/v1/repository/%s", auth.URL, name), nil, quayToken, repository)
+	if err != nil {
+		return err
+	}
+	if len(repository.Name) > 0 {
+		return qa.updateDescription(auth, name, report, quayToken)
+	}
+
+	created := map[string]string{
+		"namespace":   namespace,
+		"repository":  reportRepository,
+		"visibility":  "private",
+		"repo_kind":   utils.QuayImageKind,
+		"description": withSummary("", report),
+	}
+	err = utils.SendRequest(qa.client, http.MethodPost, fmt.Sprintf("%s/api/v1/repository", auth.URL), nil, quayToken, created, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	log.Infof("Successfully created the report of quay namespace %s in %s", namespace, name)
+	return nil
+}
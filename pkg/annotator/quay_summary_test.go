@@ -0,0 +1,151 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package annotator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/blackducksoftware/perceivers/pkg/utils"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+// fakeQuayRepositories is a stand-in for the quay API of repositories.  It
+// records the repositories created
+type fakeQuayRepositories struct {
+	utils.FakeAPI
+	repositories map[string]*utils.QuayRepository
+	created      []string
+}
+
+func newFakeQuayRepositories(repositories map[string]*utils.QuayRepository) *fakeQuayRepositories {
+	fq := &fakeQuayRepositories{repositories: repositories}
+	path := "/api/v1/repository"
+	fq.Handle(http.MethodPost, path, func(w http.ResponseWriter, r *http.Request) {
+		created := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&created)
+		name := created["namespace"] + "/" + created["repository"]
+		fq.repositories[name] = &utils.QuayRepository{Namespace: created["namespace"], Name: created["repository"], Description: created["description"]}
+		fq.created = append(fq.created, name)
+		w.WriteHeader(http.StatusCreated)
+	})
+	fq.Handle(http.MethodGet, path+"/*", func(w http.ResponseWriter, r *http.Request) {
+		if repository, ok := fq.repository(w, r); ok {
+			json.NewEncoder(w).Encode(repository)
+		}
+	})
+	fq.Handle(http.MethodPut, path+"/*", func(w http.ResponseWriter, r *http.Request) {
+		if repository, ok := fq.repository(w, r); ok {
+			updated := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&updated)
+			repository.Description = updated["description"]
+		}
+	})
+	return fq
+}
+
+// repository returns the repository of a request, or answers it with the
+// error quay sends for a missing repository
+func (fq *fakeQuayRepositories) repository(w http.ResponseWriter, r *http.Request) (*utils.QuayRepository, bool) {
+	repository, ok := fq.repositories[strings.TrimPrefix(r.URL.Path, "/api/v1/repository/")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error_message": "Not Found"})
+	}
+	return repository, ok
+}
+
+func TestWithSummary(t *testing.T) {
+	section := quaySummaryStart + "\nnew\n" + quaySummaryEnd
+	testcases := []struct {
+		description string
+		existing    string
+		expected    string
+	}{
+		{
+			description: "empty description",
+			existing:    "",
+			expected:    section,
+		},
+		{
+			description: "description without a summary",
+			existing:    "Our app\n",
+			expected:    "Our app\n\n" + section,
+		},
+		{
+			description: "description with a summary",
+			existing:    "Our app\n\n" + quaySummaryStart + "\nold\n" + quaySummaryEnd + "\n\nMore text",
+			expected:    "Our app\n\n" + section + "\n\nMore text",
+		},
+	}
+
+	for _, tc := range testcases {
+		result := withSummary(tc.existing, "new")
+		if result != tc.expected {
+			t.Errorf("[%s] expected %q got %q", tc.description, tc.expected, result)
+		}
+	}
+}
+
+func TestQuaySummarize(t *testing.T) {
+	fq := newFakeQuayRepositories(map[string]*utils.QuayRepository{
+		"team/app": {Namespace: "team", Name: "app", Description: "Our app"},
+	})
+	server := httptest.NewServer(fq)
+	defer server.Close()
+
+	registryURL := strings.TrimPrefix(server.URL, "http://")
+	images := []perceptorapi.ScannedImage{
+		{Repository: registryURL + "/team/app", Tag: "1.0", Sha: "1234", OverallStatus: notInViolation, Vulnerabilities: 1},
+		{Repository: registryURL + "/team/app", Tag: "2.0", Sha: "5678", OverallStatus: inViolation, PolicyViolations: 2},
+		{Repository: registryURL + "/team/app", Tag: "2.0", Sha: "9abc", OverallStatus: notInViolation, Vulnerabilities: 4},
+		{Repository: "other.io/team/app", Tag: "1.0", Sha: "def0", OverallStatus: inViolation},
+	}
+
+	annotator := NewQuayAnnotator("http://perceptor", nil, nil, nil, QuaySummaryConfig{Repositories: true, Namespaces: true}, nil)
+	auth := &utils.RegistryAuth{URL: server.URL}
+	annotator.summarize(auth, &utils.RegistryAuth{URL: registryURL, Token: "token"}, images)
+
+	description := fq.repositories["team/app"].Description
+	for _, expected := range []string{"Our app\n\n" + quaySummaryStart, "1 of 2 tags in violation", "| 1.0 | " + notInViolation + " | 0 | 1 |", "| 2.0 | " + inViolation + " | 2 | 4 |"} {
+		if !strings.Contains(description, expected) {
+			t.Errorf("expected the repository description to contain %q, got %q", expected, description)
+		}
+	}
+	if len(fq.created) != 1 || fq.created[0] != "team/"+quayReportRepository {
+		t.Fatalf("expected the report repository to be created, got %v", fq.created)
+	}
+	report := fq.repositories["team/"+quayReportRepository].Description
+	if !strings.Contains(report, "| team/app | "+inViolation+" | 1 of 2 | 2 | 4 |") {
+		t.Errorf("unexpected namespace report %q", report)
+	}
+
+	// Unchanged scans leave the descriptions and the report repository as they are
+	annotator.summarize(auth, &utils.RegistryAuth{URL: registryURL, Token: "token"}, images)
+	if len(fq.created) != 1 || fq.repositories["team/app"].Description != description {
+		t.Errorf("expected no changes, got created %v and description %q", fq.created, fq.repositories["team/app"].Description)
+	}
+}
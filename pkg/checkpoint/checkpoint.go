@@ -0,0 +1,162 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package checkpoint
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/blackducksoftware/perceivers/pkg/election"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// invalidKeyChars are the characters that aren't allowed in the keys of a
+// ConfigMap
+var invalidKeyChars = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+// Config contains the settings of where a perceiver keeps how far its
+// controllers got
+type Config struct {
+	Enabled       bool
+	Namespace     string
+	ConfigMapName string
+}
+
+// Checkpoint keeps values, like the time of the last successful lookup of
+// a registry, across restarts and leader changes in a ConfigMap.  When
+// persistence is disabled the values are only kept in memory
+type Checkpoint struct {
+	client        kubernetes.Interface
+	namespace     string
+	configMapName string
+
+	mutex  sync.Mutex
+	values map[string]string
+}
+
+// NewCheckpoint creates a new Checkpoint object.  The ConfigMap is named
+// after the component unless a name is configured.  If the kube client is
+// nil one is created from the in cluster configuration
+func NewCheckpoint(config Config, component string, kubeClient kubernetes.Interface) (*Checkpoint, error) {
+	if !config.Enabled {
+		return &Checkpoint{values: map[string]string{}}, nil
+	}
+
+	if kubeClient == nil {
+		clusterConfig, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("unable to build config from cluster: %v", err)
+		}
+		kubeClient, err = kubernetes.NewForConfig(clusterConfig)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create kubernetes client: %v", err)
+		}
+	}
+
+	namespace, err := election.Namespace(config.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine checkpoint namespace: %v", err)
+	}
+	configMapName := config.ConfigMapName
+	if len(configMapName) == 0 {
+		configMapName = fmt.Sprintf("%s-checkpoints", component)
+	}
+
+	return &Checkpoint{
+		client:        kubeClient,
+		namespace:     namespace,
+		configMapName: configMapName,
+		values:        map[string]string{},
+	}, nil
+}
+
+// Get returns the value of a key, or an empty string if it wasn't set
+func (c *Checkpoint) Get(key string) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if value, ok := c.values[key]; ok || c.client == nil {
+		return value, nil
+	}
+
+	cm, err := c.client.CoreV1().ConfigMaps(c.namespace).Get(c.configMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("unable to get checkpoint config map %s/%s: %v", c.namespace, c.configMapName, err)
+	}
+	value := cm.Data[configMapKey(key)]
+	if len(value) > 0 {
+		c.values[key] = value
+	}
+	return value, nil
+}
+
+// Set keeps the value of a key
+func (c *Checkpoint) Set(key string, value string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.client == nil {
+		c.values[key] = value
+		return nil
+	}
+
+	cm, err := c.client.CoreV1().ConfigMaps(c.namespace).Get(c.configMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      c.configMapName,
+				Namespace: c.namespace,
+			},
+			Data: map[string]string{configMapKey(key): value},
+		}
+		_, err = c.client.CoreV1().ConfigMaps(c.namespace).Create(cm)
+		if err != nil {
+			return fmt.Errorf("unable to create checkpoint config map %s/%s: %v", c.namespace, c.configMapName, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("unable to get checkpoint config map %s/%s: %v", c.namespace, c.configMapName, err)
+	} else {
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[configMapKey(key)] = value
+		_, err = c.client.CoreV1().ConfigMaps(c.namespace).Update(cm)
+		if err != nil {
+			return fmt.Errorf("unable to update checkpoint config map %s/%s: %v", c.namespace, c.configMapName, err)
+		}
+	}
+	// Only kept in memory once persisted, so a failure is retried
+	c.values[key] = value
+	return nil
+}
+
+// configMapKey returns a key with the characters a ConfigMap doesn't allow
+// replaced, registry URLs have slashes and colons
+func configMapKey(key string) string {
+	return invalidKeyChars.ReplaceAllString(key, "_")
+}
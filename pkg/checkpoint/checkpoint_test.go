@@ -0,0 +1,105 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package checkpoint
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestCheckpoint(client *fake.Clientset) *Checkpoint {
+	return &Checkpoint{
+		client:        client,
+		namespace:     "perceivers",
+		configMapName: "artifactory-perceiver-checkpoints",
+		values:        map[string]string{},
+	}
+}
+
+func TestDisabledCheckpointInMemory(t *testing.T) {
+	checkpoint, err := NewCheckpoint(Config{Enabled: false}, "artifactory-perceiver", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	value, err := checkpoint.Get("art.example.com/artifactory")
+	if err != nil || len(value) > 0 {
+		t.Fatalf("expected no value, got %s and %v", value, err)
+	}
+	err = checkpoint.Set("art.example.com/artifactory", "2019-06-06T10:21:41.123Z")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	value, _ = checkpoint.Get("art.example.com/artifactory")
+	if value != "2019-06-06T10:21:41.123Z" {
+		t.Errorf("expected the value in memory, got %s", value)
+	}
+}
+
+func TestCheckpointPersisted(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	key := "art.example.com:8443/artifactory"
+
+	err := newTestCheckpoint(client).Set(key, "first")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = newTestCheckpoint(client).Set(key, "second")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("perceivers").Get("artifactory-perceiver-checkpoints", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cm.Data["art.example.com_8443_artifactory"] != "second" {
+		t.Errorf("expected the value under a valid key, got %v", cm.Data)
+	}
+
+	// A new leader continues from the persisted value
+	value, err := newTestCheckpoint(client).Get(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value != "second" {
+		t.Errorf("expected the persisted value, got %s", value)
+	}
+}
+
+func TestCheckpointOtherKeysKept(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "artifactory-perceiver-checkpoints", Namespace: "perceivers"},
+		Data:       map[string]string{"other": "kept"},
+	})
+	err := newTestCheckpoint(client).Set("art", "value")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cm, _ := client.CoreV1().ConfigMaps("perceivers").Get("artifactory-perceiver-checkpoints", metav1.GetOptions{})
+	if cm.Data["other"] != "kept" || cm.Data["art"] != "value" {
+		t.Errorf("expected both keys, got %v", cm.Data)
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Config describes how to reach a cluster.  Name is the cluster ID sent to
+// perceptor with the cluster's pods and images.  When neither Kubeconfig nor
+// Context is set the in cluster configuration is used
+type Config struct {
+	Name       string
+	Kubeconfig string
+	Context    string
+}
+
+// RestConfig returns the client configuration for the cluster.  Outside of
+// a cluster the default kubeconfig loading rules ($KUBECONFIG, then
+// ~/.kube/config) are used so a perceiver can be run locally for debugging
+func (c Config) RestConfig() (*rest.Config, error) {
+	if len(c.Kubeconfig) == 0 && len(c.Context) == 0 && inCluster() {
+		return rest.InClusterConfig()
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = c.Kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: c.Context}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load kubeconfig %q with context %q: %v", c.Kubeconfig, c.Context, err)
+	}
+	return config, nil
+}
+
+func inCluster() bool {
+	return len(os.Getenv("KUBERNETES_SERVICE_HOST")) > 0 && len(os.Getenv("KUBERNETES_SERVICE_PORT")) > 0
+}
+
+// ValidateClusters verifies every cluster has a unique name, which is
+// needed to tell the pods of the clusters apart
+func ValidateClusters(clusters []Config) error {
+	seen := make(map[string]bool)
+	for _, c := range clusters {
+		if len(c.Name) == 0 {
+			return fmt.Errorf("every cluster needs a name")
+		}
+		if strings.Contains(c.Name, "/") {
+			return fmt.Errorf("cluster name %s can't contain a /", c.Name)
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("cluster name %s is used more than once", c.Name)
+		}
+		seen[c.Name] = true
+	}
+	return nil
+}
+
+// QualifiedNamespace prefixes the namespace with the cluster name so
+// identically named pods in different clusters don't collide.  The
+// namespace is returned unchanged if the cluster has no name
+func QualifiedNamespace(cluster string, namespace string) string {
+	if len(cluster) == 0 {
+		return namespace
+	}
+	return fmt.Sprintf("%s/%s", cluster, namespace)
+}
+
+// SplitNamespace returns the cluster name and namespace of a namespace
+// created by QualifiedNamespace.  Kubernetes namespaces can't contain a /
+// so anything before it is the cluster name
+func SplitNamespace(qualified string) (string, string) {
+	parts := strings.SplitN(qualified, "/", 2)
+	if len(parts) == 1 {
+		return "", parts[0]
+	}
+	return parts[0], parts[1]
+}
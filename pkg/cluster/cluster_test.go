@@ -0,0 +1,103 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package cluster
+
+import (
+	"testing"
+)
+
+func TestQualifiedNamespace(t *testing.T) {
+	testcases := []struct {
+		description string
+		cluster     string
+		namespace   string
+		expected    string
+	}{
+		{
+			description: "no cluster name",
+			cluster:     "",
+			namespace:   "default",
+			expected:    "default",
+		},
+		{
+			description: "cluster name",
+			cluster:     "east",
+			namespace:   "default",
+			expected:    "east/default",
+		},
+	}
+
+	for _, tc := range testcases {
+		result := QualifiedNamespace(tc.cluster, tc.namespace)
+		if result != tc.expected {
+			t.Errorf("[%s] expected %s, got %s", tc.description, tc.expected, result)
+		}
+		cluster, namespace := SplitNamespace(result)
+		if cluster != tc.cluster || namespace != tc.namespace {
+			t.Errorf("[%s] expected %s and %s from %s, got %s and %s", tc.description, tc.cluster, tc.namespace, result, cluster, namespace)
+		}
+	}
+}
+
+func TestValidateClusters(t *testing.T) {
+	testcases := []struct {
+		description string
+		clusters    []Config
+		shouldPass  bool
+	}{
+		{
+			description: "no clusters",
+			clusters:    []Config{},
+			shouldPass:  true,
+		},
+		{
+			description: "unique names",
+			clusters:    []Config{{Name: "east"}, {Name: "west"}},
+			shouldPass:  true,
+		},
+		{
+			description: "missing name",
+			clusters:    []Config{{Name: "east"}, {Kubeconfig: "/etc/west"}},
+			shouldPass:  false,
+		},
+		{
+			description: "duplicate name",
+			clusters:    []Config{{Name: "east"}, {Name: "east"}},
+			shouldPass:  false,
+		},
+		{
+			description: "name with a slash",
+			clusters:    []Config{{Name: "east/1"}},
+			shouldPass:  false,
+		},
+	}
+
+	for _, tc := range testcases {
+		err := ValidateClusters(tc.clusters)
+		if err != nil && tc.shouldPass {
+			t.Errorf("[%s] unexpected error: %v", tc.description, err)
+		}
+		if err == nil && !tc.shouldPass {
+			t.Errorf("[%s] expected an error", tc.description)
+		}
+	}
+}
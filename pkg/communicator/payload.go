@@ -0,0 +1,103 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package communicator
+
+import (
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+// Pod is a perceptor pod tagged with the cluster it runs in, the container
+// runtime that runs it and the pull secrets of its images
+type Pod struct {
+	perceptorapi.Pod
+	ClusterID   string       `json:",omitempty"`
+	Runtime     string       `json:",omitempty"`
+	PullSecrets []PullSecret `json:",omitempty"`
+}
+
+// PullSecret names the secret perceptor can pull the image of a container
+// with.  Only the reference is sent, never the credentials
+type PullSecret struct {
+	Container string
+	Registry  string
+	Namespace string
+	Name      string
+}
+
+// NewPullSecret creates a new PullSecret object
+func NewPullSecret(container string, registry string, namespace string, name string) *PullSecret {
+	return &PullSecret{Container: container, Registry: registry, Namespace: namespace, Name: name}
+}
+
+// NewPod creates a new Pod object
+func NewPod(clusterID string, pod perceptorapi.Pod) *Pod {
+	return &Pod{Pod: pod, ClusterID: clusterID}
+}
+
+// Image is a perceptor image tagged with the cluster it was found in.  An
+// image that is one platform of a manifest list also names its platform
+// and the sha of the list
+type Image struct {
+	perceptorapi.Image
+	ClusterID string `json:",omitempty"`
+	Platform  string `json:",omitempty"`
+	IndexSha  string `json:",omitempty"`
+}
+
+// NewImage creates a new Image object
+func NewImage(clusterID string, image perceptorapi.Image) *Image {
+	return &Image{Image: image, ClusterID: clusterID}
+}
+
+// NewPlatformImage creates a new Image object for the manifest of one
+// platform of the manifest list with the sha indexSha
+func NewPlatformImage(image perceptorapi.Image, platform string, indexSha string) *Image {
+	return &Image{Image: image, Platform: platform, IndexSha: indexSha}
+}
+
+// AllPods holds every pod of a cluster.  Perceptor replaces every pod it
+// knows about with them, whatever their cluster
+type AllPods struct {
+	ClusterID string `json:",omitempty"`
+	Pods      []Pod
+}
+
+// NewAllPods creates a new AllPods object
+func NewAllPods(clusterID string, pods []Pod) *AllPods {
+	return &AllPods{ClusterID: clusterID, Pods: pods}
+}
+
+// AllImages holds every image of a cluster.  Perceptor replaces every
+// image it knows about with them, whatever their cluster
+type AllImages struct {
+	ClusterID string `json:",omitempty"`
+	Images    []Image
+}
+
+// NewAllImages creates a new AllImages object
+func NewAllImages(clusterID string, images []perceptorapi.Image) *AllImages {
+	allImages := &AllImages{ClusterID: clusterID, Images: []Image{}}
+	for _, image := range images {
+		allImages.Images = append(allImages.Images, *NewImage(clusterID, image))
+	}
+	return allImages
+}
@@ -0,0 +1,84 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package communicator
+
+import (
+	"encoding/json"
+	"testing"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+func TestPayloadSerialization(t *testing.T) {
+	pod := *perceptorapi.NewPod("name", "uid", "ns", []perceptorapi.Container{})
+	testcases := []struct {
+		description string
+		payload     interface{}
+		expected    string
+	}{
+		{
+			description: "pod without cluster",
+			payload:     NewPod("", pod),
+			expected:    `{"Name":"name","UID":"uid","Namespace":"ns","Containers":[]}`,
+		},
+		{
+			description: "pod with cluster",
+			payload:     NewPod("east", pod),
+			expected:    `{"Name":"name","UID":"uid","Namespace":"ns","Containers":[],"ClusterID":"east"}`,
+		},
+		{
+			description: "all pods with cluster",
+			payload:     NewAllPods("east", []Pod{*NewPod("east", pod)}),
+			expected:    `{"ClusterID":"east","Pods":[{"Name":"name","UID":"uid","Namespace":"ns","Containers":[],"ClusterID":"east"}]}`,
+		},
+		{
+			description: "pod with runtime",
+			payload:     &Pod{Pod: pod, Runtime: "containerd"},
+			expected:    `{"Name":"name","UID":"uid","Namespace":"ns","Containers":[],"Runtime":"containerd"}`,
+		},
+		{
+			description: "pod with pull secrets",
+			payload:     &Pod{Pod: pod, PullSecrets: []PullSecret{*NewPullSecret("app", "quay.io", "ns", "pull")}},
+			expected:    `{"Name":"name","UID":"uid","Namespace":"ns","Containers":[],"PullSecrets":[{"Container":"app","Registry":"quay.io","Namespace":"ns","Name":"pull"}]}`,
+		},
+		{
+			description: "platform image",
+			payload:     NewPlatformImage(perceptorapi.Image{Repository: "quay.io/team/app", Tag: "1.0", Sha: "sha"}, "linux/arm64/v8", "indexsha"),
+			expected:    `{"Repository":"quay.io/team/app","Tag":"1.0","Sha":"sha","Priority":null,"BlackDuckProjectName":"","BlackDuckProjectVersion":"","Platform":"linux/arm64/v8","IndexSha":"indexsha"}`,
+		},
+		{
+			description: "all images without images",
+			payload:     NewAllImages("east", []perceptorapi.Image{}),
+			expected:    `{"ClusterID":"east","Images":[]}`,
+		},
+	}
+
+	for _, tc := range testcases {
+		bytes, err := json.Marshal(tc.payload)
+		if err != nil {
+			t.Fatalf("[%s] unexpected error: %v", tc.description, err)
+		}
+		if string(bytes) != tc.expected {
+			t.Errorf("[%s] expected %s, got %s", tc.description, tc.expected, string(bytes))
+		}
+	}
+}
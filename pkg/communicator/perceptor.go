@@ -27,8 +27,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
 )
 
+// ModelPath is the path of the model of perceptor, which has the pods it
+// knows about
+const ModelPath = "model"
+
 // SendPerceptorAddEvent sends an add event to perceptor at the dest endpoint
 func SendPerceptorAddEvent(dest string, obj interface{}) error {
 	jsonBytes, err := json.Marshal(obj)
@@ -68,6 +75,45 @@ func SendPerceptorDeleteEvent(dest string, name string) error {
 	return nil
 }
 
+// SendPerceptorPodDeleteEvent sends the delete event of a pod to perceptor
+// at the dest endpoint.  Perceptor deletes the pod with the qualified name
+// in the body, the UID is sent as the uid parameter so the delete of a pod
+// can be told apart from a pod created since with the same name
+func SendPerceptorPodDeleteEvent(dest string, qualifiedName string, uid string) error {
+	if len(uid) > 0 {
+		dest = fmt.Sprintf("%s?uid=%s", dest, url.QueryEscape(uid))
+	}
+	return SendPerceptorDeleteEvent(dest, qualifiedName)
+}
+
+// GetPerceptorPods gets the pods perceptor knows about from its model at
+// the provided url
+func GetPerceptorPods(modelURL string) ([]perceptorapi.Pod, error) {
+	resp, err := http.Get(modelURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to GET %s: %v", modelURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("failed to GET %s.  Got %d instead of 200", modelURL, resp.StatusCode)
+	}
+
+	model := struct {
+		CoreModel struct {
+			Pods map[string]perceptorapi.Pod
+		}
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&model)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode the model from %s: %v", modelURL, err)
+	}
+	pods := []perceptorapi.Pod{}
+	for _, pod := range model.CoreModel.Pods {
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
 // GetPerceptorScanResults will get scan results from the perceptor located at
 // the provided url
 func GetPerceptorScanResults(url string) ([]byte, error) {
@@ -22,27 +22,62 @@ under the License.
 package controller
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"path"
+	"strings"
 	"time"
 
+	"github.com/blackducksoftware/perceivers/pkg/checkpoint"
 	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/mapper"
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
 	utils "github.com/blackducksoftware/perceivers/pkg/utils"
 	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// ArtifactoryController handles watching images and sending them to perceptor
+// artModifiedLayout is how the time a manifest was modified is kept in the
+// checkpoint and compared by AQL
+const artModifiedLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// artMaxManifestFailures is how many lookups a manifest may fail in before
+// it is given up on, so the checkpoint can move past it
+const artMaxManifestFailures = 5
+
+// artManifests is what a lookup of an instance found.  Only the manifests
+// of the last lookup are kept, the others are behind the checkpoint
+type artManifests struct {
+	// sent is the digest of the manifests sent by path, a manifest that is
+	// modified without changing isn't sent again
+	sent map[string]string
+	// failures counts the lookups each manifest couldn't be sent in
+	failures map[string]int
+}
+
+// ArtifactoryController handles watching images and sending them to perceptor.
+// Each lookup only searches for the manifests modified since the last
+// successful one of the instance
 type ArtifactoryController struct {
 	perceptorURL  string
 	registryAuths []*utils.RegistryAuth
+	checkpoint    *checkpoint.Checkpoint
+	filter        *utils.ArtifactoryFilter
+	// manifests is what the last lookup of each instance found, by its URL
+	manifests map[string]*artManifests
 }
 
 // NewArtifactoryController creates a new ArtifactoryController object
-func NewArtifactoryController(perceptorURL string, credentials []*utils.RegistryAuth) *ArtifactoryController {
+func NewArtifactoryController(perceptorURL string, credentials []*utils.RegistryAuth, checkpoints *checkpoint.Checkpoint, filter *utils.ArtifactoryFilter) *ArtifactoryController {
 	return &ArtifactoryController{
 		perceptorURL:  perceptorURL,
 		registryAuths: credentials,
+		checkpoint:    checkpoints,
+		filter:        filter,
+		manifests:     map[string]*artManifests{},
 	}
 }
 
@@ -56,7 +91,9 @@ func (ic *ArtifactoryController) Run(interval time.Duration, stopCh <-chan struc
 		default:
 		}
 
+		lookupStart := time.Now()
 		err := ic.imageLookup()
+		metrics.RecordReconcile("artifactory_controller", err == nil, time.Now().Sub(lookupStart))
 		if err != nil {
 			log.Errorf("Controller: failed to add artifactory images to scan queue: %v", err)
 		}
@@ -67,6 +104,7 @@ func (ic *ArtifactoryController) Run(interval time.Duration, stopCh <-chan struc
 
 func (ic *ArtifactoryController) imageLookup() error {
 	log.Infof("Controller: Total %d private registries credentials found!", len(ic.registryAuths))
+	failed := 0
 	for _, registry := range ic.registryAuths {
 
 		cred, err := utils.PingArtifactoryServer("https://"+registry.URL, registry.User, registry.Password)
@@ -75,64 +113,176 @@ func (ic *ArtifactoryController) imageLookup() error {
 			continue
 		}
 
-		dockerRepos := &utils.ArtDockerRepo{}
-		images := &utils.ArtImages{}
-		imageTags := &utils.ArtImageTags{}
-		imageSHAs := &utils.ArtImageSHAs{}
-
-		url := fmt.Sprintf("%s/api/repositories?packageType=docker", cred.URL)
-		err = utils.GetResourceOfType(url, cred, "", dockerRepos)
+		err = ic.manifestLookup(registry, cred)
 		if err != nil {
-			log.Errorf("Controller: Error in getting docker repo: %e", err)
+			log.Errorf("Controller: Error in looking up manifests in artifactory instance %s: %v", registry.URL, err)
+			failed = failed + 1
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("unable to look up %d of %d artifactory instances", failed, len(ic.registryAuths))
+	}
+	return nil
+}
+
+// manifestLookup sends the manifests of an instance modified since its
+// checkpoint.  The checkpoint moves up to the first manifest that couldn't
+// be sent, which is tried again by the next lookups until it was tried
+// artMaxManifestFailures times
+func (ic *ArtifactoryController) manifestLookup(artifactory *utils.RegistryAuth, cred *utils.RegistryAuth) error {
+	repoTypes, err := utils.GetArtifactoryDockerRepos(cred)
+	if err != nil {
+		return fmt.Errorf("unable to get docker repos: %v", err)
+	}
+
+	since, err := ic.checkpoint.Get(artifactory.URL)
+	if err != nil {
+		return err
+	}
+	// The checkpoint moves past the manifests the filter skips, so they are
+	// only found again by starting over when the filter changes
+	filterKey := artifactory.URL + " filter"
+	lastFilter, err := ic.checkpoint.Get(filterKey)
+	if err != nil {
+		return err
+	}
+	filter := ic.filterFingerprint()
+	if lastFilter != filter {
+		log.Infof("Controller: Repository filter of artifactory instance %s changed, looking up every manifest", artifactory.URL)
+		since = ""
+	}
+	items, err := utils.FindArtifactoryManifests(cred, since)
+	if err != nil {
+		return fmt.Errorf("unable to search for manifests modified after %s: %v", since, err)
+	}
+
+	last, ok := ic.manifests[artifactory.URL]
+	if !ok {
+		last = &artManifests{sent: map[string]string{}, failures: map[string]int{}}
+	}
+	found := &artManifests{sent: map[string]string{}, failures: map[string]int{}}
+	failed := 0
+	// firstFailed is when the first manifest that will be tried again was
+	// modified, the checkpoint stays before it
+	retry := false
+	firstFailed := ""
+	for _, item := range items {
+		repoKey := utils.ArtDockerRepoKey(item.Repo, repoTypes)
+		if len(repoKey) == 0 || !ic.filter.Matches(repoKey, repoTypes[repoKey], path.Dir(item.Path)) {
+			continue
+		}
+		itemURL := fmt.Sprintf("%s/%s/%s", artifactory.URL, item.Repo, item.Path)
+		if last.sent[itemURL] == item.Sha256 {
+			found.sent[itemURL] = item.Sha256
+			continue
+		}
+		err = ic.sendManifest(artifactory, cred, repoKey, item)
+		if err == nil {
+			found.sent[itemURL] = item.Sha256
 			continue
 		}
 
-		for _, repo := range *dockerRepos {
-			url = fmt.Sprintf("%s/api/docker/%s/v2/_catalog", cred.URL, repo.Key)
-			err = utils.GetResourceOfType(url, cred, "", images)
-			if err != nil {
-				log.Errorf("Controller: Error in getting catalog in repo: %e", err)
-				continue
-			}
-
-			for _, image := range images.Repositories {
-				url = fmt.Sprintf("%s/api/docker/%s/v2/%s/tags/list", cred.URL, repo.Key, image)
-				err = utils.GetResourceOfType(url, cred, "", imageTags)
-				if err != nil {
-					log.Errorf("Controller: Error in getting image: %e", err)
-					continue
-				}
-
-				for _, tag := range imageTags.Tags {
-					url = fmt.Sprintf("%s/api/storage/%s/%s/%s/manifest.json?properties=sha256", cred.URL, repo.Key, image, tag)
-					err = utils.GetResourceOfType(url, cred, "", imageSHAs)
-					if err != nil {
-						log.Errorf("Controller: Error in getting SHAs of the artifactory image: %e", err)
-						continue
-					}
-
-					for _, sha := range imageSHAs.Properties.Sha256 {
-
-						// Remove Tag & HTTPS because image model doesn't require it
-						url = fmt.Sprintf("%s/%s/%s", registry.URL, repo.Key, image)
-						priority := 1
-						artImage := perceptorapi.NewImage(url, tag, sha, &priority, url, tag)
-						imageURL := fmt.Sprintf("%s/%s", ic.perceptorURL, perceptorapi.ImagePath)
-						err = communicator.SendPerceptorAddEvent(imageURL, artImage)
-						if err != nil {
-							log.Errorf("Controller: Error putting artifactory image %v in perceptor queue %e", artImage, err)
-						} else {
-							log.Infof("Controller: Successfully put image %s with tag %s in perceptor queue", url, tag)
-						}
-
-					}
-				}
-			}
+		log.Errorf("Controller: %v", err)
+		failed = failed + 1
+		found.failures[itemURL] = last.failures[itemURL] + 1
+		if found.failures[itemURL] >= artMaxManifestFailures {
+			log.Errorf("Controller: Giving up on %s after %d failed lookups", itemURL, found.failures[itemURL])
+			continue
 		}
+		if modified := artModified(item); !retry || modified < firstFailed {
+			firstFailed = modified
+		}
+		retry = true
+	}
+	ic.manifests[artifactory.URL] = found
+	log.Infof("Controller: There were total %d manifests modified after %q in artifactory instance %s.", len(items), since, artifactory.URL)
 
-		log.Infof("Controller: There were total %d docker repositories found in artifactory instance %s.", len(images.Repositories), registry.URL)
+	latest := since
+	for _, item := range items {
+		modified := artModified(item)
+		if modified > latest && (!retry || modified < firstFailed) {
+			latest = modified
+		}
+	}
+	if latest != since {
+		err = ic.checkpoint.Set(artifactory.URL, latest)
+		if err != nil {
+			return err
+		}
+	}
+	if lastFilter != filter {
+		err = ic.checkpoint.Set(filterKey, filter)
+		if err != nil {
+			return err
+		}
+	}
 
+	// The manifests that weren't sent are searched for again by the next lookup
+	if failed > 0 {
+		return fmt.Errorf("unable to send %d of %d manifests", failed, len(items))
 	}
+	return nil
+}
 
+// artModified returns when an item was modified in the layout of the
+// checkpoint, which sorts like the times, or an empty string if it can't
+// be read
+func artModified(item utils.ArtItem) string {
+	modified, err := time.Parse(time.RFC3339Nano, item.Modified)
+	if err != nil {
+		return ""
+	}
+	return modified.UTC().Format(artModifiedLayout)
+}
+
+// filterFingerprint returns the filter as a string that changes with it
+func (ic *ArtifactoryController) filterFingerprint() string {
+	if ic.filter.IsEmpty() {
+		return ""
+	}
+	filter, _ := json.Marshal(ic.filter)
+	return fmt.Sprintf("%x", sha256.Sum256(filter))
+}
+
+// sendManifest sends the images of the manifest of a tag to perceptor.  The
+// digest of a manifest is the sha256 of the file, only the platforms of a
+// manifest list need to be read
+func (ic *ArtifactoryController) sendManifest(artifactory *utils.RegistryAuth, cred *utils.RegistryAuth, repoKey string, item utils.ArtItem) error {
+	slash := strings.LastIndex(item.Path, "/")
+	if slash <= 0 {
+		return nil
+	}
+	image, tag := item.Path[:slash], item.Path[slash+1:]
+	// The platforms of a manifest list are kept by digest, they are sent with the list
+	if strings.HasPrefix(tag, "sha256__") {
+		return nil
+	}
+
+	manifest := &registry.Manifest{Digest: fmt.Sprintf("sha256:%s", item.Sha256)}
+	if item.Name == utils.ArtManifestListName {
+		url := fmt.Sprintf("%s/api/docker/%s/v2/%s/manifests/%s", cred.URL, repoKey, image, tag)
+		var err error
+		manifest, err = utils.GetManifest(url, cred, "")
+		if err != nil {
+			return fmt.Errorf("Error in getting manifest list of the artifactory image %s:%s: %v", image, tag, err)
+		}
+	}
+
+	// Remove Tag & HTTPS because image model doesn't require it
+	url := fmt.Sprintf("%s/%s/%s", artifactory.URL, repoKey, image)
+	artImages, err := mapper.NewPerceptorImagesFromManifest(url, tag, manifest)
+	if err != nil {
+		return err
+	}
+
+	for _, artImage := range artImages {
+		imageURL := fmt.Sprintf("%s/%s", ic.perceptorURL, perceptorapi.ImagePath)
+		err = communicator.SendPerceptorAddEvent(imageURL, artImage)
+		if err != nil {
+			return fmt.Errorf("Error putting artifactory image %v in perceptor queue %e", artImage, err)
+		}
+		log.Infof("Controller: Successfully put image %s with tag %s in perceptor queue", url, tag)
+	}
 	return nil
 }
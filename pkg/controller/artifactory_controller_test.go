@@ -0,0 +1,160 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/blackducksoftware/perceivers/pkg/checkpoint"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+)
+
+// artModifiedAfter matches the time an AQL search is for manifests
+// modified after
+var artModifiedAfter = regexp.MustCompile(`"\$gt":"([^"]*)"`)
+
+// newFakeArtifactory is a stand-in for artifactory with a local docker
+// repository that has the items.  Searches only find the items modified
+// after the time they are for
+func newFakeArtifactory(items []utils.ArtItem) *utils.FakeAPI {
+	fa := &utils.FakeAPI{}
+	fa.Reply(http.MethodGet, "/api/repositories", utils.ArtDockerRepo{{Key: "docker-local", Type: utils.ArtLocalRepository}})
+	fa.Handle(http.MethodPost, "/api/search/aql", func(w http.ResponseWriter, r *http.Request) {
+		query, _ := ioutil.ReadAll(r.Body)
+		since := ""
+		if match := artModifiedAfter.FindSubmatch(query); match != nil {
+			since = string(match[1])
+		}
+		found := utils.ArtItems{Results: []utils.ArtItem{}}
+		for _, item := range items {
+			if artModified(item) > since {
+				found.Results = append(found.Results, item)
+			}
+		}
+		json.NewEncoder(w).Encode(found)
+	})
+	return fa
+}
+
+func TestArtifactoryManifestLookup(t *testing.T) {
+	shaA := "1111111111111111111111111111111111111111111111111111111111111111"
+	shaB := "2222222222222222222222222222222222222222222222222222222222222222"
+	shaC := "3333333333333333333333333333333333333333333333333333333333333333"
+	items := []utils.ArtItem{
+		{Repo: "docker-local", Path: "team/a/1.0", Name: utils.ArtManifestName, Sha256: shaA, Modified: "2019-01-01T10:00:00.000Z"},
+		{Repo: "docker-local", Path: "team/b/1.0", Name: utils.ArtManifestName, Sha256: shaB, Modified: "2019-01-01T11:00:00.000Z"},
+		{Repo: "docker-local", Path: "team/c/1.0", Name: utils.ArtManifestName, Sha256: shaC, Modified: "2019-01-01T12:00:00.000Z"},
+	}
+	server := httptest.NewServer(newFakeArtifactory(items))
+	defer server.Close()
+	cred := &utils.RegistryAuth{URL: server.URL}
+
+	testcases := []struct {
+		description string
+		failing     bool
+		shas        []string
+		checkpoint  string
+		// tracked is how many manifests the controller remembers, only
+		// those of the last search
+		tracked int
+	}{
+		{
+			description: "a manifest that can't be sent holds the checkpoint before it",
+			failing:     true,
+			shas:        []string{shaA, shaC},
+			checkpoint:  "2019-01-01T10:00:00.000Z",
+			tracked:     3,
+		},
+		{
+			description: "a sent manifest after the failed one isn't sent again",
+			failing:     true,
+			shas:        []string{},
+			checkpoint:  "2019-01-01T10:00:00.000Z",
+			tracked:     2,
+		},
+		{
+			description: "the failed manifest is sent once it can be",
+			failing:     false,
+			shas:        []string{shaB},
+			checkpoint:  "2019-01-01T12:00:00.000Z",
+			tracked:     2,
+		},
+	}
+
+	checkpoints, _ := checkpoint.NewCheckpoint(checkpoint.Config{}, "artifactory-perceiver", nil)
+	ic := NewArtifactoryController("", []*utils.RegistryAuth{cred}, checkpoints, nil)
+	for _, tc := range testcases {
+		fp := utils.NewFakePerceptor()
+		fp.Failing[shaB] = tc.failing
+		perceptor := httptest.NewServer(fp)
+		ic.perceptorURL = perceptor.URL
+
+		err := ic.manifestLookup(cred, cred)
+		perceptor.Close()
+		if (err == nil) == tc.failing {
+			t.Errorf("[%s] expected failure %t, got %v", tc.description, tc.failing, err)
+		}
+		if shas := fp.Shas(); !reflect.DeepEqual(shas, tc.shas) {
+			t.Errorf("[%s] expected images %v, got %v", tc.description, tc.shas, shas)
+		}
+		if since, _ := checkpoints.Get(cred.URL); since != tc.checkpoint {
+			t.Errorf("[%s] expected checkpoint %s, got %s", tc.description, tc.checkpoint, since)
+		}
+		found := ic.manifests[cred.URL]
+		if tracked := len(found.sent) + len(found.failures); tracked != tc.tracked {
+			t.Errorf("[%s] expected %d tracked manifests, got %d", tc.description, tc.tracked, tracked)
+		}
+	}
+}
+
+func TestArtifactoryManifestGivenUp(t *testing.T) {
+	sha := "2222222222222222222222222222222222222222222222222222222222222222"
+	items := []utils.ArtItem{
+		{Repo: "docker-local", Path: "team/b/1.0", Name: utils.ArtManifestName, Sha256: sha, Modified: "2019-01-01T11:00:00.000Z"},
+	}
+	server := httptest.NewServer(newFakeArtifactory(items))
+	defer server.Close()
+	cred := &utils.RegistryAuth{URL: server.URL}
+	fp := utils.NewFakePerceptor()
+	fp.Failing[sha] = true
+	perceptor := httptest.NewServer(fp)
+	defer perceptor.Close()
+
+	checkpoints, _ := checkpoint.NewCheckpoint(checkpoint.Config{}, "artifactory-perceiver", nil)
+	ic := NewArtifactoryController(perceptor.URL, []*utils.RegistryAuth{cred}, checkpoints, nil)
+	for lookup := 1; lookup <= artMaxManifestFailures; lookup++ {
+		ic.manifestLookup(cred, cred)
+		since, _ := checkpoints.Get(cred.URL)
+		if lookup < artMaxManifestFailures && len(since) > 0 {
+			t.Errorf("[lookup %d] expected the checkpoint to stay before the failed manifest, got %s", lookup, since)
+		}
+		if lookup == artMaxManifestFailures && since != items[0].Modified {
+			t.Errorf("[lookup %d] expected the checkpoint to move past the given up manifest, got %s", lookup, since)
+		}
+	}
+}
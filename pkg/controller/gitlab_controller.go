@@ -0,0 +1,140 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/mapper"
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// GitLabController handles walking the container repositories of GitLab
+// projects and sending their images to perceptor
+type GitLabController struct {
+	perceptorURL  string
+	registryAuths []*utils.RegistryAuth
+	client        *registry.Client
+}
+
+// NewGitLabController creates a new GitLabController object.  The client
+// reaches the container registry of GitLab for the platforms of manifest
+// lists
+func NewGitLabController(perceptorURL string, credentials []*utils.RegistryAuth, client *registry.Client) *GitLabController {
+	return &GitLabController{
+		perceptorURL:  perceptorURL,
+		registryAuths: credentials,
+		client:        client,
+	}
+}
+
+// Run starts a controller that walks the projects and sends their images
+// to perceptor
+func (gc *GitLabController) Run(interval time.Duration, stopCh <-chan struct{}) {
+	log.Infof("Controller: starting gitlab controller")
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		lookupStart := time.Now()
+		err := gc.imageLookup()
+		metrics.RecordReconcile("gitlab_controller", err == nil, time.Now().Sub(lookupStart))
+		if err != nil {
+			log.Errorf("Controller: failed to add gitlab images to scan queue: %v", err)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (gc *GitLabController) imageLookup() error {
+	log.Infof("Controller: Total %d private registries credentials found!", len(gc.registryAuths))
+	for _, auth := range gc.registryAuths {
+		cred, err := utils.PingGitLabServer("https://"+auth.URL, auth.User, auth.Password, auth.Token)
+		if err != nil {
+			log.Debugf("Controller: URL %s either not a valid GitLab instance or incorrect token: %e", auth.URL, err)
+			continue
+		}
+
+		projects, err := utils.GetGitLabProjects(cred)
+		if err != nil {
+			log.Errorf("Controller: Error in getting gitlab projects: %e", err)
+			continue
+		}
+		for _, project := range projects {
+			gc.ProjectLookup(cred, project.ID)
+		}
+
+		log.Infof("Controller: There were total %d projects found in gitlab instance %s.", len(projects), auth.URL)
+	}
+
+	return nil
+}
+
+// ProjectLookup sends the images of the container repositories of a
+// project to perceptor.  cred is the one PingGitLabServer returned
+func (gc *GitLabController) ProjectLookup(cred *utils.RegistryAuth, projectID int64) {
+	repositories, err := utils.GetGitLabRepositories(cred, projectID)
+	if err != nil {
+		log.Errorf("Controller: Error in getting container repositories of gitlab project %d: %e", projectID, err)
+		return
+	}
+
+	for _, repository := range repositories {
+		for _, tag := range repository.Tags {
+			manifest, err := utils.GetGitLabManifest(gc.client, cred, &repository, tag.Name)
+			if err != nil {
+				log.Errorf("Controller: Error in getting manifest of %s:%s: %v", repository.Location, tag.Name, err)
+				continue
+			}
+			gc.sendImages(repository.Location, tag.Name, manifest)
+		}
+	}
+}
+
+func (gc *GitLabController) sendImages(repository string, tag string, manifest *registry.Manifest) {
+	images, err := mapper.NewPerceptorImagesFromManifest(repository, tag, manifest)
+	if err != nil {
+		log.Errorf("Controller: %v", err)
+		return
+	}
+
+	imageURL := fmt.Sprintf("%s/%s", gc.perceptorURL, perceptorapi.ImagePath)
+	for _, image := range images {
+		err = communicator.SendPerceptorAddEvent(imageURL, image)
+		if err != nil {
+			log.Errorf("Controller: Error putting gitlab image %v in perceptor queue %e", image, err)
+		} else {
+			log.Infof("Controller: Successfully put image %s with tag %s in perceptor queue", repository, tag)
+		}
+	}
+}
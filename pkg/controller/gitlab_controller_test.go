@@ -0,0 +1,104 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+)
+
+// newFakeGitLab is a stand-in for the gitlab API of a project with the
+// container repository team/app in the registry at registryHost.  The tag
+// 1.0 is an image, 2.0 is a manifest list and gone was deleted since the
+// repository was listed
+func newFakeGitLab(registryHost string) *utils.FakeAPI {
+	fg := &utils.FakeAPI{Token: "token"}
+	fg.Reply(http.MethodGet, "/api/v4/version", map[string]string{"version": "12.10.0"})
+	fg.Reply(http.MethodGet, "/api/v4/projects", []utils.GitLabProject{{ID: 7, PathWithNamespace: "team/app"}})
+	fg.Reply(http.MethodGet, "/api/v4/projects/7/registry/repositories", []utils.GitLabRepository{{
+		ID:        3,
+		Path:      "team/app",
+		ProjectID: 7,
+		Location:  registryHost + "/team/app",
+		Tags:      []utils.GitLabTag{{Name: "1.0"}, {Name: "2.0"}, {Name: "gone"}},
+	}})
+	tags := "/api/v4/projects/7/registry/repositories/3/tags/"
+	fg.Reply(http.MethodGet, tags+"1.0", utils.GitLabTag{Name: "1.0", Digest: testDigest})
+	fg.Reply(http.MethodGet, tags+"2.0", utils.GitLabTag{Name: "2.0", Digest: testListDigest})
+	return fg
+}
+
+// newFakeContainerRegistry is a stand-in for the registry API of the
+// container registry of gitlab, with the image and manifest list of the
+// repository team/app
+func newFakeContainerRegistry() *utils.FakeAPI {
+	fr := &utils.FakeAPI{}
+	fr.Handle(http.MethodHead, "/v2/team/app/manifests/"+testDigest, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", registry.MediaTypeManifest)
+		w.Header().Set("Docker-Content-Digest", testDigest)
+	})
+	list := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", registry.MediaTypeManifestList)
+		w.Header().Set("Docker-Content-Digest", testListDigest)
+		w.Write([]byte(testManifestList))
+	}
+	fr.Handle(http.MethodHead, "/v2/team/app/manifests/"+testListDigest, list)
+	fr.Handle(http.MethodGet, "/v2/team/app/manifests/"+testListDigest, list)
+	return fr
+}
+
+func TestGitLabImageLookup(t *testing.T) {
+	containerRegistry := httptest.NewServer(newFakeContainerRegistry())
+	defer containerRegistry.Close()
+	registryHost := strings.TrimPrefix(containerRegistry.URL, "http://")
+	gitLab := httptest.NewServer(newFakeGitLab(registryHost))
+	defer gitLab.Close()
+	fp := utils.NewFakePerceptor()
+	perceptor := httptest.NewServer(fp)
+	defer perceptor.Close()
+
+	auths := []*utils.RegistryAuth{
+		{URL: strings.TrimPrefix(gitLab.URL, "http://"), Token: "token"},
+		// A token that isn't valid for the instance finds no projects
+		{URL: strings.TrimPrefix(gitLab.URL, "http://"), Token: "expired"},
+	}
+	gc := NewGitLabController(perceptor.URL, auths, registry.NewClient([]string{registryHost}, 5*time.Second))
+	if err := gc.imageLookup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The deleted tag is skipped
+	expected := []string{
+		registryHost + "/team/app:1.0 " + strings.TrimPrefix(testDigest, "sha256:"),
+		registryHost + "/team/app:2.0 linux/amd64 " + strings.TrimPrefix(testAmd64Digest, "sha256:"),
+		registryHost + "/team/app:2.0 linux/arm64 " + strings.TrimPrefix(testArm64Digest, "sha256:"),
+	}
+	if images := perceptorImages(fp); !reflect.DeepEqual(images, expected) {
+		t.Errorf("expected images %v, got %v", expected, images)
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/mapper"
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HarborController handles listing the images of Harbor and sending them
+// to perceptor
+type HarborController struct {
+	perceptorURL  string
+	registryAuths []*utils.RegistryAuth
+}
+
+// NewHarborController creates a new HarborController object
+func NewHarborController(perceptorURL string, credentials []*utils.RegistryAuth) *HarborController {
+	return &HarborController{
+		perceptorURL:  perceptorURL,
+		registryAuths: credentials,
+	}
+}
+
+// Run starts a controller that lists images and sends them to perceptor
+func (hc *HarborController) Run(interval time.Duration, stopCh <-chan struct{}) {
+	log.Infof("Controller: starting harbor controller")
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		lookupStart := time.Now()
+		err := hc.imageLookup()
+		metrics.RecordReconcile("harbor_controller", err == nil, time.Now().Sub(lookupStart))
+		if err != nil {
+			log.Errorf("Controller: failed to add harbor images to scan queue: %v", err)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (hc *HarborController) imageLookup() error {
+	log.Infof("Controller: Total %d private registries credentials found!", len(hc.registryAuths))
+	for _, registry := range hc.registryAuths {
+
+		cred, err := utils.PingHarborServer("https://"+registry.URL, registry.User, registry.Password)
+		if err != nil {
+			log.Debugf("Controller: URL %s either not a valid Harbor registry or incorrect credentials: %e", registry.URL, err)
+			continue
+		}
+
+		repos := 0
+		for page := 1; ; page++ {
+			projects := []utils.HarborProject{}
+			url := fmt.Sprintf("%s/api/v2.0/projects?page=%d&page_size=%d", cred.URL, page, utils.HarborPageSize)
+			err = utils.GetResourceOfType(url, cred, "", &projects)
+			if err != nil {
+				log.Errorf("Controller: Error in getting harbor projects: %e", err)
+				break
+			}
+
+			for _, project := range projects {
+				repos = repos + hc.projectLookup(registry, cred, project)
+			}
+			if len(projects) < utils.HarborPageSize {
+				break
+			}
+		}
+
+		log.Infof("Controller: There were total %d repositories found in harbor instance %s.", repos, registry.URL)
+	}
+
+	return nil
+}
+
+// projectLookup sends the tagged images of the repositories of a project
+// to perceptor and returns how many repositories there were
+func (hc *HarborController) projectLookup(registry *utils.RegistryAuth, cred *utils.RegistryAuth, project utils.HarborProject) int {
+	repos := 0
+	for page := 1; ; page++ {
+		repositories := []utils.HarborRepository{}
+		url := fmt.Sprintf("%s/api/v2.0/projects/%s/repositories?page=%d&page_size=%d", cred.URL, project.Name, page, utils.HarborPageSize)
+		err := utils.GetResourceOfType(url, cred, "", &repositories)
+		if err != nil {
+			log.Errorf("Controller: Error in getting repositories of harbor project %s: %e", project.Name, err)
+			return repos
+		}
+
+		for _, repository := range repositories {
+			hc.repositoryLookup(registry, cred, repository)
+		}
+		repos = repos + len(repositories)
+		if len(repositories) < utils.HarborPageSize {
+			return repos
+		}
+	}
+}
+
+// repositoryLookup sends the tagged images of a repository to perceptor
+func (hc *HarborController) repositoryLookup(registry *utils.RegistryAuth, cred *utils.RegistryAuth, repository utils.HarborRepository) {
+	artifactsURL, err := utils.HarborArtifactsURL(cred.URL, repository.Name)
+	if err != nil {
+		log.Errorf("Controller: %v", err)
+		return
+	}
+
+	for page := 1; ; page++ {
+		artifacts := []utils.HarborArtifact{}
+		url := fmt.Sprintf("%s?with_tag=true&page=%d&page_size=%d", artifactsURL, page, utils.HarborPageSize)
+		err = utils.GetResourceOfType(url, cred, "", &artifacts)
+		if err != nil {
+			log.Errorf("Controller: Error in getting artifacts of harbor repository %s: %e", repository.Name, err)
+			return
+		}
+
+		for _, artifact := range artifacts {
+			// Charts aren't scanned, and the untagged platform manifests
+			// of a manifest list are sent with the list
+			if artifact.Type != utils.HarborImageType {
+				continue
+			}
+			for _, tag := range artifact.Tags {
+				hc.sendArtifact(fmt.Sprintf("%s/%s", registry.URL, repository.Name), tag.Name, &artifact)
+			}
+		}
+		if len(artifacts) < utils.HarborPageSize {
+			return
+		}
+	}
+}
+
+func (hc *HarborController) sendArtifact(repository string, tag string, artifact *utils.HarborArtifact) {
+	images, err := mapper.NewPerceptorImagesFromManifest(repository, tag, artifact.Manifest())
+	if err != nil {
+		log.Errorf("Controller: %v", err)
+		return
+	}
+
+	imageURL := fmt.Sprintf("%s/%s", hc.perceptorURL, perceptorapi.ImagePath)
+	for _, image := range images {
+		err = communicator.SendPerceptorAddEvent(imageURL, image)
+		if err != nil {
+			log.Errorf("Controller: Error putting harbor image %v in perceptor queue %e", image, err)
+		} else {
+			log.Infof("Controller: Successfully put image %s with tag %s in perceptor queue", repository, tag)
+		}
+	}
+}
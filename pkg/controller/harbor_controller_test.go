@@ -0,0 +1,96 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+)
+
+// newFakeHarbor is a stand-in for the harbor API of a project with the
+// repository team/apps/web, which has an image tagged 1.0 and latest, a
+// manifest list, an untagged platform manifest and a chart
+func newFakeHarbor() *utils.FakeAPI {
+	fh := &utils.FakeAPI{}
+	fh.Reply(http.MethodGet, "/api/v2.0/projects", []utils.HarborProject{{ProjectID: 1, Name: "team"}})
+	fh.Reply(http.MethodGet, "/api/v2.0/projects/team/repositories", []utils.HarborRepository{{ID: 1, ProjectID: 1, Name: "team/apps/web"}})
+	fh.Reply(http.MethodGet, "/api/v2.0/projects/team/repositories/apps%252Fweb/artifacts", []utils.HarborArtifact{
+		{
+			Type:              utils.HarborImageType,
+			Digest:            testDigest,
+			ManifestMediaType: registry.MediaTypeManifest,
+			Tags:              []utils.HarborTag{{Name: "1.0"}, {Name: "latest"}},
+		},
+		{
+			Type:              utils.HarborImageType,
+			Digest:            testListDigest,
+			ManifestMediaType: registry.MediaTypeManifestList,
+			Tags:              []utils.HarborTag{{Name: "2.0"}},
+			References: []utils.HarborReference{
+				{ChildDigest: testAmd64Digest, Platform: &registry.Platform{OS: "linux", Architecture: "amd64"}},
+				{ChildDigest: testArm64Digest, Platform: &registry.Platform{OS: "linux", Architecture: "arm64"}},
+			},
+		},
+		{
+			Type:              utils.HarborImageType,
+			Digest:            testAmd64Digest,
+			ManifestMediaType: registry.MediaTypeManifest,
+		},
+		{
+			Type:   "CHART",
+			Digest: "sha256:4444444444444444444444444444444444444444444444444444444444444444",
+			Tags:   []utils.HarborTag{{Name: "0.1.0"}},
+		},
+	})
+	return fh
+}
+
+func TestHarborImageLookup(t *testing.T) {
+	server := httptest.NewServer(newFakeHarbor())
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	fp := utils.NewFakePerceptor()
+	perceptor := httptest.NewServer(fp)
+	defer perceptor.Close()
+
+	hc := NewHarborController(perceptor.URL, []*utils.RegistryAuth{{URL: host, User: "admin", Password: "password"}})
+	if err := hc.imageLookup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The chart and the untagged platform manifest aren't sent
+	sha := strings.TrimPrefix(testDigest, "sha256:")
+	expected := []string{
+		host + "/team/apps/web:1.0 " + sha,
+		host + "/team/apps/web:latest " + sha,
+		host + "/team/apps/web:2.0 linux/amd64 " + strings.TrimPrefix(testAmd64Digest, "sha256:"),
+		host + "/team/apps/web:2.0 linux/arm64 " + strings.TrimPrefix(testArm64Digest, "sha256:"),
+	}
+	if images := perceptorImages(fp); !reflect.DeepEqual(images, expected) {
+		t.Errorf("expected images %v, got %v", expected, images)
+	}
+}
@@ -61,15 +61,19 @@ type ImageController struct {
 	queue       workqueue.RateLimitingInterface
 
 	h annotations.ImageAnnotatorHandler
+
+	cluster string
 }
 
-// NewImageController creates a new ImageController object
-func NewImageController(oic *imageclient.ImageV1Client, perceptorURL string, handler annotations.ImageAnnotatorHandler) *ImageController {
+// NewImageController creates a new ImageController object.  If clusterName
+// isn't empty every image sent to perceptor is tagged with it
+func NewImageController(oic *imageclient.ImageV1Client, perceptorURL string, handler annotations.ImageAnnotatorHandler, clusterName string) *ImageController {
 	ic := ImageController{
 		client:   oic,
 		queue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Images"),
 		imageURL: fmt.Sprintf("%s/%s", perceptorURL, perceptorapi.ImagePath),
 		h:        handler,
+		cluster:  clusterName,
 	}
 	ic.indexer, ic.imageController = cache.NewIndexerInformer(
 		&cache.ListWatch{
@@ -109,6 +113,12 @@ func (ic *ImageController) Run(threadiness int, stopCh <-chan struct{}) {
 
 	go ic.imageController.Run(stopCh)
 
+	metrics.RecordInformerSynced("image_controller", false)
+	if !cache.WaitForCacheSync(stopCh, ic.imageController.HasSynced) {
+		return
+	}
+	metrics.RecordInformerSynced("image_controller", true)
+
 	// Start up your worker threads based on threadiness.  Some controllers have multiple kinds of workers
 	for i := 0; i < threadiness; i++ {
 		// runWorker will loop until "something bad" happens.  The .Until will then rekick the worker
@@ -153,7 +163,9 @@ func (ic *ImageController) processNextWorkItem() bool {
 
 	key := keyObj.(string)
 	// Do your work on the key.  This method will contains your "do stuff" logic
+	reconcileStart := time.Now()
 	err := ic.syncHandler(key)
+	metrics.RecordReconcile("image_controller", err == nil, time.Now().Sub(reconcileStart))
 	if err == nil {
 		// if you had no error, tell the queue to stop tracking history for your key.  This will
 		// reset things like failure counts for per-item rate limiting
@@ -203,7 +215,7 @@ func (ic *ImageController) processImage(key string) error {
 	if err != nil {
 		return fmt.Errorf("error converting image to perceptor image: %v", err)
 	}
-	err = communicator.SendPerceptorAddEvent(ic.imageURL, imageInfo)
+	err = communicator.SendPerceptorAddEvent(ic.imageURL, communicator.NewImage(ic.cluster, *imageInfo))
 	if err != nil {
 		metrics.RecordError("image_controller", "error sending image add event")
 	}
@@ -28,9 +28,9 @@ import (
 
 	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
 
-	"github.com/blackducksoftware/perceivers/pkg/mapper"
-	//	"github.com/blackducksoftware/perceivers/image/pkg/metrics"
 	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/mapper"
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -106,6 +106,12 @@ func (osisc *OSImageStreamController) Run(threadiness int, stopCh <-chan struct{
 
 	go osisc.imageController.Run(stopCh)
 
+	metrics.RecordInformerSynced("imagestream_controller", false)
+	if !cache.WaitForCacheSync(stopCh, osisc.imageController.HasSynced) {
+		return
+	}
+	metrics.RecordInformerSynced("imagestream_controller", true)
+
 	// start up your worker threads based on threadiness.  Some controllers have multiple kinds of workers
 	for i := 0; i < threadiness; i++ {
 		// runWorker will loop until "something bad" happens.  The .Until will then rekick the worker
@@ -140,13 +146,15 @@ func (osisc *OSImageStreamController) processNextWorkItem() bool {
 
 	key, ok := keyObj.(*imageapi.ImageStream)
 	if !ok {
-		//		metrics.RecordError("imagestream_controller", "key wasn't an imagestream")
+		metrics.RecordError("imagestream_controller", "key wasn't an imagestream")
 		utilruntime.HandleError(fmt.Errorf("key wasn't an imagestream"))
 		return true
 	}
 
 	// do your work on the key.  This method will contains your "do stuff" logic
+	reconcileStart := time.Now()
 	err := osisc.syncHandler(key)
+	metrics.RecordReconcile("imagestream_controller", err == nil, time.Now().Sub(reconcileStart))
 	if err == nil {
 		// if you had no error, tell the queue to stop tracking history for your key.  This will
 		// reset things like failure counts for per-item rate limiting
@@ -154,7 +162,7 @@ func (osisc *OSImageStreamController) processNextWorkItem() bool {
 		return true
 	}
 
-	//	metrics.RecordError("imagestream_controller", "unable to sync handler")
+	metrics.RecordError("imagestream_controller", "unable to sync handler")
 
 	// there was a failure so be sure to report it.  This method allows for pluggable error handling
 	// which can be used for things like cluster-monitoring
@@ -172,52 +180,50 @@ func (osisc *OSImageStreamController) processNextWorkItem() bool {
 func (osisc *OSImageStreamController) processImageStream(obj *imageapi.ImageStream) error {
 	errList := []string{}
 	// Get an updated version of this imagestream if it exists
-	//	getImageStream := time.Now()
+	getImageStream := time.Now()
 	is, err := osisc.imageStreamLister.ImageStreams(metav1.NamespaceAll).Get(obj.GetName())
-	//	metrics.RecordDuration("get image stream", time.Now().Sub(getImageStream))
-	if err != nil {
-		//		metrics.RecordError("imagestream_controller", "unable to get updated version of image stream")
-	}
+	metrics.RecordDuration("get image stream", time.Now().Sub(getImageStream))
 	if errors.IsNotFound(err) {
 		// ImageStream doesn't exist (anymore), so this is a delete event
 		images, err := osisc.getImagesFromImageStream(obj)
-		//		metrics.RecordError("imagestream_controller", "unable to get images from image stream #1")
 		if err != nil {
+			metrics.RecordError("imagestream_controller", "unable to get images from deleted image stream")
 			return err
 		}
 		for _, image := range images {
 			err = communicator.SendPerceptorDeleteEvent(osisc.imageURL, image.Repository)
-			//			metrics.RecordHTTPStats(osisc.imageURL, err == nil)
+			metrics.RecordHTTPStats(osisc.imageURL, err == nil)
 			if err != nil {
-				//				metrics.RecordError("imagestream_controller", "unable to send delete event")
+				metrics.RecordError("imagestream_controller", "unable to send delete event")
 				errList = append(errList, err.Error())
 			}
 		}
-		return fmt.Errorf(strings.Join(errList, ","))
+		return joinErrors(errList)
 	} else if err != nil {
+		metrics.RecordError("imagestream_controller", "unable to get updated version of image stream")
 		is = obj
 	}
 
 	images, err := osisc.getImagesFromImageStream(is)
 	if err != nil {
-		//		metrics.RecordError("imagestream_controller", "unable to get images from image stream #2")
+		metrics.RecordError("imagestream_controller", "unable to get images from image stream")
 		return err
 	}
 	for _, image := range images {
 		err = communicator.SendPerceptorAddEvent(osisc.imageURL, image)
-		//		metrics.RecordHTTPStats(osisc.imageURL, err == nil)
+		metrics.RecordHTTPStats(osisc.imageURL, err == nil)
 		if err != nil {
-			//			metrics.RecordError("imagestream_controller", "unable to send add event")
+			metrics.RecordError("imagestream_controller", "unable to send add event")
 			errList = append(errList, err.Error())
 		}
 	}
-	return fmt.Errorf(strings.Join(errList, ","))
+	return joinErrors(errList)
 }
 
 func (osisc *OSImageStreamController) getImagesFromImageStream(stream *imageapi.ImageStream) ([]*perceptorapi.Image, error) {
 	tags := stream.Status.Tags
 	if tags == nil {
-		//		metrics.RecordError("imagestream_controller", "image stream has no tags")
+		metrics.RecordError("imagestream_controller", "image stream has no tags")
 		return nil, fmt.Errorf("image stream %s has no tags", stream.GetName())
 	}
 
@@ -225,11 +231,11 @@ func (osisc *OSImageStreamController) getImagesFromImageStream(stream *imageapi.
 	images := []*perceptorapi.Image{}
 	for _, events := range tags {
 		ref := events.Items[0].Image
-		//		getImagesStart := time.Now()
+		getImagesStart := time.Now()
 		image, err := osisc.client.Images().Get(ref, metav1.GetOptions{})
-		//		metrics.RecordDuration("get images from image stream", time.Now().Sub(getImagesStart))
+		metrics.RecordDuration("get images from image stream", time.Now().Sub(getImagesStart))
 		if err != nil {
-			//			metrics.RecordError("imagestream_controller", "error getting image")
+			metrics.RecordError("imagestream_controller", "error getting image")
 			return nil, fmt.Errorf("error getting image %s@%s: %v", digest, ref, err)
 		}
 
@@ -239,3 +245,12 @@ func (osisc *OSImageStreamController) getImagesFromImageStream(stream *imageapi.
 	}
 	return images, nil
 }
+
+// joinErrors collapses the collected error messages into a single error,
+// returning nil when nothing failed so the work item isn't requeued
+func joinErrors(errList []string) error {
+	if len(errList) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(errList, ","))
+}
@@ -0,0 +1,172 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/mapper"
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// NexusController handles watching images and sending them to perceptor
+type NexusController struct {
+	perceptorURL  string
+	registryAuths []*utils.RegistryAuth
+}
+
+// NewNexusController creates a new NexusController object
+func NewNexusController(perceptorURL string, credentials []*utils.RegistryAuth) *NexusController {
+	return &NexusController{
+		perceptorURL:  perceptorURL,
+		registryAuths: credentials,
+	}
+}
+
+// Run starts a controller that watches images and sends them to perceptor
+func (nc *NexusController) Run(interval time.Duration, stopCh <-chan struct{}) {
+	log.Infof("Controller: starting nexus controller")
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		lookupStart := time.Now()
+		err := nc.imageLookup()
+		metrics.RecordReconcile("nexus_controller", err == nil, time.Now().Sub(lookupStart))
+		if err != nil {
+			log.Errorf("Controller: failed to add nexus images to scan queue: %v", err)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (nc *NexusController) imageLookup() error {
+	log.Infof("Controller: Total %d private registries credentials found!", len(nc.registryAuths))
+	for _, registry := range nc.registryAuths {
+
+		cred, err := utils.PingNexusServer("https://"+registry.URL, registry.User, registry.Password)
+		if err != nil {
+			log.Debugf("Controller: URL %s either not a valid Nexus repository or incorrect credentials: %e", registry.URL, err)
+			continue
+		}
+
+		repositories := []utils.NexusRepository{}
+		url := fmt.Sprintf("%s/service/rest/v1/repositories", cred.URL)
+		err = utils.GetResourceOfType(url, cred, "", &repositories)
+		if err != nil {
+			log.Errorf("Controller: Error in getting nexus repositories: %e", err)
+			continue
+		}
+
+		dockerRepos := 0
+		for _, repo := range repositories {
+			if repo.Format != utils.NexusDockerFormat {
+				continue
+			}
+			dockerRepos = dockerRepos + 1
+			nc.repositoryLookup(registry, cred, repo.Name)
+		}
+
+		log.Infof("Controller: There were total %d docker repositories found in nexus instance %s.", dockerRepos, registry.URL)
+	}
+
+	return nil
+}
+
+// repositoryLookup sends the images of the components of a docker
+// repository to perceptor, following the continuation tokens of the pages
+func (nc *NexusController) repositoryLookup(registry *utils.RegistryAuth, cred *utils.RegistryAuth, repo string) {
+	token := ""
+	for {
+		components := &utils.NexusComponents{}
+		componentsURL := fmt.Sprintf("%s/service/rest/v1/components?repository=%s", cred.URL, url.QueryEscape(repo))
+		if len(token) > 0 {
+			componentsURL = fmt.Sprintf("%s&continuationToken=%s", componentsURL, url.QueryEscape(token))
+		}
+		err := utils.GetResourceOfType(componentsURL, cred, "", components)
+		if err != nil {
+			log.Errorf("Controller: Error in getting components of nexus repository %s: %e", repo, err)
+			return
+		}
+
+		for _, component := range components.Items {
+			// The platform manifests of a manifest list are sent with the list
+			if utils.IsNexusDigestVersion(component.Version) {
+				continue
+			}
+			manifest, err := nc.componentManifest(cred, repo, &component)
+			if err != nil {
+				log.Errorf("Controller: Error in getting manifest of %s:%s: %e", component.Name, component.Version, err)
+				continue
+			}
+			nc.sendImages(fmt.Sprintf("%s/%s/%s", registry.URL, repo, component.Name), component.Version, manifest)
+		}
+
+		token = components.ContinuationToken
+		if len(token) == 0 {
+			return
+		}
+	}
+}
+
+// componentManifest returns the manifest of a docker component.  The
+// digest is the checksum of its manifest asset, only a manifest list is
+// downloaded for its platforms
+func (nc *NexusController) componentManifest(cred *utils.RegistryAuth, repo string, component *utils.NexusComponent) (*registry.Manifest, error) {
+	for _, asset := range component.Assets {
+		manifest := &registry.Manifest{MediaType: asset.ContentType, Digest: fmt.Sprintf("sha256:%s", asset.Checksum.Sha256)}
+		if len(asset.Checksum.Sha256) > 0 && !manifest.IsList() {
+			return manifest, nil
+		}
+	}
+	return utils.GetManifest(utils.NexusManifestURL(cred.URL, repo, component.Name, component.Version), cred, "")
+}
+
+func (nc *NexusController) sendImages(repository string, tag string, manifest *registry.Manifest) {
+	images, err := mapper.NewPerceptorImagesFromManifest(repository, tag, manifest)
+	if err != nil {
+		log.Errorf("Controller: %v", err)
+		return
+	}
+
+	imageURL := fmt.Sprintf("%s/%s", nc.perceptorURL, perceptorapi.ImagePath)
+	for _, image := range images {
+		err = communicator.SendPerceptorAddEvent(imageURL, image)
+		if err != nil {
+			log.Errorf("Controller: Error putting nexus image %v in perceptor queue %e", image, err)
+		} else {
+			log.Infof("Controller: Successfully put image %s with tag %s in perceptor queue", repository, tag)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+)
+
+// nexusAsset returns the manifest asset of a docker component
+func nexusAsset(contentType string, digest string) utils.NexusAsset {
+	asset := utils.NexusAsset{ContentType: contentType}
+	asset.Checksum.Sha256 = strings.TrimPrefix(digest, "sha256:")
+	return asset
+}
+
+// newFakeNexus is a stand-in for the nexus API of a docker repository with
+// two pages of components, the image team/app:1.0, the platform manifest
+// of the manifest list team/app:2.0 and the list, and a maven repository.
+// It records the manifests it was asked for
+func newFakeNexus() (*utils.FakeAPI, *[]string) {
+	fn := &utils.FakeAPI{}
+	requested := []string{}
+	fn.Reply(http.MethodGet, "/service/rest/v1/repositories", []utils.NexusRepository{
+		{Name: "docker-hosted", Format: utils.NexusDockerFormat, Type: "hosted"},
+		{Name: "maven-releases", Format: "maven2", Type: "hosted"},
+	})
+	fn.Handle(http.MethodGet, "/service/rest/v1/components", func(w http.ResponseWriter, r *http.Request) {
+		components := utils.NexusComponents{}
+		switch query := r.URL.Query(); {
+		case query.Get("repository") != "docker-hosted":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case query.Get("continuationToken") == "":
+			components.Items = []utils.NexusComponent{
+				{Repository: "docker-hosted", Format: utils.NexusDockerFormat, Name: "team/app", Version: "1.0", Assets: []utils.NexusAsset{nexusAsset(registry.MediaTypeManifest, testDigest)}},
+				{Repository: "docker-hosted", Format: utils.NexusDockerFormat, Name: "team/app", Version: testAmd64Digest, Assets: []utils.NexusAsset{nexusAsset(registry.MediaTypeManifest, testAmd64Digest)}},
+			}
+			components.ContinuationToken = "88491cd1d185dd136f143f20c4e7d50c"
+		case query.Get("continuationToken") == "88491cd1d185dd136f143f20c4e7d50c":
+			components.Items = []utils.NexusComponent{
+				{Repository: "docker-hosted", Format: utils.NexusDockerFormat, Name: "team/app", Version: "2.0", Assets: []utils.NexusAsset{nexusAsset(registry.MediaTypeManifestList, testListDigest)}},
+			}
+		}
+		json.NewEncoder(w).Encode(components)
+	})
+	fn.Handle(http.MethodGet, "/repository/docker-hosted/v2/team/app/manifests/*", func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		w.Header().Set("Content-Type", registry.MediaTypeManifestList)
+		w.Header().Set("Docker-Content-Digest", testListDigest)
+		w.Write([]byte(testManifestList))
+	})
+	return fn, &requested
+}
+
+func TestNexusImageLookup(t *testing.T) {
+	fn, requested := newFakeNexus()
+	server := httptest.NewServer(fn)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	fp := utils.NewFakePerceptor()
+	perceptor := httptest.NewServer(fp)
+	defer perceptor.Close()
+
+	nc := NewNexusController(perceptor.URL, []*utils.RegistryAuth{{URL: host, User: "admin", Password: "password"}})
+	if err := nc.imageLookup(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The platform manifest is sent with its list, which is the only
+	// manifest downloaded
+	expected := []string{
+		host + "/docker-hosted/team/app:1.0 " + strings.TrimPrefix(testDigest, "sha256:"),
+		host + "/docker-hosted/team/app:2.0 linux/amd64 " + strings.TrimPrefix(testAmd64Digest, "sha256:"),
+		host + "/docker-hosted/team/app:2.0 linux/arm64 " + strings.TrimPrefix(testArm64Digest, "sha256:"),
+	}
+	if images := perceptorImages(fp); !reflect.DeepEqual(images, expected) {
+		t.Errorf("expected images %v, got %v", expected, images)
+	}
+	if paths := []string{"/repository/docker-hosted/v2/team/app/manifests/2.0"}; !reflect.DeepEqual(*requested, paths) {
+		t.Errorf("expected manifests %v to be asked for, got %v", paths, *requested)
+	}
+}
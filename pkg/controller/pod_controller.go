@@ -23,12 +23,16 @@ package controller
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/blackducksoftware/perceivers/pkg/annotations"
+	"github.com/blackducksoftware/perceivers/pkg/cluster"
 	"github.com/blackducksoftware/perceivers/pkg/communicator"
 	"github.com/blackducksoftware/perceivers/pkg/mapper"
 	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	"github.com/blackducksoftware/perceivers/pkg/sharding"
 
 	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
 
@@ -61,15 +65,38 @@ type PodController struct {
 	queue       workqueue.RateLimitingInterface
 
 	h annotations.ImageAnnotatorHandler
+
+	shard    *sharding.Shard
+	cluster  string
+	resolver *registry.Resolver
+
+	// deletedUIDs is the UID of the deleted pods by key, until their delete
+	// event was sent
+	deletedUIDs     map[string]string
+	deletedUIDsLock sync.Mutex
 }
 
-// NewPodController creates a new PodController object
-func NewPodController(kubeClient kubernetes.Interface, perceptorURL string, nsFilter string, handler annotations.ImageAnnotatorHandler) *PodController {
+// NewPodController creates a new PodController object.  If shard isn't nil
+// only pods in the namespaces owned by the shard are processed.  If
+// clusterName isn't empty every pod sent to perceptor is tagged with it and
+// its namespace is qualified with it.  If resolver isn't nil pods are sent
+// as soon as they are created, with digests resolved through the registry
+func NewPodController(kubeClient kubernetes.Interface, perceptorURL string, nsFilter string, handler annotations.ImageAnnotatorHandler, shard *sharding.Shard, clusterName string, resolver *registry.Resolver) *PodController {
+	// Every cluster gets its own queue so their metrics can be told apart
+	queueName := "Pods"
+	if len(clusterName) > 0 {
+		queueName = fmt.Sprintf("Pods-%s", clusterName)
+	}
 	pc := PodController{
-		client: kubeClient,
-		queue:  workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Pods"),
-		podURL: fmt.Sprintf("%s/%s", perceptorURL, perceptorapi.PodPath),
-		h:      handler,
+		client:   kubeClient,
+		queue:    workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), queueName),
+		podURL:   fmt.Sprintf("%s/%s", perceptorURL, perceptorapi.PodPath),
+		h:        handler,
+		shard:    shard,
+		cluster:  clusterName,
+		resolver: resolver,
+
+		deletedUIDs: map[string]string{},
 	}
 
 	if nsFilter == "" {
@@ -95,13 +122,16 @@ func NewPodController(kubeClient kubernetes.Interface, perceptorURL string, nsFi
 					pc.enqueueJob(new)
 				}
 			},
-			DeleteFunc: pc.enqueueJob,
+			DeleteFunc: pc.enqueueDelete,
 		},
 		cache.Indexers{},
 	)
 	pc.podLister = v1lister.NewPodLister(pc.podIndexer)
 	pc.syncHandler = pc.processPod
 
+	// Pick up the pods of namespaces this replica took over
+	shard.OnChange(pc.enqueueAll)
+
 	return &pc
 }
 
@@ -113,9 +143,11 @@ func (pc *PodController) Run(threadiness int, stopCh <-chan struct{}) {
 
 	go pc.podController.Run(stopCh)
 
-	if !cache.WaitForCacheSync(stopCh, pc.podController.HasSynced) {
+	metrics.RecordInformerSynced("pod_controller", false)
+	if !cache.WaitForCacheSync(stopCh, pc.podController.HasSynced, pc.resolver.HasSynced) {
 		return
 	}
+	metrics.RecordInformerSynced("pod_controller", true)
 
 	// Start up your worker threads based on threadiness.  Some controllers have multiple kinds of workers
 	for i := 0; i < threadiness; i++ {
@@ -130,10 +162,60 @@ func (pc *PodController) Run(threadiness int, stopCh <-chan struct{}) {
 
 func (pc *PodController) enqueueJob(obj interface{}) {
 	key, err := cache.MetaNamespaceKeyFunc(obj)
-	if err == nil {
-		pc.queue.Add(key)
-	} else {
+	if err != nil {
+		metrics.RecordError("pod_controller", "unable to create key for enqueuing")
+		return
+	}
+	pc.enqueueKey(key)
+}
+
+// enqueueDelete keeps the UID of a deleted pod for its delete event
+func (pc *PodController) enqueueDelete(obj interface{}) {
+	pod, ok := obj.(*v1.Pod)
+	if tombstone, isTombstone := obj.(cache.DeletedFinalStateUnknown); isTombstone {
+		pod, ok = tombstone.Obj.(*v1.Pod)
+	}
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
 		metrics.RecordError("pod_controller", "unable to create key for enqueuing")
+		return
+	}
+	if ok {
+		pc.deletedUIDsLock.Lock()
+		pc.deletedUIDs[key] = string(pod.UID)
+		pc.deletedUIDsLock.Unlock()
+	}
+	pc.enqueueKey(key)
+}
+
+// deletedUID returns the UID of a deleted pod, if it is known
+func (pc *PodController) deletedUID(key string) string {
+	pc.deletedUIDsLock.Lock()
+	defer pc.deletedUIDsLock.Unlock()
+	return pc.deletedUIDs[key]
+}
+
+// forgetDeletedUID forgets the UID of a deleted pod
+func (pc *PodController) forgetDeletedUID(key string) {
+	pc.deletedUIDsLock.Lock()
+	defer pc.deletedUIDsLock.Unlock()
+	delete(pc.deletedUIDs, key)
+}
+
+func (pc *PodController) enqueueKey(key string) {
+	ns, _, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		metrics.RecordError("pod_controller", "unable to split key for enqueuing")
+		return
+	}
+	if pc.shard.Owns(cluster.QualifiedNamespace(pc.cluster, ns)) {
+		pc.queue.Add(key)
+	}
+}
+
+func (pc *PodController) enqueueAll() {
+	for _, key := range pc.podIndexer.ListKeys() {
+		pc.enqueueKey(key)
 	}
 }
 
@@ -162,7 +244,9 @@ func (pc *PodController) processNextWorkItem() bool {
 	key := keyObj.(string)
 
 	// Do your work on the key.  This method will contains your "do stuff" logic
+	reconcileStart := time.Now()
 	err := pc.syncHandler(key)
+	metrics.RecordReconcile("pod_controller", err == nil, time.Now().Sub(reconcileStart))
 	if err == nil {
 		// if you had no error, tell the queue to stop tracking history for your key.  This will
 		// reset things like failure counts for per-item rate limiting
@@ -192,30 +276,47 @@ func (pc *PodController) processPod(key string) error {
 		return fmt.Errorf("error getting name of pod %q to get pod from informer: %v", key, err)
 	}
 
+	// The key may have been queued before the namespace moved to another replica
+	if !pc.shard.Owns(cluster.QualifiedNamespace(pc.cluster, ns)) {
+		log.Debugf("skipping pod %s, its namespace is owned by another replica", key)
+		return nil
+	}
+
 	// Get the pod
 	getPodStart := time.Now()
 	pod, err := pc.podLister.Pods(ns).Get(name)
 	metrics.RecordDuration("get pod -- pod controller", time.Now().Sub(getPodStart))
 	if errors.IsNotFound(err) {
 		// Pod doesn't exist (anymore), so this is a delete event
-		err = communicator.SendPerceptorDeleteEvent(pc.podURL, name)
+		// perceptor deletes pods by namespace/name, the UID is kept until
+		// the event was sent
+		uid := pc.deletedUID(key)
+		err = communicator.SendPerceptorPodDeleteEvent(pc.podURL, fmt.Sprintf("%s/%s", cluster.QualifiedNamespace(pc.cluster, ns), name), uid)
 		if err != nil {
 			metrics.RecordError("pod_controller", "error sending pod delete event")
+			return err
 		}
-		return err
+		pc.forgetDeletedUID(key)
+		return nil
 	} else if err != nil {
 		metrics.RecordError("pod_controller", "error getting pod from informer")
 		return fmt.Errorf("error getting pod %s from informer: %v", name, err)
 	}
+	// A pod created again with the name replaces the deleted one
+	pc.forgetDeletedUID(key)
 
 	// Convert the pod from kubernetes to perceptor format and send to
 	// the perceptor
-	podInfo, err := mapper.NewPerceptorPodFromKubePod(pod)
+	podInfo, err := mapper.NewPerceptorPodFromKubePod(pod, pc.resolver)
 	if err != nil {
 		// This may or may not be a real error, but log anyway
 		return fmt.Errorf("Could not convert pod to perceptor pod: %v.  This pod will not be sent for processing", err)
 	}
-	err = communicator.SendPerceptorAddEvent(pc.podURL, podInfo)
+	podInfo.Namespace = cluster.QualifiedNamespace(pc.cluster, podInfo.Namespace)
+	payload := communicator.NewPod(pc.cluster, *podInfo)
+	payload.Runtime = mapper.PodRuntime(pod)
+	payload.PullSecrets = mapper.PodPullSecrets(pod, podInfo, pc.resolver)
+	err = communicator.SendPerceptorAddEvent(pc.podURL, payload)
 	if err != nil {
 		metrics.RecordError("pod_controller", "error sending pod add event")
 	}
@@ -0,0 +1,78 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+
+	"k8s.io/api/core/v1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPodDeleteEvent(t *testing.T) {
+	failing := true
+	deleted := []string{}
+	perceptor := &utils.FakeAPI{}
+	perceptor.Handle(http.MethodDelete, "/pod", func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var name string
+		json.NewDecoder(r.Body).Decode(&name)
+		deleted = append(deleted, fmt.Sprintf("%s %s", name, r.URL.Query().Get("uid")))
+	})
+	server := httptest.NewServer(perceptor)
+	defer server.Close()
+
+	pc := NewPodController(fake.NewSimpleClientset(), server.URL, "", nil, nil, "east", nil)
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns", UID: "uid-1"}}
+	pc.enqueueDelete(cache.DeletedFinalStateUnknown{Key: "ns/app", Obj: pod})
+
+	// The UID is kept until the delete event was sent
+	if err := pc.processPod("ns/app"); err == nil {
+		t.Errorf("expected the failed delete event to fail")
+	}
+	failing = false
+	if err := pc.processPod("ns/app"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := pc.processPod("ns/app"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expected := []string{"east/ns/app uid-1", "east/ns/app "}
+	if !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("expected deletes %v, got %v", expected, deleted)
+	}
+}
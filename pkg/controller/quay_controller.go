@@ -0,0 +1,181 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/mapper"
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// QuayController handles listing the images of quay and sending them to
+// perceptor, so images pushed while the webhook wasn't listening are scanned
+type QuayController struct {
+	perceptorURL  string
+	registryAuths []*utils.RegistryAuth
+	filter        *utils.RepositoryFilter
+	paging        *utils.QuayPaging
+}
+
+// NewQuayController creates a new QuayController object.  Only the
+// repositories the filter matches, by namespace/name, are sent
+func NewQuayController(perceptorURL string, credentials []*utils.RegistryAuth, filter *utils.RepositoryFilter, paging *utils.QuayPaging) *QuayController {
+	return &QuayController{
+		perceptorURL:  perceptorURL,
+		registryAuths: credentials,
+		filter:        filter,
+		paging:        paging,
+	}
+}
+
+// Run starts a controller that lists images and sends them to perceptor
+func (qc *QuayController) Run(interval time.Duration, stopCh <-chan struct{}) {
+	log.Infof("Controller: starting quay controller")
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		lookupStart := time.Now()
+		err := qc.imageLookup()
+		metrics.RecordReconcile("quay_controller", err == nil, time.Now().Sub(lookupStart))
+		if err != nil {
+			log.Errorf("Controller: failed to add quay images to scan queue: %v", err)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (qc *QuayController) imageLookup() error {
+	log.Infof("Controller: Total %d private registries credentials found!", len(qc.registryAuths))
+	failed := 0
+	instances := 0
+	for _, auth := range qc.registryAuths {
+		// The quay API is only reached with an access token
+		if len(auth.Token) == 0 {
+			continue
+		}
+
+		cred, err := utils.PingQuayServer("https://"+auth.URL, auth.User, auth.Password, auth.Token)
+		if err != nil {
+			log.Debugf("Controller: URL %s either not a valid quay repository or incorrect token: %e", auth.URL, err)
+			continue
+		}
+		instances = instances + 1
+
+		namespaces, err := utils.GetQuayNamespaces(cred)
+		if err != nil {
+			log.Errorf("Controller: Error in getting namespaces of quay instance %s: %e", auth.URL, err)
+			failed = failed + 1
+			continue
+		}
+
+		repos := 0
+		incomplete := false
+		for _, namespace := range namespaces {
+			repositories, err := utils.GetQuayRepositories(cred, namespace, qc.paging)
+			if err != nil {
+				log.Errorf("Controller: Error in getting repositories of quay namespace %s: %e", namespace, err)
+				incomplete = true
+				continue
+			}
+
+			for _, repository := range repositories {
+				name := fmt.Sprintf("%s/%s", repository.Namespace, repository.Name)
+				if len(repository.Kind) > 0 && repository.Kind != utils.QuayImageKind {
+					continue
+				}
+				if !qc.filter.Matches(name) {
+					log.Debugf("Controller: Quay repository %s is filtered out", name)
+					continue
+				}
+				repos = repos + 1
+				err = qc.repositoryLookup(auth, cred, name)
+				if err != nil {
+					log.Errorf("Controller: %v", err)
+					incomplete = true
+				}
+			}
+		}
+		if incomplete {
+			failed = failed + 1
+		}
+
+		log.Infof("Controller: There were total %d repositories found in quay instance %s.", repos, auth.URL)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("unable to look up %d of %d quay instances", failed, instances)
+	}
+	return nil
+}
+
+// repositoryLookup sends the images of the active tags of a repository to
+// perceptor, it returns an error if its tags can't be listed
+func (qc *QuayController) repositoryLookup(auth *utils.RegistryAuth, cred *utils.RegistryAuth, name string) error {
+	repoURL := fmt.Sprintf("%s/api/v1/repository/%s", cred.URL, name)
+	tags, err := utils.GetQuayTags(repoURL, cred.Token, qc.paging)
+	if err != nil {
+		return fmt.Errorf("unable to get tags of quay repository %s: %v", name, err)
+	}
+
+	// Remove HTTPS because image model doesn't require it
+	repository := fmt.Sprintf("%s/%s", auth.URL, name)
+	for _, tag := range tags {
+		manifest := &registry.Manifest{Digest: tag.ManifestDigest}
+		if tag.IsManifestList {
+			manifest, err = utils.GetQuayManifest(repoURL, tag.ManifestDigest, cred.Token)
+			if err != nil {
+				log.Errorf("Controller: Error in getting manifest list for tag %s of %s: %v", tag.Name, repository, err)
+				continue
+			}
+		}
+
+		quayImages, err := mapper.NewPerceptorImagesFromManifest(repository, tag.Name, manifest)
+		if err != nil {
+			log.Errorf("Controller: Invalid digest for tag %s of %s: %v", tag.Name, repository, err)
+			continue
+		}
+
+		imageURL := fmt.Sprintf("%s/%s", qc.perceptorURL, perceptorapi.ImagePath)
+		for _, quayImage := range quayImages {
+			err = communicator.SendPerceptorAddEvent(imageURL, quayImage)
+			if err != nil {
+				log.Errorf("Controller: Error putting quay image %v in perceptor queue %e", quayImage, err)
+			} else {
+				log.Infof("Controller: Successfully put image %s with tag %s in perceptor queue", repository, tag.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+)
+
+// newFakeQuay is a stand-in for the quay API of the user jane and the
+// organization team.  The tags of jane/scratch can't be listed, team/app
+// has an image and a manifest list, and team has an application
+// repository and a repository the tests filter out
+func newFakeQuay() *utils.FakeAPI {
+	fq := &utils.FakeAPI{Token: "token"}
+	fq.Reply(http.MethodGet, "/api/v1/user", utils.QuayUser{Username: "jane", Organizations: []utils.QuayOrganization{{Name: "team"}}})
+	repositories := map[string][]utils.QuayRepository{
+		"jane": {{Namespace: "jane", Name: "scratch", Kind: utils.QuayImageKind}},
+		"team": {
+			{Namespace: "team", Name: "app", Kind: utils.QuayImageKind},
+			{Namespace: "team", Name: "chart", Kind: "application"},
+			{Namespace: "team", Name: "skip", Kind: utils.QuayImageKind},
+		},
+	}
+	fq.Handle(http.MethodGet, "/api/v1/repository", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(utils.QuayRepositories{Repositories: repositories[r.URL.Query().Get("namespace")]})
+	})
+	fq.Handle(http.MethodGet, "/api/v1/repository/jane/scratch/tag/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "internal error"})
+	})
+	fq.Reply(http.MethodGet, "/api/v1/repository/team/app/tag/", utils.QuayTagDigest{Page: 1, Tags: []utils.QuayTag{
+		{Name: "1.0", ManifestDigest: testDigest},
+		{Name: "2.0", ManifestDigest: testListDigest, IsManifestList: true},
+	}})
+	fq.Reply(http.MethodGet, "/api/v1/repository/team/skip/tag/", utils.QuayTagDigest{Page: 1, Tags: []utils.QuayTag{{Name: "1.0", ManifestDigest: testDigest}}})
+	fq.Reply(http.MethodGet, "/api/v1/repository/team/app/manifest/"+testListDigest, utils.QuayManifest{Digest: testListDigest, IsManifestList: true, ManifestData: testManifestList})
+	return fq
+}
+
+func TestQuayImageLookup(t *testing.T) {
+	// The quay API is only pinged over https
+	server := httptest.NewTLSServer(newFakeQuay())
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+	fp := utils.NewFakePerceptor()
+	perceptor := httptest.NewServer(fp)
+	defer perceptor.Close()
+
+	auths := []*utils.RegistryAuth{
+		{URL: host, Token: "token"},
+		// Credentials without a token aren't for the quay API
+		{URL: host, User: "jane", Password: "password"},
+	}
+	qc := NewQuayController(perceptor.URL, auths, &utils.RepositoryFilter{Exclude: []string{"team/skip"}}, nil)
+	if err := qc.imageLookup(); err == nil {
+		t.Errorf("expected an error for the repository whose tags can't be listed")
+	}
+	expected := []string{
+		host + "/team/app:1.0 " + strings.TrimPrefix(testDigest, "sha256:"),
+		host + "/team/app:2.0 linux/amd64 " + strings.TrimPrefix(testAmd64Digest, "sha256:"),
+		host + "/team/app:2.0 linux/arm64 " + strings.TrimPrefix(testArm64Digest, "sha256:"),
+	}
+	if images := perceptorImages(fp); !reflect.DeepEqual(images, expected) {
+		t.Errorf("expected images %v, got %v", expected, images)
+	}
+}
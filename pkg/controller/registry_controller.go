@@ -0,0 +1,136 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/mapper"
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RegistryController handles discovering the images of registries that
+// implement the docker registry API and sending them to perceptor
+type RegistryController struct {
+	perceptorURL  string
+	registryAuths []*utils.RegistryAuth
+	client        *registry.Client
+}
+
+// NewRegistryController creates a new RegistryController object
+func NewRegistryController(perceptorURL string, credentials []*utils.RegistryAuth, client *registry.Client) *RegistryController {
+	return &RegistryController{
+		perceptorURL:  perceptorURL,
+		registryAuths: credentials,
+		client:        client,
+	}
+}
+
+// Run starts a controller that lists the images of the registries and
+// sends them to perceptor
+func (rc *RegistryController) Run(interval time.Duration, stopCh <-chan struct{}) {
+	log.Infof("Controller: starting registry controller")
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		lookupStart := time.Now()
+		err := rc.imageLookup()
+		metrics.RecordReconcile("registry_controller", err == nil, time.Now().Sub(lookupStart))
+		if err != nil {
+			log.Errorf("Controller: failed to add registry images to scan queue: %v", err)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (rc *RegistryController) imageLookup() error {
+	log.Infof("Controller: Total %d registries found!", len(rc.registryAuths))
+	failed := 0
+	for _, auth := range rc.registryAuths {
+		cred := auth.Credentials()
+		repositories, err := rc.client.Catalog(auth.URL, cred)
+		if err != nil {
+			metrics.RecordError("registry_controller", "unable to list catalog")
+			log.Errorf("Controller: %v", err)
+			failed++
+			continue
+		}
+
+		for _, repository := range repositories {
+			tags, err := rc.client.Tags(auth.URL, repository, cred)
+			if err != nil {
+				log.Errorf("Controller: %v", err)
+				continue
+			}
+
+			for _, tag := range tags {
+				ref := &docker.Reference{Registry: auth.URL, Repository: repository, Tag: tag}
+				manifest, err := rc.client.ManifestHead(ref, cred)
+				if err != nil {
+					log.Errorf("Controller: Error in getting manifest of %s: %v", ref, err)
+					continue
+				}
+				sendRegistryImages(rc.perceptorURL, fmt.Sprintf("%s/%s", auth.URL, repository), tag, manifest)
+			}
+		}
+
+		log.Infof("Controller: There were total %d repositories found in registry %s.", len(repositories), auth.URL)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("unable to list %d of %d registries", failed, len(rc.registryAuths))
+	}
+	return nil
+}
+
+// sendRegistryImages sends the images of the manifest of a tag of a
+// repository to perceptor, one per platform for a manifest list
+func sendRegistryImages(perceptorURL string, repository string, tag string, manifest *registry.Manifest) {
+	images, err := mapper.NewPerceptorImagesFromManifest(repository, tag, manifest)
+	if err != nil {
+		log.Errorf("Controller: %v", err)
+		return
+	}
+
+	imageURL := fmt.Sprintf("%s/%s", perceptorURL, perceptorapi.ImagePath)
+	for _, image := range images {
+		err = communicator.SendPerceptorAddEvent(imageURL, image)
+		if err != nil {
+			log.Errorf("Controller: Error putting image %v in perceptor queue %e", image, err)
+		} else {
+			log.Infof("Controller: Successfully put image %s with tag %s in perceptor queue", repository, tag)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+)
+
+const (
+	testDigest      = "sha256:cb4983d8399a59bb5ee6e68b6177d878966a8fe41abe18a45c3b1d8809f1d043"
+	testListDigest  = "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+	testAmd64Digest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	testArm64Digest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+)
+
+// testManifestList is a manifest list of an amd64 and an arm64 image
+var testManifestList = fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"manifests":[
+	{"digest":%q,"platform":{"os":"linux","architecture":"amd64"}},
+	{"digest":%q,"platform":{"os":"linux","architecture":"arm64"}}]}`,
+	registry.MediaTypeManifestList, testAmd64Digest, testArm64Digest)
+
+// newFakeDockerRegistry is a stand-in for the registry API of a registry
+// with the image team/app:1.0, the manifest list team/web:2.0 and the tag
+// team/web:broken, whose manifest is missing
+func newFakeDockerRegistry() *utils.FakeAPI {
+	fr := &utils.FakeAPI{}
+	fr.Reply(http.MethodGet, "/v2/_catalog", map[string][]string{"repositories": {"team/app", "team/web"}})
+	fr.Reply(http.MethodGet, "/v2/team/app/tags/list", map[string]interface{}{"name": "team/app", "tags": []string{"1.0"}})
+	fr.Reply(http.MethodGet, "/v2/team/web/tags/list", map[string]interface{}{"name": "team/web", "tags": []string{"2.0", "broken"}})
+	fr.Handle(http.MethodHead, "/v2/team/app/manifests/1.0", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", registry.MediaTypeManifest)
+		w.Header().Set("Docker-Content-Digest", testDigest)
+	})
+	list := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", registry.MediaTypeManifestList)
+		w.Header().Set("Docker-Content-Digest", testListDigest)
+		w.Write([]byte(testManifestList))
+	}
+	fr.Handle(http.MethodHead, "/v2/team/web/manifests/2.0", list)
+	fr.Handle(http.MethodGet, "/v2/team/web/manifests/2.0", list)
+	return fr
+}
+
+func TestRegistryImageLookup(t *testing.T) {
+	server := httptest.NewServer(newFakeDockerRegistry())
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	// Nothing listens on a closed server
+	closed := httptest.NewServer(&utils.FakeAPI{})
+	closed.Close()
+	unreachable := strings.TrimPrefix(closed.URL, "http://")
+
+	testcases := []struct {
+		description string
+		registries  []string
+		images      []string
+		shouldPass  bool
+	}{
+		{
+			description: "registry",
+			registries:  []string{host},
+			images: []string{
+				host + "/team/app:1.0 " + strings.TrimPrefix(testDigest, "sha256:"),
+				host + "/team/web:2.0 linux/amd64 " + strings.TrimPrefix(testAmd64Digest, "sha256:"),
+				host + "/team/web:2.0 linux/arm64 " + strings.TrimPrefix(testArm64Digest, "sha256:"),
+			},
+			shouldPass: true,
+		},
+		{
+			description: "unreachable registry",
+			registries:  []string{unreachable, host},
+			images: []string{
+				host + "/team/app:1.0 " + strings.TrimPrefix(testDigest, "sha256:"),
+				host + "/team/web:2.0 linux/amd64 " + strings.TrimPrefix(testAmd64Digest, "sha256:"),
+				host + "/team/web:2.0 linux/arm64 " + strings.TrimPrefix(testArm64Digest, "sha256:"),
+			},
+			shouldPass: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		fp := utils.NewFakePerceptor()
+		perceptor := httptest.NewServer(fp)
+		auths := []*utils.RegistryAuth{}
+		for _, url := range tc.registries {
+			auths = append(auths, &utils.RegistryAuth{URL: url, User: "user", Password: "password"})
+		}
+		rc := NewRegistryController(perceptor.URL, auths, registry.NewClient(tc.registries, 5*time.Second))
+
+		err := rc.imageLookup()
+		perceptor.Close()
+		if err != nil && tc.shouldPass {
+			t.Errorf("[%s] unexpected error: %v", tc.description, err)
+		}
+		if err == nil && !tc.shouldPass {
+			t.Errorf("[%s] expected an error", tc.description)
+		}
+		if images := perceptorImages(fp); !reflect.DeepEqual(images, tc.images) {
+			t.Errorf("[%s] expected images %v, got %v", tc.description, tc.images, images)
+		}
+	}
+}
+
+// perceptorImages returns the images perceptor was sent, each as its
+// repository and tag followed by its sha
+func perceptorImages(fp *utils.FakePerceptor) []string {
+	images := []string{}
+	shas := fp.Shas()
+	for i, image := range fp.Images {
+		images = append(images, fmt.Sprintf("%s:%s %s", image.Repository, image.Tag, shas[i]))
+	}
+	return images
+}
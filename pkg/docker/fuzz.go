@@ -0,0 +1,59 @@
+//go:build gofuzz
+// +build gofuzz
+
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package docker
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Fuzz is the entry point for go-fuzz (https://github.com/dvyukov/go-fuzz).
+// The seed inputs are in testdata/corpus:
+//
+//	go-fuzz-build github.com/blackducksoftware/perceivers/pkg/docker
+//	go-fuzz -bin=docker-fuzz.zip -workdir=pkg/docker/testdata
+func Fuzz(data []byte) int {
+	ParseImageID(string(data))
+
+	ref, err := ParseReference(string(data))
+	if err != nil {
+		return 0
+	}
+	reparsed, err := ParseReference(ref.String())
+	if err != nil {
+		panic(fmt.Sprintf("unable to parse %q printed from %q: %v", ref.String(), data, err))
+	}
+	if !reflect.DeepEqual(ref, reparsed) {
+		panic(fmt.Sprintf("%q parsed to %+v, but its string %q parsed to %+v", data, ref, ref.String(), reparsed))
+	}
+	normalized, err := ParseReference(ref.Normalize().String())
+	if err != nil {
+		panic(fmt.Sprintf("unable to parse normalized %q of %q: %v", ref.Normalize().String(), data, err))
+	}
+	if !reflect.DeepEqual(normalized, normalized.Normalize()) {
+		panic(fmt.Sprintf("normalizing %q twice changed it", data))
+	}
+	return 1
+}
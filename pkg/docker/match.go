@@ -0,0 +1,177 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package docker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MatchMode is how image references are compared to scan results
+type MatchMode string
+
+const (
+	// MatchExact compares the names as written and the digests
+	MatchExact MatchMode = "exact"
+	// MatchCanonical compares the canonical names, with registry aliases
+	// applied, and the digests.  nginx and docker.io/library/nginx are the
+	// same image
+	MatchCanonical MatchMode = "canonical"
+	// MatchDigest compares only the digests, so an image matches its scan
+	// from any registry or repository it was mirrored to
+	MatchDigest MatchMode = "digest"
+)
+
+// MatchConfig configures how the annotators find the scan results of an image
+type MatchConfig struct {
+	// Mode is exact, canonical or digest.  It defaults to exact, the names
+	// as the registries and Black Duck list them
+	Mode string
+	// RegistryAliases maps a mirror, a registry with an optional path, to
+	// the registry it mirrors, i.e. mirror.example.com/dockerhub: docker.io
+	RegistryAliases map[string]string
+}
+
+// registryAlias replaces the prefix of a name with the registry it mirrors
+type registryAlias struct {
+	prefix string
+	target string
+}
+
+// Matcher decides whether an image is the one a scan result was recorded
+// for.  A nil Matcher matches exact names
+type Matcher struct {
+	mode    MatchMode
+	aliases []registryAlias
+}
+
+// NewMatcher creates a new Matcher object from the configuration
+func NewMatcher(config MatchConfig) (*Matcher, error) {
+	m := &Matcher{mode: MatchMode(strings.ToLower(config.Mode))}
+	switch m.mode {
+	case "":
+		m.mode = MatchExact
+	case MatchExact, MatchCanonical, MatchDigest:
+	default:
+		return nil, fmt.Errorf("unknown match mode %s", config.Mode)
+	}
+
+	for prefix, target := range config.RegistryAliases {
+		prefix = strings.TrimSuffix(strings.ToLower(prefix), "/")
+		target = strings.TrimSuffix(strings.ToLower(target), "/")
+		if len(prefix) == 0 || len(target) == 0 {
+			return nil, fmt.Errorf("registry alias %q: %q needs both a mirror and a registry", prefix, target)
+		}
+		m.aliases = append(m.aliases, registryAlias{prefix: prefix, target: target})
+	}
+	// The longest prefix wins, so a path on a mirror can be aliased apart
+	// from the rest of the mirror
+	sort.Slice(m.aliases, func(i, j int) bool {
+		if len(m.aliases[i].prefix) != len(m.aliases[j].prefix) {
+			return len(m.aliases[i].prefix) > len(m.aliases[j].prefix)
+		}
+		return m.aliases[i].prefix < m.aliases[j].prefix
+	})
+	return m, nil
+}
+
+// Mode returns the match mode
+func (m *Matcher) Mode() MatchMode {
+	if m == nil {
+		return MatchExact
+	}
+	return m.mode
+}
+
+// Canonical returns the canonical form of the reference: it is normalized,
+// the registry is lower cased and then the registry aliases are applied.
+// The digest and tag are kept
+func (m *Matcher) Canonical(ref *Reference) *Reference {
+	canonical := ref.Normalize()
+	// Host names aren't case sensitive, repositories are always lower case
+	canonical.Registry = strings.ToLower(canonical.Registry)
+	if m == nil || len(canonical.Repository) == 0 {
+		return canonical
+	}
+	name := canonical.Name()
+	for _, alias := range m.aliases {
+		if name != alias.prefix && !strings.HasPrefix(name, alias.prefix+"/") {
+			continue
+		}
+		aliased, err := ParseReference(alias.target + name[len(alias.prefix):])
+		if err != nil {
+			// The alias produced an invalid name, keep the original
+			break
+		}
+		aliased = aliased.Normalize()
+		canonical.Registry, canonical.Repository = aliased.Registry, aliased.Repository
+		break
+	}
+	return canonical
+}
+
+// CanonicalName returns the canonical registry and repository of a name,
+// or the name unchanged if it can't be parsed
+func (m *Matcher) CanonicalName(name string) string {
+	ref, err := ParseReference(name)
+	if err != nil {
+		return name
+	}
+	return m.Canonical(ref).Name()
+}
+
+// Matches returns whether the image is the scanned image with the given
+// repository and sha256 digest
+func (m *Matcher) Matches(image *Reference, repository string, sha string) bool {
+	if image.Digest != sha || (len(image.DigestAlgorithm) > 0 && image.DigestAlgorithm != "sha256") {
+		return false
+	}
+	switch m.Mode() {
+	case MatchDigest:
+		return true
+	case MatchExact:
+		return image.Name() == repository
+	}
+	if len(image.Repository) == 0 {
+		return false
+	}
+	return m.Canonical(image).Name() == m.CanonicalName(repository)
+}
+
+// InRegistry returns whether the scanned repository is hosted on the
+// registry, or on a mirror of it.  Only the host of the registry is compared
+func (m *Matcher) InRegistry(repository string, registry string) bool {
+	host := strings.Split(registry, "/")[0]
+	if m.Mode() == MatchExact {
+		return strings.Contains(repository, host)
+	}
+	ref, err := ParseReference(repository)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(ref.Normalize().Registry, host) {
+		return true
+	}
+	registryRef := m.Canonical(&Reference{Registry: host, Repository: "x"})
+	return m.Canonical(ref).Registry == registryRef.Registry
+}
@@ -0,0 +1,265 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package docker
+
+import (
+	"testing"
+)
+
+func TestNewMatcher(t *testing.T) {
+	testcases := []struct {
+		description string
+		config      MatchConfig
+		expected    MatchMode
+		shouldPass  bool
+	}{
+		{
+			description: "default mode",
+			config:      MatchConfig{},
+			expected:    MatchExact,
+			shouldPass:  true,
+		},
+		{
+			description: "canonical mode",
+			config:      MatchConfig{Mode: "canonical"},
+			expected:    MatchCanonical,
+			shouldPass:  true,
+		},
+		{
+			description: "digest mode",
+			config:      MatchConfig{Mode: "Digest"},
+			expected:    MatchDigest,
+			shouldPass:  true,
+		},
+		{
+			description: "unknown mode",
+			config:      MatchConfig{Mode: "fuzzy"},
+			shouldPass:  false,
+		},
+		{
+			description: "alias without a registry",
+			config:      MatchConfig{RegistryAliases: map[string]string{"mirror.example.com": ""}},
+			shouldPass:  false,
+		},
+	}
+
+	for _, tc := range testcases {
+		m, err := NewMatcher(tc.config)
+		if err != nil && tc.shouldPass {
+			t.Fatalf("[%s] unexpected error: %v", tc.description, err)
+		}
+		if err == nil && !tc.shouldPass {
+			t.Fatalf("[%s] expected an error", tc.description)
+		}
+		if tc.shouldPass && m.Mode() != tc.expected {
+			t.Errorf("[%s] expected mode %s, got %s", tc.description, tc.expected, m.Mode())
+		}
+	}
+}
+
+func TestMatcherMatches(t *testing.T) {
+	aliases := map[string]string{
+		"mirror.example.com":           "docker.io",
+		"registry.example.com/quay-io": "quay.io",
+	}
+	otherDigest := "007b4fab5780e0306d9b23395606fd68f01930a20f778c4ef487c36f604350d9"
+	testcases := []struct {
+		description string
+		mode        string
+		image       string
+		repository  string
+		sha         string
+		expected    bool
+	}{
+		{
+			description: "exact name",
+			mode:        "exact",
+			image:       "nginx@sha256:" + testDigest,
+			repository:  "nginx",
+			sha:         testDigest,
+			expected:    true,
+		},
+		{
+			description: "exact mode with a normalized scan",
+			mode:        "exact",
+			image:       "nginx@sha256:" + testDigest,
+			repository:  "docker.io/library/nginx",
+			sha:         testDigest,
+			expected:    false,
+		},
+		{
+			description: "canonical mode with a normalized scan",
+			mode:        "canonical",
+			image:       "nginx@sha256:" + testDigest,
+			repository:  "docker.io/library/nginx",
+			sha:         testDigest,
+			expected:    true,
+		},
+		{
+			description: "canonical mode with the legacy default registry",
+			mode:        "canonical",
+			image:       "index.docker.io/team/app@sha256:" + testDigest,
+			repository:  "team/app",
+			sha:         testDigest,
+			expected:    true,
+		},
+		{
+			description: "canonical mode with a different digest",
+			mode:        "canonical",
+			image:       "nginx@sha256:" + testDigest,
+			repository:  "nginx",
+			sha:         otherDigest,
+			expected:    false,
+		},
+		{
+			description: "canonical mode with a mirror",
+			mode:        "canonical",
+			image:       "mirror.example.com/nginx@sha256:" + testDigest,
+			repository:  "docker.io/library/nginx",
+			sha:         testDigest,
+			expected:    true,
+		},
+		{
+			description: "canonical mode with a path on a mirror",
+			mode:        "canonical",
+			image:       "registry.example.com/quay-io/coreos/etcd@sha256:" + testDigest,
+			repository:  "quay.io/coreos/etcd",
+			sha:         testDigest,
+			expected:    true,
+		},
+		{
+			description: "canonical mode with an unaliased registry",
+			mode:        "canonical",
+			image:       "other.example.com/nginx@sha256:" + testDigest,
+			repository:  "nginx",
+			sha:         testDigest,
+			expected:    false,
+		},
+		{
+			description: "digest mode with another registry",
+			mode:        "digest",
+			image:       "other.example.com/nginx@sha256:" + testDigest,
+			repository:  "nginx",
+			sha:         testDigest,
+			expected:    true,
+		},
+		{
+			description: "digest mode with a bare digest",
+			mode:        "digest",
+			image:       "sha256:" + testDigest,
+			repository:  "nginx",
+			sha:         testDigest,
+			expected:    true,
+		},
+		{
+			description: "digest mode with a different digest",
+			mode:        "digest",
+			image:       "nginx@sha256:" + testDigest,
+			repository:  "nginx",
+			sha:         otherDigest,
+			expected:    false,
+		},
+	}
+
+	for _, tc := range testcases {
+		m, err := NewMatcher(MatchConfig{Mode: tc.mode, RegistryAliases: aliases})
+		if err != nil {
+			t.Fatalf("[%s] unexpected error: %v", tc.description, err)
+		}
+		image, err := ParseImageID(tc.image)
+		if err != nil {
+			t.Fatalf("[%s] unable to parse %s: %v", tc.description, tc.image, err)
+		}
+		result := m.Matches(image, tc.repository, tc.sha)
+		if result != tc.expected {
+			t.Errorf("[%s] expected %t, got %t", tc.description, tc.expected, result)
+		}
+	}
+}
+
+func TestMatcherInRegistry(t *testing.T) {
+	m, err := NewMatcher(MatchConfig{Mode: "canonical", RegistryAliases: map[string]string{"mirror.example.com": "quay.io"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testcases := []struct {
+		description string
+		repository  string
+		registry    string
+		expected    bool
+	}{
+		{
+			description: "same registry",
+			repository:  "quay.io/coreos/etcd",
+			registry:    "quay.io",
+			expected:    true,
+		},
+		{
+			description: "registry with a path",
+			repository:  "artifactory.example.com:5000/team/app",
+			registry:    "artifactory.example.com:5000/artifactory",
+			expected:    true,
+		},
+		{
+			description: "registry in upper case",
+			repository:  "quay.io/coreos/etcd",
+			registry:    "Quay.io",
+			expected:    true,
+		},
+		{
+			description: "mirror of the registry",
+			repository:  "mirror.example.com/coreos/etcd",
+			registry:    "quay.io",
+			expected:    true,
+		},
+		{
+			description: "registry as a substring of another",
+			repository:  "notquay.io/coreos/etcd",
+			registry:    "quay.io",
+			expected:    false,
+		},
+		{
+			description: "default registry",
+			repository:  "nginx",
+			registry:    "quay.io",
+			expected:    false,
+		},
+	}
+
+	for _, tc := range testcases {
+		result := m.InRegistry(tc.repository, tc.registry)
+		if result != tc.expected {
+			t.Errorf("[%s] expected %t, got %t", tc.description, tc.expected, result)
+		}
+	}
+}
+
+func TestNilMatcher(t *testing.T) {
+	var m *Matcher
+	image, err := ParseImageID("nginx@sha256:" + testDigest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !m.Matches(image, "nginx", testDigest) || m.Matches(image, "docker.io/library/nginx", testDigest) {
+		t.Errorf("expected a nil matcher to match exact names")
+	}
+}
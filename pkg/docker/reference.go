@@ -0,0 +1,229 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package docker
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultRegistry is the registry of references that don't name one
+	DefaultRegistry = "docker.io"
+	// legacyDefaultRegistry is the old name of the default registry
+	legacyDefaultRegistry = "index.docker.io"
+	// officialRepositoryPrefix is the namespace of the official images on
+	// the default registry
+	officialRepositoryPrefix = "library/"
+	// nameMaxLength is the longest name a reference may have
+	nameMaxLength = 255
+)
+
+// The grammar follows the reference format of the docker distribution
+// project (https://github.com/docker/distribution/blob/master/reference/reference.go)
+var (
+	nameComponent   = `[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*`
+	domainComponent = `(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])`
+	domain          = domainComponent + `(?:\.` + domainComponent + `)*(?::[0-9]+)?`
+	name            = `(?:` + domain + `/)?` + nameComponent + `(?:/` + nameComponent + `)*`
+	tag             = `[\w][\w.-]{0,127}`
+	digest          = `[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}`
+
+	referenceRegexp = regexp.MustCompile(`^(` + name + `)(?::(` + tag + `))?(?:@(` + digest + `))?$`)
+	digestRegexp    = regexp.MustCompile(`^` + digest + `$`)
+
+	bareDigestRegexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// digestLengths contains the length of the hex encoded digest of every
+// supported digest algorithm
+var digestLengths = map[string]int{
+	"sha256": 64,
+	"sha384": 96,
+	"sha512": 128,
+}
+
+// imageIDPrefixes are the transports the container runtimes put in front
+// of image IDs
+var imageIDPrefixes = []string{"docker-pullable://", "docker://"}
+
+// Reference is a parsed image reference.  Registry and Repository are kept
+// as written, use Normalize to get the canonical form
+type Reference struct {
+	Registry        string
+	Repository      string
+	Tag             string
+	DigestAlgorithm string
+	Digest          string
+}
+
+// ParseReference parses an image reference
+// Example image reference:
+//
+//	registry.example.com:5000/team/app:1.0@sha256:cb4983d8399a59bb5ee6e68b6177d878966a8fe41abe18a45c3b1d8809f1d043
+func ParseReference(s string) (*Reference, error) {
+	match := referenceRegexp.FindStringSubmatch(s)
+	if match == nil {
+		return nil, fmt.Errorf("invalid image reference %q", s)
+	}
+	if len(match[1]) > nameMaxLength {
+		return nil, fmt.Errorf("image reference %q has a name longer than %d characters", s, nameMaxLength)
+	}
+
+	ref := &Reference{Tag: match[2]}
+	ref.Registry, ref.Repository = splitRegistry(match[1])
+	if len(match[3]) > 0 {
+		algorithm, hex, err := ParseDigest(match[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid image reference %q: %v", s, err)
+		}
+		ref.DigestAlgorithm, ref.Digest = algorithm, hex
+	}
+	return ref, nil
+}
+
+// ParseImageID parses the image ID of a container.  The ID is a reference
+// with a digest, optionally behind a transport like docker-pullable://, or
+// only a digest with or without its algorithm.  A reference parsed from only
+// a digest has no repository
+// Example image id:
+//
+//	docker-pullable://registry.kipp.blackducksoftware.com/blackducksoftware/hub-registration@sha256:cb4983d8399a59bb5ee6e68b6177d878966a8fe41abe18a45c3b1d8809f1d043
+func ParseImageID(imageID string) (*Reference, error) {
+	id := imageID
+	for _, prefix := range imageIDPrefixes {
+		id = strings.TrimPrefix(id, prefix)
+	}
+
+	// A bare digest could also be read as a repository with a tag, so it is
+	// checked first
+	if algorithm, hex, ok := parseDigestOnly(id); ok {
+		return &Reference{DigestAlgorithm: algorithm, Digest: hex}, nil
+	}
+
+	ref, err := ParseReference(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(ref.Digest) == 0 {
+		return nil, fmt.Errorf("image ID %q has no digest", imageID)
+	}
+	return ref, nil
+}
+
+// ParseDigest parses a digest like sha256:cb4983d8... and returns the
+// algorithm and the hex encoded digest
+func ParseDigest(s string) (string, string, error) {
+	if !digestRegexp.MatchString(s) {
+		return "", "", fmt.Errorf("invalid digest %q", s)
+	}
+	i := strings.Index(s, ":")
+	algorithm, hex := s[:i], s[i+1:]
+	length, ok := digestLengths[algorithm]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported digest algorithm %s", algorithm)
+	}
+	if len(hex) != length {
+		return "", "", fmt.Errorf("%s digest %s should be %d characters long", algorithm, hex, length)
+	}
+	if strings.ToLower(hex) != hex {
+		return "", "", fmt.Errorf("%s digest %s isn't lower case", algorithm, hex)
+	}
+	return algorithm, hex, nil
+}
+
+// parseDigestOnly parses an image ID that is only a digest, either with its
+// algorithm or as the bare sha256 hex some runtimes report
+func parseDigestOnly(id string) (string, string, bool) {
+	if algorithm, hex, err := ParseDigest(id); err == nil {
+		return algorithm, hex, true
+	}
+	if bareDigestRegexp.MatchString(id) {
+		return "sha256", id, true
+	}
+	return "", "", false
+}
+
+// splitRegistry splits the registry off a name the way docker does: the
+// first component is a registry if it looks like a host name
+func splitRegistry(name string) (string, string) {
+	i := strings.Index(name, "/")
+	if i == -1 {
+		return "", name
+	}
+	first := name[:i]
+	if !strings.ContainsAny(first, ".:") && first != "localhost" && strings.ToLower(first) == first {
+		return "", name
+	}
+	return first, name[i+1:]
+}
+
+// Name returns the registry and repository as written
+func (r *Reference) Name() string {
+	if len(r.Registry) == 0 {
+		return r.Repository
+	}
+	return fmt.Sprintf("%s/%s", r.Registry, r.Repository)
+}
+
+// FullDigest returns the digest with its algorithm, or an empty string if
+// the reference has no digest
+func (r *Reference) FullDigest() string {
+	if len(r.Digest) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s", r.DigestAlgorithm, r.Digest)
+}
+
+// String returns the reference in the form it is parsed from
+func (r *Reference) String() string {
+	if len(r.Repository) == 0 {
+		return r.FullDigest()
+	}
+	s := r.Name()
+	if len(r.Tag) > 0 {
+		s = fmt.Sprintf("%s:%s", s, r.Tag)
+	}
+	if len(r.Digest) > 0 {
+		s = fmt.Sprintf("%s@%s", s, r.FullDigest())
+	}
+	return s
+}
+
+// Normalize returns a copy of the reference in canonical form: the default
+// registry is filled in and official images get the library/ namespace, so
+// nginx becomes docker.io/library/nginx.  A reference without a repository
+// is returned unchanged
+func (r *Reference) Normalize() *Reference {
+	normalized := *r
+	if len(normalized.Repository) == 0 {
+		return &normalized
+	}
+	if len(normalized.Registry) == 0 || normalized.Registry == legacyDefaultRegistry {
+		normalized.Registry = DefaultRegistry
+	}
+	if normalized.Registry == DefaultRegistry && !strings.Contains(normalized.Repository, "/") {
+		normalized.Repository = officialRepositoryPrefix + normalized.Repository
+	}
+	return &normalized
+}
@@ -0,0 +1,299 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package docker
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testDigest = "cb4983d8399a59bb5ee6e68b6177d878966a8fe41abe18a45c3b1d8809f1d043"
+
+func TestParseReference(t *testing.T) {
+	testcases := []struct {
+		description string
+		reference   string
+		expected    *Reference
+		shouldPass  bool
+	}{
+		{
+			description: "image name only",
+			reference:   "nginx",
+			expected:    &Reference{Repository: "nginx"},
+			shouldPass:  true,
+		},
+		{
+			description: "image name with tag",
+			reference:   "nginx:1.2.3",
+			expected:    &Reference{Repository: "nginx", Tag: "1.2.3"},
+			shouldPass:  true,
+		},
+		{
+			description: "repo with path without registry",
+			reference:   "blackducksoftware/perceptor:latest",
+			expected:    &Reference{Repository: "blackducksoftware/perceptor", Tag: "latest"},
+			shouldPass:  true,
+		},
+		{
+			description: "repo with registry and tag",
+			reference:   "url.com/imagename:latest",
+			expected:    &Reference{Registry: "url.com", Repository: "imagename", Tag: "latest"},
+			shouldPass:  true,
+		},
+		{
+			description: "repo with registry and port without tag",
+			reference:   "url.com:80/imagename",
+			expected:    &Reference{Registry: "url.com:80", Repository: "imagename"},
+			shouldPass:  true,
+		},
+		{
+			description: "repo with registry and port and tag",
+			reference:   "url.com:80/imagename:latest",
+			expected:    &Reference{Registry: "url.com:80", Repository: "imagename", Tag: "latest"},
+			shouldPass:  true,
+		},
+		{
+			description: "localhost registry",
+			reference:   "localhost/imagename",
+			expected:    &Reference{Registry: "localhost", Repository: "imagename"},
+			shouldPass:  true,
+		},
+		{
+			description: "repo with digest",
+			reference:   "docker-registry.default.svc:5000/def/ghi@sha256:" + testDigest,
+			expected:    &Reference{Registry: "docker-registry.default.svc:5000", Repository: "def/ghi", DigestAlgorithm: "sha256", Digest: testDigest},
+			shouldPass:  true,
+		},
+		{
+			description: "repo with tag and digest",
+			reference:   "nginx:1.15@sha256:" + testDigest,
+			expected:    &Reference{Repository: "nginx", Tag: "1.15", DigestAlgorithm: "sha256", Digest: testDigest},
+			shouldPass:  true,
+		},
+		{
+			description: "sha512 digest",
+			reference:   "nginx@sha512:" + testDigest + testDigest,
+			expected:    &Reference{Repository: "nginx", DigestAlgorithm: "sha512", Digest: testDigest + testDigest},
+			shouldPass:  true,
+		},
+		{
+			description: "upper case repository",
+			reference:   "url.com/ImageName",
+			shouldPass:  false,
+		},
+		{
+			description: "short digest",
+			reference:   "nginx@sha256:235n348g24",
+			shouldPass:  false,
+		},
+		{
+			description: "unsupported digest algorithm",
+			reference:   "nginx@md5:d41d8cd98f00b204e9800998ecf8427e",
+			shouldPass:  false,
+		},
+		{
+			description: "empty tag",
+			reference:   "nginx:",
+			shouldPass:  false,
+		},
+		{
+			description: "empty reference",
+			reference:   "",
+			shouldPass:  false,
+		},
+	}
+
+	for _, tc := range testcases {
+		result, err := ParseReference(tc.reference)
+		if err != nil && tc.shouldPass {
+			t.Errorf("[%s] unexpected error: %v", tc.description, err)
+			continue
+		}
+		if err == nil && !tc.shouldPass {
+			t.Errorf("[%s] expected an error, got %+v", tc.description, result)
+			continue
+		}
+		if tc.shouldPass && !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("[%s] expected %+v, got %+v", tc.description, tc.expected, result)
+		}
+	}
+}
+
+func TestParseImageID(t *testing.T) {
+	testcases := []struct {
+		description string
+		imageID     string
+		name        string
+		digest      string
+		shouldPass  bool
+	}{
+		{
+			description: "docker-pullable prefix",
+			imageID:     "docker-pullable://abc/def@sha256:" + testDigest,
+			name:        "abc/def",
+			digest:      testDigest,
+			shouldPass:  true,
+		},
+		{
+			description: "docker-pullable prefix with private registry",
+			imageID:     "docker-pullable://docker-registry.default.svc:5000/def/ghi@sha256:" + testDigest,
+			name:        "docker-registry.default.svc:5000/def/ghi",
+			digest:      testDigest,
+			shouldPass:  true,
+		},
+		{
+			description: "missing prefix",
+			imageID:     "abc/def@sha256:" + testDigest,
+			name:        "abc/def",
+			digest:      testDigest,
+			shouldPass:  true,
+		},
+		{
+			description: "docker prefix with digest only",
+			imageID:     "docker://sha256:" + testDigest,
+			name:        "",
+			digest:      testDigest,
+			shouldPass:  true,
+		},
+		{
+			description: "bare digest without algorithm",
+			imageID:     testDigest,
+			name:        "",
+			digest:      testDigest,
+			shouldPass:  true,
+		},
+		{
+			description: "missing image name",
+			imageID:     "docker-pullable://@sha256:" + testDigest,
+			shouldPass:  false,
+		},
+		{
+			description: "missing sha",
+			imageID:     "docker-pullable://abc/def@sha256:",
+			shouldPass:  false,
+		},
+		{
+			description: "tag without digest",
+			imageID:     "abc/def:latest",
+			shouldPass:  false,
+		},
+	}
+
+	for _, tc := range testcases {
+		result, err := ParseImageID(tc.imageID)
+		if err != nil && tc.shouldPass {
+			t.Errorf("[%s] unexpected error: %v", tc.description, err)
+			continue
+		}
+		if err == nil && !tc.shouldPass {
+			t.Errorf("[%s] expected an error, got %+v", tc.description, result)
+			continue
+		}
+		if !tc.shouldPass {
+			continue
+		}
+		if result.Name() != tc.name {
+			t.Errorf("[%s] name is wrong.  Expected %s got %s", tc.description, tc.name, result.Name())
+		}
+		if result.Digest != tc.digest {
+			t.Errorf("[%s] digest is wrong.  Expected %s got %s", tc.description, tc.digest, result.Digest)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	testcases := []struct {
+		description string
+		reference   string
+		expected    string
+	}{
+		{
+			description: "official image",
+			reference:   "nginx:1.15",
+			expected:    "docker.io/library/nginx:1.15",
+		},
+		{
+			description: "user image on the default registry",
+			reference:   "blackducksoftware/perceptor",
+			expected:    "docker.io/blackducksoftware/perceptor",
+		},
+		{
+			description: "legacy default registry",
+			reference:   "index.docker.io/nginx",
+			expected:    "docker.io/library/nginx",
+		},
+		{
+			description: "already canonical",
+			reference:   "docker.io/library/nginx",
+			expected:    "docker.io/library/nginx",
+		},
+		{
+			description: "private registry",
+			reference:   "url.com:80/imagename@sha256:" + testDigest,
+			expected:    "url.com:80/imagename@sha256:" + testDigest,
+		},
+	}
+
+	for _, tc := range testcases {
+		ref, err := ParseReference(tc.reference)
+		if err != nil {
+			t.Fatalf("[%s] unexpected error: %v", tc.description, err)
+		}
+		result := ref.Normalize().String()
+		if result != tc.expected {
+			t.Errorf("[%s] expected %s, got %s", tc.description, tc.expected, result)
+		}
+	}
+}
+
+// TestCorpus runs the fuzzing corpus through the same checks as Fuzz
+func TestCorpus(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "corpus", "*"))
+	if err != nil {
+		t.Fatalf("unable to list the corpus: %v", err)
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("unable to read %s: %v", file, err)
+		}
+		ParseImageID(string(data))
+		ref, err := ParseReference(string(data))
+		if err != nil {
+			continue
+		}
+		reparsed, err := ParseReference(ref.String())
+		if err != nil {
+			t.Errorf("[%s] unable to parse %q: %v", file, ref.String(), err)
+			continue
+		}
+		if !reflect.DeepEqual(ref, reparsed) {
+			t.Errorf("[%s] %+v changed to %+v when parsing its string", file, ref, reparsed)
+		}
+		normalized := ref.Normalize()
+		if !reflect.DeepEqual(normalized, normalized.Normalize()) {
+			t.Errorf("[%s] normalizing %q twice changed it", file, data)
+		}
+	}
+}
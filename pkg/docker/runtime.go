@@ -0,0 +1,80 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package docker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RuntimeFromContainerID returns the runtime named by the scheme of a
+// container ID like containerd://4f1e... (docker, containerd or cri-o), or
+// an empty string if the ID has no scheme
+func RuntimeFromContainerID(containerID string) string {
+	i := strings.Index(containerID, "://")
+	if i == -1 {
+		return ""
+	}
+	return containerID[:i]
+}
+
+// IsLocalImageID returns whether the image ID is the ID docker gives an
+// image it has no repository digest for, like docker://sha256:....  The
+// digest of such an ID is the digest of the image config, which no
+// registry serves
+func IsLocalImageID(imageID string) bool {
+	return strings.HasPrefix(imageID, "docker://")
+}
+
+// ParseContainerImage returns the image a container runs from the ImageID
+// and Image of its status.  The runtimes report image IDs differently:
+//
+//	docker:     docker-pullable://nginx@sha256:..., or docker://sha256:...
+//	            for images without a repository digest
+//	containerd: docker.io/library/nginx@sha256:..., or sha256:...
+//	cri-o:      docker.io/library/nginx@sha256:..., or the bare sha256 hex
+//
+// When the image ID has no repository the repository of the image is used
+// with the digest of the ID.  The tag always comes from the image
+func ParseContainerImage(imageID string, image string) (*Reference, error) {
+	ref, err := ParseImageID(imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The image may also be only a digest, which names no repository or tag
+	var imageRef *Reference
+	if _, _, ok := parseDigestOnly(image); !ok {
+		imageRef, _ = ParseReference(image)
+	}
+
+	if len(ref.Repository) == 0 {
+		if imageRef == nil {
+			return nil, fmt.Errorf("image ID %q has no repository and image %q can't be used instead", imageID, image)
+		}
+		ref.Registry, ref.Repository = imageRef.Registry, imageRef.Repository
+	}
+	if imageRef != nil {
+		ref.Tag = imageRef.Tag
+	}
+	return ref, nil
+}
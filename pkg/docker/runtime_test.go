@@ -0,0 +1,161 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package docker
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRuntimeFromContainerID(t *testing.T) {
+	testcases := []struct {
+		description string
+		containerID string
+		expected    string
+	}{
+		{
+			description: "docker",
+			containerID: "docker://4f1e0c1bb9a2",
+			expected:    "docker",
+		},
+		{
+			description: "containerd",
+			containerID: "containerd://4f1e0c1bb9a2",
+			expected:    "containerd",
+		},
+		{
+			description: "cri-o",
+			containerID: "cri-o://4f1e0c1bb9a2",
+			expected:    "cri-o",
+		},
+		{
+			description: "not started yet",
+			containerID: "",
+			expected:    "",
+		},
+	}
+
+	for _, tc := range testcases {
+		result := RuntimeFromContainerID(tc.containerID)
+		if result != tc.expected {
+			t.Errorf("[%s] expected %s, got %s", tc.description, tc.expected, result)
+		}
+	}
+}
+
+func TestIsLocalImageID(t *testing.T) {
+	testcases := []struct {
+		imageID  string
+		expected bool
+	}{
+		{imageID: "docker://sha256:" + testDigest, expected: true},
+		{imageID: "docker-pullable://nginx@sha256:" + testDigest, expected: false},
+		{imageID: "sha256:" + testDigest, expected: false},
+	}
+
+	for _, tc := range testcases {
+		result := IsLocalImageID(tc.imageID)
+		if result != tc.expected {
+			t.Errorf("[%s] expected %t, got %t", tc.imageID, tc.expected, result)
+		}
+	}
+}
+
+func TestParseContainerImage(t *testing.T) {
+	testcases := []struct {
+		description string
+		imageID     string
+		image       string
+		expected    *Reference
+		shouldPass  bool
+	}{
+		{
+			description: "docker with repository digest",
+			imageID:     "docker-pullable://nginx@sha256:" + testDigest,
+			image:       "nginx:1.15",
+			expected:    &Reference{Repository: "nginx", Tag: "1.15", DigestAlgorithm: "sha256", Digest: testDigest},
+			shouldPass:  true,
+		},
+		{
+			description: "docker without repository digest",
+			imageID:     "docker://sha256:" + testDigest,
+			image:       "registry.example.com:5000/team/app:1.0",
+			expected:    &Reference{Registry: "registry.example.com:5000", Repository: "team/app", Tag: "1.0", DigestAlgorithm: "sha256", Digest: testDigest},
+			shouldPass:  true,
+		},
+		{
+			description: "containerd with repository digest",
+			imageID:     "docker.io/library/nginx@sha256:" + testDigest,
+			image:       "docker.io/library/nginx:latest",
+			expected:    &Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "latest", DigestAlgorithm: "sha256", Digest: testDigest},
+			shouldPass:  true,
+		},
+		{
+			description: "containerd with digest only",
+			imageID:     "sha256:" + testDigest,
+			image:       "docker.io/library/nginx:latest",
+			expected:    &Reference{Registry: "docker.io", Repository: "library/nginx", Tag: "latest", DigestAlgorithm: "sha256", Digest: testDigest},
+			shouldPass:  true,
+		},
+		{
+			description: "cri-o with bare digest",
+			imageID:     testDigest,
+			image:       "quay.io/team/app:2.0",
+			expected:    &Reference{Registry: "quay.io", Repository: "team/app", Tag: "2.0", DigestAlgorithm: "sha256", Digest: testDigest},
+			shouldPass:  true,
+		},
+		{
+			description: "image is a digest too",
+			imageID:     "docker.io/library/nginx@sha256:" + testDigest,
+			image:       "sha256:" + testDigest,
+			expected:    &Reference{Registry: "docker.io", Repository: "library/nginx", DigestAlgorithm: "sha256", Digest: testDigest},
+			shouldPass:  true,
+		},
+		{
+			description: "no repository anywhere",
+			imageID:     "sha256:" + testDigest,
+			image:       "sha256:" + testDigest,
+			shouldPass:  false,
+		},
+		{
+			description: "invalid image ID",
+			imageID:     "invalid ID",
+			image:       "nginx",
+			shouldPass:  false,
+		},
+	}
+
+	for _, tc := range testcases {
+		result, err := ParseContainerImage(tc.imageID, tc.image)
+		if err != nil && tc.shouldPass {
+			t.Errorf("[%s] unexpected error: %v", tc.description, err)
+			continue
+		}
+		if err == nil && !tc.shouldPass {
+			t.Errorf("[%s] expected an error, got %+v", tc.description, result)
+			continue
+		}
+		if tc.shouldPass && !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("[%s] expected %+v, got %+v", tc.description, tc.expected, result)
+		}
+	}
+}
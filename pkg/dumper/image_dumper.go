@@ -43,13 +43,16 @@ import (
 type ImageDumper struct {
 	client       imageclient.ImageV1Interface
 	allImagesURL string
+	cluster      string
 }
 
-// NewImageDumper creates a new ImageDumper object
-func NewImageDumper(ic imageclient.ImageV1Interface, perceptorURL string) *ImageDumper {
+// NewImageDumper creates a new ImageDumper object.  If clusterName isn't
+// empty the images are tagged with it
+func NewImageDumper(ic imageclient.ImageV1Interface, perceptorURL string, clusterName string) *ImageDumper {
 	return &ImageDumper{
 		client:       ic,
 		allImagesURL: fmt.Sprintf("%s/%s", perceptorURL, perceptorapi.AllImagesPath),
+		cluster:      clusterName,
 	}
 }
 
@@ -75,7 +78,7 @@ func (id *ImageDumper) Run(interval time.Duration, stopCh <-chan struct{}) {
 		}
 		log.Infof("about to PUT all images -- found %d images", len(images))
 
-		jsonBytes, err := json.Marshal(perceptorapi.NewAllImages(images))
+		jsonBytes, err := json.Marshal(communicator.NewAllImages(id.cluster, images))
 		if err != nil {
 			metrics.RecordError("image_dumper", "unable to serialize all images")
 			log.Errorf("unable to serialize all images: %v", err)
@@ -41,17 +41,17 @@ func TestGetAllImagesAsPerceptorImages(t *testing.T) {
 		ObjectMeta: metav1.ObjectMeta{
 			Name: "invalidImage",
 		},
-		DockerImageReference: "imageName",
+		DockerImageReference: "imagename",
 	}
 	validImage := v1.Image{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "sha256:235n348g24",
+			Name: "sha256:736804b23dd305d189bb2514eaa74063a818297616c7291aea6b9d4c7e143be8",
 		},
-		DockerImageReference: "imageName@sha256:235n348g24",
+		DockerImageReference: "imagename@sha256:736804b23dd305d189bb2514eaa74063a818297616c7291aea6b9d4c7e143be8",
 	}
 	validPerceptorImage := perceptorapi.Image{
-		Repository: "imageName",
-		Sha:        "235n348g24",
+		Repository: "imagename",
+		Sha:        "736804b23dd305d189bb2514eaa74063a818297616c7291aea6b9d4c7e143be8",
 		Priority:   &zero,
 	}
 
@@ -26,12 +26,16 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/blackducksoftware/perceivers/pkg/cluster"
 	"github.com/blackducksoftware/perceivers/pkg/communicator"
 	"github.com/blackducksoftware/perceivers/pkg/mapper"
 	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	"github.com/blackducksoftware/perceivers/pkg/sharding"
 
 	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
 
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -43,18 +47,31 @@ import (
 type PodDumper struct {
 	coreV1     corev1.CoreV1Interface
 	allPodsURL string
+	podURL     string
+	modelURL   string
 	filter     string
+	shard      *sharding.Shard
+	cluster    string
+	resolver   *registry.Resolver
 }
 
-// NewPodDumper creates a new PodDumper object
-func NewPodDumper(core corev1.CoreV1Interface, perceptorURL string, nsFilter string) *PodDumper {
+// NewPodDumper creates a new PodDumper object.  If shard isn't nil only pods
+// in the namespaces owned by the shard are sent.  If clusterName isn't empty
+// every pod is tagged with it and its namespace is qualified with it.  If
+// resolver isn't nil pods are sent before their containers run
+func NewPodDumper(core corev1.CoreV1Interface, perceptorURL string, nsFilter string, shard *sharding.Shard, clusterName string, resolver *registry.Resolver) *PodDumper {
 	if nsFilter == "" {
 		nsFilter = metav1.NamespaceAll
 	}
 	return &PodDumper{
 		coreV1:     core,
 		allPodsURL: fmt.Sprintf("%s/%s", perceptorURL, perceptorapi.AllPodsPath),
+		podURL:     fmt.Sprintf("%s/%s", perceptorURL, perceptorapi.PodPath),
+		modelURL:   fmt.Sprintf("%s/%s", perceptorURL, communicator.ModelPath),
 		filter:     nsFilter,
+		shard:      shard,
+		cluster:    clusterName,
+		resolver:   resolver,
 	}
 }
 
@@ -72,15 +89,26 @@ func (pd *PodDumper) Run(interval time.Duration, stopCh <-chan struct{}) {
 		time.Sleep(interval)
 
 		// Get all the pods in the format perceptor uses
-		pods, err := pd.getAllPodsAsPerceptorPods()
+		pods, current, err := pd.getAllPodsAsPerceptorPods()
 		if err != nil {
 			metrics.RecordError("pod_dumper", "unable to get all pods")
 			log.Errorf("unable to get all pods: %v", err)
 			continue
 		}
+
+		// Sending all pods replaces every pod perceptor knows about, which
+		// would drop the pods of the other shards or clusters, so those pods
+		// are sent one at a time instead.  The pods of the dumper whose
+		// delete events were missed are deleted from perceptor one at a time
+		if pd.shard != nil || len(pd.cluster) > 0 {
+			pd.sendPods(pods)
+			pd.removeStalePods(current)
+			continue
+		}
+
 		log.Infof("about to PUT all pods -- found %d pods", len(pods))
 
-		jsonBytes, err := json.Marshal(perceptorapi.NewAllPods(pods))
+		jsonBytes, err := json.Marshal(communicator.NewAllPods(pd.cluster, pods))
 		if err != nil {
 			metrics.RecordError("pod_dumper", "unable to serialize all pods")
 			log.Errorf("unable to serialize all pods: %v", err)
@@ -99,8 +127,12 @@ func (pd *PodDumper) Run(interval time.Duration, stopCh <-chan struct{}) {
 	}
 }
 
-func (pd *PodDumper) getAllPodsAsPerceptorPods() ([]perceptorapi.Pod, error) {
-	perceptorPods := []perceptorapi.Pod{}
+// getAllPodsAsPerceptorPods returns the pods of the dumper in the format
+// perceptor uses and the qualified names of all of them, including the
+// pods that can't be sent yet
+func (pd *PodDumper) getAllPodsAsPerceptorPods() ([]communicator.Pod, map[string]bool, error) {
+	perceptorPods := []communicator.Pod{}
+	current := map[string]bool{}
 
 	// Get all pods from kubernetes
 	getPodsStart := time.Now()
@@ -108,17 +140,82 @@ func (pd *PodDumper) getAllPodsAsPerceptorPods() ([]perceptorapi.Pod, error) {
 	pods, err := pd.coreV1.Pods(pd.filter).List(metav1.ListOptions{})
 	metrics.RecordDuration("get pods", time.Now().Sub(getPodsStart))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Translate the pods from kubernetes to perceptor format
 	for _, pod := range pods.Items {
-		perceptorPod, err := mapper.NewPerceptorPodFromKubePod(&pod)
+		namespace := cluster.QualifiedNamespace(pd.cluster, pod.Namespace)
+		if !pd.shard.Owns(namespace) {
+			continue
+		}
+		current[fmt.Sprintf("%s/%s", namespace, pod.Name)] = true
+		perceptorPod, err := mapper.NewPerceptorPodFromKubePod(&pod, pd.resolver)
 		if err != nil {
 			metrics.RecordError("pod_dumper", "unable to convert pod to perceptor pod")
 			continue
 		}
-		perceptorPods = append(perceptorPods, *perceptorPod)
+		perceptorPod.Namespace = namespace
+		payload := communicator.NewPod(pd.cluster, *perceptorPod)
+		payload.Runtime = mapper.PodRuntime(&pod)
+		payload.PullSecrets = mapper.PodPullSecrets(&pod, perceptorPod, pd.resolver)
+		perceptorPods = append(perceptorPods, *payload)
+	}
+	return perceptorPods, current, nil
+}
+
+func (pd *PodDumper) sendPods(pods []communicator.Pod) {
+	log.Infof("about to POST %d pods one at a time", len(pods))
+	for _, pod := range pods {
+		err := communicator.SendPerceptorAddEvent(pd.podURL, pod)
+		metrics.RecordHTTPStats(pd.podURL, err == nil)
+		if err != nil {
+			metrics.RecordError("pod_dumper", "unable to send pod")
+			log.Errorf("failed to send pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		}
+	}
+}
+
+// removeStalePods deletes the pods perceptor has in the namespaces of the
+// dumper that aren't current, their delete events were missed.  A pod
+// created since the pods were listed is left for the next dump
+func (pd *PodDumper) removeStalePods(current map[string]bool) {
+	perceptorPods, err := communicator.GetPerceptorPods(pd.modelURL)
+	if err != nil {
+		metrics.RecordError("pod_dumper", "unable to get perceptor pods")
+		log.Errorf("unable to get the pods of perceptor: %v", err)
+		return
+	}
+
+	for _, pod := range perceptorPods {
+		qualifiedName := fmt.Sprintf("%s/%s", pod.Namespace, pod.Name)
+		if current[qualifiedName] || !pd.owns(pod.Namespace) {
+			continue
+		}
+		_, namespace := cluster.SplitNamespace(pod.Namespace)
+		_, err = pd.coreV1.Pods(namespace).Get(pod.Name, metav1.GetOptions{})
+		if !errors.IsNotFound(err) {
+			continue
+		}
+		err = communicator.SendPerceptorPodDeleteEvent(pd.podURL, qualifiedName, pod.UID)
+		metrics.RecordHTTPStats(pd.podURL, err == nil)
+		if err != nil {
+			metrics.RecordError("pod_dumper", "unable to delete pod")
+			log.Errorf("failed to delete pod %s: %v", qualifiedName, err)
+			continue
+		}
+		log.Infof("deleted pod %s from perceptor, it is gone from the cluster", qualifiedName)
+	}
+}
+
+// owns returns whether the dumper sends the pods of the qualified namespace
+func (pd *PodDumper) owns(qualified string) bool {
+	clusterName, namespace := cluster.SplitNamespace(qualified)
+	if clusterName != pd.cluster {
+		return false
+	}
+	if pd.filter != metav1.NamespaceAll && namespace != pd.filter {
+		return false
 	}
-	return perceptorPods, nil
+	return pd.shard.Owns(qualified)
 }
@@ -22,9 +22,16 @@ under the License.
 package dumper
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"testing"
 
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/utils"
+
 	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
 
 	"k8s.io/api/core/v1"
@@ -54,53 +61,112 @@ func TestGetAllPodsAsPerceptorPods(t *testing.T) {
 			Name:      "podName",
 			Namespace: "ns",
 		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "image1"}},
+		},
 		Status: v1.PodStatus{
 			ContainerStatuses: []v1.ContainerStatus{
 				{
 					Name:    "image1",
-					ImageID: "docker-pullable://imageName@sha256:23f2sdf23",
-					Image:   "imageName@sha256:23f2sdf23",
+					ImageID: "docker-pullable://imagename@sha256:cb4983d8399a59bb5ee6e68b6177d878966a8fe41abe18a45c3b1d8809f1d043",
+					Image:   "imagename@sha256:cb4983d8399a59bb5ee6e68b6177d878966a8fe41abe18a45c3b1d8809f1d043",
 				},
 			},
 		},
 	}
-	validPerceptorPod := perceptorapi.Pod{
-		Name:      "podName",
-		Namespace: "ns",
-		Containers: []perceptorapi.Container{
-			{
-				Name: "image1",
-				Image: perceptorapi.Image{
-					Repository: "imageName",
-					Sha:        "23f2sdf23",
-					Tag:        "latest",
+	priority := 1
+	validPerceptorPod := communicator.Pod{
+		Pod: perceptorapi.Pod{
+			Name:      "podName",
+			Namespace: "ns",
+			Containers: []perceptorapi.Container{
+				{
+					Name: "image1",
+					Image: perceptorapi.Image{
+						Repository: "imagename",
+						Sha:        "cb4983d8399a59bb5ee6e68b6177d878966a8fe41abe18a45c3b1d8809f1d043",
+						Priority:   &priority,
+					},
 				},
 			},
 		},
 	}
+	criPod := func(runtime string) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      runtime + "Pod",
+				Namespace: "ns",
+			},
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{{Name: "image1"}},
+			},
+			Status: v1.PodStatus{
+				ContainerStatuses: []v1.ContainerStatus{
+					{
+						Name:        "image1",
+						ContainerID: runtime + "://4f1e0c1bb9a2",
+						ImageID:     "sha256:54f0ed699ee659203143c19086eac558d95ff553c60eaa8f1f8fcc852984261f",
+						Image:       "imagename:latest",
+					},
+				},
+			},
+		}
+	}
+	criPerceptorPod := func(runtime string) communicator.Pod {
+		return communicator.Pod{
+			Pod: perceptorapi.Pod{
+				Name:      runtime + "Pod",
+				Namespace: "ns",
+				Containers: []perceptorapi.Container{
+					{
+						Name: "image1",
+						Image: perceptorapi.Image{
+							Repository: "imagename",
+							Sha:        "54f0ed699ee659203143c19086eac558d95ff553c60eaa8f1f8fcc852984261f",
+							Tag:        "latest",
+							Priority:   &priority,
+						},
+					},
+				},
+			},
+			Runtime: runtime,
+		}
+	}
 
 	testcases := []struct {
 		description string
 		kubePods    v1.PodList
-		expected    []perceptorapi.Pod
+		expected    []communicator.Pod
 		shouldPass  bool
 	}{
 		{
 			description: "valid pods",
 			kubePods:    v1.PodList{Items: []v1.Pod{validPod}},
-			expected:    []perceptorapi.Pod{validPerceptorPod},
+			expected:    []communicator.Pod{validPerceptorPod},
+			shouldPass:  true,
+		},
+		{
+			description: "containerd pods",
+			kubePods:    v1.PodList{Items: []v1.Pod{criPod("containerd")}},
+			expected:    []communicator.Pod{criPerceptorPod("containerd")},
+			shouldPass:  true,
+		},
+		{
+			description: "cri-o pods",
+			kubePods:    v1.PodList{Items: []v1.Pod{criPod("cri-o")}},
+			expected:    []communicator.Pod{criPerceptorPod("cri-o")},
 			shouldPass:  true,
 		},
 		{
 			description: "invalid pod",
 			kubePods:    v1.PodList{Items: []v1.Pod{invalidPod}},
-			expected:    make([]perceptorapi.Pod, 0),
+			expected:    make([]communicator.Pod, 0),
 			shouldPass:  false,
 		},
 		{
 			description: "invalid and valid pods",
 			kubePods:    v1.PodList{Items: []v1.Pod{invalidPod, validPod}},
-			expected:    []perceptorapi.Pod{validPerceptorPod},
+			expected:    []communicator.Pod{validPerceptorPod},
 			shouldPass:  false,
 		},
 	}
@@ -110,10 +176,14 @@ func TestGetAllPodsAsPerceptorPods(t *testing.T) {
 		pd := PodDumper{
 			coreV1: client.CoreV1(),
 		}
-		pods, err := pd.getAllPodsAsPerceptorPods()
+		pods, _, err := pd.getAllPodsAsPerceptorPods()
 		if err != nil && tc.shouldPass {
 			t.Errorf("[%s] unexpected error: %v", tc.description, err)
 		}
+		if len(pods) != len(tc.expected) {
+			t.Errorf("[%s] expected %d pods, got %d", tc.description, len(tc.expected), len(pods))
+			continue
+		}
 		for cnt, pod := range pods {
 			if !reflect.DeepEqual(pod, tc.expected[cnt]) {
 				t.Errorf("[%s] expected pod %v, got %v", tc.description, tc.expected[cnt], pod)
@@ -121,3 +191,36 @@ func TestGetAllPodsAsPerceptorPods(t *testing.T) {
 		}
 	}
 }
+
+func TestRemoveStalePods(t *testing.T) {
+	perceptor := &utils.FakeAPI{}
+	perceptor.Reply(http.MethodGet, "/model", map[string]interface{}{
+		"CoreModel": map[string]interface{}{
+			"Pods": map[string]perceptorapi.Pod{
+				"east/ns/gone":    {Name: "gone", UID: "uid-gone", Namespace: "east/ns"},
+				"east/ns/kept":    {Name: "kept", UID: "uid-kept", Namespace: "east/ns"},
+				"east/ns/new":     {Name: "new", UID: "uid-new", Namespace: "east/ns"},
+				"east/other/gone": {Name: "gone", UID: "uid-other", Namespace: "east/other"},
+				"west/ns/gone":    {Name: "gone", UID: "uid-west", Namespace: "west/ns"},
+			},
+		},
+	})
+	deleted := []string{}
+	perceptor.Handle(http.MethodDelete, "/pod", func(w http.ResponseWriter, r *http.Request) {
+		var name string
+		json.NewDecoder(r.Body).Decode(&name)
+		deleted = append(deleted, fmt.Sprintf("%s %s", name, r.URL.Query().Get("uid")))
+	})
+	server := httptest.NewServer(perceptor)
+	defer server.Close()
+
+	// The pod created since the pods were listed isn't deleted
+	client := fake.NewSimpleClientset(&v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "new", Namespace: "ns"}})
+	pd := NewPodDumper(client.CoreV1(), server.URL, "ns", nil, "east", nil)
+	pd.removeStalePods(map[string]bool{"east/ns/kept": true})
+
+	expected := []string{"east/ns/gone uid-gone"}
+	if !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("expected deletes %v, got %v", expected, deleted)
+	}
+}
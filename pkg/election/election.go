@@ -0,0 +1,273 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package election
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/api/core/v1"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// Config contains the leader election settings of a perceiver
+type Config struct {
+	Enabled              bool
+	Namespace            string
+	LockName             string
+	LeaseDurationSeconds int
+	RenewDeadlineSeconds int
+	RetryPeriodSeconds   int
+}
+
+// Elector makes sure that only a single replica of a perceiver performs
+// the work that must not be duplicated, like polling registries, sending
+// events to perceptor and annotating objects.  When leader election is
+// disabled the work is started right away
+type Elector struct {
+	enabled       bool
+	identity      string
+	lock          resourcelock.Interface
+	leaseDuration time.Duration
+	renewDeadline time.Duration
+	retryPeriod   time.Duration
+}
+
+// NewElector creates a new Elector object.  The leader lock is a ConfigMap
+// named after the component unless a lock name is configured.  If the
+// kube client is nil one is created from the in cluster configuration
+func NewElector(config Config, component string, kubeClient kubernetes.Interface) (*Elector, error) {
+	if !config.Enabled {
+		return &Elector{enabled: false}, nil
+	}
+
+	if kubeClient == nil {
+		clusterConfig, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("unable to build config from cluster: %v", err)
+		}
+		kubeClient, err = kubernetes.NewForConfig(clusterConfig)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create kubernetes client: %v", err)
+		}
+	}
+
+	identity, err := Identity()
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine leader election identity: %v", err)
+	}
+	namespace, err := Namespace(config.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine leader election namespace: %v", err)
+	}
+	lockName := config.LockName
+	if len(lockName) == 0 {
+		lockName = fmt.Sprintf("%s-leader", strings.Replace(component, "_", "-", -1))
+	}
+
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&corev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events(namespace)})
+	recorder := broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: component})
+
+	lock, err := resourcelock.New(resourcelock.ConfigMapsResourceLock, namespace, lockName, kubeClient.CoreV1(), resourcelock.ResourceLockConfig{
+		Identity:      identity,
+		EventRecorder: recorder,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create leader election lock %s/%s: %v", namespace, lockName, err)
+	}
+
+	return &Elector{
+		enabled:       true,
+		identity:      identity,
+		lock:          lock,
+		leaseDuration: durationOrDefault(config.LeaseDurationSeconds, 15*time.Second),
+		renewDeadline: durationOrDefault(config.RenewDeadlineSeconds, 10*time.Second),
+		retryPeriod:   durationOrDefault(config.RetryPeriodSeconds, 2*time.Second),
+	}, nil
+}
+
+// Run calls the start function each time this replica becomes the leader.
+// The channel passed to the start function is closed when stopCh is closed
+// or the leadership is lost, after which the replica stands by to become
+// the leader again.  Run returns when stopCh is closed, the lease isn't
+// renewed any more and a standby replica becomes the leader once it expires
+func (e *Elector) Run(stopCh <-chan struct{}, start func(<-chan struct{})) {
+	if !e.enabled {
+		start(stopCh)
+		return
+	}
+
+	// The elector of client-go can't be stopped, the lock fails once stopCh
+	// is closed so it can't become the leader or renew its lease any more
+	lock := &stoppableLock{Interface: e.lock, stopCh: stopCh}
+	for {
+		stopped := make(chan struct{})
+		le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+			Lock:          lock,
+			LeaseDuration: e.leaseDuration,
+			RenewDeadline: e.renewDeadline,
+			RetryPeriod:   e.retryPeriod,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(leaderStopCh <-chan struct{}) {
+					log.Infof("%s became the leader of %s", e.identity, e.lock.Describe())
+					start(mergeStopChannels(stopCh, leaderStopCh))
+				},
+				OnStoppedLeading: func() {
+					close(stopped)
+				},
+				OnNewLeader: func(identity string) {
+					log.Infof("%s is the leader of %s", identity, e.lock.Describe())
+				},
+			},
+		})
+		if err != nil {
+			log.Errorf("unable to create leader elector: %v", err)
+			return
+		}
+
+		log.Infof("%s waiting to become the leader of %s", e.identity, e.lock.Describe())
+		go le.Run()
+		select {
+		case <-stopCh:
+			return
+		case <-stopped:
+			log.Errorf("%s lost the leadership of %s", e.identity, e.lock.Describe())
+		}
+	}
+}
+
+// RunOnce is Run for work that can't be started again, like controllers
+// whose queues are shut down when they stop.  If the leadership is lost the
+// process exits, so it is restarted as a standby
+func (e *Elector) RunOnce(stopCh <-chan struct{}, start func(<-chan struct{})) {
+	e.Run(stopCh, func(leaderStopCh <-chan struct{}) {
+		go func() {
+			<-leaderStopCh
+			select {
+			case <-stopCh:
+			default:
+				log.Fatalf("%s lost the leadership of %s, exiting", e.identity, e.lock.Describe())
+			}
+		}()
+		start(leaderStopCh)
+	})
+}
+
+// stoppableLock is a leader election lock that fails once its stop channel
+// is closed
+type stoppableLock struct {
+	resourcelock.Interface
+	stopCh <-chan struct{}
+}
+
+func (l *stoppableLock) stopped() error {
+	select {
+	case <-l.stopCh:
+		return fmt.Errorf("leader election of %s is stopped", l.Describe())
+	default:
+		return nil
+	}
+}
+
+// Get returns the record of the lock unless it is stopped
+func (l *stoppableLock) Get() (*resourcelock.LeaderElectionRecord, error) {
+	if err := l.stopped(); err != nil {
+		return nil, err
+	}
+	return l.Interface.Get()
+}
+
+// Create creates the record of the lock unless it is stopped
+func (l *stoppableLock) Create(ler resourcelock.LeaderElectionRecord) error {
+	if err := l.stopped(); err != nil {
+		return err
+	}
+	return l.Interface.Create(ler)
+}
+
+// Update updates the record of the lock unless it is stopped
+func (l *stoppableLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	if err := l.stopped(); err != nil {
+		return err
+	}
+	return l.Interface.Update(ler)
+}
+
+// Identity returns the name this replica uses when coordinating with other
+// replicas, which is the pod name when running in a cluster
+func Identity() (string, error) {
+	if name, ok := os.LookupEnv("POD_NAME"); ok && len(name) > 0 {
+		return name, nil
+	}
+	return os.Hostname()
+}
+
+// Namespace returns the namespace that coordination objects are kept in.
+// The configured namespace wins, then the POD_NAMESPACE environment variable,
+// then the namespace of the service account the pod runs as
+func Namespace(namespace string) (string, error) {
+	if len(namespace) > 0 {
+		return namespace, nil
+	}
+	if ns, ok := os.LookupEnv("POD_NAMESPACE"); ok && len(ns) > 0 {
+		return ns, nil
+	}
+	data, err := ioutil.ReadFile(serviceAccountNamespaceFile)
+	if err != nil {
+		return "", fmt.Errorf("no namespace configured and unable to read %s: %v", serviceAccountNamespaceFile, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func durationOrDefault(seconds int, defaultDuration time.Duration) time.Duration {
+	if seconds <= 0 {
+		return defaultDuration
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func mergeStopChannels(a <-chan struct{}, b <-chan struct{}) <-chan struct{} {
+	merged := make(chan struct{})
+	go func() {
+		defer close(merged)
+		select {
+		case <-a:
+		case <-b:
+		}
+	}()
+	return merged
+}
@@ -0,0 +1,204 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package election
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// flakyLock is a leader election lock whose updates fail while failing is
+// set, like when the API server can't be reached
+type flakyLock struct {
+	resourcelock.Interface
+	failing int32
+}
+
+func (l *flakyLock) Update(ler resourcelock.LeaderElectionRecord) error {
+	if atomic.LoadInt32(&l.failing) == 1 {
+		return fmt.Errorf("unable to reach the API server")
+	}
+	return l.Interface.Update(ler)
+}
+
+// newTestElector creates an elector of a fake cluster with short
+// durations, its lock fails while it is set to
+func newTestElector(t *testing.T) (*Elector, *flakyLock) {
+	os.Setenv("POD_NAME", "perceiver-0")
+	defer os.Unsetenv("POD_NAME")
+	elector, err := NewElector(Config{Enabled: true, Namespace: "perceivers"}, "test-perceiver", fake.NewSimpleClientset())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	lock := &flakyLock{Interface: elector.lock}
+	elector.lock = lock
+	elector.leaseDuration = time.Second
+	elector.renewDeadline = 500 * time.Millisecond
+	elector.retryPeriod = 100 * time.Millisecond
+	return elector, lock
+}
+
+func TestDisabledElectorStartsImmediately(t *testing.T) {
+	elector, err := NewElector(Config{Enabled: false}, "test-perceiver", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stopCh := make(chan struct{})
+	started := false
+	elector.Run(stopCh, func(leaderStopCh <-chan struct{}) {
+		started = true
+	})
+	if !started {
+		t.Errorf("expected the start function to be called when leader election is disabled")
+	}
+}
+
+func TestElectorStops(t *testing.T) {
+	elector, _ := newTestElector(t)
+	stopCh := make(chan struct{})
+	started := make(chan (<-chan struct{}), 1)
+	done := make(chan struct{})
+	go func() {
+		elector.Run(stopCh, func(leaderStopCh <-chan struct{}) {
+			started <- leaderStopCh
+		})
+		close(done)
+	}()
+
+	var leaderStopCh <-chan struct{}
+	select {
+	case leaderStopCh = <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the elector to become the leader")
+	}
+	close(stopCh)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the elector to return when stopCh is closed")
+	}
+	select {
+	case <-leaderStopCh:
+	case <-time.After(time.Second):
+		t.Errorf("expected the channel of the leader to be closed")
+	}
+}
+
+func TestElectorStandsByAfterLosingLeadership(t *testing.T) {
+	elector, lock := newTestElector(t)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	started := make(chan (<-chan struct{}), 2)
+	go elector.Run(stopCh, func(leaderStopCh <-chan struct{}) {
+		started <- leaderStopCh
+	})
+
+	var leaderStopCh <-chan struct{}
+	select {
+	case leaderStopCh = <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the elector to become the leader")
+	}
+
+	// The lease can't be renewed, so the leadership is lost
+	atomic.StoreInt32(&lock.failing, 1)
+	select {
+	case <-leaderStopCh:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the leadership to be lost")
+	}
+
+	// The replica becomes the leader again once the lock can be updated
+	atomic.StoreInt32(&lock.failing, 0)
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected the elector to become the leader again")
+	}
+}
+
+func TestDurationOrDefault(t *testing.T) {
+	testcases := []struct {
+		description string
+		seconds     int
+		expected    time.Duration
+	}{
+		{
+			description: "unset value",
+			seconds:     0,
+			expected:    15 * time.Second,
+		},
+		{
+			description: "negative value",
+			seconds:     -1,
+			expected:    15 * time.Second,
+		},
+		{
+			description: "configured value",
+			seconds:     30,
+			expected:    30 * time.Second,
+		},
+	}
+
+	for _, tc := range testcases {
+		result := durationOrDefault(tc.seconds, 15*time.Second)
+		if result != tc.expected {
+			t.Errorf("[%s] expected %v got %v", tc.description, tc.expected, result)
+		}
+	}
+}
+
+func TestGetNamespace(t *testing.T) {
+	ns, err := Namespace("configured")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ns != "configured" {
+		t.Errorf("expected namespace configured got %s", ns)
+	}
+}
+
+func TestMergeStopChannels(t *testing.T) {
+	a := make(chan struct{})
+	b := make(chan struct{})
+	merged := mergeStopChannels(a, b)
+
+	select {
+	case <-merged:
+		t.Fatalf("merged channel closed before either input channel")
+	default:
+	}
+
+	close(b)
+	select {
+	case <-merged:
+	case <-time.After(time.Second):
+		t.Errorf("merged channel wasn't closed after an input channel was closed")
+	}
+}
@@ -36,11 +36,15 @@ import (
 // perceptor image object
 func NewPerceptorImageFromOSImage(image *imageapi.Image) (*perceptorapi.Image, error) {
 	dockerRef := image.DockerImageReference
-	name, sha, err := docker.ParseImageIDString(dockerRef)
+	ref, err := docker.ParseImageID(dockerRef)
 	if err != nil {
 		metrics.RecordError("image_mapper", "unable to parse openshift imageID")
 		return nil, fmt.Errorf("unable to parse openshift imageID %s: %v", dockerRef, err)
 	}
+	if len(ref.Repository) == 0 {
+		metrics.RecordError("image_mapper", "openshift imageID without repository")
+		return nil, fmt.Errorf("openshift imageID %s has no repository", dockerRef)
+	}
 	priority := 0
-	return perceptorapi.NewImage(name, "", sha, &priority, "", ""), nil
+	return perceptorapi.NewImage(ref.Name(), "", ref.Digest, &priority, "", ""), nil
 }
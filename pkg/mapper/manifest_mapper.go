@@ -0,0 +1,62 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package mapper
+
+import (
+	"fmt"
+
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+// NewPerceptorImagesFromManifest will convert the manifest of a tag in a
+// registry to perceptor image objects.  A manifest list is expanded into
+// one image per platform that names the sha of the list
+func NewPerceptorImagesFromManifest(repository string, tag string, manifest *registry.Manifest) ([]*communicator.Image, error) {
+	_, sha, err := docker.ParseDigest(manifest.Digest)
+	if err != nil {
+		metrics.RecordError("manifest_mapper", "invalid manifest digest")
+		return nil, fmt.Errorf("manifest of %s:%s has an invalid digest: %v", repository, tag, err)
+	}
+
+	priority := 1
+	if !manifest.IsList() {
+		image := perceptorapi.NewImage(repository, tag, sha, &priority, repository, tag)
+		return []*communicator.Image{communicator.NewImage("", *image)}, nil
+	}
+
+	images := []*communicator.Image{}
+	for _, pm := range manifest.Platforms {
+		_, platformSha, err := docker.ParseDigest(pm.Digest)
+		if err != nil {
+			metrics.RecordError("manifest_mapper", "invalid platform manifest digest")
+			return nil, fmt.Errorf("manifest list of %s:%s has an invalid digest for %s: %v", repository, tag, pm.Platform, err)
+		}
+		image := perceptorapi.NewImage(repository, tag, platformSha, &priority, repository, tag)
+		images = append(images, communicator.NewPlatformImage(*image, pm.Platform.String(), sha))
+	}
+	return images, nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package mapper
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+)
+
+func TestNewPerceptorImagesFromManifest(t *testing.T) {
+	indexSha := "736804b23dd305d189bb2514eaa74063a818297616c7291aea6b9d4c7e143be8"
+	amdSha := "0b1e3b8f8b8c3a86d1f6d8d3d43ad2c0a3a6ab8d3c5e3c5b7f5f3e8b5b0a9c1d"
+	armSha := "5f0b1e0c4a6fd4ea4c6d8e2e6c2b3a1a8c5d7f6e4b2a9c8d7e6f5a4b3c2d1e0f"
+	priority := 1
+	image := func(sha string) perceptorapi.Image {
+		return *perceptorapi.NewImage("registry/app", "1.0", sha, &priority, "registry/app", "1.0")
+	}
+
+	testcases := []struct {
+		description string
+		manifest    *registry.Manifest
+		expected    []*communicator.Image
+		shouldPass  bool
+	}{
+		{
+			description: "single manifest",
+			manifest:    &registry.Manifest{MediaType: registry.MediaTypeManifest, Digest: "sha256:" + indexSha},
+			expected:    []*communicator.Image{communicator.NewImage("", image(indexSha))},
+			shouldPass:  true,
+		},
+		{
+			description: "manifest list",
+			manifest: &registry.Manifest{
+				MediaType: registry.MediaTypeManifestList,
+				Digest:    "sha256:" + indexSha,
+				Platforms: []registry.PlatformManifest{
+					{Digest: "sha256:" + amdSha, Platform: registry.Platform{OS: "linux", Architecture: "amd64"}},
+					{Digest: "sha256:" + armSha, Platform: registry.Platform{OS: "linux", Architecture: "arm64", Variant: "v8"}},
+				},
+			},
+			expected: []*communicator.Image{
+				communicator.NewPlatformImage(image(amdSha), "linux/amd64", indexSha),
+				communicator.NewPlatformImage(image(armSha), "linux/arm64/v8", indexSha),
+			},
+			shouldPass: true,
+		},
+		{
+			description: "invalid digest",
+			manifest:    &registry.Manifest{MediaType: registry.MediaTypeManifest, Digest: "sha256:1234"},
+			shouldPass:  false,
+		},
+		{
+			description: "invalid platform digest",
+			manifest: &registry.Manifest{
+				MediaType: registry.MediaTypeImageIndex,
+				Digest:    "sha256:" + indexSha,
+				Platforms: []registry.PlatformManifest{{Digest: "amd64", Platform: registry.Platform{OS: "linux", Architecture: "amd64"}}},
+			},
+			shouldPass: false,
+		},
+	}
+
+	for _, tc := range testcases {
+		result, err := NewPerceptorImagesFromManifest("registry/app", "1.0", tc.manifest)
+		if err != nil && tc.shouldPass {
+			t.Fatalf("[%s] unexpected error: %v", tc.description, err)
+		}
+		if err == nil && !tc.shouldPass {
+			t.Errorf("[%s] expected error but got %v", tc.description, result)
+		}
+		if tc.shouldPass && !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("[%s] expected %v, got %v", tc.description, tc.expected, result)
+		}
+	}
+}
@@ -24,17 +24,27 @@ package mapper
 import (
 	"fmt"
 
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
 	"github.com/blackducksoftware/perceivers/pkg/docker"
 	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
 
 	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
 
 	"k8s.io/api/core/v1"
+
+	log "github.com/sirupsen/logrus"
 )
 
 // NewPerceptorPodFromKubePod will convert a kubernetes pod object to a
-// perceptor pod object
-func NewPerceptorPodFromKubePod(kubePod *v1.Pod) (*perceptorapi.Pod, error) {
+// perceptor pod object.  If resolver isn't nil the images of containers
+// that haven't started, or that docker only knows by a local image ID, are
+// resolved through their registry, so pods are sent before they run
+func NewPerceptorPodFromKubePod(kubePod *v1.Pod, resolver *registry.Resolver) (*perceptorapi.Pod, error) {
+	if resolver.ResolvesImages() {
+		return newPerceptorPodWithResolver(kubePod, resolver)
+	}
+
 	containers := []perceptorapi.Container{}
 	actual := len(kubePod.Status.ContainerStatuses)
 	expected := len(kubePod.Spec.Containers)
@@ -47,15 +57,12 @@ func NewPerceptorPodFromKubePod(kubePod *v1.Pod) (*perceptorapi.Pod, error) {
 	}
 	for _, newCont := range kubePod.Status.ContainerStatuses {
 		if len(newCont.ImageID) > 0 {
-			name, sha, err := docker.ParseImageIDString(newCont.ImageID)
+			ref, err := docker.ParseContainerImage(newCont.ImageID, newCont.Image)
 			if err != nil {
 				metrics.RecordError("pod_mapper", "unable to parse kubernetes imageID")
 				return nil, fmt.Errorf("unable to parse kubernetes imageID string %s from pod %s/%s: %v", newCont.ImageID, kubePod.Namespace, kubePod.Name, err)
 			}
-			_, tag := docker.ParseImageString(newCont.Image)
-			priority := 1
-			addedCont := perceptorapi.NewContainer(*perceptorapi.NewImage(name, tag, sha, &priority, "", ""), newCont.Name)
-			containers = append(containers, *addedCont)
+			containers = append(containers, *newPerceptorContainer(newCont.Name, ref))
 		} else {
 			metrics.RecordError("pod_mapper", "empty kubernetes imageID")
 			return nil, fmt.Errorf("empty kubernetes imageID from pod %s/%s, container %s", kubePod.Namespace, kubePod.Name, newCont.Name)
@@ -63,3 +70,80 @@ func NewPerceptorPodFromKubePod(kubePod *v1.Pod) (*perceptorapi.Pod, error) {
 	}
 	return perceptorapi.NewPod(kubePod.Name, string(kubePod.UID), kubePod.Namespace, containers), nil
 }
+
+// newPerceptorPodWithResolver converts the containers of the pod spec,
+// using the status of a container when its runtime reports a pullable
+// image ID and the registry otherwise.  Manifest lists are replaced by the
+// manifest for the platform of the pod's node
+func newPerceptorPodWithResolver(kubePod *v1.Pod, resolver *registry.Resolver) (*perceptorapi.Pod, error) {
+	statuses := make(map[string]v1.ContainerStatus)
+	for _, status := range kubePod.Status.ContainerStatuses {
+		statuses[status.Name] = status
+	}
+
+	containers := []perceptorapi.Container{}
+	for _, container := range kubePod.Spec.Containers {
+		var ref *docker.Reference
+		var err error
+		status, ok := statuses[container.Name]
+		if ok && len(status.ImageID) > 0 && !docker.IsLocalImageID(status.ImageID) {
+			ref, err = docker.ParseContainerImage(status.ImageID, status.Image)
+			if err != nil {
+				metrics.RecordError("pod_mapper", "unable to parse kubernetes imageID")
+				return nil, fmt.Errorf("unable to parse kubernetes imageID string %s from pod %s/%s: %v", status.ImageID, kubePod.Namespace, kubePod.Name, err)
+			}
+		} else {
+			ref, err = resolver.Resolve(kubePod, container.Image)
+			if err != nil {
+				metrics.RecordError("pod_mapper", "unable to resolve image")
+				return nil, fmt.Errorf("unable to resolve image %s from pod %s/%s, container %s: %v", container.Image, kubePod.Namespace, kubePod.Name, container.Name, err)
+			}
+		}
+		// The digest may be that of a manifest list, while the node runs the
+		// manifest of its own platform
+		platformRef, err := resolver.PlatformImage(kubePod, ref)
+		if err != nil {
+			log.Warningf("unable to find the platform image of %s from pod %s/%s, sending the digest as is: %v", ref, kubePod.Namespace, kubePod.Name, err)
+		}
+		containers = append(containers, *newPerceptorContainer(container.Name, platformRef))
+	}
+	return perceptorapi.NewPod(kubePod.Name, string(kubePod.UID), kubePod.Namespace, containers), nil
+}
+
+func newPerceptorContainer(name string, ref *docker.Reference) *perceptorapi.Container {
+	priority := 1
+	return perceptorapi.NewContainer(*perceptorapi.NewImage(ref.Name(), ref.Tag, ref.Digest, &priority, "", ""), name)
+}
+
+// PodRuntime returns the container runtime that runs the pod's containers,
+// or an empty string if no container has been started yet
+func PodRuntime(kubePod *v1.Pod) string {
+	for _, status := range kubePod.Status.ContainerStatuses {
+		runtime := docker.RuntimeFromContainerID(status.ContainerID)
+		if len(runtime) > 0 {
+			return runtime
+		}
+	}
+	return ""
+}
+
+// PodPullSecrets returns a reference to the pull secret of every container
+// of the perceptor pod whose registry the kube pod has one for
+func PodPullSecrets(kubePod *v1.Pod, pod *perceptorapi.Pod, resolver *registry.Resolver) []communicator.PullSecret {
+	pullSecrets := []communicator.PullSecret{}
+	for _, container := range pod.Containers {
+		ref, err := docker.ParseReference(container.Image.Repository)
+		if err != nil {
+			continue
+		}
+		secret := resolver.PullSecret(kubePod, ref.Normalize().Registry)
+		if secret == nil {
+			continue
+		}
+		pullSecrets = append(pullSecrets, *communicator.NewPullSecret(container.Name, secret.Registry, secret.Namespace, secret.Name))
+	}
+	if len(pullSecrets) == 0 {
+		return nil
+	}
+	return pullSecrets
+}
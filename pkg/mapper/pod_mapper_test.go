@@ -22,14 +22,24 @@ under the License.
 package mapper
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+
 	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
 
 	"k8s.io/api/core/v1"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestNewPerceptorPodFromKubePod(t *testing.T) {
@@ -68,13 +78,13 @@ func TestNewPerceptorPodFromKubePod(t *testing.T) {
 			ContainerStatuses: []v1.ContainerStatus{
 				{
 					Name:    "image1",
-					ImageID: "docker-pullable://imageName@sha256:23f2sdf23",
-					Image:   "imageName",
+					ImageID: "docker-pullable://imagename@sha256:54f0ed699ee659203143c19086eac558d95ff553c60eaa8f1f8fcc852984261f",
+					Image:   "imagename",
 				},
 				{
 					Name:    "image2",
-					ImageID: "docker-pullable://imageName2@sha256:4823nv823rn",
-					Image:   "imageName2",
+					ImageID: "docker-pullable://imagename2@sha256:0923cc8ff139aa0ebcda9c2f9e7dd76dbe7d0d31a22604e58b1080600bbd836a",
+					Image:   "imagename2",
 				},
 			},
 		},
@@ -87,16 +97,16 @@ func TestNewPerceptorPodFromKubePod(t *testing.T) {
 			{
 				Name: "image1",
 				Image: perceptorapi.Image{
-					Repository: "imageName",
-					Sha:        "23f2sdf23",
+					Repository: "imagename",
+					Sha:        "54f0ed699ee659203143c19086eac558d95ff553c60eaa8f1f8fcc852984261f",
 					Priority:   &priority,
 				},
 			},
 			{
 				Name: "image2",
 				Image: perceptorapi.Image{
-					Repository: "imageName2",
-					Sha:        "4823nv823rn",
+					Repository: "imagename2",
+					Sha:        "0923cc8ff139aa0ebcda9c2f9e7dd76dbe7d0d31a22604e58b1080600bbd836a",
 					Priority:   &priority,
 				},
 			},
@@ -117,7 +127,7 @@ func TestNewPerceptorPodFromKubePod(t *testing.T) {
 			ContainerStatuses: []v1.ContainerStatus{
 				{
 					Name:  "image1",
-					Image: "imageName@sha256:23f2sdf23",
+					Image: "imagename@sha256:54f0ed699ee659203143c19086eac558d95ff553c60eaa8f1f8fcc852984261f",
 				},
 			},
 		},
@@ -171,7 +181,7 @@ func TestNewPerceptorPodFromKubePod(t *testing.T) {
 	}
 
 	for _, tc := range testcases {
-		result, err := NewPerceptorPodFromKubePod(tc.pod)
+		result, err := NewPerceptorPodFromKubePod(tc.pod, nil)
 		if err != nil && tc.shouldPass {
 			t.Errorf("[%s] unexpected error: %v", tc.description, err)
 		}
@@ -180,3 +190,124 @@ func TestNewPerceptorPodFromKubePod(t *testing.T) {
 		}
 	}
 }
+
+func TestNewPerceptorPodFromKubePodWithResolver(t *testing.T) {
+	resolvedDigest := "0923cc8ff139aa0ebcda9c2f9e7dd76dbe7d0d31a22604e58b1080600bbd836a"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/team/app/manifests/1.0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", "sha256:"+resolvedDigest)
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	config := registry.Config{Enabled: true, InsecureRegistries: []string{host}}
+	resolver := registry.NewResolver(config, fake.NewSimpleClientset(), "")
+
+	image := fmt.Sprintf("%s/team/app:1.0", host)
+	makePod := func(statuses ...v1.ContainerStatus) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "podName", Namespace: "ns"},
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{{Name: "app", Image: image}},
+			},
+			Status: v1.PodStatus{ContainerStatuses: statuses},
+		}
+	}
+	priority := 1
+	makePerceptorPod := func(sha string) *perceptorapi.Pod {
+		return &perceptorapi.Pod{
+			Name:      "podName",
+			Namespace: "ns",
+			Containers: []perceptorapi.Container{
+				{
+					Name: "app",
+					Image: perceptorapi.Image{
+						Repository: fmt.Sprintf("%s/team/app", host),
+						Tag:        "1.0",
+						Sha:        sha,
+						Priority:   &priority,
+					},
+				},
+			},
+		}
+	}
+	runningDigest := "54f0ed699ee659203143c19086eac558d95ff553c60eaa8f1f8fcc852984261f"
+
+	testcases := []struct {
+		description string
+		pod         *v1.Pod
+		expected    *perceptorapi.Pod
+	}{
+		{
+			description: "pod that isn't scheduled",
+			pod:         makePod(),
+			expected:    makePerceptorPod(resolvedDigest),
+		},
+		{
+			description: "container pulling its image",
+			pod:         makePod(v1.ContainerStatus{Name: "app", Image: image}),
+			expected:    makePerceptorPod(resolvedDigest),
+		},
+		{
+			description: "container with a local docker image ID",
+			pod:         makePod(v1.ContainerStatus{Name: "app", Image: image, ImageID: "docker://sha256:" + runningDigest}),
+			expected:    makePerceptorPod(resolvedDigest),
+		},
+		{
+			description: "running container",
+			pod:         makePod(v1.ContainerStatus{Name: "app", Image: image, ImageID: fmt.Sprintf("docker-pullable://%s/team/app@sha256:%s", host, runningDigest)}),
+			expected:    makePerceptorPod(runningDigest),
+		},
+	}
+
+	for _, tc := range testcases {
+		result, err := NewPerceptorPodFromKubePod(tc.pod, resolver)
+		if err != nil {
+			t.Fatalf("[%s] unexpected error: %v", tc.description, err)
+		}
+		if !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("[%s] expected %v, got %v", tc.description, tc.expected, result)
+		}
+	}
+}
+
+func TestPodPullSecrets(t *testing.T) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "pull", Namespace: "ns"},
+		Type:       v1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{v1.DockerConfigJsonKey: []byte(`{"auths":{"quay.io":{"username":"user","password":"secret"}}}`)},
+	}
+	resolver := registry.NewResolver(registry.Config{SendPullSecrets: true}, fake.NewSimpleClientset(secret), "")
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	resolver.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, resolver.HasSynced) {
+		t.Fatalf("resolver didn't sync")
+	}
+
+	kubePod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "podName", Namespace: "ns"},
+		Spec: v1.PodSpec{
+			ImagePullSecrets: []v1.LocalObjectReference{{Name: "pull"}},
+		},
+	}
+	pod := &perceptorapi.Pod{
+		Name:      "podName",
+		Namespace: "ns",
+		Containers: []perceptorapi.Container{
+			{Name: "private", Image: perceptorapi.Image{Repository: "quay.io/team/app"}},
+			{Name: "public", Image: perceptorapi.Image{Repository: "nginx"}},
+		},
+	}
+
+	expected := []communicator.PullSecret{{Container: "private", Registry: "quay.io", Namespace: "ns", Name: "pull"}}
+	result := PodPullSecrets(kubePod, pod, resolver)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	if result := PodPullSecrets(kubePod, pod, nil); result != nil {
+		t.Errorf("expected no pull secrets without a resolver, got %v", result)
+	}
+}
@@ -26,6 +26,8 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+
+	"k8s.io/client-go/util/workqueue"
 )
 
 var httpResults *prometheus.CounterVec
@@ -35,6 +37,12 @@ var imagesAnnotated *prometheus.CounterVec
 var totalImagesAnnotated *prometheus.CounterVec
 var podsAnnotated *prometheus.CounterVec
 var totalPodsAnnotated *prometheus.CounterVec
+var reconcileDurations *prometheus.HistogramVec
+var reconcileResults *prometheus.CounterVec
+var informerSynced *prometheus.GaugeVec
+var labelChanges *prometheus.CounterVec
+var webhookRejections *prometheus.CounterVec
+var enforcements *prometheus.CounterVec
 
 // RecordError records metric information related to errors
 func RecordError(errorStage string, errorName string) {
@@ -68,7 +76,48 @@ func RecordPodAnnotation(annotator string, podName string) {
 	totalPodsAnnotated.With(prometheus.Labels{"annotator": annotator, "pods_annotated": "total"}).Inc()
 }
 
-// InitMetrics must be called before using any metrics
+// RecordReconcile records the duration and outcome of a single controller
+// reconcile of a work queue item
+func RecordReconcile(controller string, success bool, duration time.Duration) {
+	InitMetrics("test")
+	reconcileDurations.With(prometheus.Labels{"controller": controller}).Observe(duration.Seconds())
+	reconcileResults.With(prometheus.Labels{"controller": controller, "result": fmt.Sprintf("%t", success)}).Inc()
+}
+
+// RecordInformerSynced records whether the informer backing a controller
+// has completed its initial sync
+func RecordInformerSynced(controller string, synced bool) {
+	InitMetrics("test")
+	value := 0.0
+	if synced {
+		value = 1
+	}
+	informerSynced.With(prometheus.Labels{"controller": controller}).Set(value)
+}
+
+// RecordLabelChanges records how many labels of images in a registry an
+// annotator created, deleted or left unchanged
+func RecordLabelChanges(annotator string, change string, count int) {
+	InitMetrics("test")
+	labelChanges.With(prometheus.Labels{"annotator": annotator, "change": change}).Add(float64(count))
+}
+
+// RecordWebhookRejection records a request a webhook rejected and why
+func RecordWebhookRejection(webhook string, reason string) {
+	InitMetrics("test")
+	webhookRejections.With(prometheus.Labels{"webhook": webhook, "reason": reason}).Inc()
+}
+
+// RecordEnforcement records an action an annotator applied to or reverted
+// from an image in violation in a registry
+func RecordEnforcement(annotator string, action string, change string) {
+	InitMetrics("test")
+	enforcements.With(prometheus.Labels{"annotator": annotator, "action": action, "change": change}).Inc()
+}
+
+// InitMetrics must be called before using any metrics.  It also registers
+// the prometheus provider for client-go work queue metrics, so it needs to
+// be called before any work queue is created
 func InitMetrics(subsystem string) {
 	if httpResults != nil {
 		return
@@ -130,6 +179,55 @@ func InitMetrics(subsystem string) {
 			Help:      "total pods annotated",
 		}, []string{"annotator", "pods_annotated"})
 
+	reconcileDurations = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: "perceptor",
+			Subsystem: subsystem,
+			Name:      "reconcile_duration_seconds",
+			Help:      "time taken by a controller to reconcile a single work queue item",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 16),
+		}, []string{"controller"})
+
+	reconcileResults = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "perceptor",
+			Subsystem: subsystem,
+			Name:      "reconciles",
+			Help:      "success/failure outcomes of controller reconciles",
+		}, []string{"controller", "result"})
+
+	informerSynced = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "perceptor",
+			Subsystem: subsystem,
+			Name:      "informer_synced",
+			Help:      "whether the informer of a controller has completed its initial sync (1) or not (0)",
+		}, []string{"controller"})
+
+	labelChanges = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "perceptor",
+			Subsystem: subsystem,
+			Name:      "registry_label_changes",
+			Help:      "labels of images in registries created, deleted or left unchanged by annotators",
+		}, []string{"annotator", "change"})
+
+	webhookRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "perceptor",
+			Subsystem: subsystem,
+			Name:      "webhook_rejections",
+			Help:      "requests rejected by webhooks, by the reason they were rejected",
+		}, []string{"webhook", "reason"})
+
+	enforcements = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "perceptor",
+			Subsystem: subsystem,
+			Name:      "registry_enforcements",
+			Help:      "actions applied to or reverted from images in violation in registries by annotators",
+		}, []string{"annotator", "action", "change"})
+
 	prometheus.MustRegister(errorsCounter)
 	prometheus.MustRegister(durationsHistogram)
 	prometheus.MustRegister(httpResults)
@@ -137,4 +235,12 @@ func InitMetrics(subsystem string) {
 	prometheus.MustRegister(totalImagesAnnotated)
 	prometheus.MustRegister(podsAnnotated)
 	prometheus.MustRegister(totalPodsAnnotated)
+	prometheus.MustRegister(reconcileDurations)
+	prometheus.MustRegister(reconcileResults)
+	prometheus.MustRegister(informerSynced)
+	prometheus.MustRegister(labelChanges)
+	prometheus.MustRegister(webhookRejections)
+	prometheus.MustRegister(enforcements)
+
+	workqueue.SetProvider(newWorkqueueMetricsProvider(subsystem))
 }
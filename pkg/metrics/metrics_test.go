@@ -34,6 +34,11 @@ func TestMetrics(t *testing.T) {
 	RecordHTTPStats("getnextimage", true)
 	RecordPodAnnotation("abc", "def")
 	RecordImageAnnotation("qrs", "tuv")
+	RecordReconcile("pod_controller", true, time.Now().Sub(time.Now()))
+	RecordInformerSynced("pod_controller", true)
+	RecordLabelChanges("quay_annotator", "unchanged", 4)
+	RecordWebhookRejection("quay", "secret")
+	RecordEnforcement("quay_annotator", "expire", "applied")
 
 	message := "finished test case"
 	t.Log(message)
@@ -0,0 +1,113 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// workqueueMetricsProvider implements workqueue.MetricsProvider so the
+// depth, adds, latency, work duration and retries of every named work queue
+// are exposed through prometheus.  All queues share the same metric families
+// and are distinguished by the queue label
+type workqueueMetricsProvider struct {
+	depth        *prometheus.GaugeVec
+	adds         *prometheus.CounterVec
+	latency      *prometheus.SummaryVec
+	workDuration *prometheus.SummaryVec
+	retries      *prometheus.CounterVec
+}
+
+func newWorkqueueMetricsProvider(subsystem string) *workqueueMetricsProvider {
+	p := &workqueueMetricsProvider{
+		depth: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: "perceptor",
+				Subsystem: subsystem,
+				Name:      "workqueue_depth",
+				Help:      "current depth of the work queue",
+			}, []string{"queue"}),
+		adds: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "perceptor",
+				Subsystem: subsystem,
+				Name:      "workqueue_adds",
+				Help:      "total number of adds handled by the work queue",
+			}, []string{"queue"}),
+		latency: prometheus.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Namespace: "perceptor",
+				Subsystem: subsystem,
+				Name:      "workqueue_queue_latency_microseconds",
+				Help:      "how long an item stays in the work queue before being requested",
+			}, []string{"queue"}),
+		workDuration: prometheus.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Namespace: "perceptor",
+				Subsystem: subsystem,
+				Name:      "workqueue_work_duration_microseconds",
+				Help:      "how long processing an item from the work queue takes",
+			}, []string{"queue"}),
+		retries: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: "perceptor",
+				Subsystem: subsystem,
+				Name:      "workqueue_retries",
+				Help:      "total number of retries handled by the work queue",
+			}, []string{"queue"}),
+	}
+
+	prometheus.MustRegister(p.depth)
+	prometheus.MustRegister(p.adds)
+	prometheus.MustRegister(p.latency)
+	prometheus.MustRegister(p.workDuration)
+	prometheus.MustRegister(p.retries)
+
+	return p
+}
+
+// NewDepthMetric returns the depth gauge for the named queue
+func (p *workqueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return p.depth.With(prometheus.Labels{"queue": name})
+}
+
+// NewAddsMetric returns the adds counter for the named queue
+func (p *workqueueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return p.adds.With(prometheus.Labels{"queue": name})
+}
+
+// NewLatencyMetric returns the latency summary for the named queue
+func (p *workqueueMetricsProvider) NewLatencyMetric(name string) workqueue.SummaryMetric {
+	return p.latency.With(prometheus.Labels{"queue": name})
+}
+
+// NewWorkDurationMetric returns the work duration summary for the named queue
+func (p *workqueueMetricsProvider) NewWorkDurationMetric(name string) workqueue.SummaryMetric {
+	return p.workDuration.With(prometheus.Labels{"queue": name})
+}
+
+// NewRetriesMetric returns the retries counter for the named queue
+func (p *workqueueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return p.retries.With(prometheus.Labels{"queue": name})
+}
@@ -0,0 +1,104 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package perceiver
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/election"
+	"github.com/blackducksoftware/perceivers/pkg/utils"
+	"github.com/spf13/viper"
+)
+
+// PerceptorConfig contains Perceptor config
+type PerceptorConfig struct {
+	Host string
+	Port int
+}
+
+// URL returns the URL perceptor is reached at
+func (c *PerceptorConfig) URL() string {
+	return fmt.Sprintf("http://%s:%d", c.Host, c.Port)
+}
+
+// Config contains the Perceiver config every registry perceiver has.  A
+// perceiver's own config squashes it in next to its registry's section
+type Config struct {
+	Certificate               string
+	CertificateKey            string
+	AnnotationIntervalSeconds int
+	DumpIntervalMinutes       int
+	Port                      int
+	LeaderElection            election.Config
+	ImageMatching             docker.MatchConfig
+}
+
+// AnnotationInterval returns how long the annotator waits between runs
+func (c *Config) AnnotationInterval() time.Duration {
+	return time.Second * time.Duration(c.AnnotationIntervalSeconds)
+}
+
+// DumpInterval returns how long the controller waits between runs
+func (c *Config) DumpInterval() time.Duration {
+	return time.Minute * time.Duration(c.DumpIntervalMinutes)
+}
+
+// ReadConfig reads the configuration file into the config
+func ReadConfig(configPath string, config interface{}) error {
+	viper.SetConfigFile(configPath)
+
+	err := viper.ReadInConfig()
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	err = viper.Unmarshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal config: %v", err)
+	}
+	return nil
+}
+
+// PrivateDockerRegistries returns the registries and their credentials
+// from the securedRegistries.json environment variable
+func PrivateDockerRegistries() ([]*utils.RegistryAuth, error) {
+	credentials, ok := os.LookupEnv("securedRegistries.json")
+	if !ok {
+		return nil, fmt.Errorf("cannot find Private Docker Registries: environment variable securedRegistries not found")
+	}
+
+	privateDockerRegistries := map[string]*utils.RegistryAuth{}
+	err := json.Unmarshal([]byte(credentials), &privateDockerRegistries)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshall Private Docker registries due to %+v", err)
+	}
+
+	dockerRegistries := []*utils.RegistryAuth{}
+	for _, privatedockerRegistry := range privateDockerRegistries {
+		dockerRegistries = append(dockerRegistries, privatedockerRegistry)
+	}
+	return dockerRegistries, nil
+}
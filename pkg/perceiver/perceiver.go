@@ -0,0 +1,104 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package perceiver
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/election"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Poller is a controller or annotator that runs at an interval until it
+// is stopped
+type Poller interface {
+	Run(interval time.Duration, stopCh <-chan struct{})
+}
+
+// Webhook receives the pushes of a registry
+type Webhook interface {
+	Run()
+}
+
+// Perceiver handles the images of a registry: every replica receives its
+// webhooks, only the leader polls and annotates
+type Perceiver struct {
+	name               string
+	controller         Poller
+	annotator          Poller
+	webhook            Webhook
+	annotationInterval time.Duration
+	dumpInterval       time.Duration
+	dumper             bool
+	elector            *election.Elector
+}
+
+// Setup configures prometheus for metrics and sets the log level, before
+// anything of a perceiver is created
+func Setup(logLevel string) {
+	prometheus.Unregister(prometheus.NewProcessCollector(os.Getpid(), ""))
+	prometheus.Unregister(prometheus.NewGoCollector())
+	http.Handle("/metrics", prometheus.Handler())
+
+	level, err := log.ParseLevel(logLevel)
+	if err != nil {
+		level = log.DebugLevel
+	}
+	log.SetLevel(level)
+}
+
+// NewPerceiver creates a new Perceiver object.  The controller only runs if
+// dumper is set
+func NewPerceiver(name string, config *Config, controller Poller, annotator Poller, webhook Webhook, dumper bool) (*Perceiver, error) {
+	elector, err := election.NewElector(config.LeaderElection, name, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create leader elector: %v", err)
+	}
+	return &Perceiver{
+		name:               name,
+		controller:         controller,
+		annotator:          annotator,
+		webhook:            webhook,
+		annotationInterval: config.AnnotationInterval(),
+		dumpInterval:       config.DumpInterval(),
+		dumper:             dumper,
+		elector:            elector,
+	}, nil
+}
+
+// Run starts the Perceiver watching and annotating Images
+func (p *Perceiver) Run(stopCh <-chan struct{}) {
+	log.Infof("starting %s controllers", p.name)
+	go p.webhook.Run()
+	go p.elector.Run(stopCh, func(leaderStopCh <-chan struct{}) {
+		// Only run if config set
+		if p.dumper {
+			go p.controller.Run(p.dumpInterval, leaderStopCh)
+		}
+		go p.annotator.Run(p.annotationInterval, leaderStopCh)
+	})
+	<-stopCh
+}
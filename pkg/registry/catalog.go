@@ -0,0 +1,149 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+)
+
+// pageSize is how many repositories or tags are asked for at a time
+const pageSize = 100
+
+// catalogResponse is a page of the repositories of a registry
+type catalogResponse struct {
+	Repositories []string `json:"repositories"`
+}
+
+// tagsResponse is a page of the tags of a repository
+type tagsResponse struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// Catalog lists the repositories of a registry.  Registries that only let
+// some users list the catalog return the repositories those credentials
+// can see
+func (c *Client) Catalog(registry string, cred *Credentials) ([]string, error) {
+	repositories := []string{}
+	next := fmt.Sprintf("%s/v2/_catalog?n=%d", c.baseURL(registry), pageSize)
+	for len(next) > 0 {
+		page := catalogResponse{}
+		var err error
+		next, err = c.page(next, "", cred, &page)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list the catalog of %s: %v", registry, err)
+		}
+		repositories = append(repositories, page.Repositories...)
+	}
+	return repositories, nil
+}
+
+// Tags lists the tags of a repository
+func (c *Client) Tags(registry string, repository string, cred *Credentials) ([]string, error) {
+	tags := []string{}
+	next := fmt.Sprintf("%s/v2/%s/tags/list?n=%d", c.baseURL(registry), repository, pageSize)
+	for len(next) > 0 {
+		page := tagsResponse{}
+		var err error
+		next, err = c.page(next, repository, cred, &page)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list the tags of %s/%s: %v", registry, repository, err)
+		}
+		tags = append(tags, page.Tags...)
+	}
+	return tags, nil
+}
+
+// ManifestHead gets the media type and digest of the manifest the
+// reference points at with a HEAD request.  Only a manifest list, or a
+// manifest of a registry that doesn't send its digest, is downloaded
+func (c *Client) ManifestHead(ref *docker.Reference, cred *Credentials) (*Manifest, error) {
+	normalized := ref.Normalize()
+	reference := normalized.FullDigest()
+	if len(reference) == 0 {
+		reference = normalized.Tag
+	}
+	if len(reference) == 0 {
+		reference = "latest"
+	}
+
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL(normalized.Registry), normalized.Repository, reference)
+	resp, err := c.do(http.MethodHead, manifestURL, normalized.Repository, cred)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	manifest := &Manifest{MediaType: strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if len(digest) == 0 || manifest.IsList() {
+		return c.Manifest(ref, cred)
+	}
+	manifest.Digest, err = validDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// page gets one page of a list and returns the URL of the next page, if
+// the registry sent a Link header for one
+func (c *Client) page(pageURL string, repository string, cred *Credentials, target interface{}) (string, error) {
+	resp, err := c.do(http.MethodGet, pageURL, repository, cred)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(target)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode %s: %v", pageURL, err)
+	}
+	return nextPage(pageURL, resp.Header.Get("Link"))
+}
+
+// nextPage returns the URL of the next page from a Link header like
+// </v2/_catalog?last=team%2Fapp&n=100>; rel="next", resolved against the
+// URL of the current page
+func nextPage(pageURL string, link string) (string, error) {
+	if len(link) == 0 {
+		return "", nil
+	}
+	start, end := strings.Index(link, "<"), strings.Index(link, ">")
+	if start == -1 || end < start || !strings.Contains(link[end:], `rel="next"`) {
+		return "", nil
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", err
+	}
+	next, err := base.Parse(link[start+1 : end])
+	if err != nil {
+		return "", fmt.Errorf("invalid Link header %q: %v", link, err)
+	}
+	return next.String(), nil
+}
@@ -0,0 +1,175 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+)
+
+// fakeCatalog is a stand-in for a registry that lists two pages of
+// repositories and tags, and only lets a bearer token for the catalog
+// scope list the catalog
+func fakeCatalog(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			json.NewEncoder(w).Encode(tokenResponse{AccessToken: r.URL.Query().Get("scope")})
+		case "/v2/_catalog":
+			if r.Header.Get("Authorization") != "Bearer registry:catalog:*" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, server.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("last") == "" {
+				w.Header().Set("Link", `</v2/_catalog?last=team%2Fapp&n=100>; rel="next"`)
+				json.NewEncoder(w).Encode(catalogResponse{Repositories: []string{"team/api", "team/app"}})
+				return
+			}
+			json.NewEncoder(w).Encode(catalogResponse{Repositories: []string{"team/web"}})
+		case "/v2/team/app/tags/list":
+			if r.URL.Query().Get("last") == "" {
+				w.Header().Set("Link", fmt.Sprintf(`<%s/v2/team/app/tags/list?last=1.0&n=100>; rel="next"`, server.URL))
+				json.NewEncoder(w).Encode(tagsResponse{Name: "team/app", Tags: []string{"1.0"}})
+				return
+			}
+			json.NewEncoder(w).Encode(tagsResponse{Name: "team/app", Tags: []string{"2.0"}})
+		case "/v2/team/app/manifests/1.0":
+			w.Header().Set("Content-Type", MediaTypeManifest)
+			w.Header().Set("Docker-Content-Digest", testDigest)
+			if r.Method != http.MethodHead {
+				t.Errorf("expected only a HEAD request for a manifest, got %s", r.Method)
+			}
+		case "/v2/team/app/manifests/2.0":
+			w.Header().Set("Content-Type", MediaTypeImageIndex)
+			w.Header().Set("Docker-Content-Digest", testDigest)
+			if r.Method == http.MethodGet {
+				w.Write([]byte(testManifestList))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server
+}
+
+func TestCatalog(t *testing.T) {
+	server := fakeCatalog(t)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	client := NewClient([]string{host}, time.Second)
+
+	repositories, err := client.Catalog(host, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"team/api", "team/app", "team/web"}
+	if !reflect.DeepEqual(repositories, expected) {
+		t.Errorf("expected repositories %v, got %v", expected, repositories)
+	}
+
+	tags, err := client.Tags(host, "team/app", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = []string{"1.0", "2.0"}
+	if !reflect.DeepEqual(tags, expected) {
+		t.Errorf("expected tags %v, got %v", expected, tags)
+	}
+
+	_, err = client.Tags(host, "team/missing", nil)
+	if err == nil {
+		t.Errorf("expected an error listing the tags of a missing repository")
+	}
+}
+
+func TestManifestHead(t *testing.T) {
+	server := fakeCatalog(t)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	client := NewClient([]string{host}, time.Second)
+
+	manifest, err := client.ManifestHead(&docker.Reference{Registry: host, Repository: "team/app", Tag: "1.0"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if manifest.Digest != testDigest || manifest.IsList() {
+		t.Errorf("unexpected manifest %+v", manifest)
+	}
+
+	// The platforms of a manifest list are only in its body
+	manifest, err = client.ManifestHead(&docker.Reference{Registry: host, Repository: "team/app", Tag: "2.0"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if manifest.Digest != testDigest || !manifest.IsList() || len(manifest.Platforms) != 2 {
+		t.Errorf("unexpected manifest %+v", manifest)
+	}
+}
+
+func TestNextPage(t *testing.T) {
+	testcases := []struct {
+		description string
+		link        string
+		expected    string
+	}{
+		{
+			description: "relative link",
+			link:        `</v2/_catalog?last=b&n=100>; rel="next"`,
+			expected:    "https://registry.example.com/v2/_catalog?last=b&n=100",
+		},
+		{
+			description: "absolute link",
+			link:        `<https://other.example.com/v2/_catalog?last=b>; rel="next"`,
+			expected:    "https://other.example.com/v2/_catalog?last=b",
+		},
+		{
+			description: "no link",
+			link:        "",
+			expected:    "",
+		},
+		{
+			description: "not the next page",
+			link:        `</v2/_catalog?n=100>; rel="first"`,
+			expected:    "",
+		},
+	}
+
+	for _, tc := range testcases {
+		next, err := nextPage("https://registry.example.com/v2/_catalog?n=100", tc.link)
+		if err != nil {
+			t.Fatalf("[%s] unexpected error: %v", tc.description, err)
+		}
+		if next != tc.expected {
+			t.Errorf("[%s] expected %s, got %s", tc.description, tc.expected, next)
+		}
+	}
+}
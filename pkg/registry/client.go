@@ -0,0 +1,334 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+)
+
+// defaultRegistryHost is the host that serves the registry API of the
+// default registry
+const defaultRegistryHost = "registry-1.docker.io"
+
+// ManifestMediaTypes are the manifest formats the client accepts, most
+// preferred first
+var ManifestMediaTypes = []string{
+	MediaTypeManifestList,
+	MediaTypeImageIndex,
+	MediaTypeManifest,
+	MediaTypeImageManifest,
+	"application/vnd.docker.distribution.manifest.v1+prettyjws",
+}
+
+// Client talks to registries through the Docker Registry HTTP API v2
+type Client struct {
+	client   *http.Client
+	insecure map[string]bool
+}
+
+// NewClient creates a new Client object.  The insecure registries are
+// reached over plain http
+func NewClient(insecureRegistries []string, timeout time.Duration) *Client {
+	insecure := make(map[string]bool)
+	for _, registry := range insecureRegistries {
+		insecure[strings.ToLower(registry)] = true
+	}
+	return &Client{
+		client:   &http.Client{Timeout: timeout},
+		insecure: insecure,
+	}
+}
+
+// ManifestDigest returns the digest of the manifest the reference points
+// at.  A reference that already has a digest is returned as is, one
+// without a tag resolves latest
+func (c *Client) ManifestDigest(ref *docker.Reference, cred *Credentials) (string, error) {
+	if len(ref.Digest) > 0 {
+		return ref.FullDigest(), nil
+	}
+	normalized := ref.Normalize()
+	tag := normalized.Tag
+	if len(tag) == 0 {
+		tag = "latest"
+	}
+
+	manifestURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL(normalized.Registry), normalized.Repository, tag)
+	resp, err := c.do(http.MethodHead, manifestURL, normalized.Repository, cred)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	if digest := resp.Header.Get("Docker-Content-Digest"); len(digest) > 0 {
+		return validDigest(digest)
+	}
+
+	// Some registries only send the digest with the manifest, so hash it
+	resp, err = c.do(http.MethodGet, manifestURL, normalized.Repository, cred)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("unable to read manifest of %s: %v", ref, err)
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); len(digest) > 0 {
+		return validDigest(digest)
+	}
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf("sha256:%s", hex.EncodeToString(sum[:])), nil
+}
+
+// Manifest gets the manifest the reference points at, by digest if it has
+// one and otherwise by tag
+func (c *Client) Manifest(ref *docker.Reference, cred *Credentials) (*Manifest, error) {
+	normalized := ref.Normalize()
+	reference := normalized.FullDigest()
+	if len(reference) == 0 {
+		reference = normalized.Tag
+	}
+	if len(reference) == 0 {
+		reference = "latest"
+	}
+	return c.ManifestAt(fmt.Sprintf("%s/v2", c.baseURL(normalized.Registry)), normalized.Repository, reference, cred)
+}
+
+// ManifestAt gets a manifest from the registry API at apiURL, which is the
+// URL that /<repository>/manifests/<reference> is appended to.  Registries
+// like Artifactory serve the API under a path of their own
+func (c *Client) ManifestAt(apiURL string, repository string, reference string, cred *Credentials) (*Manifest, error) {
+	manifestURL := fmt.Sprintf("%s/%s/manifests/%s", strings.TrimSuffix(apiURL, "/"), repository, reference)
+	resp, err := c.do(http.MethodGet, manifestURL, repository, cred)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest %s of %s: %v", reference, repository, err)
+	}
+	manifest, err := ParseManifest(resp.Header.Get("Content-Type"), body)
+	if err != nil {
+		return nil, fmt.Errorf("manifest %s of %s: %v", reference, repository, err)
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); len(digest) > 0 {
+		manifest.Digest, err = validDigest(digest)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return manifest, nil
+}
+
+// baseURL returns the scheme and host of the registry API
+func (c *Client) baseURL(registry string) string {
+	host := registry
+	if host == docker.DefaultRegistry {
+		host = defaultRegistryHost
+	}
+	if c.insecure[strings.ToLower(registry)] {
+		return fmt.Sprintf("http://%s", host)
+	}
+	return fmt.Sprintf("https://%s", host)
+}
+
+// do sends a request to the registry API.  If the registry challenges it the request
+// is sent again with the credentials or a bearer token they are exchanged for
+func (c *Client) do(method string, apiURL string, repository string, cred *Credentials) (*http.Response, error) {
+	resp, err := c.send(method, apiURL, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		authorization, err := c.authorize(resp.Header.Get("WWW-Authenticate"), repository, cred)
+		if err != nil {
+			return nil, err
+		}
+		resp, err = c.send(method, apiURL, authorization)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s %s returned %d", method, apiURL, resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func (c *Client) send(method string, url string, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request for %s: %v", url, err)
+	}
+	req.Header.Set("Accept", strings.Join(ManifestMediaTypes, ", "))
+	if len(authorization) > 0 {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to %s %s: %v", method, url, err)
+	}
+	return resp, nil
+}
+
+// authorize answers the challenge of a registry with the value of the
+// Authorization header
+func (c *Client) authorize(header string, repository string, cred *Credentials) (string, error) {
+	scheme, params := parseChallenge(header)
+	switch scheme {
+	case "basic":
+		if cred == nil {
+			return "", fmt.Errorf("registry requires credentials for %s", repository)
+		}
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(cred.Username, cred.Password)
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+		token, err := c.token(params, repository, cred)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Bearer %s", token), nil
+	}
+	return "", fmt.Errorf("unsupported authentication challenge %q", header)
+}
+
+// tokenResponse is the reply of a token server, older servers send token
+// and newer ones access_token
+type tokenResponse struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+}
+
+// token gets a bearer token from the token server named by a challenge
+func (c *Client) token(params map[string]string, repository string, cred *Credentials) (string, error) {
+	realm, ok := params["realm"]
+	if !ok {
+		return "", fmt.Errorf("bearer challenge has no realm")
+	}
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("invalid token realm %s: %v", realm, err)
+	}
+	query := tokenURL.Query()
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+	scope, ok := params["scope"]
+	if !ok && len(repository) > 0 {
+		scope = fmt.Sprintf("repository:%s:pull", repository)
+	} else if !ok {
+		scope = "registry:catalog:*"
+	}
+	query.Set("scope", scope)
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to create token request: %v", err)
+	}
+	if cred != nil {
+		req.SetBasicAuth(cred.Username, cred.Password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to get token from %s: %v", realm, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token server %s returned %d", realm, resp.StatusCode)
+	}
+
+	var tr tokenResponse
+	err = json.NewDecoder(resp.Body).Decode(&tr)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode token from %s: %v", realm, err)
+	}
+	if len(tr.Token) > 0 {
+		return tr.Token, nil
+	}
+	if len(tr.AccessToken) > 0 {
+		return tr.AccessToken, nil
+	}
+	return "", fmt.Errorf("token server %s returned no token", realm)
+}
+
+// parseChallenge parses a WWW-Authenticate header like
+// Bearer realm="https://auth.example.com/token",service="registry" into its
+// lower cased scheme and parameters
+func parseChallenge(header string) (string, map[string]string) {
+	params := make(map[string]string)
+	header = strings.TrimSpace(header)
+	i := strings.Index(header, " ")
+	if i == -1 {
+		return strings.ToLower(header), params
+	}
+	scheme, rest := strings.ToLower(header[:i]), header[i+1:]
+
+	for len(rest) > 0 {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.Index(rest, "=")
+		if eq == -1 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end == -1 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.Index(rest, ",")
+			if end == -1 {
+				value, rest = rest, ""
+			} else {
+				value, rest = rest[:end], rest[end:]
+			}
+		}
+		params[key] = value
+	}
+	return scheme, params
+}
+
+func validDigest(digest string) (string, error) {
+	algorithm, encoded, err := docker.ParseDigest(digest)
+	if err != nil {
+		return "", fmt.Errorf("registry returned an invalid digest: %v", err)
+	}
+	return fmt.Sprintf("%s:%s", algorithm, encoded), nil
+}
@@ -0,0 +1,218 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+)
+
+const testDigest = "sha256:cb4983d8399a59bb5ee6e68b6177d878966a8fe41abe18a45c3b1d8809f1d043"
+
+// fakeRegistry is a stand-in for a registry serving one manifest.  It
+// challenges requests for a bearer token or basic credentials when auth is
+// set, and only sends the digest with GET requests when headDigest is false
+type fakeRegistry struct {
+	auth       string
+	headDigest bool
+	manifest   string
+	requests   int
+
+	server *httptest.Server
+}
+
+func newFakeRegistry(auth string, headDigest bool) *fakeRegistry {
+	fr := &fakeRegistry{auth: auth, headDigest: headDigest, manifest: `{"schemaVersion":2}`}
+	fr.server = httptest.NewServer(fr)
+	return fr
+}
+
+func (fr *fakeRegistry) host() string {
+	return strings.TrimPrefix(fr.server.URL, "http://")
+}
+
+func (fr *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "user" || password != "secret" || r.URL.Query().Get("scope") != "repository:team/app:pull" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(tokenResponse{Token: "token"})
+		return
+	}
+
+	fr.requests++
+	switch fr.auth {
+	case "bearer":
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, fr.server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	case "basic":
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "user" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+	if r.URL.Path != "/v2/team/app/manifests/1.0" && r.URL.Path != "/v2/team/app/manifests/"+testDigest {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if fr.headDigest {
+		w.Header().Set("Docker-Content-Digest", testDigest)
+	}
+	if r.Method == http.MethodGet {
+		w.Write([]byte(fr.manifest))
+	}
+}
+
+func TestManifestDigest(t *testing.T) {
+	cred := &Credentials{Username: "user", Password: "secret"}
+	manifestSum := sha256.Sum256([]byte(`{"schemaVersion":2}`))
+	testcases := []struct {
+		description string
+		auth        string
+		headDigest  bool
+		image       string
+		cred        *Credentials
+		expected    string
+		shouldPass  bool
+	}{
+		{
+			description: "anonymous registry",
+			headDigest:  true,
+			image:       "team/app:1.0",
+			expected:    testDigest,
+			shouldPass:  true,
+		},
+		{
+			description: "bearer token",
+			auth:        "bearer",
+			headDigest:  true,
+			image:       "team/app:1.0",
+			cred:        cred,
+			expected:    testDigest,
+			shouldPass:  true,
+		},
+		{
+			description: "bearer token without credentials",
+			auth:        "bearer",
+			headDigest:  true,
+			image:       "team/app:1.0",
+			shouldPass:  false,
+		},
+		{
+			description: "basic auth",
+			auth:        "basic",
+			headDigest:  true,
+			image:       "team/app:1.0",
+			cred:        cred,
+			expected:    testDigest,
+			shouldPass:  true,
+		},
+		{
+			description: "digest hashed from the manifest",
+			headDigest:  false,
+			image:       "team/app:1.0",
+			expected:    "sha256:" + hex.EncodeToString(manifestSum[:]),
+			shouldPass:  true,
+		},
+		{
+			description: "unknown tag",
+			headDigest:  true,
+			image:       "team/app:2.0",
+			shouldPass:  false,
+		},
+	}
+
+	for _, tc := range testcases {
+		fr := newFakeRegistry(tc.auth, tc.headDigest)
+		defer fr.server.Close()
+
+		ref, err := docker.ParseReference(fmt.Sprintf("%s/%s", fr.host(), tc.image))
+		if err != nil {
+			t.Fatalf("[%s] unable to parse image: %v", tc.description, err)
+		}
+		client := NewClient([]string{fr.host()}, time.Second)
+		digest, err := client.ManifestDigest(ref, tc.cred)
+		if err != nil && tc.shouldPass {
+			t.Fatalf("[%s] unexpected error: %v", tc.description, err)
+		}
+		if err == nil && !tc.shouldPass {
+			t.Fatalf("[%s] expected an error", tc.description)
+		}
+		if digest != tc.expected {
+			t.Errorf("[%s] expected digest %s, got %s", tc.description, tc.expected, digest)
+		}
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	testcases := []struct {
+		description    string
+		header         string
+		expectedScheme string
+		expectedParams map[string]string
+	}{
+		{
+			description:    "bearer",
+			header:         `Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`,
+			expectedScheme: "bearer",
+			expectedParams: map[string]string{"realm": "https://auth.docker.io/token", "service": "registry.docker.io", "scope": "repository:library/nginx:pull"},
+		},
+		{
+			description:    "basic with unquoted realm",
+			header:         `Basic realm=registry`,
+			expectedScheme: "basic",
+			expectedParams: map[string]string{"realm": "registry"},
+		},
+		{
+			description:    "no parameters",
+			header:         `Basic`,
+			expectedScheme: "basic",
+			expectedParams: map[string]string{},
+		},
+	}
+
+	for _, tc := range testcases {
+		scheme, params := parseChallenge(tc.header)
+		if scheme != tc.expectedScheme {
+			t.Errorf("[%s] expected scheme %s, got %s", tc.description, tc.expectedScheme, scheme)
+		}
+		if fmt.Sprint(params) != fmt.Sprint(tc.expectedParams) {
+			t.Errorf("[%s] expected params %v, got %v", tc.description, tc.expectedParams, params)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+
+	"k8s.io/api/core/v1"
+)
+
+// defaultRegistryAliases are the other names the default registry is known
+// by in docker config files
+var defaultRegistryAliases = []string{"index.docker.io", "registry-1.docker.io", "registry.hub.docker.com"}
+
+// Credentials are the user name and password used to pull from a registry
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// String returns the user name of the credentials, the password is
+// redacted so credentials can't end up in the logs
+func (c *Credentials) String() string {
+	return fmt.Sprintf("{Username:%s Password:<redacted>}", c.Username)
+}
+
+// Keyring holds the credentials of registries by host
+type Keyring map[string]*Credentials
+
+// dockerConfigEntry is an entry of a .dockercfg or .docker/config.json
+type dockerConfigEntry struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Auth     string `json:"auth"`
+}
+
+// dockerConfigJSON is the content of a kubernetes.io/dockerconfigjson secret
+type dockerConfigJSON struct {
+	Auths map[string]dockerConfigEntry `json:"auths"`
+}
+
+// ParsePullSecret reads the credentials of a kubernetes.io/dockerconfigjson
+// or kubernetes.io/dockercfg secret
+func ParsePullSecret(secret *v1.Secret) (Keyring, error) {
+	var entries map[string]dockerConfigEntry
+	switch secret.Type {
+	case v1.SecretTypeDockerConfigJson:
+		var config dockerConfigJSON
+		err := json.Unmarshal(secret.Data[v1.DockerConfigJsonKey], &config)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %s of secret %s/%s: %v", v1.DockerConfigJsonKey, secret.Namespace, secret.Name, err)
+		}
+		entries = config.Auths
+	case v1.SecretTypeDockercfg:
+		err := json.Unmarshal(secret.Data[v1.DockerConfigKey], &entries)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %s of secret %s/%s: %v", v1.DockerConfigKey, secret.Namespace, secret.Name, err)
+		}
+	default:
+		return nil, fmt.Errorf("secret %s/%s of type %s isn't a pull secret", secret.Namespace, secret.Name, secret.Type)
+	}
+
+	keyring := make(Keyring)
+	for key, entry := range entries {
+		cred := &Credentials{Username: entry.Username, Password: entry.Password}
+		if len(cred.Username) == 0 && len(entry.Auth) > 0 {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth for %s in secret %s/%s", key, secret.Namespace, secret.Name)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid auth for %s in secret %s/%s", key, secret.Namespace, secret.Name)
+			}
+			cred.Username, cred.Password = parts[0], parts[1]
+		}
+		keyring[registryHost(key)] = cred
+	}
+	return keyring, nil
+}
+
+// Lookup returns the credentials of the registry, or nil if there are none
+func (k Keyring) Lookup(registry string) *Credentials {
+	return k[registryHost(registry)]
+}
+
+// merge adds the credentials of another keyring.  The first credentials of
+// a registry win, like the first pull secret does in the kubelet
+func (k Keyring) merge(other Keyring) {
+	for host, cred := range other {
+		if _, ok := k[host]; !ok {
+			k[host] = cred
+		}
+	}
+}
+
+// registryHost returns the host of a docker config key, which may be a URL
+// like https://index.docker.io/v1/
+func registryHost(key string) string {
+	host := strings.ToLower(key)
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	if i := strings.Index(host, "/"); i != -1 {
+		host = host[:i]
+	}
+	for _, alias := range defaultRegistryAliases {
+		if host == alias {
+			return docker.DefaultRegistry
+		}
+	}
+	return host
+}
@@ -0,0 +1,100 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package registry
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func makePullSecret(name string, secretType v1.SecretType, key string, data string) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+		Type:       secretType,
+		Data:       map[string][]byte{key: []byte(data)},
+	}
+}
+
+func TestParsePullSecret(t *testing.T) {
+	testcases := []struct {
+		description string
+		secret      *v1.Secret
+		expected    Keyring
+		shouldPass  bool
+	}{
+		{
+			description: "dockerconfigjson with user name and password",
+			secret:      makePullSecret("s", v1.SecretTypeDockerConfigJson, v1.DockerConfigJsonKey, `{"auths":{"registry.example.com:5000":{"username":"user","password":"secret"}}}`),
+			expected:    Keyring{"registry.example.com:5000": {Username: "user", Password: "secret"}},
+			shouldPass:  true,
+		},
+		{
+			description: "dockerconfigjson with auth for the default registry",
+			secret:      makePullSecret("s", v1.SecretTypeDockerConfigJson, v1.DockerConfigJsonKey, `{"auths":{"https://index.docker.io/v1/":{"auth":"dXNlcjpzZWNyZXQ="}}}`),
+			expected:    Keyring{"docker.io": {Username: "user", Password: "secret"}},
+			shouldPass:  true,
+		},
+		{
+			description: "dockercfg",
+			secret:      makePullSecret("s", v1.SecretTypeDockercfg, v1.DockerConfigKey, `{"https://Quay.io":{"username":"user","password":"secret"}}`),
+			expected:    Keyring{"quay.io": {Username: "user", Password: "secret"}},
+			shouldPass:  true,
+		},
+		{
+			description: "invalid auth",
+			secret:      makePullSecret("s", v1.SecretTypeDockerConfigJson, v1.DockerConfigJsonKey, `{"auths":{"quay.io":{"auth":"bm9jb2xvbg=="}}}`),
+			shouldPass:  false,
+		},
+		{
+			description: "opaque secret",
+			secret:      makePullSecret("s", v1.SecretTypeOpaque, "password", "secret"),
+			shouldPass:  false,
+		},
+	}
+
+	for _, tc := range testcases {
+		keyring, err := ParsePullSecret(tc.secret)
+		if err != nil && tc.shouldPass {
+			t.Fatalf("[%s] unexpected error: %v", tc.description, err)
+		}
+		if err == nil && !tc.shouldPass {
+			t.Fatalf("[%s] expected an error", tc.description)
+		}
+		if !reflect.DeepEqual(keyring, tc.expected) {
+			t.Errorf("[%s] expected %v, got %v", tc.description, tc.expected, keyring)
+		}
+	}
+}
+
+func TestCredentialsString(t *testing.T) {
+	cred := &Credentials{Username: "user", Password: "secret"}
+	for _, s := range []string{cred.String(), fmt.Sprintf("%v", cred)} {
+		if strings.Contains(s, "secret") {
+			t.Errorf("expected the password to be redacted, got %s", s)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+)
+
+// The media types of the manifests the client understands
+const (
+	MediaTypeManifestList  = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeImageIndex    = "application/vnd.oci.image.index.v1+json"
+	MediaTypeManifest      = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeImageManifest = "application/vnd.oci.image.manifest.v1+json"
+)
+
+// unknownPlatform is the platform of the entries buildx adds to an index
+// for attestations, which aren't images
+const unknownPlatform = "unknown"
+
+// Platform is the operating system and CPU architecture an image runs on
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// String returns the platform in the form docker uses, like linux/arm64/v8
+func (p Platform) String() string {
+	s := fmt.Sprintf("%s/%s", p.OS, p.Architecture)
+	if len(p.Variant) > 0 {
+		s = fmt.Sprintf("%s/%s", s, p.Variant)
+	}
+	return s
+}
+
+// Matches returns whether an image for the platform runs on the other
+// platform.  An empty variant matches any variant
+func (p Platform) Matches(other Platform) bool {
+	return p.OS == other.OS && p.Architecture == other.Architecture &&
+		(len(p.Variant) == 0 || len(other.Variant) == 0 || p.Variant == other.Variant)
+}
+
+// PlatformManifest is the manifest of one platform of a manifest list
+type PlatformManifest struct {
+	Digest   string
+	Platform Platform
+}
+
+// Manifest is an image manifest, or a manifest list or image index that
+// points at the manifests of each platform
+type Manifest struct {
+	MediaType string
+	// Digest is the digest with its algorithm
+	Digest string
+	// Platforms lists the platform manifests of a manifest list
+	Platforms []PlatformManifest
+}
+
+// manifestJSON has the fields of every manifest format that are needed to
+// tell them apart and to read the platforms of a list
+type manifestJSON struct {
+	MediaType string `json:"mediaType"`
+	Manifests []struct {
+		MediaType string    `json:"mediaType"`
+		Digest    string    `json:"digest"`
+		Platform  *Platform `json:"platform"`
+	} `json:"manifests"`
+}
+
+// ParseManifest parses a manifest with the media type the registry sent it
+// with.  When the registry sent no useful media type the one in the
+// manifest is used.  The digest is the sha256 of the manifest
+func ParseManifest(mediaType string, body []byte) (*Manifest, error) {
+	var parsed manifestJSON
+	err := json.Unmarshal(body, &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse manifest: %v", err)
+	}
+
+	sum := sha256.Sum256(body)
+	manifest := &Manifest{
+		MediaType: strings.TrimSpace(strings.Split(mediaType, ";")[0]),
+		Digest:    fmt.Sprintf("sha256:%s", hex.EncodeToString(sum[:])),
+	}
+	if len(manifest.MediaType) == 0 || manifest.MediaType == "application/json" || manifest.MediaType == "text/plain" {
+		manifest.MediaType = parsed.MediaType
+	}
+	// An OCI index isn't required to name its media type
+	if len(manifest.MediaType) == 0 && len(parsed.Manifests) > 0 {
+		manifest.MediaType = MediaTypeImageIndex
+	}
+	if !manifest.IsList() {
+		return manifest, nil
+	}
+
+	for _, entry := range parsed.Manifests {
+		if entry.Platform == nil || entry.Platform.Architecture == unknownPlatform {
+			continue
+		}
+		_, _, err := docker.ParseDigest(entry.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("manifest list has an invalid entry: %v", err)
+		}
+		manifest.Platforms = append(manifest.Platforms, PlatformManifest{Digest: entry.Digest, Platform: *entry.Platform})
+	}
+	return manifest, nil
+}
+
+// IsList returns whether the manifest is a manifest list or image index
+func (m *Manifest) IsList() bool {
+	return m.MediaType == MediaTypeManifestList || m.MediaType == MediaTypeImageIndex
+}
+
+// PlatformDigest returns the digest of the manifest for the platform.  The
+// digest of a manifest that isn't a list is returned for any platform
+func (m *Manifest) PlatformDigest(platform Platform) (string, bool) {
+	if !m.IsList() {
+		return m.Digest, true
+	}
+	for _, pm := range m.Platforms {
+		if pm.Platform.Matches(platform) {
+			return pm.Digest, true
+		}
+	}
+	return "", false
+}
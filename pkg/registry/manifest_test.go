@@ -0,0 +1,167 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package registry
+
+import (
+	"testing"
+)
+
+const (
+	amd64Digest = "sha256:0b1e3b8f8b8c3a86d1f6d8d3d43ad2c0a3a6ab8d3c5e3c5b7f5f3e8b5b0a9c1d"
+	arm64Digest = "sha256:5f0b1e0c4a6fd4ea4c6d8e2e6c2b3a1a8c5d7f6e4b2a9c8d7e6f5a4b3c2d1e0f"
+)
+
+// testManifestList is a buildx index with an attestation entry
+const testManifestList = `{
+	"schemaVersion": 2,
+	"mediaType": "application/vnd.oci.image.index.v1+json",
+	"manifests": [
+		{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + amd64Digest + `", "platform": {"os": "linux", "architecture": "amd64"}},
+		{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + arm64Digest + `", "platform": {"os": "linux", "architecture": "arm64", "variant": "v8"}},
+		{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "` + testDigest + `", "platform": {"os": "unknown", "architecture": "unknown"}}
+	]
+}`
+
+func TestParseManifest(t *testing.T) {
+	testcases := []struct {
+		description string
+		mediaType   string
+		body        string
+		isList      bool
+		platforms   int
+		shouldPass  bool
+	}{
+		{
+			description: "image manifest",
+			mediaType:   MediaTypeManifest,
+			body:        `{"schemaVersion":2,"mediaType":"application/vnd.docker.distribution.manifest.v2+json"}`,
+			isList:      false,
+			shouldPass:  true,
+		},
+		{
+			description: "index with media type from the body",
+			mediaType:   "application/json",
+			body:        testManifestList,
+			isList:      true,
+			platforms:   2,
+			shouldPass:  true,
+		},
+		{
+			description: "index without media type",
+			body:        `{"schemaVersion":2,"manifests":[{"digest":"` + amd64Digest + `","platform":{"os":"linux","architecture":"amd64"}}]}`,
+			isList:      true,
+			platforms:   1,
+			shouldPass:  true,
+		},
+		{
+			description: "manifest list with parameters in the media type",
+			mediaType:   MediaTypeManifestList + "; charset=utf-8",
+			body:        `{"schemaVersion":2,"manifests":[{"digest":"` + arm64Digest + `","platform":{"os":"linux","architecture":"arm64"}}]}`,
+			isList:      true,
+			platforms:   1,
+			shouldPass:  true,
+		},
+		{
+			description: "invalid entry digest",
+			mediaType:   MediaTypeManifestList,
+			body:        `{"schemaVersion":2,"manifests":[{"digest":"sha256:1234","platform":{"os":"linux","architecture":"amd64"}}]}`,
+			shouldPass:  false,
+		},
+		{
+			description: "invalid json",
+			mediaType:   MediaTypeManifest,
+			body:        `{"schemaVersion":`,
+			shouldPass:  false,
+		},
+	}
+
+	for _, tc := range testcases {
+		manifest, err := ParseManifest(tc.mediaType, []byte(tc.body))
+		if err != nil {
+			if tc.shouldPass {
+				t.Errorf("[%s] unexpected error: %v", tc.description, err)
+			}
+			continue
+		}
+		if !tc.shouldPass {
+			t.Errorf("[%s] expected error but got %v", tc.description, manifest)
+			continue
+		}
+		if manifest.IsList() != tc.isList || len(manifest.Platforms) != tc.platforms {
+			t.Errorf("[%s] unexpected manifest %+v", tc.description, manifest)
+		}
+		if _, err := validDigest(manifest.Digest); err != nil {
+			t.Errorf("[%s] invalid digest %s", tc.description, manifest.Digest)
+		}
+	}
+}
+
+func TestPlatformDigest(t *testing.T) {
+	list, err := ParseManifest(MediaTypeImageIndex, []byte(testManifestList))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	single := &Manifest{MediaType: MediaTypeManifest, Digest: testDigest}
+
+	testcases := []struct {
+		description string
+		manifest    *Manifest
+		platform    Platform
+		expected    string
+		shouldPass  bool
+	}{
+		{
+			description: "amd64",
+			manifest:    list,
+			platform:    Platform{OS: "linux", Architecture: "amd64"},
+			expected:    amd64Digest,
+			shouldPass:  true,
+		},
+		{
+			description: "arm64 node without variant",
+			manifest:    list,
+			platform:    Platform{OS: "linux", Architecture: "arm64"},
+			expected:    arm64Digest,
+			shouldPass:  true,
+		},
+		{
+			description: "platform without manifest",
+			manifest:    list,
+			platform:    Platform{OS: "windows", Architecture: "amd64"},
+			shouldPass:  false,
+		},
+		{
+			description: "single manifest",
+			manifest:    single,
+			platform:    Platform{OS: "linux", Architecture: "s390x"},
+			expected:    testDigest,
+			shouldPass:  true,
+		},
+	}
+
+	for _, tc := range testcases {
+		digest, ok := tc.manifest.PlatformDigest(tc.platform)
+		if ok != tc.shouldPass || digest != tc.expected {
+			t.Errorf("[%s] expected %s, got %s", tc.description, tc.expected, digest)
+		}
+	}
+}
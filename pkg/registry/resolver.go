@@ -0,0 +1,251 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package registry
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Config contains the settings used to resolve the digests of images that
+// no container runs yet, and to tell perceptor which pull secret to scan
+// an image with
+type Config struct {
+	Enabled bool
+	// SendPullSecrets sends perceptor a reference to the pull secret of
+	// every image of a pod that has one.  The credentials are never sent
+	SendPullSecrets    bool
+	InsecureRegistries []string
+	TimeoutSeconds     int
+	CacheSeconds       int
+}
+
+// cachedDigest is a resolved digest and when it was resolved
+type cachedDigest struct {
+	digest   string
+	resolved time.Time
+}
+
+// Resolver turns the image a pod asks for into a reference with a digest
+// by asking the registry, using the pull secrets of the pod, and finds the
+// pull secret each image is pulled with.  A nil Resolver does neither
+type Resolver struct {
+	client          *Client
+	kubeClient      kubernetes.Interface
+	secrets         *PullSecrets
+	resolveImages   bool
+	sendPullSecrets bool
+	cacheTTL        time.Duration
+
+	mutex         sync.Mutex
+	cache         map[string]cachedDigest
+	nodePlatforms map[string]Platform
+}
+
+// NewResolver creates a new Resolver object that watches the pull secrets
+// of the namespaces that match nsFilter.  Nil is returned when neither
+// resolving images nor sending pull secrets is enabled so callers can hand
+// the result straight to the controllers
+func NewResolver(config Config, kubeClient kubernetes.Interface, nsFilter string) *Resolver {
+	if !config.Enabled && !config.SendPullSecrets {
+		return nil
+	}
+	timeout := time.Duration(config.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &Resolver{
+		client:          NewClient(config.InsecureRegistries, timeout),
+		kubeClient:      kubeClient,
+		secrets:         NewPullSecrets(kubeClient, nsFilter),
+		resolveImages:   config.Enabled,
+		sendPullSecrets: config.SendPullSecrets,
+		cacheTTL:        time.Duration(config.CacheSeconds) * time.Second,
+		cache:           make(map[string]cachedDigest),
+		nodePlatforms:   make(map[string]Platform),
+	}
+}
+
+// Run starts watching the pull secrets until stopCh is closed
+func (r *Resolver) Run(stopCh <-chan struct{}) {
+	if r == nil {
+		return
+	}
+	r.secrets.Run(stopCh)
+}
+
+// HasSynced returns whether every pull secret is known
+func (r *Resolver) HasSynced() bool {
+	if r == nil {
+		return true
+	}
+	return r.secrets.HasSynced()
+}
+
+// ResolvesImages returns whether images are resolved through the registry
+func (r *Resolver) ResolvesImages() bool {
+	return r != nil && r.resolveImages
+}
+
+// PullSecret returns a reference to the pull secret the pod pulls images
+// of the registry with, or nil if it has none or sending pull secrets is
+// disabled
+func (r *Resolver) PullSecret(pod *v1.Pod, registry string) *SecretReference {
+	if r == nil || !r.sendPullSecrets {
+		return nil
+	}
+	return r.secrets.Reference(pod, registry)
+}
+
+// Resolve returns the reference of an image of the pod with the digest the
+// registry has for its tag.  An image without a tag resolves latest
+func (r *Resolver) Resolve(pod *v1.Pod, image string) (*docker.Reference, error) {
+	if !r.ResolvesImages() {
+		return nil, fmt.Errorf("resolving image %s isn't enabled", image)
+	}
+	ref, err := docker.ParseReference(image)
+	if err != nil {
+		return nil, err
+	}
+	if len(ref.Digest) > 0 {
+		return ref, nil
+	}
+	if len(ref.Tag) == 0 {
+		ref.Tag = "latest"
+	}
+
+	key := ref.Normalize().String()
+	digest, ok := r.cached(key)
+	if !ok {
+		keyring := r.secrets.Keyring(pod)
+		resolveStart := time.Now()
+		digest, err = r.client.ManifestDigest(ref, keyring.Lookup(ref.Normalize().Registry))
+		metrics.RecordDuration("resolve image digest", time.Now().Sub(resolveStart))
+		if err != nil {
+			metrics.RecordError("registry_resolver", "unable to resolve image digest")
+			return nil, fmt.Errorf("unable to resolve digest of %s: %v", image, err)
+		}
+		r.store(key, digest)
+	}
+
+	ref.DigestAlgorithm, ref.Digest, err = docker.ParseDigest(digest)
+	if err != nil {
+		return nil, err
+	}
+	return ref, nil
+}
+
+// PlatformImage returns the reference with the digest of the manifest for
+// the platform of the pod's node, when the digest is that of a manifest
+// list or image index.  Any other reference is returned as is, as is every
+// reference when images aren't resolved or the pod isn't scheduled yet
+func (r *Resolver) PlatformImage(pod *v1.Pod, ref *docker.Reference) (*docker.Reference, error) {
+	if !r.ResolvesImages() || len(ref.Digest) == 0 || len(ref.Repository) == 0 || len(pod.Spec.NodeName) == 0 {
+		return ref, nil
+	}
+	platform, err := r.nodePlatform(pod.Spec.NodeName)
+	if err != nil {
+		return ref, err
+	}
+
+	key := fmt.Sprintf("%s@%s#%s", ref.Normalize().Name(), ref.FullDigest(), platform)
+	digest, ok := r.cached(key)
+	if !ok {
+		keyring := r.secrets.Keyring(pod)
+		manifest, err := r.client.Manifest(ref, keyring.Lookup(ref.Normalize().Registry))
+		if err != nil {
+			metrics.RecordError("registry_resolver", "unable to get manifest")
+			return ref, fmt.Errorf("unable to get manifest of %s: %v", ref, err)
+		}
+		digest, ok = manifest.PlatformDigest(platform)
+		if !ok {
+			return ref, fmt.Errorf("manifest list %s has no manifest for %s", ref, platform)
+		}
+		if !manifest.IsList() {
+			// The digest of a manifest fetched by digest is the one asked for
+			digest = ref.FullDigest()
+		}
+		r.store(key, digest)
+	}
+
+	platformRef := *ref
+	platformRef.DigestAlgorithm, platformRef.Digest, err = docker.ParseDigest(digest)
+	if err != nil {
+		return ref, err
+	}
+	return &platformRef, nil
+}
+
+// nodePlatform returns the platform of a node, which never changes, so it
+// is only asked for once
+func (r *Resolver) nodePlatform(name string) (Platform, error) {
+	r.mutex.Lock()
+	platform, ok := r.nodePlatforms[name]
+	r.mutex.Unlock()
+	if ok {
+		return platform, nil
+	}
+
+	node, err := r.kubeClient.CoreV1().Nodes().Get(name, metav1.GetOptions{})
+	if err != nil {
+		return Platform{}, fmt.Errorf("unable to get node %s: %v", name, err)
+	}
+	platform = Platform{OS: node.Status.NodeInfo.OperatingSystem, Architecture: node.Status.NodeInfo.Architecture}
+	r.mutex.Lock()
+	r.nodePlatforms[name] = platform
+	r.mutex.Unlock()
+	return platform, nil
+}
+
+func (r *Resolver) cached(key string) (string, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	entry, ok := r.cache[key]
+	if !ok || time.Now().Sub(entry.resolved) >= r.cacheTTL {
+		return "", false
+	}
+	return entry.digest, true
+}
+
+func (r *Resolver) store(key string, digest string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.cacheTTL <= 0 {
+		return
+	}
+	now := time.Now()
+	for k, entry := range r.cache {
+		if now.Sub(entry.resolved) >= r.cacheTTL {
+			delete(r.cache, k)
+		}
+	}
+	r.cache[key] = cachedDigest{digest: digest, resolved: now}
+}
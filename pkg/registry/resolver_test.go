@@ -0,0 +1,153 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package registry
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestResolve(t *testing.T) {
+	fr := newFakeRegistry("bearer", true)
+	defer fr.server.Close()
+
+	secret := makePullSecret("pull", v1.SecretTypeDockerConfigJson, v1.DockerConfigJsonKey, fmt.Sprintf(`{"auths":{"%s":{"username":"user","password":"secret"}}}`, fr.host()))
+	config := Config{
+		Enabled:            true,
+		SendPullSecrets:    true,
+		InsecureRegistries: []string{fr.host()},
+		CacheSeconds:       60,
+	}
+	resolver := NewResolver(config, fake.NewSimpleClientset(secret), "")
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	resolver.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, resolver.HasSynced) {
+		t.Fatalf("resolver didn't sync")
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "ns"},
+		Spec: v1.PodSpec{
+			ImagePullSecrets: []v1.LocalObjectReference{{Name: "pull"}},
+		},
+	}
+
+	image := fmt.Sprintf("%s/team/app:1.0", fr.host())
+	for i := 0; i < 2; i++ {
+		ref, err := resolver.Resolve(pod, image)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if ref.FullDigest() != testDigest || ref.Tag != "1.0" || ref.Repository != "team/app" {
+			t.Errorf("unexpected reference %v", ref)
+		}
+	}
+	// The second lookup is served from the cache
+	if fr.requests != 2 {
+		t.Errorf("expected 2 requests to the registry, got %d", fr.requests)
+	}
+
+	_, err := resolver.Resolve(&v1.Pod{}, fmt.Sprintf("%s/team/app:2.0", fr.host()))
+	if err == nil {
+		t.Errorf("expected an error resolving an image without credentials")
+	}
+
+	expected := SecretReference{Registry: fr.host(), Namespace: "ns", Name: "pull"}
+	if ref := resolver.PullSecret(pod, fr.host()); ref == nil || *ref != expected {
+		t.Errorf("expected %v, got %v", expected, ref)
+	}
+}
+
+func TestPlatformImage(t *testing.T) {
+	fr := newFakeRegistry("", true)
+	defer fr.server.Close()
+	fr.manifest = testManifestList
+
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "arm"},
+		Status:     v1.NodeStatus{NodeInfo: v1.NodeSystemInfo{OperatingSystem: "linux", Architecture: "arm64"}},
+	}
+	config := Config{Enabled: true, InsecureRegistries: []string{fr.host()}, CacheSeconds: 60}
+	resolver := NewResolver(config, fake.NewSimpleClientset(node), "")
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	resolver.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, resolver.HasSynced) {
+		t.Fatalf("resolver didn't sync")
+	}
+
+	ref, err := resolver.Resolve(&v1.Pod{}, fmt.Sprintf("%s/team/app@%s", fr.host(), testDigest))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pod := &v1.Pod{Spec: v1.PodSpec{NodeName: "arm"}}
+	for i := 0; i < 2; i++ {
+		platformRef, err := resolver.PlatformImage(pod, ref)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if platformRef.FullDigest() != arm64Digest || platformRef.Repository != "team/app" {
+			t.Errorf("expected the arm64 manifest, got %v", platformRef)
+		}
+	}
+	// The second lookup is served from the cache
+	if fr.requests != 1 {
+		t.Errorf("expected 1 request to the registry, got %d", fr.requests)
+	}
+
+	unscheduled, err := resolver.PlatformImage(&v1.Pod{}, ref)
+	if err != nil || unscheduled != ref {
+		t.Errorf("expected the reference of an unscheduled pod to be kept, got %v: %v", unscheduled, err)
+	}
+
+	_, err = resolver.PlatformImage(&v1.Pod{Spec: v1.PodSpec{NodeName: "missing"}}, ref)
+	if err == nil {
+		t.Errorf("expected an error for a pod on an unknown node")
+	}
+}
+
+func TestResolverWithoutPullSecrets(t *testing.T) {
+	resolver := NewResolver(Config{Enabled: true}, fake.NewSimpleClientset(), "")
+	if !resolver.ResolvesImages() {
+		t.Errorf("expected the resolver to resolve images")
+	}
+	if ref := resolver.PullSecret(&v1.Pod{}, "quay.io"); ref != nil {
+		t.Errorf("expected no pull secret when sending them is disabled, got %v", ref)
+	}
+}
+
+func TestNewResolverDisabled(t *testing.T) {
+	resolver := NewResolver(Config{}, nil, "")
+	if resolver != nil {
+		t.Fatalf("expected no resolver when disabled")
+	}
+	_, err := resolver.Resolve(&v1.Pod{}, "nginx")
+	if err == nil {
+		t.Errorf("expected an error from a nil resolver")
+	}
+}
@@ -0,0 +1,221 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package registry
+
+import (
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"k8s.io/client-go/kubernetes"
+	v1lister "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// pullSecretTypes are the types of secrets the kubelet pulls images with
+var pullSecretTypes = []v1.SecretType{v1.SecretTypeDockerConfigJson, v1.SecretTypeDockercfg}
+
+// SecretReference names the pull secret that has the credentials of the
+// registry of an image.  It never holds the credentials themselves
+type SecretReference struct {
+	Registry  string
+	Namespace string
+	Name      string
+}
+
+// PullSecrets keeps the pull secrets and service accounts of a cluster in
+// informer caches, so the pull secrets of a pod can be found without
+// asking the API server.  Only secrets of the pull secret types are
+// watched.  A nil PullSecrets has no secrets
+type PullSecrets struct {
+	secretIndexers    []cache.Indexer
+	secretControllers []cache.Controller
+
+	serviceAccountLister     v1lister.ServiceAccountLister
+	serviceAccountController cache.Controller
+}
+
+// NewPullSecrets creates a new PullSecrets object watching the namespaces
+// that match nsFilter, or every namespace if it is empty
+func NewPullSecrets(kubeClient kubernetes.Interface, nsFilter string) *PullSecrets {
+	if nsFilter == "" {
+		nsFilter = metav1.NamespaceAll
+	}
+	ps := &PullSecrets{}
+	for _, secretType := range pullSecretTypes {
+		selector := fields.OneTermEqualSelector("type", string(secretType)).String()
+		indexer, controller := cache.NewIndexerInformer(
+			&cache.ListWatch{
+				ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+					opts.FieldSelector = selector
+					return kubeClient.CoreV1().Secrets(nsFilter).List(opts)
+				},
+				WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+					opts.FieldSelector = selector
+					return kubeClient.CoreV1().Secrets(nsFilter).Watch(opts)
+				},
+			},
+			&v1.Secret{},
+			0,
+			cache.ResourceEventHandlerFuncs{},
+			cache.Indexers{},
+		)
+		ps.secretIndexers = append(ps.secretIndexers, indexer)
+		ps.secretControllers = append(ps.secretControllers, controller)
+	}
+
+	var serviceAccountIndexer cache.Indexer
+	serviceAccountIndexer, ps.serviceAccountController = cache.NewIndexerInformer(
+		&cache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				return kubeClient.CoreV1().ServiceAccounts(nsFilter).List(opts)
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				return kubeClient.CoreV1().ServiceAccounts(nsFilter).Watch(opts)
+			},
+		},
+		&v1.ServiceAccount{},
+		0,
+		cache.ResourceEventHandlerFuncs{},
+		cache.Indexers{},
+	)
+	ps.serviceAccountLister = v1lister.NewServiceAccountLister(serviceAccountIndexer)
+	return ps
+}
+
+// Run starts the informers until stopCh is closed
+func (ps *PullSecrets) Run(stopCh <-chan struct{}) {
+	if ps == nil {
+		return
+	}
+	log.Infof("starting pull secret informers")
+	for _, controller := range ps.secretControllers {
+		go controller.Run(stopCh)
+	}
+	go ps.serviceAccountController.Run(stopCh)
+}
+
+// HasSynced returns whether the informers have listed every pull secret
+// and service account
+func (ps *PullSecrets) HasSynced() bool {
+	if ps == nil {
+		return true
+	}
+	for _, controller := range ps.secretControllers {
+		if !controller.HasSynced() {
+			return false
+		}
+	}
+	return ps.serviceAccountController.HasSynced()
+}
+
+// PodSecrets returns the pull secrets of a pod in the order the kubelet
+// tries them: the secrets of the pod and then those of its service account
+func (ps *PullSecrets) PodSecrets(pod *v1.Pod) []*v1.Secret {
+	if ps == nil {
+		return nil
+	}
+	names := []string{}
+	for _, ref := range pod.Spec.ImagePullSecrets {
+		names = append(names, ref.Name)
+	}
+	serviceAccountName := pod.Spec.ServiceAccountName
+	if len(serviceAccountName) == 0 {
+		serviceAccountName = "default"
+	}
+	serviceAccount, err := ps.serviceAccountLister.ServiceAccounts(pod.Namespace).Get(serviceAccountName)
+	if err != nil && !errors.IsNotFound(err) {
+		log.Warningf("unable to get service account %s/%s of pod %s: %v", pod.Namespace, serviceAccountName, pod.Name, err)
+	} else if err == nil {
+		for _, ref := range serviceAccount.ImagePullSecrets {
+			names = append(names, ref.Name)
+		}
+	}
+
+	secrets := []*v1.Secret{}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		secret, ok := ps.getSecret(pod.Namespace, name)
+		if !ok {
+			log.Warningf("pull secret %s/%s of pod %s doesn't exist or isn't a pull secret", pod.Namespace, name, pod.Name)
+			continue
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets
+}
+
+// Keyring returns the credentials of the pull secrets of a pod.  Secrets
+// that can't be parsed are skipped, the same way the kubelet does
+func (ps *PullSecrets) Keyring(pod *v1.Pod) Keyring {
+	keyring := make(Keyring)
+	for _, secret := range ps.PodSecrets(pod) {
+		secretKeyring, err := ParsePullSecret(secret)
+		if err != nil {
+			log.Warningf("unable to use pull secret %s/%s of pod %s: %v", secret.Namespace, secret.Name, pod.Name, err)
+			continue
+		}
+		keyring.merge(secretKeyring)
+	}
+	return keyring
+}
+
+// Reference returns a reference to the pull secret of the pod that has
+// credentials for the registry, or nil if none has
+func (ps *PullSecrets) Reference(pod *v1.Pod, registry string) *SecretReference {
+	host := registryHost(registry)
+	for _, secret := range ps.PodSecrets(pod) {
+		secretKeyring, err := ParsePullSecret(secret)
+		if err != nil {
+			continue
+		}
+		if secretKeyring.Lookup(host) != nil {
+			return &SecretReference{Registry: host, Namespace: secret.Namespace, Name: secret.Name}
+		}
+	}
+	return nil
+}
+
+func (ps *PullSecrets) getSecret(namespace string, name string) (*v1.Secret, bool) {
+	key := fmt.Sprintf("%s/%s", namespace, name)
+	for _, indexer := range ps.secretIndexers {
+		obj, exists, err := indexer.GetByKey(key)
+		if err != nil || !exists {
+			continue
+		}
+		if secret, ok := obj.(*v1.Secret); ok {
+			return secret, true
+		}
+	}
+	return nil, false
+}
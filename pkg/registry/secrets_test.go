@@ -0,0 +1,101 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package registry
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+// runPullSecrets starts the informers of ps and waits until they synced
+func runPullSecrets(t *testing.T, ps *PullSecrets, stopCh chan struct{}) {
+	ps.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, ps.HasSynced) {
+		t.Fatalf("pull secret informers didn't sync")
+	}
+}
+
+func TestPullSecrets(t *testing.T) {
+	first := makePullSecret("first", v1.SecretTypeDockerConfigJson, v1.DockerConfigJsonKey, `{"auths":{"quay.io":{"username":"first","password":"secret"}}}`)
+	second := makePullSecret("second", v1.SecretTypeDockerConfigJson, v1.DockerConfigJsonKey, `{"auths":{"quay.io":{"username":"second","password":"secret"},"docker.io":{"username":"user","password":"secret"}}}`)
+	fromServiceAccount := makePullSecret("from-sa", v1.SecretTypeDockercfg, v1.DockerConfigKey, `{"registry.example.com":{"username":"sa","password":"secret"}}`)
+	serviceAccount := &v1.ServiceAccount{
+		ObjectMeta:       metav1.ObjectMeta{Name: "builder", Namespace: "ns"},
+		ImagePullSecrets: []v1.LocalObjectReference{{Name: "from-sa"}, {Name: "first"}},
+	}
+	client := fake.NewSimpleClientset(first, second, fromServiceAccount, serviceAccount)
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "ns"},
+		Spec: v1.PodSpec{
+			ServiceAccountName: "builder",
+			ImagePullSecrets:   []v1.LocalObjectReference{{Name: "first"}, {Name: "missing"}, {Name: "second"}},
+		},
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	ps := NewPullSecrets(client, "")
+	runPullSecrets(t, ps, stopCh)
+
+	secrets := ps.PodSecrets(pod)
+	names := []string{}
+	for _, secret := range secrets {
+		names = append(names, secret.Name)
+	}
+	if len(names) != 3 || names[0] != "first" || names[1] != "second" || names[2] != "from-sa" {
+		t.Errorf("expected the secrets first, second and from-sa, got %v", names)
+	}
+
+	keyring := ps.Keyring(pod)
+	if cred := keyring.Lookup("quay.io"); cred == nil || cred.Username != "first" {
+		t.Errorf("expected the credentials of the first secret for quay.io, got %v", cred)
+	}
+	if cred := keyring.Lookup("index.docker.io"); cred == nil || cred.Username != "user" {
+		t.Errorf("expected credentials for docker.io, got %v", cred)
+	}
+	if cred := keyring.Lookup("registry.example.com"); cred == nil || cred.Username != "sa" {
+		t.Errorf("expected the credentials of the service account for registry.example.com, got %v", cred)
+	}
+
+	expected := SecretReference{Registry: "registry.example.com", Namespace: "ns", Name: "from-sa"}
+	if ref := ps.Reference(pod, "registry.example.com"); ref == nil || *ref != expected {
+		t.Errorf("expected %v, got %v", expected, ref)
+	}
+	if ref := ps.Reference(pod, "gcr.io"); ref != nil {
+		t.Errorf("expected no secret for gcr.io, got %v", ref)
+	}
+}
+
+func TestNilPullSecrets(t *testing.T) {
+	var ps *PullSecrets
+	if !ps.HasSynced() {
+		t.Errorf("expected nil pull secrets to be synced")
+	}
+	if secrets := ps.PodSecrets(&v1.Pod{}); len(secrets) != 0 {
+		t.Errorf("expected no secrets, got %v", secrets)
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package sharding
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+// virtualNodes is the number of points each member gets on the ring.  More
+// points spread the namespaces more evenly across the members
+const virtualNodes = 128
+
+// Ring is a consistent hash ring that maps keys to members.  When a member
+// joins or leaves only the keys next to its points on the ring move
+type Ring struct {
+	members []string
+	points  []uint64
+	owners  map[uint64]string
+}
+
+// NewRing creates a new Ring object from the list of members
+func NewRing(members []string) *Ring {
+	r := &Ring{
+		members: []string{},
+		points:  []uint64{},
+		owners:  make(map[uint64]string),
+	}
+	seen := make(map[string]bool)
+	for _, member := range members {
+		if seen[member] {
+			continue
+		}
+		seen[member] = true
+		r.members = append(r.members, member)
+		for i := 0; i < virtualNodes; i++ {
+			point := hashKey(fmt.Sprintf("%s#%d", member, i))
+			if owner, ok := r.owners[point]; ok {
+				// On the (very) unlikely collision keep the smaller member
+				// so every replica builds an identical ring
+				if member < owner {
+					r.owners[point] = member
+				}
+				continue
+			}
+			r.points = append(r.points, point)
+			r.owners[point] = member
+		}
+	}
+	sort.Strings(r.members)
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r
+}
+
+// Members returns the sorted list of members on the ring
+func (r *Ring) Members() []string {
+	return r.members
+}
+
+// Owner returns the member that owns the key, or an empty string
+// if the ring has no members
+func (r *Ring) Owner(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	point := hashKey(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= point })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+func hashKey(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return h.Sum64()
+}
@@ -0,0 +1,90 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package sharding
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRingOwner(t *testing.T) {
+	testcases := []struct {
+		description string
+		members     []string
+		expected    []string
+	}{
+		{
+			description: "no members",
+			members:     []string{},
+			expected:    []string{""},
+		},
+		{
+			description: "single member",
+			members:     []string{"a"},
+			expected:    []string{"a"},
+		},
+		{
+			description: "duplicate members",
+			members:     []string{"a", "a", "b"},
+			expected:    []string{"a", "b"},
+		},
+	}
+
+	for _, tc := range testcases {
+		ring := NewRing(tc.members)
+		for i := 0; i < 100; i++ {
+			owner := ring.Owner(fmt.Sprintf("namespace-%d", i))
+			found := false
+			for _, e := range tc.expected {
+				if owner == e {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("[%s] owner %q isn't one of %v", tc.description, owner, tc.expected)
+			}
+		}
+	}
+}
+
+func TestRingIsDeterministic(t *testing.T) {
+	first := NewRing([]string{"a", "b", "c"})
+	second := NewRing([]string{"c", "a", "b"})
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("namespace-%d", i)
+		if first.Owner(key) != second.Owner(key) {
+			t.Errorf("key %s is owned by %s and %s depending on member order", key, first.Owner(key), second.Owner(key))
+		}
+	}
+}
+
+func TestRingMovesOnlyKeysOfLeavingMember(t *testing.T) {
+	before := NewRing([]string{"a", "b", "c"})
+	after := NewRing([]string{"a", "b"})
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("namespace-%d", i)
+		owner := before.Owner(key)
+		if owner != "c" && after.Owner(key) != owner {
+			t.Errorf("key %s moved from %s to %s although %s is still a member", key, owner, after.Owner(key), owner)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package sharding
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/election"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/client-go/kubernetes"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Config contains the settings used to partition namespaces across
+// active replicas of a perceiver.  Sharded replicas can't replace every pod
+// in perceptor, so deleted pods are only removed by their delete events
+type Config struct {
+	Enabled                  bool
+	Namespace                string
+	ConfigMapName            string
+	HeartbeatIntervalSeconds int
+	MemberTimeoutSeconds     int
+}
+
+// Shard tracks the replicas that are alive through heartbeats kept in a
+// ConfigMap and decides which namespaces this replica is responsible for.
+// A nil Shard owns every namespace
+type Shard struct {
+	client            kubernetes.Interface
+	namespace         string
+	configMapName     string
+	identity          string
+	heartbeatInterval time.Duration
+	memberTimeout     time.Duration
+
+	mutex     sync.RWMutex
+	ring      *Ring
+	listeners []func()
+}
+
+// NewShard creates a new Shard object.  Nil is returned when sharding is
+// disabled so callers can hand the result straight to the controllers.  The
+// shard owns no namespace until it has joined the membership
+func NewShard(config Config, component string, kubeClient kubernetes.Interface) (*Shard, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	identity, err := election.Identity()
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine shard member identity: %v", err)
+	}
+	namespace, err := election.Namespace(config.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine shard membership namespace: %v", err)
+	}
+	configMapName := config.ConfigMapName
+	if len(configMapName) == 0 {
+		configMapName = fmt.Sprintf("%s-shards", component)
+	}
+	heartbeatInterval := time.Duration(config.HeartbeatIntervalSeconds) * time.Second
+	if heartbeatInterval <= 0 {
+		heartbeatInterval = 10 * time.Second
+	}
+	memberTimeout := time.Duration(config.MemberTimeoutSeconds) * time.Second
+	if memberTimeout <= heartbeatInterval {
+		memberTimeout = 3 * heartbeatInterval
+	}
+
+	return &Shard{
+		client:            kubeClient,
+		namespace:         namespace,
+		configMapName:     configMapName,
+		identity:          identity,
+		heartbeatInterval: heartbeatInterval,
+		memberTimeout:     memberTimeout,
+		ring:              NewRing([]string{}),
+	}, nil
+}
+
+// Owns returns true if this replica is responsible for the namespace
+func (s *Shard) Owns(namespace string) bool {
+	if s == nil {
+		return true
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.ring.Owner(namespace) == s.identity
+}
+
+// OnChange registers a function that is called whenever the membership,
+// and therefore the set of owned namespaces, changes
+func (s *Shard) OnChange(listener func()) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// Join records this replica in the membership and reads the other members.
+// It is called before the controllers start, so a starting replica doesn't
+// process the namespaces of the others
+func (s *Shard) Join() error {
+	if s == nil {
+		return nil
+	}
+	return s.heartbeat()
+}
+
+// Run keeps the heartbeat of this replica up to date and rebalances the
+// namespaces whenever replicas come and go
+func (s *Shard) Run(stopCh <-chan struct{}) {
+	log.Infof("starting shard membership for %s in %s/%s", s.identity, s.namespace, s.configMapName)
+	for {
+		err := s.heartbeat()
+		if err != nil {
+			log.Errorf("unable to update shard membership: %v", err)
+		}
+
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(s.heartbeatInterval):
+		}
+	}
+}
+
+// heartbeat records this replica as alive, prunes replicas whose heartbeat
+// expired and rebuilds the ring from the live members
+func (s *Shard) heartbeat() error {
+	now := time.Now()
+	cm, err := s.client.CoreV1().ConfigMaps(s.namespace).Get(s.configMapName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      s.configMapName,
+				Namespace: s.namespace,
+			},
+			Data: map[string]string{s.identity: now.UTC().Format(time.RFC3339)},
+		}
+		cm, err = s.client.CoreV1().ConfigMaps(s.namespace).Create(cm)
+		if err != nil {
+			return fmt.Errorf("unable to create membership config map %s/%s: %v", s.namespace, s.configMapName, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("unable to get membership config map %s/%s: %v", s.namespace, s.configMapName, err)
+	} else {
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[s.identity] = now.UTC().Format(time.RFC3339)
+		for member, beat := range cm.Data {
+			if !s.isAlive(beat, now) {
+				delete(cm.Data, member)
+			}
+		}
+		// A conflict means another replica updated the membership at the
+		// same time, the next heartbeat will try again
+		cm, err = s.client.CoreV1().ConfigMaps(s.namespace).Update(cm)
+		if err != nil {
+			return fmt.Errorf("unable to update membership config map %s/%s: %v", s.namespace, s.configMapName, err)
+		}
+	}
+
+	members := []string{}
+	for member, beat := range cm.Data {
+		if s.isAlive(beat, now) {
+			members = append(members, member)
+		}
+	}
+	s.setMembers(members)
+	return nil
+}
+
+func (s *Shard) isAlive(beat string, now time.Time) bool {
+	last, err := time.Parse(time.RFC3339, beat)
+	if err != nil {
+		return false
+	}
+	return now.Sub(last) <= s.memberTimeout
+}
+
+func (s *Shard) setMembers(members []string) {
+	sort.Strings(members)
+	s.mutex.Lock()
+	if reflect.DeepEqual(s.ring.Members(), members) {
+		s.mutex.Unlock()
+		return
+	}
+	log.Infof("shard membership changed from %v to %v, rebalancing namespaces", s.ring.Members(), members)
+	s.ring = NewRing(members)
+	listeners := make([]func(), len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mutex.Unlock()
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
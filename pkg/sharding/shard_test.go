@@ -0,0 +1,120 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package sharding
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestShard(identity string, client *fake.Clientset) *Shard {
+	return &Shard{
+		client:            client,
+		namespace:         "perceivers",
+		configMapName:     "pod-perceiver-shards",
+		identity:          identity,
+		heartbeatInterval: time.Second,
+		memberTimeout:     time.Minute,
+		ring:              NewRing([]string{}),
+	}
+}
+
+func TestNilShardOwnsEverything(t *testing.T) {
+	var shard *Shard
+	if !shard.Owns("any") {
+		t.Errorf("expected a nil shard to own every namespace")
+	}
+	shard.OnChange(func() {})
+}
+
+func TestDisabledShardIsNil(t *testing.T) {
+	shard, err := NewShard(Config{Enabled: false}, "pod-perceiver", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if shard != nil {
+		t.Errorf("expected no shard when sharding is disabled")
+	}
+}
+
+func TestShardOwnsNothingBeforeJoining(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	shard := newTestShard("a", client)
+	if shard.Owns("default") {
+		t.Errorf("expected a shard to own no namespace before joining")
+	}
+	if err := shard.Join(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !shard.Owns("default") {
+		t.Errorf("expected the only member to own every namespace")
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	stale := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	client := fake.NewSimpleClientset(&v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod-perceiver-shards", Namespace: "perceivers"},
+		Data:       map[string]string{"gone": stale},
+	})
+	a := newTestShard("a", client)
+	b := newTestShard("b", client)
+
+	changes := 0
+	a.OnChange(func() { changes++ })
+
+	for _, s := range []*Shard{a, b, a} {
+		if err := s.heartbeat(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	cm, err := client.CoreV1().ConfigMaps("perceivers").Get("pod-perceiver-shards", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := cm.Data["gone"]; ok {
+		t.Errorf("expected the stale member to be pruned")
+	}
+	members := a.ring.Members()
+	if len(members) != 2 || members[0] != "a" || members[1] != "b" {
+		t.Errorf("expected members [a b], got %v", members)
+	}
+	// a joins by itself first, then sees b
+	if changes != 2 {
+		t.Errorf("expected 2 membership changes, got %d", changes)
+	}
+
+	if err := b.heartbeat(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, ns := range []string{"default", "kube-system", "perceivers", "team-a", "team-b", "team-c"} {
+		if a.Owns(ns) == b.Owns(ns) {
+			t.Errorf("expected namespace %s to be owned by exactly one member", ns)
+		}
+	}
+}
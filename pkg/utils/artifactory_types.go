@@ -21,6 +21,24 @@ under the License.
 
 package utils
 
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The files artifactory keeps the manifest of a docker tag in
+const (
+	ArtManifestName     = "manifest.json"
+	ArtManifestListName = "list.manifest.json"
+)
+
+// ArtAQLPageSize is how many items an AQL search returns at a time
+const ArtAQLPageSize = 1000
+
 // ArtDockerRepo contains list of docker repos in artifactory
 type ArtDockerRepo []struct {
 	Key         string `json:"key"`
@@ -40,14 +58,6 @@ type ArtImageTags struct {
 	Tags []string `json:"tags"`
 }
 
-// ArtImageSHAs gets all the sha256 of an image
-type ArtImageSHAs struct {
-	Properties struct {
-		Sha256 []string `json:"sha256"`
-	} `json:"properties"`
-	URI string `json:"uri"`
-}
-
 // ArtReposBySha collects URIs for given SHA256
 type ArtReposBySha struct {
 	Results []struct {
@@ -64,3 +74,151 @@ type ArtHookStruct struct {
 		Reference string `json:"reference"`
 	} `json:"artifacts"`
 }
+
+// ArtEventStruct is the structure of the events sent by the webhooks built
+// in to artifactory, the events of the docker domain are about a tag
+type ArtEventStruct struct {
+	Domain    string `json:"domain"`
+	EventType string `json:"event_type"`
+	JpdOrigin string `json:"jpd_origin"`
+	Data      struct {
+		RepoKey   string `json:"repo_key"`
+		ImageName string `json:"image_name"`
+		Tag       string `json:"tag"`
+		Sha256    string `json:"sha256"`
+	} `json:"data"`
+}
+
+// ArtSubscription is a webhook built in to artifactory, which sends the
+// events of a domain to its handlers
+type ArtSubscription struct {
+	Key         string            `json:"key"`
+	Description string            `json:"description"`
+	Enabled     bool              `json:"enabled"`
+	EventFilter ArtEventFilter    `json:"event_filter"`
+	Handlers    []ArtEventHandler `json:"handlers"`
+}
+
+// ArtEventFilter selects the events of a webhook by domain, type and
+// repository
+type ArtEventFilter struct {
+	Domain     string           `json:"domain"`
+	EventTypes []string         `json:"event_types"`
+	Criteria   ArtEventCriteria `json:"criteria"`
+}
+
+// ArtEventCriteria selects the repositories the events of a webhook are for
+type ArtEventCriteria struct {
+	AnyLocal        bool     `json:"anyLocal"`
+	AnyRemote       bool     `json:"anyRemote"`
+	RepoKeys        []string `json:"repoKeys"`
+	IncludePatterns []string `json:"includePatterns"`
+	ExcludePatterns []string `json:"excludePatterns"`
+}
+
+// ArtEventHandler is where a webhook sends its events to.  The secret signs
+// the payload when it is used for signing
+type ArtEventHandler struct {
+	HandlerType         string `json:"handler_type"`
+	URL                 string `json:"url"`
+	Secret              string `json:"secret,omitempty"`
+	UseSecretForSigning bool   `json:"use_secret_for_signing"`
+}
+
+// ArtItems is the result of an AQL search for items
+type ArtItems struct {
+	Results []ArtItem `json:"results"`
+}
+
+// ArtItem is a file in an artifactory repository
+type ArtItem struct {
+	Repo     string `json:"repo"`
+	Path     string `json:"path"`
+	Name     string `json:"name"`
+	Sha256   string `json:"sha256"`
+	Modified string `json:"modified"`
+}
+
+// FindArtifactoryManifests searches with AQL for the manifests of docker tags
+// modified after a time, all of them if it is empty.  They are returned
+// from the oldest to the latest modified
+func FindArtifactoryManifests(cred *RegistryAuth, modifiedAfter string) ([]ArtItem, error) {
+	criteria := []map[string]interface{}{
+		{"name": map[string][]string{"$in": {ArtManifestName, ArtManifestListName}}},
+	}
+	if len(modifiedAfter) > 0 {
+		criteria = append(criteria, map[string]interface{}{"modified": map[string]string{"$gt": modifiedAfter}})
+	}
+	find, err := json.Marshal(map[string]interface{}{"$and": criteria})
+	if err != nil {
+		return nil, err
+	}
+
+	items := []ArtItem{}
+	for offset := 0; ; offset = offset + ArtAQLPageSize {
+		query := fmt.Sprintf(`items.find(%s).include("repo","path","name","sha256","modified").sort({"$asc":["modified"]}).offset(%d).limit(%d)`, find, offset, ArtAQLPageSize)
+		page := &ArtItems{}
+		err = searchArtifactory(cred, query, page)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Results...)
+		if len(page.Results) < ArtAQLPageSize {
+			return items, nil
+		}
+	}
+}
+
+// GetArtifactoryDockerRepos returns the types of the docker repositories of
+// artifactory by their keys
+func GetArtifactoryDockerRepos(cred *RegistryAuth) (map[string]string, error) {
+	dockerRepos := &ArtDockerRepo{}
+	err := GetResourceOfType(fmt.Sprintf("%s/api/repositories?packageType=docker", cred.URL), cred, "", dockerRepos)
+	if err != nil {
+		return nil, err
+	}
+	repoTypes := map[string]string{}
+	for _, repo := range *dockerRepos {
+		repoTypes[repo.Key] = repo.Type
+	}
+	return repoTypes, nil
+}
+
+// ArtDockerRepoKey returns the docker repository an item is pulled from.
+// The items of a remote repository are kept in its -cache repository.
+// Items outside of the docker repositories return an empty key
+func ArtDockerRepoKey(itemRepo string, repoTypes map[string]string) string {
+	if _, ok := repoTypes[itemRepo]; ok {
+		return itemRepo
+	}
+	if remote := strings.TrimSuffix(itemRepo, "-cache"); remote != itemRepo {
+		if _, ok := repoTypes[remote]; ok {
+			return remote
+		}
+	}
+	return ""
+}
+
+// searchArtifactory runs an AQL query
+func searchArtifactory(cred *RegistryAuth, query string, target interface{}) error {
+	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	client := &http.Client{Transport: tr}
+
+	url := fmt.Sprintf("%s/api/search/aql", cred.URL)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(query))
+	if err != nil {
+		return fmt.Errorf("Error in creating AQL request %e at url %s", err, url)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	req.SetBasicAuth(cred.User, cred.Password)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("AQL search at url %s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
@@ -0,0 +1,125 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeArtifactory is a stand-in for the AQL search of an artifactory with
+// many manifests, modified one second apart.  It records the queries
+type fakeArtifactory struct {
+	manifests int
+	queries   []string
+}
+
+var aqlPage = regexp.MustCompile(`\.offset\((\d+)\)\.limit\((\d+)\)$`)
+
+func (fa *fakeArtifactory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user, password, _ := r.BasicAuth()
+	if r.Method != http.MethodPost || r.URL.Path != "/artifactory/api/search/aql" || user != "admin" || password != "password" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	query := string(body)
+	fa.queries = append(fa.queries, query)
+
+	page := aqlPage.FindStringSubmatch(query)
+	offset, _ := strconv.Atoi(page[1])
+	limit, _ := strconv.Atoi(page[2])
+	items := ArtItems{Results: []ArtItem{}}
+	for i := offset; i < offset+limit && i < fa.manifests; i++ {
+		// Every manifest is older than the times the test searches after
+		if strings.Contains(query, `"$gt"`) {
+			break
+		}
+		modified := fmt.Sprintf("2019-06-06T10:%02d:%02d.000Z", i/60%60, i%60)
+		items.Results = append(items.Results, ArtItem{Repo: "docker-local", Path: fmt.Sprintf("app/%d", i), Name: ArtManifestName, Sha256: fmt.Sprintf("%064d", i), Modified: modified})
+	}
+	json.NewEncoder(w).Encode(items)
+}
+
+func TestFindArtifactoryManifests(t *testing.T) {
+	fa := &fakeArtifactory{manifests: ArtAQLPageSize + 10}
+	server := httptest.NewServer(fa)
+	defer server.Close()
+	cred := &RegistryAuth{URL: server.URL + "/artifactory", User: "admin", Password: "password"}
+
+	items, err := FindArtifactoryManifests(cred, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != ArtAQLPageSize+10 {
+		t.Errorf("expected %d manifests, got %d", ArtAQLPageSize+10, len(items))
+	}
+	if len(fa.queries) != 2 || strings.Contains(fa.queries[0], `"modified":`) {
+		t.Errorf("expected 2 pages without a modified criteria, got %v", fa.queries)
+	}
+	if items[0].Sha256 != fmt.Sprintf("%064d", 0) || items[0].Path != "app/0" {
+		t.Errorf("unexpected first manifest %+v", items[0])
+	}
+
+	fa.queries = nil
+	items, err = FindArtifactoryManifests(cred, "2019-06-06T11:00:00.000Z")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 0 || len(fa.queries) != 1 || !strings.Contains(fa.queries[0], `{"modified":{"$gt":"2019-06-06T11:00:00.000Z"}}`) {
+		t.Errorf("expected no manifests modified after the time, got %d from %v", len(items), fa.queries)
+	}
+
+	cred.Password = "wrong"
+	_, err = FindArtifactoryManifests(cred, "")
+	if err == nil {
+		t.Errorf("expected an error with the wrong password")
+	}
+}
+
+func TestArtDockerRepoKey(t *testing.T) {
+	repoTypes := map[string]string{"docker-local": "LOCAL", "docker-remote": "REMOTE", "docker": "VIRTUAL"}
+	testcases := []struct {
+		description string
+		itemRepo    string
+		expected    string
+	}{
+		{description: "local repository", itemRepo: "docker-local", expected: "docker-local"},
+		{description: "cache of a remote repository", itemRepo: "docker-remote-cache", expected: "docker-remote"},
+		{description: "not a docker repository", itemRepo: "generic-local", expected: ""},
+		{description: "cache of another repository", itemRepo: "npm-remote-cache", expected: ""},
+	}
+
+	for _, tc := range testcases {
+		result := ArtDockerRepoKey(tc.itemRepo, repoTypes)
+		if result != tc.expected {
+			t.Errorf("[%s] expected %q got %q", tc.description, tc.expected, result)
+		}
+	}
+}
@@ -22,11 +22,15 @@ under the License.
 package utils
 
 import (
+	"bytes"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"strings"
+
+	"github.com/blackducksoftware/perceivers/pkg/registry"
 )
 
 // RegistryAuth stores the credentials for a private docker repo
@@ -38,9 +42,35 @@ type RegistryAuth struct {
 	Token    string
 }
 
+// String returns the URL and user of the registry, the password and token
+// are redacted so credentials can't end up in the logs
+func (r *RegistryAuth) String() string {
+	return fmt.Sprintf("{URL:%s User:%s Password:<redacted> Token:<redacted>}", r.URL, r.User)
+}
+
+// Credentials returns the user and password to talk to the registry API
+// with, or nil for a registry that allows anonymous pulls
+func (r *RegistryAuth) Credentials() *registry.Credentials {
+	if len(r.User) == 0 && len(r.Password) == 0 {
+		return nil
+	}
+	return &registry.Credentials{Username: r.User, Password: r.Password}
+}
+
 // GetResourceOfType takes in the specified URL with credentials and
 // tries to decode returning json to specified interface
 func GetResourceOfType(url string, cred *RegistryAuth, bearerToken string, target interface{}) error {
+	return getResource(url, cred, bearerToken, target, false)
+}
+
+// GetPageOfType is GetResourceOfType for a page of a listing.  A page that
+// isn't answered with 200 is an error, so a failed page doesn't end the
+// listing early
+func GetPageOfType(url string, cred *RegistryAuth, bearerToken string, target interface{}) error {
+	return getResource(url, cred, bearerToken, target, true)
+}
+
+func getResource(url string, cred *RegistryAuth, bearerToken string, target interface{}, checkStatus bool) error {
 	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
 	client := &http.Client{Transport: tr}
 
@@ -63,9 +93,86 @@ func GetResourceOfType(url string, cred *RegistryAuth, bearerToken string, targe
 		return err
 	}
 	defer resp.Body.Close()
+	if checkStatus && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
 	return json.NewDecoder(resp.Body).Decode(target)
 }
 
+// SendRequest sends a request with the body as json to the API of a
+// registry with the credentials, or the bearer token if it is set, and
+// returns an error unless it is answered with the expected status
+func SendRequest(client *http.Client, method string, url string, cred *RegistryAuth, bearerToken string, body interface{}, expectedStatus int) error {
+	buffer := new(bytes.Buffer)
+	if body != nil {
+		json.NewEncoder(buffer).Encode(body)
+	}
+	req, err := http.NewRequest(method, url, buffer)
+	if err != nil {
+		return fmt.Errorf("Error in creating %s request %e", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(bearerToken) > 0 {
+		req.Header.Set("Authorization", "Bearer "+bearerToken)
+	} else if cred != nil {
+		req.SetBasicAuth(cred.User, cred.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error in sending %s request %e", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != expectedStatus {
+		return fmt.Errorf("%s %s returned %d, expected %d", method, url, resp.StatusCode, expectedStatus)
+	}
+	return nil
+}
+
+// GetManifest takes in the URL of a manifest in a registry API with
+// credentials and parses the manifest, which may be a manifest list
+func GetManifest(url string, cred *RegistryAuth, bearerToken string) (*registry.Manifest, error) {
+	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	client := &http.Client{Transport: tr}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error in creating get request %e at url %s", err, url)
+	}
+	req.Header.Set("Accept", strings.Join(registry.ManifestMediaTypes, ", "))
+
+	if cred != nil {
+		req.SetBasicAuth(cred.User, cred.Password)
+	}
+
+	if len(bearerToken) > 0 {
+		req.Header.Set("Authorization", "Bearer "+bearerToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read manifest at url %s: %v", url, err)
+	}
+	manifest, err := registry.ParseManifest(resp.Header.Get("Content-Type"), body)
+	if err != nil {
+		return nil, err
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); len(digest) > 0 {
+		manifest.Digest = digest
+	}
+	return manifest, nil
+}
+
 // PingArtifactoryServer takes in the specified URL with username & password and checks weather
 // it's a valid login for artifactory by pinging the server with various options and returns the correct URL
 func PingArtifactoryServer(url string, username string, password string) (*RegistryAuth, error) {
@@ -107,3 +214,98 @@ func PingArtifactoryServer(url string, username string, password string) (*Regis
 	url = strings.Replace(url, "/api/system/ping", "", -1)
 	return &RegistryAuth{URL: url, User: username, Password: password}, nil
 }
+
+// PingQuayServer takes in the specified URL with access token and checks weather
+// it's a valid token for quay by pinging the server
+func PingQuayServer(url string, user string, password string, accessToken string) (*RegistryAuth, error) {
+	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	client := &http.Client{Transport: tr}
+
+	url = fmt.Sprintf("%s/api/v1/user", url)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error in creating ping request for quay server %e", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error in pinging quay server %+v, response: %+v", err, resp)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// Making sure that http and https both return not OK
+		if strings.Contains(url, "https://") {
+			url = strings.Replace(url, "https://", "http://", -1)
+			// Reset to baseURL
+			url = strings.Replace(url, "/api/v1/user", "", -1)
+			return PingQuayServer(url, user, password, accessToken)
+		}
+
+		return nil, fmt.Errorf("Error in pinging quay server supposed to get %d response code got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	// Reset to baseURL
+	url = strings.Replace(url, "/api/v1/user", "", -1)
+	return &RegistryAuth{URL: url, User: user, Password: password, Token: accessToken}, nil
+}
+
+// PingHarborServer takes in the specified URL with username & password and checks weather
+// it's a valid login for harbor by listing a project and returns the correct URL
+func PingHarborServer(url string, username string, password string) (*RegistryAuth, error) {
+	projects := []HarborProject{}
+	cred := &RegistryAuth{URL: url, User: username, Password: password}
+	err := GetResourceOfType(fmt.Sprintf("%s/api/v2.0/projects?page_size=1", url), cred, "", &projects)
+	if err != nil {
+		// Making sure that http and https both fail
+		if strings.HasPrefix(url, "https://") {
+			return PingHarborServer(strings.Replace(url, "https://", "http://", 1), username, password)
+		}
+		return nil, fmt.Errorf("Error in pinging harbor server: %v", err)
+	}
+	return cred, nil
+}
+
+// PingNexusServer takes in the specified URL with username & password and checks weather
+// it's a valid login for nexus by listing its repositories and returns the correct URL
+func PingNexusServer(url string, username string, password string) (*RegistryAuth, error) {
+	repositories := []NexusRepository{}
+	cred := &RegistryAuth{URL: url, User: username, Password: password}
+	err := GetResourceOfType(fmt.Sprintf("%s/service/rest/v1/repositories", url), cred, "", &repositories)
+	if err != nil {
+		// The instance may be served under /nexus
+		if !strings.HasSuffix(url, "/nexus") {
+			return PingNexusServer(url+"/nexus", username, password)
+		}
+		// Making sure that http and https both fail
+		url = strings.TrimSuffix(url, "/nexus")
+		if strings.HasPrefix(url, "https://") {
+			return PingNexusServer(strings.Replace(url, "https://", "http://", 1), username, password)
+		}
+		return nil, fmt.Errorf("Error in pinging nexus server: %v", err)
+	}
+	return cred, nil
+}
+
+// PingGitLabServer takes in the specified URL with an access token and checks weather
+// it's a valid token for gitlab by pinging the server and returns the correct URL.
+// The token is the password when it isn't set
+func PingGitLabServer(url string, username string, password string, accessToken string) (*RegistryAuth, error) {
+	if len(accessToken) == 0 {
+		accessToken = password
+	}
+	version := &struct {
+		Version string `json:"version"`
+	}{}
+	err := GetResourceOfType(fmt.Sprintf("%s/api/v4/version", url), nil, accessToken, version)
+	if err != nil || len(version.Version) == 0 {
+		// Making sure that http and https both fail
+		if strings.HasPrefix(url, "https://") {
+			return PingGitLabServer(strings.Replace(url, "https://", "http://", 1), username, password, accessToken)
+		}
+		return nil, fmt.Errorf("Error in pinging gitlab server: %v", err)
+	}
+	return &RegistryAuth{URL: url, User: username, Password: password, Token: accessToken}, nil
+}
@@ -22,10 +22,15 @@ under the License.
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
 )
 
 // LogInterface is a simple interface providing Errorf an Logf
@@ -77,3 +82,99 @@ func (f *FakeHandler) Validate(expectedPath string, expectedMethod string, expec
 	}
 	return nil
 }
+
+// FakeAPI is a stand-in for the json API of a registry in tests.  A request
+// is answered by the first route with its method and escaped path, a path
+// ending in * is a prefix.  Other requests are answered with 404.  Routes
+// are called one at a time, so they can record requests without locking
+type FakeAPI struct {
+	// Token, if set, is the bearer token every request needs
+	Token  string
+	lock   sync.Mutex
+	routes []fakeRoute
+}
+
+type fakeRoute struct {
+	method  string
+	path    string
+	handler http.HandlerFunc
+}
+
+// Handle adds a route
+func (f *FakeAPI) Handle(method string, path string, handler http.HandlerFunc) {
+	f.routes = append(f.routes, fakeRoute{method: method, path: path, handler: handler})
+}
+
+// Reply adds a route that answers with the body as json
+func (f *FakeAPI) Reply(method string, path string, body interface{}) {
+	f.Handle(method, path, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(body)
+	})
+}
+
+func (f *FakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if len(f.Token) > 0 && r.Header.Get("Authorization") != "Bearer "+f.Token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := r.URL.EscapedPath()
+	for _, route := range f.routes {
+		if route.method != r.Method {
+			continue
+		}
+		if route.path == path || strings.HasSuffix(route.path, "*") && strings.HasPrefix(path, strings.TrimSuffix(route.path, "*")) {
+			route.handler(w, r)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+// FakePerceptor is a stand-in for perceptor that records the images it is
+// sent.  Images with a sha in Failing are answered with 500
+type FakePerceptor struct {
+	FakeAPI
+	Images  []FakeImage
+	Failing map[string]bool
+}
+
+// FakeImage is an image FakePerceptor was sent, with the platform and list
+// sha of a platform image
+type FakeImage struct {
+	perceptorapi.Image
+	Platform string
+	IndexSha string
+}
+
+// NewFakePerceptor creates a new FakePerceptor object
+func NewFakePerceptor() *FakePerceptor {
+	fp := &FakePerceptor{Failing: map[string]bool{}}
+	fp.Handle(http.MethodPost, "/"+perceptorapi.ImagePath, func(w http.ResponseWriter, r *http.Request) {
+		image := FakeImage{}
+		json.NewDecoder(r.Body).Decode(&image)
+		if fp.Failing[image.Sha] {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fp.Images = append(fp.Images, image)
+	})
+	return fp
+}
+
+// Shas returns the sha of each image perceptor was sent, after its
+// platform if it has one
+func (fp *FakePerceptor) Shas() []string {
+	fp.lock.Lock()
+	defer fp.lock.Unlock()
+	shas := []string{}
+	for _, image := range fp.Images {
+		sha := image.Sha
+		if len(image.Platform) > 0 {
+			sha = fmt.Sprintf("%s %s", image.Platform, sha)
+		}
+		shas = append(shas, sha)
+	}
+	return shas
+}
@@ -0,0 +1,152 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// The types of artifactory repositories
+const (
+	ArtLocalRepository   = "local"
+	ArtRemoteRepository  = "remote"
+	ArtVirtualRepository = "virtual"
+)
+
+// RepositoryFilter selects the repositories of a registry by globs of their
+// names, like team/* for the repositories of the namespace team.  A
+// repository is selected when it matches an include glob, or there are none,
+// and doesn't match any exclude glob
+type RepositoryFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// IsEmpty returns whether the filter selects every repository
+func (f *RepositoryFilter) IsEmpty() bool {
+	return f == nil || (len(f.Include) == 0 && len(f.Exclude) == 0)
+}
+
+// Validate returns an error for a glob that isn't valid
+func (f *RepositoryFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid repository glob %s: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Matches returns whether the repository with the name is selected.  A nil
+// filter selects every repository
+func (f *RepositoryFilter) Matches(name string) bool {
+	if f == nil {
+		return true
+	}
+	for _, pattern := range f.Exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, pattern := range f.Include {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ArtifactoryFilter selects the docker repositories of artifactory by globs
+// of their keys and by their types, and the images in them by globs of their
+// paths, like team/* for the images of team.  Every type is selected when
+// there are none
+type ArtifactoryFilter struct {
+	Keys  RepositoryFilter
+	Types []string
+	Paths RepositoryFilter
+}
+
+// IsEmpty returns whether the filter selects every image
+func (f *ArtifactoryFilter) IsEmpty() bool {
+	return f == nil || (f.Keys.IsEmpty() && len(f.Types) == 0 && f.Paths.IsEmpty())
+}
+
+// Validate returns an error for a glob or type that isn't valid
+func (f *ArtifactoryFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	err := f.Keys.Validate()
+	if err != nil {
+		return err
+	}
+	err = f.Paths.Validate()
+	if err != nil {
+		return err
+	}
+	for _, repoType := range f.Types {
+		switch strings.ToLower(repoType) {
+		case ArtLocalRepository, ArtRemoteRepository, ArtVirtualRepository:
+		default:
+			return fmt.Errorf("invalid repository type %s, expected one of %s, %s or %s", repoType, ArtLocalRepository, ArtRemoteRepository, ArtVirtualRepository)
+		}
+	}
+	return nil
+}
+
+// MatchesRepository returns whether the repository with the key and type is
+// selected.  A nil filter selects every repository
+func (f *ArtifactoryFilter) MatchesRepository(key string, repoType string) bool {
+	if f == nil {
+		return true
+	}
+	if !f.Keys.Matches(key) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		// Artifactory lists the types in upper case
+		if strings.EqualFold(t, repoType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches returns whether the image with the path in the repository with
+// the key and type is selected.  A nil filter selects every image
+func (f *ArtifactoryFilter) Matches(key string, repoType string, image string) bool {
+	if f == nil {
+		return true
+	}
+	return f.MatchesRepository(key, repoType) && f.Paths.Matches(image)
+}
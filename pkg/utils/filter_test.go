@@ -0,0 +1,170 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+)
+
+func TestRepositoryFilter(t *testing.T) {
+	testcases := []struct {
+		description string
+		filter      *RepositoryFilter
+		name        string
+		shouldPass  bool
+	}{
+		{
+			description: "nil filter",
+			filter:      nil,
+			name:        "team/app",
+			shouldPass:  true,
+		},
+		{
+			description: "empty filter",
+			filter:      &RepositoryFilter{},
+			name:        "team/app",
+			shouldPass:  true,
+		},
+		{
+			description: "included namespace",
+			filter:      &RepositoryFilter{Include: []string{"team/*"}},
+			name:        "team/app",
+			shouldPass:  true,
+		},
+		{
+			description: "other namespace",
+			filter:      &RepositoryFilter{Include: []string{"team/*"}},
+			name:        "other/app",
+			shouldPass:  false,
+		},
+		{
+			description: "glob doesn't cross a slash",
+			filter:      &RepositoryFilter{Include: []string{"team/*"}},
+			name:        "team/apps/web",
+			shouldPass:  false,
+		},
+		{
+			description: "excluded repository",
+			filter:      &RepositoryFilter{Include: []string{"team/*"}, Exclude: []string{"team/*-cache"}},
+			name:        "team/hub-cache",
+			shouldPass:  false,
+		},
+		{
+			description: "exclude only",
+			filter:      &RepositoryFilter{Exclude: []string{"sandbox/*"}},
+			name:        "team/app",
+			shouldPass:  true,
+		},
+	}
+
+	for _, tc := range testcases {
+		result := tc.filter.Matches(tc.name)
+		if result != tc.shouldPass {
+			t.Errorf("[%s] expected %t for %s, got %t", tc.description, tc.shouldPass, tc.name, result)
+		}
+	}
+
+	invalid := &RepositoryFilter{Exclude: []string{"team/["}}
+	if invalid.Validate() == nil {
+		t.Errorf("expected glob %s to be invalid", invalid.Exclude[0])
+	}
+}
+
+func TestArtifactoryFilter(t *testing.T) {
+	testcases := []struct {
+		description string
+		filter      *ArtifactoryFilter
+		key         string
+		repoType    string
+		image       string
+		shouldPass  bool
+	}{
+		{
+			description: "nil filter",
+			filter:      nil,
+			key:         "docker-remote",
+			repoType:    "REMOTE",
+			image:       "library/busybox",
+			shouldPass:  true,
+		},
+		{
+			description: "local type",
+			filter:      &ArtifactoryFilter{Types: []string{ArtLocalRepository}},
+			key:         "docker-local",
+			repoType:    "LOCAL",
+			image:       "team/app",
+			shouldPass:  true,
+		},
+		{
+			description: "remote type",
+			filter:      &ArtifactoryFilter{Types: []string{ArtLocalRepository}},
+			key:         "docker-remote",
+			repoType:    "REMOTE",
+			image:       "library/busybox",
+			shouldPass:  false,
+		},
+		{
+			description: "excluded key",
+			filter:      &ArtifactoryFilter{Keys: RepositoryFilter{Exclude: []string{"*-snapshots"}}},
+			key:         "docker-snapshots",
+			repoType:    "LOCAL",
+			image:       "team/app",
+			shouldPass:  false,
+		},
+		{
+			description: "included path",
+			filter:      &ArtifactoryFilter{Keys: RepositoryFilter{Include: []string{"docker-*"}}, Paths: RepositoryFilter{Include: []string{"team/*"}}},
+			key:         "docker-local",
+			repoType:    "LOCAL",
+			image:       "team/app",
+			shouldPass:  true,
+		},
+		{
+			description: "other path",
+			filter:      &ArtifactoryFilter{Paths: RepositoryFilter{Include: []string{"team/*"}}},
+			key:         "docker-local",
+			repoType:    "LOCAL",
+			image:       "other/app",
+			shouldPass:  false,
+		},
+	}
+
+	for _, tc := range testcases {
+		result := tc.filter.Matches(tc.key, tc.repoType, tc.image)
+		if result != tc.shouldPass {
+			t.Errorf("[%s] expected %t for %s/%s, got %t", tc.description, tc.shouldPass, tc.key, tc.image, result)
+		}
+	}
+
+	if !(&ArtifactoryFilter{}).IsEmpty() || (&ArtifactoryFilter{Types: []string{ArtLocalRepository}}).IsEmpty() {
+		t.Errorf("expected only a filter without keys, types and paths to be empty")
+	}
+
+	invalid := &ArtifactoryFilter{Types: []string{"federated"}}
+	if invalid.Validate() == nil {
+		t.Errorf("expected type %s to be invalid", invalid.Types[0])
+	}
+	invalid = &ArtifactoryFilter{Paths: RepositoryFilter{Include: []string{"team/["}}}
+	if invalid.Validate() == nil {
+		t.Errorf("expected glob %s to be invalid", invalid.Paths.Include[0])
+	}
+}
@@ -0,0 +1,207 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+)
+
+// GitLabPageSize is how many items are asked for per page of the GitLab API
+const GitLabPageSize = 100
+
+// GitLabProject is a project of GitLab, which may have container repositories
+type GitLabProject struct {
+	ID                int64  `json:"id"`
+	PathWithNamespace string `json:"path_with_namespace"`
+}
+
+// GitLabRepository is a container repository of a project.  Its location
+// is the name of its images in the container registry of GitLab
+type GitLabRepository struct {
+	ID        int64       `json:"id"`
+	Name      string      `json:"name"`
+	Path      string      `json:"path"`
+	ProjectID int64       `json:"project_id"`
+	Location  string      `json:"location"`
+	Tags      []GitLabTag `json:"tags"`
+}
+
+// GitLabTag is a tag of a container repository, only the details of a tag
+// have its digest
+type GitLabTag struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Location string `json:"location"`
+	Digest   string `json:"digest"`
+}
+
+// GitLabVariable is a CI variable of a project
+type GitLabVariable struct {
+	Key          string `json:"key"`
+	Value        string `json:"value"`
+	VariableType string `json:"variable_type,omitempty"`
+	Protected    bool   `json:"protected"`
+	Masked       bool   `json:"masked"`
+}
+
+// GitLabHookStruct is the structure of the system hooks GitLab sends for
+// pushes to the repositories of projects
+type GitLabHookStruct struct {
+	ObjectKind string `json:"object_kind"`
+	EventName  string `json:"event_name"`
+	ProjectID  int64  `json:"project_id"`
+}
+
+// gitLabVariableKeyRegexp matches the characters a CI variable key can't have
+var gitLabVariableKeyRegexp = regexp.MustCompile("[^A-Z0-9_]")
+
+// gitLabVariableKeysRegexp matches the keys GitLabVariableKey returns
+var gitLabVariableKeysRegexp = regexp.MustCompile("^BLACKDUCK_[A-Z0-9_]*_[0-9A-F]{12}$")
+
+// GitLabVariableKey returns the key of the CI variable that holds the BD
+// results of a tag of a container repository, like
+// BLACKDUCK_APP_API_1_0_5254E2C15456 for the tag 1.0 of the repository
+// app/api.  Repositories and tags that only differ in the characters a key
+// can't have are told apart by the last part, the first 12 hex digits of
+// the sha256 of app/api:1.0
+func GitLabVariableKey(repositoryPath string, tag string) string {
+	name := strings.ToUpper(fmt.Sprintf("BLACKDUCK_%s_%s", repositoryPath, tag))
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%s", repositoryPath, tag)))
+	return fmt.Sprintf("%s_%s", gitLabVariableKeyRegexp.ReplaceAllString(name, "_"), strings.ToUpper(hex.EncodeToString(sum[:6])))
+}
+
+// IsGitLabVariableKey returns whether the key is one GitLabVariableKey
+// returns, so the variable holds BD results
+func IsGitLabVariableKey(key string) bool {
+	return gitLabVariableKeysRegexp.MatchString(key)
+}
+
+// GetGitLabProjects lists the projects the access token is a member of
+func GetGitLabProjects(cred *RegistryAuth) ([]GitLabProject, error) {
+	projects := []GitLabProject{}
+	for page := 1; ; page++ {
+		pageProjects := []GitLabProject{}
+		projectsURL := fmt.Sprintf("%s/api/v4/projects?membership=true&simple=true&page=%d&per_page=%d", cred.URL, page, GitLabPageSize)
+		err := GetResourceOfType(projectsURL, nil, cred.Token, &pageProjects)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, pageProjects...)
+		if len(pageProjects) < GitLabPageSize {
+			return projects, nil
+		}
+	}
+}
+
+// GetGitLabRepositories lists the container repositories of a project with
+// their tags
+func GetGitLabRepositories(cred *RegistryAuth, projectID int64) ([]GitLabRepository, error) {
+	repositories := []GitLabRepository{}
+	for page := 1; ; page++ {
+		pageRepositories := []GitLabRepository{}
+		repositoriesURL := fmt.Sprintf("%s/api/v4/projects/%d/registry/repositories?tags=true&page=%d&per_page=%d", cred.URL, projectID, page, GitLabPageSize)
+		err := GetResourceOfType(repositoriesURL, nil, cred.Token, &pageRepositories)
+		if err != nil {
+			return nil, err
+		}
+		repositories = append(repositories, pageRepositories...)
+		if len(pageRepositories) < GitLabPageSize {
+			return repositories, nil
+		}
+	}
+}
+
+// GetGitLabVariables lists the CI variables of a project
+func GetGitLabVariables(cred *RegistryAuth, projectID int64) ([]GitLabVariable, error) {
+	variables := []GitLabVariable{}
+	for page := 1; ; page++ {
+		pageVariables := []GitLabVariable{}
+		variablesURL := fmt.Sprintf("%s/api/v4/projects/%d/variables?page=%d&per_page=%d", cred.URL, projectID, page, GitLabPageSize)
+		err := GetPageOfType(variablesURL, nil, cred.Token, &pageVariables)
+		if err != nil {
+			return nil, err
+		}
+		variables = append(variables, pageVariables...)
+		if len(pageVariables) < GitLabPageSize {
+			return variables, nil
+		}
+	}
+}
+
+// GetGitLabTag gets the details of a tag of a container repository
+func GetGitLabTag(cred *RegistryAuth, repository *GitLabRepository, tag string) (*GitLabTag, error) {
+	details := &GitLabTag{}
+	tagURL := fmt.Sprintf("%s/api/v4/projects/%d/registry/repositories/%d/tags/%s", cred.URL, repository.ProjectID, repository.ID, url.PathEscape(tag))
+	err := GetResourceOfType(tagURL, nil, cred.Token, details)
+	if err != nil {
+		return nil, err
+	}
+	if len(details.Digest) == 0 {
+		return nil, fmt.Errorf("tag %s of %s has no digest", tag, repository.Location)
+	}
+	return details, nil
+}
+
+// GitLabRegistryCredentials returns the credentials to log in to the
+// container registry of a GitLab instance with, which takes the access
+// token as the password
+func GitLabRegistryCredentials(cred *RegistryAuth) *registry.Credentials {
+	token := cred.Token
+	if len(token) == 0 {
+		token = cred.Password
+	}
+	// Access tokens log in to the registry with any user name
+	username := cred.User
+	if len(username) == 0 {
+		username = "oauth2"
+	}
+	return &registry.Credentials{Username: username, Password: token}
+}
+
+// GetGitLabManifest returns the manifest of a tag of a container
+// repository.  The digest comes from the GitLab API, the container registry
+// is asked whether it is a manifest list.  The error of a registry that
+// can't be reached is returned, the tag may be a list whose digest isn't
+// the sha of an image
+func GetGitLabManifest(client *registry.Client, cred *RegistryAuth, repository *GitLabRepository, tag string) (*registry.Manifest, error) {
+	details, err := GetGitLabTag(cred, repository, tag)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := docker.ParseReference(repository.Location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid location of %s: %v", repository.Path, err)
+	}
+	ref.DigestAlgorithm, ref.Digest, err = docker.ParseDigest(details.Digest)
+	if err != nil {
+		return nil, err
+	}
+	return client.ManifestHead(ref, GitLabRegistryCredentials(cred))
+}
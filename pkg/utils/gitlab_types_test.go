@@ -0,0 +1,122 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+)
+
+func TestGetGitLabManifest(t *testing.T) {
+	digest := "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	gitLab := &FakeAPI{Token: "token"}
+	gitLab.Reply(http.MethodGet, "/api/v4/projects/7/registry/repositories/3/tags/1.0", GitLabTag{Name: "1.0", Digest: digest})
+	gitLabServer := httptest.NewServer(gitLab)
+	defer gitLabServer.Close()
+
+	containerRegistry := &FakeAPI{}
+	containerRegistry.Handle(http.MethodHead, "/v2/team/app/manifests/*", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", registry.MediaTypeManifest)
+		w.Header().Set("Docker-Content-Digest", digest)
+	})
+	registryServer := httptest.NewServer(containerRegistry)
+	defer registryServer.Close()
+	registryHost := strings.TrimPrefix(registryServer.URL, "http://")
+
+	client := registry.NewClient([]string{registryHost}, time.Second)
+	cred := &RegistryAuth{URL: gitLabServer.URL, Token: "token"}
+
+	testcases := []struct {
+		description string
+		location    string
+		shouldPass  bool
+	}{
+		{
+			description: "image",
+			location:    registryHost + "/team/app",
+			shouldPass:  true,
+		},
+		{
+			description: "registry that can't be reached",
+			location:    "localhost:1/team/app",
+			shouldPass:  false,
+		},
+		{
+			description: "invalid location",
+			location:    "localhost:1/Team/App",
+			shouldPass:  false,
+		},
+	}
+
+	for _, tc := range testcases {
+		repository := &GitLabRepository{ID: 3, ProjectID: 7, Path: "team/app", Location: tc.location}
+		manifest, err := GetGitLabManifest(client, cred, repository, "1.0")
+		if err != nil && tc.shouldPass {
+			t.Errorf("[%s] unexpected error: %v", tc.description, err)
+		}
+		if err == nil && !tc.shouldPass {
+			t.Errorf("[%s] expected an error, got manifest %v", tc.description, manifest)
+		}
+		if tc.shouldPass && (manifest.Digest != digest || manifest.IsList()) {
+			t.Errorf("[%s] expected image %s, got %v", tc.description, digest, manifest)
+		}
+	}
+}
+
+func TestGitLabVariableKey(t *testing.T) {
+	testcases := []struct {
+		repositoryPath string
+		tag            string
+	}{
+		{repositoryPath: "app/api", tag: "1.0"},
+		{repositoryPath: "app-api", tag: "1_0"},
+		{repositoryPath: "app", tag: "api_1.0"},
+		{repositoryPath: "App/API", tag: "1.0"},
+	}
+
+	keys := map[string]string{}
+	for _, tc := range testcases {
+		image := tc.repositoryPath + ":" + tc.tag
+		key := GitLabVariableKey(tc.repositoryPath, tc.tag)
+		if !IsGitLabVariableKey(key) {
+			t.Errorf("[%s] key %s isn't a key of BD results", image, key)
+		}
+		if !strings.HasPrefix(key, "BLACKDUCK_APP_API_1_0_") {
+			t.Errorf("[%s] expected key %s to start with the image", image, key)
+		}
+		if other, ok := keys[key]; ok {
+			t.Errorf("[%s] has the same key %s as %s", image, key, other)
+		}
+		keys[key] = image
+	}
+
+	for _, key := range []string{"BLACKDUCK_URL", "BLACKDUCK_APP_API_1_0", "OTHER_APP_2F9D0A1C6B3E"} {
+		if IsGitLabVariableKey(key) {
+			t.Errorf("[%s] expected a key that doesn't hold BD results", key)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+)
+
+// HarborPageSize is how many items are asked for per page of the Harbor API
+const HarborPageSize = 100
+
+// HarborImageType is the type of the artifacts that are images, rather
+// than charts or other OCI artifacts
+const HarborImageType = "IMAGE"
+
+// HarborProject is a project of Harbor, which holds repositories
+type HarborProject struct {
+	ProjectID int64  `json:"project_id"`
+	Name      string `json:"name"`
+}
+
+// HarborRepository is a repository of Harbor, its name starts with the
+// name of its project
+type HarborRepository struct {
+	ID        int64  `json:"id"`
+	ProjectID int64  `json:"project_id"`
+	Name      string `json:"name"`
+}
+
+// HarborArtifact is a manifest pushed to a repository of Harbor
+type HarborArtifact struct {
+	ID                int64             `json:"id"`
+	Type              string            `json:"type"`
+	Digest            string            `json:"digest"`
+	MediaType         string            `json:"media_type"`
+	ManifestMediaType string            `json:"manifest_media_type"`
+	Tags              []HarborTag       `json:"tags"`
+	References        []HarborReference `json:"references"`
+	Labels            []HarborLabel     `json:"labels"`
+}
+
+// HarborTag is a tag of an artifact
+type HarborTag struct {
+	Name string `json:"name"`
+}
+
+// HarborReference is the manifest of one platform of an artifact that is
+// a manifest list
+type HarborReference struct {
+	ChildDigest string             `json:"child_digest"`
+	Platform    *registry.Platform `json:"platform"`
+}
+
+// HarborLabel is a label that can be attached to artifacts
+type HarborLabel struct {
+	ID          int64  `json:"id,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Color       string `json:"color,omitempty"`
+	Scope       string `json:"scope"`
+	ProjectID   int64  `json:"project_id,omitempty"`
+}
+
+// HarborHookStruct is the structure of the webhooks Harbor sends
+type HarborHookStruct struct {
+	Type      string `json:"type"`
+	OccurAt   int64  `json:"occur_at"`
+	Operator  string `json:"operator"`
+	EventData struct {
+		Resources []struct {
+			Digest      string `json:"digest"`
+			Tag         string `json:"tag"`
+			ResourceURL string `json:"resource_url"`
+		} `json:"resources"`
+		Repository struct {
+			Name         string `json:"name"`
+			Namespace    string `json:"namespace"`
+			RepoFullName string `json:"repo_full_name"`
+		} `json:"repository"`
+	} `json:"event_data"`
+}
+
+// Manifest returns the manifest of the artifact with the platforms of a
+// manifest list
+func (a *HarborArtifact) Manifest() *registry.Manifest {
+	manifest := &registry.Manifest{MediaType: a.ManifestMediaType, Digest: a.Digest}
+	if len(manifest.MediaType) == 0 {
+		manifest.MediaType = a.MediaType
+	}
+	for _, reference := range a.References {
+		if reference.Platform == nil {
+			continue
+		}
+		manifest.Platforms = append(manifest.Platforms, registry.PlatformManifest{Digest: reference.ChildDigest, Platform: *reference.Platform})
+	}
+	return manifest
+}
+
+// HarborArtifactsURL returns the API URL of the artifacts of a repository,
+// whose name starts with the name of its project.  Harbor wants the
+// slashes of the rest of the name encoded twice
+func HarborArtifactsURL(baseURL string, repository string) (string, error) {
+	parts := strings.SplitN(repository, "/", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("harbor repository %s has no project", repository)
+	}
+	return fmt.Sprintf("%s/api/v2.0/projects/%s/repositories/%s/artifacts", baseURL, parts[0], url.PathEscape(url.PathEscape(parts[1]))), nil
+}
@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NexusDockerFormat is the format of the docker repositories of Nexus
+const NexusDockerFormat = "docker"
+
+// NexusRepository is a repository of Nexus
+type NexusRepository struct {
+	Name   string `json:"name"`
+	Format string `json:"format"`
+	Type   string `json:"type"`
+	URL    string `json:"url"`
+}
+
+// NexusComponents is a page of the components of a repository, the
+// continuation token asks for the next page
+type NexusComponents struct {
+	Items             []NexusComponent `json:"items"`
+	ContinuationToken string           `json:"continuationToken"`
+}
+
+// NexusComponent is a component of Nexus.  The components of docker
+// repositories are the tags of images, named by the image
+type NexusComponent struct {
+	ID         string       `json:"id"`
+	Repository string       `json:"repository"`
+	Format     string       `json:"format"`
+	Group      string       `json:"group"`
+	Name       string       `json:"name"`
+	Version    string       `json:"version"`
+	Assets     []NexusAsset `json:"assets"`
+}
+
+// NexusAsset is a file of a component, which for a docker component is its
+// manifest
+type NexusAsset struct {
+	ID          string `json:"id"`
+	Path        string `json:"path"`
+	DownloadURL string `json:"downloadUrl"`
+	ContentType string `json:"contentType"`
+	Checksum    struct {
+		Sha1   string `json:"sha1"`
+		Sha256 string `json:"sha256"`
+	} `json:"checksum"`
+}
+
+// NexusTag is a tag that can be associated with components, whose
+// attributes hold arbitrary values
+type NexusTag struct {
+	Name       string            `json:"name,omitempty"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// NexusHookStruct is the structure of the repository component webhooks
+// Nexus sends
+type NexusHookStruct struct {
+	Timestamp      string `json:"timestamp"`
+	NodeID         string `json:"nodeId"`
+	Initiator      string `json:"initiator"`
+	RepositoryName string `json:"repositoryName"`
+	Action         string `json:"action"`
+	Component      struct {
+		ID          string `json:"id"`
+		ComponentID string `json:"componentId"`
+		Format      string `json:"format"`
+		Name        string `json:"name"`
+		Group       string `json:"group"`
+		Version     string `json:"version"`
+	} `json:"component"`
+}
+
+// NexusManifestURL returns the URL of a manifest in the docker registry
+// API that Nexus serves for a repository
+func NexusManifestURL(baseURL string, repository string, name string, reference string) string {
+	return fmt.Sprintf("%s/repository/%s/v2/%s/manifests/%s", baseURL, repository, name, reference)
+}
+
+// IsNexusDigestVersion returns whether the version of a docker component is
+// a digest rather than a tag, like those of the platform manifests of a
+// manifest list
+func IsNexusDigestVersion(version string) bool {
+	return strings.HasPrefix(version, "sha256:")
+}
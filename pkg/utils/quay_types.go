@@ -0,0 +1,240 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package utils
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	log "github.com/sirupsen/logrus"
+)
+
+// QuayPageSize is how many items are asked for per page of the Quay API by
+// default, which is also the most it returns
+const QuayPageSize = 100
+
+// QuayMaxPages is how many pages of a list are read by default
+const QuayMaxPages = 100
+
+// QuayPaging is how the lists of the Quay API are paged through.  The pages
+// are capped so a repository with a huge number of tags can't hold up the
+// perceiver, the rest of the list is left out
+type QuayPaging struct {
+	PageSize int
+	MaxPages int
+}
+
+// pageSize returns the size of the pages, a nil paging has the defaults
+func (p *QuayPaging) pageSize() int {
+	if p == nil || p.PageSize <= 0 || p.PageSize > QuayPageSize {
+		return QuayPageSize
+	}
+	return p.PageSize
+}
+
+// maxPages returns how many pages are read at most
+func (p *QuayPaging) maxPages() int {
+	if p == nil || p.MaxPages <= 0 {
+		return QuayMaxPages
+	}
+	return p.MaxPages
+}
+
+// QuayUser is the user of an access token with the organizations it is a
+// member of
+type QuayUser struct {
+	Username      string             `json:"username"`
+	Organizations []QuayOrganization `json:"organizations"`
+}
+
+// QuayOrganization is an organization of Quay, which owns repositories
+type QuayOrganization struct {
+	Name string `json:"name"`
+}
+
+// QuayRepositories is a page of the repositories of a namespace, the next
+// page is only set when there are more
+type QuayRepositories struct {
+	Repositories []QuayRepository `json:"repositories"`
+	NextPage     string           `json:"next_page"`
+}
+
+// QuayRepository is a repository of a Quay namespace
+type QuayRepository struct {
+	Namespace   string `json:"namespace"`
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Description string `json:"description"`
+	IsPublic    bool   `json:"is_public"`
+}
+
+// QuayTagDigest contains Digest for a particular Quay image
+type QuayTagDigest struct {
+	HasAdditional bool      `json:"has_additional"`
+	Page          int       `json:"page"`
+	Tags          []QuayTag `json:"tags"`
+}
+
+// QuayTag contains individual tag info for an image version
+type QuayTag struct {
+	Name           string `json:"name"`
+	Reversion      bool   `json:"reversion"`
+	StartTs        int    `json:"start_ts"`
+	ImageID        string `json:"image_id"`
+	LastModified   string `json:"last_modified"`
+	ManifestDigest string `json:"manifest_digest"`
+	DockerImageID  string `json:"docker_image_id"`
+	IsManifestList bool   `json:"is_manifest_list"`
+	Size           int    `json:"size"`
+}
+
+// QuayNotifications is the list of the notifications of a repository
+type QuayNotifications struct {
+	Notifications []QuayNotification `json:"notifications"`
+}
+
+// QuayNotification is a notification of a repository, which calls the
+// webhook with the URL of its config on the event
+type QuayNotification struct {
+	UUID        string                 `json:"uuid,omitempty"`
+	Title       string                 `json:"title"`
+	Event       string                 `json:"event"`
+	Method      string                 `json:"method"`
+	Config      QuayNotificationConfig `json:"config"`
+	EventConfig map[string]string      `json:"eventConfig"`
+}
+
+// QuayNotificationConfig is the config of the webhook of a notification
+type QuayNotificationConfig struct {
+	URL string `json:"url"`
+}
+
+// QuayImageKind is the kind of the repositories of container images, as
+// opposed to application repositories
+const QuayImageKind = "image"
+
+// GetQuayNamespaces lists the namespaces the access token can see the
+// repositories of: the one of its user and those of its organizations
+func GetQuayNamespaces(cred *RegistryAuth) ([]string, error) {
+	user := &QuayUser{}
+	err := GetResourceOfType(fmt.Sprintf("%s/api/v1/user", cred.URL), nil, cred.Token, user)
+	if err != nil {
+		return nil, err
+	}
+	namespaces := []string{}
+	if len(user.Username) > 0 {
+		namespaces = append(namespaces, user.Username)
+	}
+	for _, organization := range user.Organizations {
+		namespaces = append(namespaces, organization.Name)
+	}
+	return namespaces, nil
+}
+
+// GetQuayRepositories lists the repositories of a namespace
+func GetQuayRepositories(cred *RegistryAuth, namespace string, paging *QuayPaging) ([]QuayRepository, error) {
+	repositories := []QuayRepository{}
+	nextPage := ""
+	for page := 1; page <= paging.maxPages(); page++ {
+		rp := &QuayRepositories{}
+		repositoriesURL := fmt.Sprintf("%s/api/v1/repository?namespace=%s", cred.URL, url.QueryEscape(namespace))
+		if len(nextPage) > 0 {
+			repositoriesURL = fmt.Sprintf("%s&next_page=%s", repositoriesURL, url.QueryEscape(nextPage))
+		}
+		err := GetPageOfType(repositoriesURL, nil, cred.Token, rp)
+		if err != nil {
+			return nil, err
+		}
+		repositories = append(repositories, rp.Repositories...)
+		if len(rp.NextPage) == 0 || rp.NextPage == nextPage {
+			return repositories, nil
+		}
+		nextPage = rp.NextPage
+	}
+	log.Warnf("Only the first %d pages of the repositories of quay namespace %s were listed", paging.maxPages(), namespace)
+	return repositories, nil
+}
+
+// GetQuayTags lists the active tags of the repository with the API URL
+func GetQuayTags(repoURL string, bearerToken string, paging *QuayPaging) ([]QuayTag, error) {
+	return getQuayTags(fmt.Sprintf("%s/tag/?onlyActiveTags=true", repoURL), bearerToken, paging)
+}
+
+// GetQuayTag lists the active tags with the name of the repository with the
+// API URL, which are more than one while it is being moved
+func GetQuayTag(repoURL string, tag string, bearerToken string, paging *QuayPaging) ([]QuayTag, error) {
+	tags, err := getQuayTags(fmt.Sprintf("%s/tag/?onlyActiveTags=true&specificTag=%s", repoURL, url.QueryEscape(tag)), bearerToken, paging)
+	if err != nil {
+		return nil, err
+	}
+	named := []QuayTag{}
+	for _, t := range tags {
+		if t.Name == tag {
+			named = append(named, t)
+		}
+	}
+	return named, nil
+}
+
+// getQuayTags reads the pages of a listing of tags
+func getQuayTags(tagsURL string, bearerToken string, paging *QuayPaging) ([]QuayTag, error) {
+	tags := []QuayTag{}
+	for page := 1; page <= paging.maxPages(); page++ {
+		rt := &QuayTagDigest{}
+		err := GetPageOfType(fmt.Sprintf("%s&page=%d&limit=%d", tagsURL, page, paging.pageSize()), nil, bearerToken, rt)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, rt.Tags...)
+		if !rt.HasAdditional || len(rt.Tags) == 0 {
+			return tags, nil
+		}
+	}
+	log.Warnf("Only the first %d pages of the tags at %s were listed", paging.maxPages(), tagsURL)
+	return tags, nil
+}
+
+// QuayManifest contains the manifest of a Quay image, which is a manifest
+// list for a multi-arch image
+type QuayManifest struct {
+	Digest         string `json:"digest"`
+	IsManifestList bool   `json:"is_manifest_list"`
+	ManifestData   string `json:"manifest_data"`
+}
+
+// GetQuayManifest gets the manifest with the digest from the API URL of a
+// Quay repository and parses it
+func GetQuayManifest(repoURL string, digest string, bearerToken string) (*registry.Manifest, error) {
+	qm := &QuayManifest{}
+	url := fmt.Sprintf("%s/manifest/%s", repoURL, digest)
+	err := GetResourceOfType(url, nil, bearerToken, qm)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := registry.ParseManifest("", []byte(qm.ManifestData))
+	if err != nil {
+		return nil, err
+	}
+	manifest.Digest = digest
+	return manifest, nil
+}
@@ -0,0 +1,171 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// newFakeQuay creates a stand-in for the quay API of a namespace with a
+// repository of many tags and many repositories.  The failed page, if set,
+// is answered with an error, which quay sends as json
+func newFakeQuay(tags int, repositories int, failedPage int) *FakeAPI {
+	fq := &FakeAPI{Token: "token"}
+	fq.Handle(http.MethodGet, "/api/v1/repository/team/app/tag/", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		page, _ := strconv.Atoi(query.Get("page"))
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		if page == failedPage {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "internal error"})
+			return
+		}
+		found := []QuayTag{}
+		for i := 0; i < tags; i++ {
+			name := fmt.Sprintf("%d", i)
+			if specificTag := query.Get("specificTag"); len(specificTag) == 0 || specificTag == name {
+				found = append(found, QuayTag{Name: name})
+			}
+		}
+		rt := QuayTagDigest{Page: page}
+		for i := (page - 1) * limit; i < page*limit && i < len(found); i++ {
+			rt.Tags = append(rt.Tags, found[i])
+		}
+		rt.HasAdditional = page*limit < len(found)
+		json.NewEncoder(w).Encode(rt)
+	})
+	fq.Handle(http.MethodGet, "/api/v1/repository", func(w http.ResponseWriter, r *http.Request) {
+		// Quay pages repositories by an opaque token
+		query := r.URL.Query()
+		start, _ := strconv.Atoi(query.Get("next_page"))
+		if start/QuayPageSize+1 == failedPage {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "internal error"})
+			return
+		}
+		rp := QuayRepositories{}
+		for i := start; i < start+QuayPageSize && i < repositories; i++ {
+			rp.Repositories = append(rp.Repositories, QuayRepository{Namespace: query.Get("namespace"), Name: fmt.Sprintf("app%d", i)})
+		}
+		if start+QuayPageSize < repositories {
+			rp.NextPage = fmt.Sprintf("%d", start+QuayPageSize)
+		}
+		json.NewEncoder(w).Encode(rp)
+	})
+	return fq
+}
+
+func TestGetQuayTags(t *testing.T) {
+	server := httptest.NewServer(newFakeQuay(250, 0, 0))
+	defer server.Close()
+	repoURL := server.URL + "/api/v1/repository/team/app"
+
+	testcases := []struct {
+		description string
+		paging      *QuayPaging
+		tags        int
+	}{
+		{
+			description: "default paging",
+			paging:      nil,
+			tags:        250,
+		},
+		{
+			description: "small pages",
+			paging:      &QuayPaging{PageSize: 30},
+			tags:        250,
+		},
+		{
+			description: "page size beyond the quay limit",
+			paging:      &QuayPaging{PageSize: 500},
+			tags:        250,
+		},
+		{
+			description: "capped pages",
+			paging:      &QuayPaging{PageSize: 50, MaxPages: 2},
+			tags:        100,
+		},
+	}
+
+	for _, tc := range testcases {
+		tags, err := GetQuayTags(repoURL, "token", tc.paging)
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", tc.description, err)
+			continue
+		}
+		if len(tags) != tc.tags {
+			t.Errorf("[%s] expected %d tags, got %d", tc.description, tc.tags, len(tags))
+			continue
+		}
+		for i, tag := range tags {
+			if tag.Name != fmt.Sprintf("%d", i) {
+				t.Errorf("[%s] expected tag %d, got %s", tc.description, i, tag.Name)
+				break
+			}
+		}
+	}
+
+	tags, err := GetQuayTag(repoURL, "220", "token", &QuayPaging{PageSize: 100})
+	if err != nil || len(tags) != 1 || tags[0].Name != "220" {
+		t.Errorf("expected tag 220, got %+v: %v", tags, err)
+	}
+
+	tags, err = GetQuayTags(repoURL, "expired", nil)
+	if err == nil {
+		t.Errorf("expected an error for an unauthorized page, got %d tags", len(tags))
+	}
+
+	failing := httptest.NewServer(newFakeQuay(250, 0, 2))
+	defer failing.Close()
+	tags, err = GetQuayTags(failing.URL+"/api/v1/repository/team/app", "token", nil)
+	if err == nil {
+		t.Errorf("expected an error for a failed page, got %d tags", len(tags))
+	}
+}
+
+func TestGetQuayRepositories(t *testing.T) {
+	server := httptest.NewServer(newFakeQuay(0, 250, 0))
+	defer server.Close()
+	cred := &RegistryAuth{URL: server.URL, Token: "token"}
+
+	repositories, err := GetQuayRepositories(cred, "team", nil)
+	if err != nil || len(repositories) != 250 || repositories[249].Name != "app249" || repositories[0].Namespace != "team" {
+		t.Errorf("expected 250 repositories of team, got %d: %v", len(repositories), err)
+	}
+
+	repositories, err = GetQuayRepositories(cred, "team", &QuayPaging{MaxPages: 2})
+	if err != nil || len(repositories) != 200 {
+		t.Errorf("expected 200 repositories of 2 pages, got %d: %v", len(repositories), err)
+	}
+
+	failing := httptest.NewServer(newFakeQuay(0, 250, 2))
+	defer failing.Close()
+	repositories, err = GetQuayRepositories(&RegistryAuth{URL: failing.URL, Token: "token"}, "team", nil)
+	if err == nil {
+		t.Errorf("expected an error for a failed page, got %d repositories", len(repositories))
+	}
+}
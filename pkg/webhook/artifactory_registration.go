@@ -0,0 +1,178 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// artWebhookHandler is the type of the handlers of webhooks built in to
+// artifactory that post the events
+const artWebhookHandler = "webhook"
+
+// ArtifactoryRegistration handles the webhook built in to artifactory, which
+// calls the webhook of the perceiver when an image is pushed to a local
+// repository.  A webhook that is deleted or changed is created again.
+// Instances without webhooks built in need the webhook plugin
+type ArtifactoryRegistration struct {
+	client        *http.Client
+	registryAuths []*utils.RegistryAuth
+	webhookURL    string
+	secret        string
+}
+
+// NewArtifactoryRegistration creates a new ArtifactoryRegistration object.
+// The secret signs the events
+func NewArtifactoryRegistration(credentials []*utils.RegistryAuth, webhookURL string, secret string) *ArtifactoryRegistration {
+	return &ArtifactoryRegistration{
+		client:        newRegistrationClient(),
+		registryAuths: credentials,
+		webhookURL:    webhookURL,
+		secret:        secret,
+	}
+}
+
+// Run starts registering the webhook in the instances
+func (ar *ArtifactoryRegistration) Run(interval time.Duration, stopCh <-chan struct{}) {
+	log.Infof("Webhook: starting artifactory webhook registration")
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		start := time.Now()
+		err := ar.register()
+		metrics.RecordReconcile("artifactory_registration", err == nil, time.Now().Sub(start))
+		if err != nil {
+			log.Errorf("Webhook: failed to register artifactory webhooks: %v", err)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (ar *ArtifactoryRegistration) register() error {
+	failed := 0
+	instances := 0
+	for _, registry := range ar.registryAuths {
+		cred, err := utils.PingArtifactoryServer("https://"+registry.URL, registry.User, registry.Password)
+		if err != nil {
+			log.Debugf("Webhook: URL %s either not a valid Artifactory repository or incorrect credentials: %e", registry.URL, err)
+			continue
+		}
+		instances = instances + 1
+		err = ar.registerInstance(cred)
+		if err != nil {
+			log.Errorf("Webhook: Error in registering the webhook in artifactory instance %s: %v", registry.URL, err)
+			failed = failed + 1
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("unable to register the webhook in %d of %d artifactory instances", failed, instances)
+	}
+	return nil
+}
+
+// subscription returns the webhook the perceiver wants the instance to have.
+// Artifactory doesn't return the secret of a webhook, so the description
+// ends in a fingerprint of the secret and whether it signs the events, which
+// changes when either does
+func (ar *ArtifactoryRegistration) subscription() *utils.ArtSubscription {
+	return &utils.ArtSubscription{
+		Key:         registrationTitle,
+		Description: fmt.Sprintf("Sends docker pushes to the Black Duck artifactory-perceiver (%s)", ar.secretFingerprint()),
+		Enabled:     true,
+		EventFilter: utils.ArtEventFilter{
+			Domain:     artDockerDomain,
+			EventTypes: []string{artPushedEvent},
+			Criteria: utils.ArtEventCriteria{
+				AnyLocal:        true,
+				RepoKeys:        []string{},
+				IncludePatterns: []string{},
+				ExcludePatterns: []string{},
+			},
+		},
+		Handlers: []utils.ArtEventHandler{{
+			HandlerType:         artWebhookHandler,
+			URL:                 ar.webhookURL,
+			Secret:              ar.secret,
+			UseSecretForSigning: len(ar.secret) > 0,
+		}},
+	}
+}
+
+// secretFingerprint returns the first 12 hex digits of an HMAC of whether
+// the secret signs the events, keyed with the secret
+func (ar *ArtifactoryRegistration) secretFingerprint() string {
+	mac := hmac.New(sha256.New, []byte(ar.secret))
+	fmt.Fprintf(mac, "signing=%t", len(ar.secret) > 0)
+	return hex.EncodeToString(mac.Sum(nil))[:12]
+}
+
+// registerInstance makes sure the instance has the webhook of the perceiver.
+// The event API is served next to artifactory, not under it
+func (ar *ArtifactoryRegistration) registerInstance(cred *utils.RegistryAuth) error {
+	subscriptionsURL := fmt.Sprintf("%s/event/api/v1/subscriptions", strings.TrimSuffix(cred.URL, "/artifactory"))
+	wanted := ar.subscription()
+
+	current := &utils.ArtSubscription{}
+	err := utils.GetResourceOfType(fmt.Sprintf("%s/%s", subscriptionsURL, registrationTitle), cred, "", current)
+	if err != nil {
+		log.Debugf("Webhook: Artifactory instance %s doesn't have webhooks built in: %v", cred.URL, err)
+		return nil
+	}
+
+	// A missing webhook is answered with errors instead
+	if current.Key != registrationTitle {
+		err = utils.SendRequest(ar.client, http.MethodPost, subscriptionsURL, cred, "", wanted, http.StatusCreated)
+		if err != nil {
+			return err
+		}
+		log.Infof("Webhook: Registered the webhook in artifactory instance %s", cred.URL)
+		return nil
+	}
+
+	if current.Enabled && current.Description == wanted.Description && reflect.DeepEqual(current.EventFilter.EventTypes, wanted.EventFilter.EventTypes) &&
+		len(current.Handlers) == 1 && current.Handlers[0].URL == ar.webhookURL && current.Handlers[0].UseSecretForSigning == wanted.Handlers[0].UseSecretForSigning {
+		return nil
+	}
+	err = utils.SendRequest(ar.client, http.MethodPut, fmt.Sprintf("%s/%s", subscriptionsURL, registrationTitle), cred, "", wanted, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	log.Infof("Webhook: Repaired the webhook in artifactory instance %s", cred.URL)
+	return nil
+}
@@ -27,28 +27,47 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 
 	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/mapper"
 	utils "github.com/blackducksoftware/perceivers/pkg/utils"
 	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
 	log "github.com/sirupsen/logrus"
 )
 
+// The domain and type of the events of the webhooks built in to artifactory
+// for docker pushes
+const (
+	artDockerDomain = "docker"
+	artPushedEvent  = "pushed"
+)
+
 // ArtifactoryWebhook handles watching images and sending them to perceptor
 type ArtifactoryWebhook struct {
 	perceptorURL   string
 	registryAuths  []*utils.RegistryAuth
 	certificate    string
 	certificateKey string
+	auth           *authenticator
+	filter         *utils.ArtifactoryFilter
+
+	// The types of the docker repositories of each instance, listed once
+	// for the filter and again only for a repository that isn't in them
+	repoTypes     map[string]map[string]string
+	repoTypesLock sync.Mutex
 }
 
 // NewArtifactoryWebhook creates a new ArtifactoryWebhook object
-func NewArtifactoryWebhook(perceptorURL string, credentials []*utils.RegistryAuth, certificate string, certificateKey string) *ArtifactoryWebhook {
+func NewArtifactoryWebhook(perceptorURL string, credentials []*utils.RegistryAuth, certificate string, certificateKey string, auth AuthConfig, filter *utils.ArtifactoryFilter) *ArtifactoryWebhook {
 	return &ArtifactoryWebhook{
 		perceptorURL:   perceptorURL,
 		registryAuths:  credentials,
 		certificate:    certificate,
 		certificateKey: certificateKey,
+		auth:           newAuthenticator("artifactory", auth),
+		filter:         filter,
+		repoTypes:      map[string]map[string]string{},
 	}
 }
 
@@ -57,9 +76,24 @@ func (aw *ArtifactoryWebhook) Run() {
 
 	http.HandleFunc("/webhook", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
+			if !aw.auth.verifySource(w, r) {
+				return
+			}
+			body, ok := aw.auth.readBody(w, r)
+			if !ok || !aw.auth.verifySignature(w, r, artifactorySignatureHeader, body) {
+				return
+			}
 			log.Info("Webhook: Artifactory hook incoming!")
+			// The hooks of the webhook plugin list artifacts, the ones of
+			// the webhooks built in to artifactory are an event
 			ahs := &utils.ArtHookStruct{}
-			json.NewDecoder(r.Body).Decode(ahs)
+			json.Unmarshal(body, ahs)
+			event := &utils.ArtEventStruct{}
+			json.Unmarshal(body, event)
+			if !aw.hasOrigin(event) {
+				aw.auth.reject(w, r, http.StatusBadRequest, "origin")
+				return
+			}
 			for _, registry := range aw.registryAuths {
 				cred, err := utils.PingArtifactoryServer("https://"+registry.URL, registry.User, registry.Password)
 				if err != nil {
@@ -67,6 +101,7 @@ func (aw *ArtifactoryWebhook) Run() {
 					continue
 				}
 				aw.webhook(ahs, cred, aw.perceptorURL)
+				aw.event(event, cred)
 			}
 		}
 	})
@@ -100,36 +135,111 @@ func (aw *ArtifactoryWebhook) webhook(ahs *utils.ArtHookStruct, cred *utils.Regi
 		if strings.Contains(returnedURL, "null") {
 			log.Errorf("Webhook: The plugin needs to setup BaseURL in the webhook json")
 		}
+		// Only the artifacts of the instance are looked up with its credentials
+		if !strings.HasPrefix(withoutScheme(returnedURL), withoutScheme(cred.URL)+"/") {
+			log.Debugf("Webhook: Artifact %s is not from artifactory instance %s", returnedURL, cred.URL)
+			continue
+		}
 		woBase := strings.Replace(returnedURL, cred.URL+"/", "", -1)
 		woRepo := strings.Replace(woBase, "/"+a.Name, "", -1)
 		repoKey := strings.Replace(woRepo, ":"+a.Version, "", -1)
 
-		imageSHAs := &utils.ArtImageSHAs{}
-		url := fmt.Sprintf("%s/api/storage/%s/%s/%s/manifest.json?properties=sha256", cred.URL, repoKey, a.Name, a.Version)
-		err := utils.GetResourceOfType(url, cred, "", imageSHAs)
+		aw.sendImage(cred, repoKey, a.Name, a.Version)
+	}
+}
+
+// event sends the image of a push in an event of a webhook built in to
+// artifactory, if the event is from the instance
+func (aw *ArtifactoryWebhook) event(event *utils.ArtEventStruct, cred *utils.RegistryAuth) {
+	if event.Domain != artDockerDomain || event.EventType != artPushedEvent {
+		return
+	}
+	if len(event.JpdOrigin) > 0 && !strings.HasPrefix(withoutScheme(cred.URL), withoutScheme(strings.TrimSuffix(event.JpdOrigin, "/"))) {
+		log.Debugf("Webhook: Event from %s is not from artifactory instance %s", event.JpdOrigin, cred.URL)
+		return
+	}
+	aw.sendImage(cred, event.Data.RepoKey, event.Data.ImageName, event.Data.Tag)
+}
+
+// hasOrigin returns whether the instance an event is from can be told.
+// An event without an origin is only taken when there is one instance
+func (aw *ArtifactoryWebhook) hasOrigin(event *utils.ArtEventStruct) bool {
+	if event.Domain != artDockerDomain || event.EventType != artPushedEvent {
+		return true
+	}
+	return len(event.JpdOrigin) > 0 || len(aw.registryAuths) <= 1
+}
+
+// sendImage sends the images of a tag of an image in a docker repository
+// of artifactory to perceptor
+func (aw *ArtifactoryWebhook) sendImage(cred *utils.RegistryAuth, repoKey string, name string, version string) {
+	if !aw.selected(cred, repoKey, name) {
+		log.Debugf("Webhook: %s/%s is not in the selected repositories", repoKey, name)
+		return
+	}
+
+	url := fmt.Sprintf("%s/api/docker/%s/v2/%s/manifests/%s", cred.URL, repoKey, name, version)
+	manifest, err := utils.GetManifest(url, cred, "")
+	if err != nil {
+		log.Errorf("Webhook: Error in getting manifest of the artifactory image: %e", err)
+		return
+	}
+
+	// Remove Tag & HTTPS, /artifactory because image model doesn't require it
+	url = fmt.Sprintf("%s/%s/%s", cred.URL, repoKey, name)
+	url = strings.Replace(url, "http://", "", -1)
+	url = strings.Replace(url, "https://", "", -1)
+	url = strings.Replace(url, "/artifactory", "", -1)
+	artImages, err := mapper.NewPerceptorImagesFromManifest(url, version, manifest)
+	if err != nil {
+		log.Errorf("Webhook: %v", err)
+		return
+	}
+
+	for _, artImage := range artImages {
+		imageURL := fmt.Sprintf("%s/%s", aw.perceptorURL, perceptorapi.ImagePath)
+		err = communicator.SendPerceptorAddEvent(imageURL, artImage)
 		if err != nil {
-			log.Errorf("Webhook: Error in getting SHAs of the artifactory image: %e", err)
-			continue
+			log.Errorf("Webhook: Error putting artifactory image %v in perceptor queue %e", artImage, err)
+		} else {
+			log.Infof("Webhook: Successfully put image %s with tag %s in perceptor queue", url, version)
 		}
-		for _, sha := range imageSHAs.Properties.Sha256 {
-
-			// Remove Tag & HTTPS, /artifactory because image model doesn't require it
-			url = fmt.Sprintf("%s/%s/%s", cred.URL, repoKey, a.Name)
-			url = strings.Replace(url, "http://", "", -1)
-			url = strings.Replace(url, "https://", "", -1)
-			url = strings.Replace(url, "/artifactory", "", -1)
-			priority := 1
-			artImage := perceptorapi.NewImage(url, a.Version, sha, &priority, url, a.Version)
-
-			imageURL := fmt.Sprintf("%s/%s", perceptorURL, perceptorapi.ImagePath)
-			err = communicator.SendPerceptorAddEvent(imageURL, artImage)
-			if err != nil {
-				log.Errorf("Webhook: Error putting artifactory image %v in perceptor queue %e", artImage, err)
-			} else {
-				log.Infof("Webhook: Successfully put image %s with tag %s in perceptor queue", url, a.Version)
-			}
+	}
+}
 
-		}
+// selected returns whether the image in the repository is selected by the
+// filter
+func (aw *ArtifactoryWebhook) selected(cred *utils.RegistryAuth, repoKey string, name string) bool {
+	if aw.filter == nil {
+		return true
+	}
+	repoType, err := aw.repoType(cred, repoKey)
+	if err != nil {
+		log.Errorf("Webhook: Error in getting docker repos: %e", err)
+		return false
+	}
+	return aw.filter.Matches(repoKey, repoType, name)
+}
 
+// repoType returns the type of a docker repository of the instance, the
+// repositories are listed again when it's one created since the last listing
+func (aw *ArtifactoryWebhook) repoType(cred *utils.RegistryAuth, repoKey string) (string, error) {
+	aw.repoTypesLock.Lock()
+	defer aw.repoTypesLock.Unlock()
+	if repoType, ok := aw.repoTypes[cred.URL][repoKey]; ok {
+		return repoType, nil
+	}
+	repoTypes, err := utils.GetArtifactoryDockerRepos(cred)
+	if err != nil {
+		return "", err
 	}
+	aw.repoTypes[cred.URL] = repoTypes
+	return repoTypes[repoKey], nil
+}
+
+// withoutScheme returns a URL without its scheme, artifactory may be pinged
+// over a different one than it has as its base URL
+func withoutScheme(url string) string {
+	url = strings.TrimPrefix(url, "http://")
+	return strings.TrimPrefix(url, "https://")
 }
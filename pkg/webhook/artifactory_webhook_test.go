@@ -0,0 +1,126 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+)
+
+func TestArtifactoryRepoTypes(t *testing.T) {
+	requests := 0
+	repos := utils.ArtDockerRepo{{Key: "docker-local", Type: "LOCAL"}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = requests + 1
+		json.NewEncoder(w).Encode(repos)
+	}))
+	defer server.Close()
+	cred := &utils.RegistryAuth{URL: server.URL}
+	filter := &utils.ArtifactoryFilter{Types: []string{utils.ArtLocalRepository}}
+	aw := NewArtifactoryWebhook("http://perceptor", []*utils.RegistryAuth{cred}, "", "", AuthConfig{}, filter)
+
+	testcases := []struct {
+		description string
+		repoKey     string
+		selected    bool
+		requests    int
+	}{
+		{
+			description: "first event lists the repositories",
+			repoKey:     "docker-local",
+			selected:    true,
+			requests:    1,
+		},
+		{
+			description: "later event of a listed repository",
+			repoKey:     "docker-local",
+			selected:    true,
+			requests:    1,
+		},
+		{
+			description: "event of a repository created since",
+			repoKey:     "docker-remote",
+			selected:    false,
+			requests:    2,
+		},
+	}
+
+	for _, tc := range testcases {
+		if tc.repoKey == "docker-remote" {
+			repos = append(repos, utils.ArtDockerRepo{{Key: "docker-remote", Type: "REMOTE"}}...)
+		}
+		if selected := aw.selected(cred, tc.repoKey, "app"); selected != tc.selected {
+			t.Errorf("[%s] expected selected to be %t, got %t", tc.description, tc.selected, selected)
+		}
+		if requests != tc.requests {
+			t.Errorf("[%s] expected %d requests, got %d", tc.description, tc.requests, requests)
+		}
+	}
+}
+
+func TestArtifactoryEventOrigin(t *testing.T) {
+	one := []*utils.RegistryAuth{{URL: "artifactory.example.com/artifactory"}}
+	two := append(one, &utils.RegistryAuth{URL: "artifactory2.example.com/artifactory"})
+
+	testcases := []struct {
+		description string
+		instances   []*utils.RegistryAuth
+		event       utils.ArtEventStruct
+		expected    bool
+	}{
+		{
+			description: "push with an origin",
+			instances:   two,
+			event:       utils.ArtEventStruct{Domain: artDockerDomain, EventType: artPushedEvent, JpdOrigin: "https://artifactory.example.com"},
+			expected:    true,
+		},
+		{
+			description: "push without an origin from the only instance",
+			instances:   one,
+			event:       utils.ArtEventStruct{Domain: artDockerDomain, EventType: artPushedEvent},
+			expected:    true,
+		},
+		{
+			description: "push without an origin with several instances",
+			instances:   two,
+			event:       utils.ArtEventStruct{Domain: artDockerDomain, EventType: artPushedEvent},
+			expected:    false,
+		},
+		{
+			description: "hook of the webhook plugin",
+			instances:   two,
+			event:       utils.ArtEventStruct{},
+			expected:    true,
+		},
+	}
+
+	for _, tc := range testcases {
+		aw := NewArtifactoryWebhook("http://perceptor", tc.instances, "", "", AuthConfig{}, nil)
+		if result := aw.hasOrigin(&tc.event); result != tc.expected {
+			t.Errorf("[%s] expected %t, got %t", tc.description, tc.expected, result)
+		}
+	}
+}
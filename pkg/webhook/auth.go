@@ -0,0 +1,187 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultMaxBodyBytes is the largest request a webhook reads by default
+const defaultMaxBodyBytes = 1 << 20
+
+// artifactorySignatureHeader is the header artifactory signs the payload of
+// its webhooks in with the secret, as a hex HMAC-SHA256
+const artifactorySignatureHeader = "X-JFrog-Event-Auth"
+
+// AuthConfig is how the requests a webhook receives are authenticated.  The
+// secret is shared with the registry, the allowed sources are the CIDRs the
+// registry sends requests from.  Requests are let through when neither is
+// set, so an existing setup keeps working
+type AuthConfig struct {
+	Secret         string
+	AllowedSources []string
+	MaxBodyBytes   int64
+}
+
+// Validate returns an error for an allowed source that isn't a CIDR or an
+// IP address
+func (c *AuthConfig) Validate() error {
+	_, err := parseSources(c.AllowedSources)
+	return err
+}
+
+// authenticator checks the requests a webhook receives against its config
+type authenticator struct {
+	webhook      string
+	secret       string
+	sources      []*net.IPNet
+	maxBodyBytes int64
+}
+
+func newAuthenticator(webhook string, config AuthConfig) *authenticator {
+	// The sources are validated with the config
+	sources, _ := parseSources(config.AllowedSources)
+	maxBodyBytes := config.MaxBodyBytes
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultMaxBodyBytes
+	}
+	return &authenticator{webhook: webhook, secret: config.Secret, sources: sources, maxBodyBytes: maxBodyBytes}
+}
+
+// parseSources parses CIDRs and IP addresses, which are a network of one
+func parseSources(sources []string) ([]*net.IPNet, error) {
+	nets := []*net.IPNet{}
+	for _, source := range sources {
+		if !strings.Contains(source, "/") {
+			ip := net.ParseIP(source)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid allowed source %s", source)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			source = fmt.Sprintf("%s/%d", source, bits)
+		}
+		_, ipNet, err := net.ParseCIDR(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed source %s: %v", source, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// verifySource checks that a request is from an allowed source, and rejects
+// the request if it isn't
+func (a *authenticator) verifySource(w http.ResponseWriter, r *http.Request) bool {
+	if !a.allowedSource(r.RemoteAddr) {
+		a.reject(w, r, http.StatusForbidden, "source")
+		return false
+	}
+	return true
+}
+
+// readBody reads the body of a request up to the size limit, or rejects the
+// request and returns false
+func (a *authenticator) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, a.maxBodyBytes))
+	if err != nil {
+		a.reject(w, r, http.StatusRequestEntityTooLarge, "size")
+		return nil, false
+	}
+	return body, true
+}
+
+// allowedSource returns whether a request from the address is allowed
+func (a *authenticator) allowedSource(remoteAddr string) bool {
+	if len(a.sources) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, source := range a.sources {
+		if source.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySignature checks the hex HMAC-SHA256 of the body with the secret in
+// the header of a request, and rejects the request if it doesn't match
+func (a *authenticator) verifySignature(w http.ResponseWriter, r *http.Request, header string, body []byte) bool {
+	if len(a.secret) == 0 {
+		return true
+	}
+	signature, err := hex.DecodeString(strings.TrimPrefix(r.Header.Get(header), "sha256="))
+	mac := hmac.New(sha256.New, []byte(a.secret))
+	mac.Write(body)
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		a.reject(w, r, http.StatusUnauthorized, "signature")
+		return false
+	}
+	return true
+}
+
+// verifySecret checks that a request has the secret, either as the last
+// element of its path, like /webhook/<secret>, or as the password of its
+// basic auth, and rejects the request if it doesn't
+func (a *authenticator) verifySecret(w http.ResponseWriter, r *http.Request) bool {
+	if len(a.secret) == 0 {
+		return true
+	}
+	_, password, ok := r.BasicAuth()
+	if ok && subtle.ConstantTimeCompare([]byte(password), []byte(a.secret)) == 1 {
+		return true
+	}
+	path := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if subtle.ConstantTimeCompare([]byte(path), []byte(a.secret)) == 1 {
+		return true
+	}
+	a.reject(w, r, http.StatusUnauthorized, "secret")
+	return false
+}
+
+// reject answers a request with the status and records why it was rejected
+func (a *authenticator) reject(w http.ResponseWriter, r *http.Request, status int, reason string) {
+	log.Warnf("Webhook: Rejected %s hook from %s: invalid %s", a.webhook, r.RemoteAddr, reason)
+	metrics.RecordWebhookRejection(a.webhook, reason)
+	w.WriteHeader(status)
+}
@@ -0,0 +1,173 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthenticator(t *testing.T) {
+	body := `{"artifacts":[]}`
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(body))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	testcases := []struct {
+		description string
+		config      AuthConfig
+		path        string
+		remoteAddr  string
+		user        string
+		password    string
+		status      int
+		shouldPass  bool
+	}{
+		{
+			description: "no authentication configured",
+			config:      AuthConfig{},
+			path:        "/webhook",
+			shouldPass:  true,
+		},
+		{
+			description: "secret in the path",
+			config:      AuthConfig{Secret: "secret"},
+			path:        "/webhook/secret",
+			shouldPass:  true,
+		},
+		{
+			description: "secret as basic auth",
+			config:      AuthConfig{Secret: "secret"},
+			path:        "/webhook",
+			user:        "quay",
+			password:    "secret",
+			shouldPass:  true,
+		},
+		{
+			description: "missing secret",
+			config:      AuthConfig{Secret: "secret"},
+			path:        "/webhook",
+			status:      http.StatusUnauthorized,
+			shouldPass:  false,
+		},
+		{
+			description: "wrong secret in the path",
+			config:      AuthConfig{Secret: "secret"},
+			path:        "/webhook/guess",
+			status:      http.StatusUnauthorized,
+			shouldPass:  false,
+		},
+		{
+			description: "allowed source",
+			config:      AuthConfig{AllowedSources: []string{"10.0.0.0/8"}},
+			path:        "/webhook",
+			remoteAddr:  "10.1.2.3:4567",
+			shouldPass:  true,
+		},
+		{
+			description: "allowed source address",
+			config:      AuthConfig{AllowedSources: []string{"192.168.0.1", "10.1.2.3"}},
+			path:        "/webhook",
+			remoteAddr:  "10.1.2.3:4567",
+			shouldPass:  true,
+		},
+		{
+			description: "other source",
+			config:      AuthConfig{AllowedSources: []string{"10.0.0.0/8"}},
+			path:        "/webhook",
+			remoteAddr:  "192.168.0.1:4567",
+			status:      http.StatusForbidden,
+			shouldPass:  false,
+		},
+		{
+			description: "body too large",
+			config:      AuthConfig{MaxBodyBytes: 8},
+			path:        "/webhook",
+			status:      http.StatusRequestEntityTooLarge,
+			shouldPass:  false,
+		},
+	}
+
+	for _, tc := range testcases {
+		a := newAuthenticator("test", tc.config)
+		r := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(body))
+		if len(tc.remoteAddr) > 0 {
+			r.RemoteAddr = tc.remoteAddr
+		}
+		if len(tc.password) > 0 {
+			r.SetBasicAuth(tc.user, tc.password)
+		}
+		w := httptest.NewRecorder()
+		result := a.verifySource(w, r) && a.verifySecret(w, r)
+		if result {
+			_, result = a.readBody(w, r)
+		}
+		if result != tc.shouldPass {
+			t.Errorf("[%s] expected %t, got %t", tc.description, tc.shouldPass, result)
+		}
+		if !tc.shouldPass && w.Code != tc.status {
+			t.Errorf("[%s] expected status %d, got %d", tc.description, tc.status, w.Code)
+		}
+	}
+
+	signatures := []struct {
+		description string
+		signature   string
+		shouldPass  bool
+	}{
+		{
+			description: "valid signature",
+			signature:   signature,
+			shouldPass:  true,
+		},
+		{
+			description: "missing signature",
+			signature:   "",
+			shouldPass:  false,
+		},
+		{
+			description: "signature of another body",
+			signature:   strings.Repeat("0", len(signature)),
+			shouldPass:  false,
+		},
+	}
+
+	a := newAuthenticator("test", AuthConfig{Secret: "secret"})
+	for _, tc := range signatures {
+		r := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+		r.Header.Set(artifactorySignatureHeader, tc.signature)
+		result := a.verifySignature(httptest.NewRecorder(), r, artifactorySignatureHeader, []byte(body))
+		if result != tc.shouldPass {
+			t.Errorf("[%s] expected %t, got %t", tc.description, tc.shouldPass, result)
+		}
+	}
+
+	invalid := &AuthConfig{AllowedSources: []string{"10.0.0.0/40"}}
+	if invalid.Validate() == nil {
+		t.Errorf("expected source %s to be invalid", invalid.AllowedSources[0])
+	}
+}
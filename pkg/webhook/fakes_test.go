@@ -0,0 +1,80 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+)
+
+const (
+	testDigest      = "sha256:cb4983d8399a59bb5ee6e68b6177d878966a8fe41abe18a45c3b1d8809f1d043"
+	testAmd64Digest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	testArm64Digest = "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	testListDigest  = "sha256:3333333333333333333333333333333333333333333333333333333333333333"
+)
+
+// testManifestList is a manifest list of an amd64 and an arm64 image
+var testManifestList = fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"manifests":[
+	{"digest":%q,"platform":{"os":"linux","architecture":"amd64"}},
+	{"digest":%q,"platform":{"os":"linux","architecture":"arm64"}}]}`,
+	registry.MediaTypeManifestList, testAmd64Digest, testArm64Digest)
+
+// fakeRegistry is a stand-in for the registry API that serves the manifest
+// list as every manifest and records the paths it was asked for
+type fakeRegistry struct {
+	utils.FakeAPI
+	paths  []string
+	server *httptest.Server
+}
+
+func newFakeRegistry() *fakeRegistry {
+	fr := &fakeRegistry{}
+	fr.Handle(http.MethodGet, "/v2/*", func(w http.ResponseWriter, r *http.Request) {
+		fr.paths = append(fr.paths, r.URL.Path)
+		w.Header().Set("Content-Type", registry.MediaTypeManifestList)
+		w.Header().Set("Docker-Content-Digest", testDigest)
+		w.Write([]byte(testManifestList))
+	})
+	fr.server = httptest.NewServer(fr)
+	return fr
+}
+
+func (fr *fakeRegistry) host() string {
+	return strings.TrimPrefix(fr.server.URL, "http://")
+}
+
+// perceptorImages returns the images perceptor was sent, each as its
+// repository and tag followed by its sha
+func perceptorImages(fp *utils.FakePerceptor) []string {
+	images := []string{}
+	shas := fp.Shas()
+	for i, image := range fp.Images {
+		images = append(images, fmt.Sprintf("%s:%s %s", image.Repository, image.Tag, shas[i]))
+	}
+	return images
+}
@@ -0,0 +1,208 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/controller"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/mapper"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// The events of the system hooks of GitLab for pushes to a project
+const (
+	gitLabPush    = "push"
+	gitLabTagPush = "tag_push"
+)
+
+// gitLabHook is a system hook of GitLab, or a notification of its
+// container registry, which has events
+type gitLabHook struct {
+	utils.GitLabHookStruct
+	Events []RegistryEvent `json:"events"`
+}
+
+// GitLabWebhook handles the system hooks of GitLab and the notifications
+// of its container registry and sends the pushed images to perceptor
+type GitLabWebhook struct {
+	perceptorURL   string
+	registryAuths  []*utils.RegistryAuth
+	certificate    string
+	certificateKey string
+	controller     *controller.GitLabController
+	client         *registry.Client
+}
+
+// NewGitLabWebhook creates a new GitLabWebhook object.  A push to a project
+// has the controller send the images of the project again
+func NewGitLabWebhook(perceptorURL string, credentials []*utils.RegistryAuth, certificate string, certificateKey string, controller *controller.GitLabController, client *registry.Client) *GitLabWebhook {
+	return &GitLabWebhook{
+		perceptorURL:   perceptorURL,
+		registryAuths:  credentials,
+		certificate:    certificate,
+		certificateKey: certificateKey,
+		controller:     controller,
+		client:         client,
+	}
+}
+
+// ServeHTTP handles a system hook of GitLab or a notification of its
+// container registry
+func (gw *GitLabWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		log.Info("Webhook: GitLab hook incoming!")
+		hook := &gitLabHook{}
+		json.NewDecoder(r.Body).Decode(hook)
+		for _, event := range hook.Events {
+			gw.registryEvent(&event)
+		}
+		if hook.EventName == gitLabPush || hook.EventName == gitLabTagPush {
+			gw.projectPush(hook.ProjectID)
+		}
+	}
+}
+
+// Run starts a webhook that receives pushes and sends them to perceptor
+func (gw *GitLabWebhook) Run() {
+
+	http.Handle("/webhook", gw)
+
+	if len(gw.certificate) > 0 && len(gw.certificateKey) > 0 {
+		errC := ioutil.WriteFile("cert", []byte(gw.certificate), 0644)
+		errK := ioutil.WriteFile("key", []byte(gw.certificateKey), 0644)
+		if errC != nil || errK != nil {
+			log.Errorf("Webhook: Writing to a certificate file failed %e %e", errC, errK)
+		} else {
+			log.Infof("Webhook: Starting HTTPs webhook with TLS enabled for gitlab on :3002 at /webhook")
+			err := http.ListenAndServeTLS(":3002", "cert", "key", nil)
+			if err != nil {
+				log.Errorf("Webhook: HTTPs listener on port 3002 failed: %e", err)
+			}
+		}
+	} else {
+		log.Infof("Webhook: starting HTTP webhook for gitlab on :3002 at /webhook")
+		err := http.ListenAndServe(":3002", nil)
+		if err != nil {
+			log.Errorf("Webhook: HTTP listener on port 3002 failed: %e", err)
+		}
+	}
+}
+
+// projectPush sends the images of a project that was pushed to, the push
+// may come with images built by its pipelines
+func (gw *GitLabWebhook) projectPush(projectID int64) {
+	for _, auth := range gw.registryAuths {
+		cred, err := utils.PingGitLabServer("https://"+auth.URL, auth.User, auth.Password, auth.Token)
+		if err != nil {
+			log.Debugf("Webhook: URL %s either not a valid GitLab instance or incorrect token: %e", auth.URL, err)
+			continue
+		}
+		gw.controller.ProjectLookup(cred, projectID)
+	}
+}
+
+// registryEvent sends the image of a push to the container registry
+func (gw *GitLabWebhook) registryEvent(event *RegistryEvent) {
+	// Blobs are pushed without a tag, and so are the platform manifests of
+	// a manifest list, which are sent when the list is pushed
+	if event.Action != registryPushAction || len(event.Target.Tag) == 0 {
+		return
+	}
+
+	// The host comes from the body of the notification, only the registry
+	// of a configured instance is trusted with its credentials
+	auth := gw.gitLabInstance(event.Request.Host)
+	if auth == nil {
+		log.Warnf("Webhook: Ignoring push to %s, not the container registry of a configured GitLab instance", event.Request.Host)
+		return
+	}
+
+	repository := fmt.Sprintf("%s/%s", event.Request.Host, event.Target.Repository)
+	manifest := &registry.Manifest{MediaType: event.Target.MediaType, Digest: event.Target.Digest}
+	if manifest.IsList() {
+		ref := &docker.Reference{Registry: event.Request.Host, Repository: event.Target.Repository, Tag: event.Target.Tag}
+		ref.DigestAlgorithm, ref.Digest, _ = docker.ParseDigest(event.Target.Digest)
+		var err error
+		manifest, err = gw.client.Manifest(ref, utils.GitLabRegistryCredentials(auth))
+		if err != nil {
+			log.Errorf("Webhook: Error in getting manifest list of %s: %v", ref, err)
+			return
+		}
+	}
+
+	images, err := mapper.NewPerceptorImagesFromManifest(repository, event.Target.Tag, manifest)
+	if err != nil {
+		log.Errorf("Webhook: %v", err)
+		return
+	}
+	imageURL := fmt.Sprintf("%s/%s", gw.perceptorURL, perceptorapi.ImagePath)
+	for _, image := range images {
+		err = communicator.SendPerceptorAddEvent(imageURL, image)
+		if err != nil {
+			log.Errorf("Webhook: Error putting gitlab image %v in perceptor queue %e", image, err)
+		} else {
+			log.Infof("Webhook: Successfully put image %s with tag %s in perceptor queue", repository, event.Target.Tag)
+		}
+	}
+}
+
+// gitLabInstance returns the GitLab instance the container registry at
+// host belongs to.  The notifications of the registry don't say which
+// instance sent them, the registry is on the host of its instance or on a
+// subdomain of it.  A host of no configured instance has none
+func (gw *GitLabWebhook) gitLabInstance(host string) *utils.RegistryAuth {
+	hostname := hostName(host)
+	if len(hostname) == 0 {
+		return nil
+	}
+	for _, auth := range gw.registryAuths {
+		instance := hostName(auth.URL)
+		if len(instance) == 0 {
+			continue
+		}
+		if hostname == instance || strings.HasSuffix(hostname, "."+instance) {
+			return auth
+		}
+	}
+	return nil
+}
+
+// hostName returns the lower case host name of a host or of a URL without
+// a scheme, without its port
+func hostName(host string) string {
+	u, err := url.Parse("//" + host)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
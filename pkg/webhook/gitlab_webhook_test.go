@@ -0,0 +1,235 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/controller"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+)
+
+// gitLabRegistryPush is a notification of the container registry of GitLab
+// for a pushed tag
+const gitLabRegistryPush = `{"events":[{
+	"id":"f0a4f1a4-5c2b-4e1b-a7b3-4d1f1c2d2e3f",
+	"action":"%s",
+	"target":{"mediaType":%q,"digest":%q,"repository":"group/app","url":"https://%[4]s/v2/group/app/manifests/%[3]s","tag":"1.0"},
+	"request":{"host":%[4]q}}]}`
+
+// gitLabSystemHook is a system hook of GitLab for a push to a project
+const gitLabSystemHook = `{
+	"object_kind":%[1]q,
+	"event_name":%[1]q,
+	"before":"95790bf891e76fee5e1747ab589903a6a1f80f22",
+	"after":"da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+	"ref":"refs/heads/master",
+	"checkout_sha":"da1560886d4f094c3e6c9ef40349f7d38b5d27d7",
+	"user_id":4,
+	"user_name":"John Smith",
+	"project_id":%[2]d,
+	"project":{"name":"app","path_with_namespace":"group/app","default_branch":"master"},
+	"commits":[],
+	"total_commits_count":0}`
+
+func TestGitLabSystemHook(t *testing.T) {
+	fr := newFakeRegistry()
+	defer fr.server.Close()
+	fr.Handle(http.MethodHead, "/v2/*", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", registry.MediaTypeManifestList)
+		w.Header().Set("Docker-Content-Digest", testDigest)
+	})
+	host := fr.host()
+	gitLab := &utils.FakeAPI{Token: "token"}
+	gitLab.Reply(http.MethodGet, "/api/v4/version", map[string]string{"version": "12.10.0"})
+	gitLab.Reply(http.MethodGet, "/api/v4/projects/7/registry/repositories", []utils.GitLabRepository{{ID: 3, Path: "group/app", ProjectID: 7, Location: host + "/group/app", Tags: []utils.GitLabTag{{Name: "1.0"}}}})
+	gitLab.Reply(http.MethodGet, "/api/v4/projects/7/registry/repositories/3/tags/1.0", utils.GitLabTag{Name: "1.0", Digest: testDigest})
+	server := httptest.NewServer(gitLab)
+	defer server.Close()
+
+	testcases := []struct {
+		description string
+		event       string
+		projectID   int64
+		images      []string
+	}{
+		{
+			description: "push",
+			event:       gitLabPush,
+			projectID:   7,
+			images: []string{
+				host + "/group/app:1.0 linux/amd64 " + strings.TrimPrefix(testAmd64Digest, "sha256:"),
+				host + "/group/app:1.0 linux/arm64 " + strings.TrimPrefix(testArm64Digest, "sha256:"),
+			},
+		},
+		{
+			description: "tag push",
+			event:       gitLabTagPush,
+			projectID:   7,
+			images: []string{
+				host + "/group/app:1.0 linux/amd64 " + strings.TrimPrefix(testAmd64Digest, "sha256:"),
+				host + "/group/app:1.0 linux/arm64 " + strings.TrimPrefix(testArm64Digest, "sha256:"),
+			},
+		},
+		{
+			description: "repository update",
+			event:       "repository_update",
+			projectID:   7,
+			images:      []string{},
+		},
+		{
+			description: "push to a project without container repositories",
+			event:       gitLabPush,
+			projectID:   8,
+			images:      []string{},
+		},
+	}
+
+	for _, tc := range testcases {
+		fp := utils.NewFakePerceptor()
+		perceptor := httptest.NewServer(fp)
+		auths := []*utils.RegistryAuth{{URL: strings.TrimPrefix(server.URL, "http://"), Token: "token"}}
+		client := registry.NewClient([]string{host}, 5*time.Second)
+		gw := NewGitLabWebhook(perceptor.URL, auths, "", "", controller.NewGitLabController(perceptor.URL, auths, client), client)
+
+		body := fmt.Sprintf(gitLabSystemHook, tc.event, tc.projectID)
+		gw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body)))
+		perceptor.Close()
+
+		if images := perceptorImages(fp); !reflect.DeepEqual(images, tc.images) {
+			t.Errorf("[%s] expected images %v, got %v", tc.description, tc.images, images)
+		}
+	}
+}
+
+func TestGitLabRegistryEvent(t *testing.T) {
+	fr := newFakeRegistry()
+	defer fr.server.Close()
+	// The fake registry is at 127.0.0.1, a suffix of its host name that
+	// isn't a domain of it is 7.0.0.1
+	host := fr.host()
+
+	testcases := []struct {
+		description string
+		instance    string
+		action      string
+		mediaType   string
+		shas        []string
+		paths       []string
+	}{
+		{
+			description: "pushed image",
+			instance:    "127.0.0.1",
+			action:      "push",
+			mediaType:   registry.MediaTypeManifest,
+			shas:        []string{strings.TrimPrefix(testDigest, "sha256:")},
+			paths:       []string{},
+		},
+		{
+			description: "pushed manifest list of a configured instance",
+			instance:    "127.0.0.1",
+			action:      "push",
+			mediaType:   registry.MediaTypeManifestList,
+			shas: []string{
+				"linux/amd64 " + strings.TrimPrefix(testAmd64Digest, "sha256:"),
+				"linux/arm64 " + strings.TrimPrefix(testArm64Digest, "sha256:"),
+			},
+			paths: []string{"/v2/group/app/manifests/" + testDigest},
+		},
+		{
+			description: "pull",
+			instance:    "127.0.0.1",
+			action:      "pull",
+			mediaType:   registry.MediaTypeManifestList,
+			shas:        []string{},
+			paths:       []string{},
+		},
+		{
+			description: "push to the registry of an unknown host",
+			instance:    "gitlab.example.com",
+			action:      "push",
+			mediaType:   registry.MediaTypeManifestList,
+			shas:        []string{},
+			paths:       []string{},
+		},
+		{
+			description: "push to a host that ends in the host of an instance",
+			instance:    "7.0.0.1",
+			action:      "push",
+			mediaType:   registry.MediaTypeManifestList,
+			shas:        []string{},
+			paths:       []string{},
+		},
+	}
+
+	for _, tc := range testcases {
+		fp := utils.NewFakePerceptor()
+		perceptor := httptest.NewServer(fp)
+		fr.paths = []string{}
+		auth := &utils.RegistryAuth{URL: tc.instance, Token: "token"}
+		client := registry.NewClient([]string{host}, 5*time.Second)
+		gw := NewGitLabWebhook(perceptor.URL, []*utils.RegistryAuth{auth}, "", "", nil, client)
+
+		body := fmt.Sprintf(gitLabRegistryPush, tc.action, tc.mediaType, testDigest, host)
+		gw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body)))
+		perceptor.Close()
+
+		if shas := fp.Shas(); !reflect.DeepEqual(shas, tc.shas) {
+			t.Errorf("[%s] expected images %v, got %v", tc.description, tc.shas, shas)
+		}
+		if !reflect.DeepEqual(fr.paths, tc.paths) {
+			t.Errorf("[%s] expected registry requests %v, got %v", tc.description, tc.paths, fr.paths)
+		}
+	}
+}
+
+func TestGitLabInstance(t *testing.T) {
+	auths := []*utils.RegistryAuth{{URL: "gitlab.example.com"}, {URL: "git.example.org:8443/gitlab"}}
+	gw := NewGitLabWebhook("http://perceptor", auths, "", "", nil, nil)
+
+	testcases := []struct {
+		host     string
+		instance *utils.RegistryAuth
+	}{
+		{host: "gitlab.example.com", instance: auths[0]},
+		{host: "registry.gitlab.example.com", instance: auths[0]},
+		{host: "Registry.GitLab.Example.com:5050", instance: auths[0]},
+		{host: "git.example.org:5050", instance: auths[1]},
+		{host: "evilgitlab.example.com", instance: nil},
+		{host: "gitlab.example.com.evil.com", instance: nil},
+		{host: "example.com", instance: nil},
+		{host: "", instance: nil},
+	}
+
+	for _, tc := range testcases {
+		if instance := gw.gitLabInstance(tc.host); instance != tc.instance {
+			t.Errorf("[%s] expected instance %v, got %v", tc.host, tc.instance, instance)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/mapper"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// harborPushArtifact is the type of the webhooks Harbor sends for pushes
+const harborPushArtifact = "PUSH_ARTIFACT"
+
+// HarborWebhook handles Harbor webhooks and sends the pushed images to perceptor
+type HarborWebhook struct {
+	perceptorURL   string
+	registryAuths  []*utils.RegistryAuth
+	certificate    string
+	certificateKey string
+}
+
+// NewHarborWebhook creates a new HarborWebhook object
+func NewHarborWebhook(perceptorURL string, credentials []*utils.RegistryAuth, certificate string, certificateKey string) *HarborWebhook {
+	return &HarborWebhook{
+		perceptorURL:   perceptorURL,
+		registryAuths:  credentials,
+		certificate:    certificate,
+		certificateKey: certificateKey,
+	}
+}
+
+// ServeHTTP handles a webhook of Harbor
+func (hw *HarborWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		log.Info("Webhook: Harbor hook incoming!")
+		hhs := &utils.HarborHookStruct{}
+		json.NewDecoder(r.Body).Decode(hhs)
+		if hhs.Type != harborPushArtifact {
+			log.Debugf("Webhook: Ignoring harbor %s event", hhs.Type)
+			return
+		}
+		for _, registry := range hw.registryAuths {
+			if !hw.fromRegistry(hhs, registry) {
+				continue
+			}
+			cred, err := utils.PingHarborServer("https://"+registry.URL, registry.User, registry.Password)
+			if err != nil {
+				log.Debugf("Webhook: URL %s either not a valid Harbor registry or incorrect credentials: %e", registry.URL, err)
+				continue
+			}
+			hw.webhook(hhs, registry, cred)
+		}
+	}
+}
+
+// Run starts a webhook that receives pushes and sends them to perceptor
+func (hw *HarborWebhook) Run() {
+
+	http.Handle("/webhook", hw)
+
+	if len(hw.certificate) > 0 && len(hw.certificateKey) > 0 {
+		errC := ioutil.WriteFile("cert", []byte(hw.certificate), 0644)
+		errK := ioutil.WriteFile("key", []byte(hw.certificateKey), 0644)
+		if errC != nil || errK != nil {
+			log.Errorf("Webhook: Writing to a certificate file failed %e %e", errC, errK)
+		} else {
+			log.Infof("Webhook: Starting HTTPs webhook with TLS enabled for harbor on :3002 at /webhook")
+			err := http.ListenAndServeTLS(":3002", "cert", "key", nil)
+			if err != nil {
+				log.Errorf("Webhook: HTTPs listener on port 3002 failed: %e", err)
+			}
+		}
+	} else {
+		log.Infof("Webhook: starting HTTP webhook for harbor on :3002 at /webhook")
+		err := http.ListenAndServe(":3002", nil)
+		if err != nil {
+			log.Errorf("Webhook: HTTP listener on port 3002 failed: %e", err)
+		}
+	}
+}
+
+// fromRegistry returns whether the resources of a webhook were pushed to
+// the registry, whose URL starts their resource URLs
+func (hw *HarborWebhook) fromRegistry(hhs *utils.HarborHookStruct, registry *utils.RegistryAuth) bool {
+	for _, resource := range hhs.EventData.Resources {
+		if strings.HasPrefix(resource.ResourceURL, registry.URL+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (hw *HarborWebhook) webhook(hhs *utils.HarborHookStruct, registry *utils.RegistryAuth, cred *utils.RegistryAuth) {
+	repository := hhs.EventData.Repository.RepoFullName
+	artifactsURL, err := utils.HarborArtifactsURL(cred.URL, repository)
+	if err != nil {
+		log.Errorf("Webhook: %v", err)
+		return
+	}
+
+	for _, resource := range hhs.EventData.Resources {
+		// The artifact has the platforms of a manifest list, which the
+		// webhook doesn't
+		artifact := &utils.HarborArtifact{}
+		url := fmt.Sprintf("%s/%s?with_tag=true", artifactsURL, resource.Digest)
+		err = utils.GetResourceOfType(url, cred, "", artifact)
+		if err != nil {
+			log.Errorf("Webhook: Error in getting harbor artifact %s of %s: %e", resource.Digest, repository, err)
+			continue
+		}
+		if artifact.Type != utils.HarborImageType {
+			continue
+		}
+
+		tags := []string{resource.Tag}
+		if len(resource.Tag) == 0 {
+			tags = []string{}
+			for _, tag := range artifact.Tags {
+				tags = append(tags, tag.Name)
+			}
+		}
+
+		imageRepository := fmt.Sprintf("%s/%s", registry.URL, repository)
+		for _, tag := range tags {
+			images, err := mapper.NewPerceptorImagesFromManifest(imageRepository, tag, artifact.Manifest())
+			if err != nil {
+				log.Errorf("Webhook: %v", err)
+				continue
+			}
+			imageURL := fmt.Sprintf("%s/%s", hw.perceptorURL, perceptorapi.ImagePath)
+			for _, image := range images {
+				err = communicator.SendPerceptorAddEvent(imageURL, image)
+				if err != nil {
+					log.Errorf("Webhook: Error putting harbor image %v in perceptor queue %e", image, err)
+				} else {
+					log.Infof("Webhook: Successfully put image %s with tag %s in perceptor queue", imageRepository, tag)
+				}
+			}
+		}
+	}
+}
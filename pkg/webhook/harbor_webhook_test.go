@@ -0,0 +1,196 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+)
+
+// harborPush is a webhook of Harbor for an artifact pushed to the
+// repository team/apps/web
+const harborPush = `{
+	"type":%q,
+	"occur_at":1586922308,
+	"operator":"admin",
+	"event_data":{
+		"resources":[{"digest":%q,"tag":%q,"resource_url":"%s/team/apps/web:%[3]s"}],
+		"repository":{"date_created":1586922308,"name":"apps/web","namespace":"team","repo_full_name":"team/apps/web","repo_type":"private"}}}`
+
+// harborChartDigest is the digest of a chart, which isn't scanned
+const harborChartDigest = "sha256:4444444444444444444444444444444444444444444444444444444444444444"
+
+// newFakeHarbor is a stand-in for the harbor API of the repository
+// team/apps/web, which has an image tagged 1.0 and latest, a manifest list
+// and a chart.  It records the artifacts it was asked for
+func newFakeHarbor() (*utils.FakeAPI, *[]string) {
+	fh := &utils.FakeAPI{}
+	requested := []string{}
+	fh.Reply(http.MethodGet, "/api/v2.0/projects", []utils.HarborProject{{ProjectID: 1, Name: "team"}})
+	artifacts := map[string]utils.HarborArtifact{
+		testDigest: {
+			Type:              utils.HarborImageType,
+			Digest:            testDigest,
+			ManifestMediaType: registry.MediaTypeManifest,
+			Tags:              []utils.HarborTag{{Name: "1.0"}, {Name: "latest"}},
+		},
+		testListDigest: {
+			Type:              utils.HarborImageType,
+			Digest:            testListDigest,
+			ManifestMediaType: registry.MediaTypeManifestList,
+			Tags:              []utils.HarborTag{{Name: "2.0"}},
+			References: []utils.HarborReference{
+				{ChildDigest: testAmd64Digest, Platform: &registry.Platform{OS: "linux", Architecture: "amd64"}},
+				{ChildDigest: testArm64Digest, Platform: &registry.Platform{OS: "linux", Architecture: "arm64"}},
+			},
+		},
+		harborChartDigest: {
+			Type:   "CHART",
+			Digest: harborChartDigest,
+			Tags:   []utils.HarborTag{{Name: "0.1.0"}},
+		},
+	}
+	path := "/api/v2.0/projects/team/repositories/apps%252Fweb/artifacts/"
+	fh.Handle(http.MethodGet, path+"*", func(w http.ResponseWriter, r *http.Request) {
+		digest := strings.TrimPrefix(r.URL.EscapedPath(), path)
+		requested = append(requested, digest)
+		artifact, ok := artifacts[digest]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(artifact)
+	})
+	return fh, &requested
+}
+
+func TestHarborWebhook(t *testing.T) {
+	fh, requested := newFakeHarbor()
+	server := httptest.NewServer(fh)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	sha := strings.TrimPrefix(testDigest, "sha256:")
+
+	testcases := []struct {
+		description string
+		event       string
+		digest      string
+		tag         string
+		resourceURL string
+		images      []string
+		requested   []string
+	}{
+		{
+			description: "pushed image",
+			event:       harborPushArtifact,
+			digest:      testDigest,
+			tag:         "1.0",
+			resourceURL: host,
+			images:      []string{host + "/team/apps/web:1.0 " + sha},
+			requested:   []string{testDigest},
+		},
+		{
+			description: "pushed manifest list",
+			event:       harborPushArtifact,
+			digest:      testListDigest,
+			tag:         "2.0",
+			resourceURL: host,
+			images: []string{
+				host + "/team/apps/web:2.0 linux/amd64 " + strings.TrimPrefix(testAmd64Digest, "sha256:"),
+				host + "/team/apps/web:2.0 linux/arm64 " + strings.TrimPrefix(testArm64Digest, "sha256:"),
+			},
+			requested: []string{testListDigest},
+		},
+		{
+			description: "image pushed by digest, sent with every tag",
+			event:       harborPushArtifact,
+			digest:      testDigest,
+			tag:         "",
+			resourceURL: host,
+			images:      []string{host + "/team/apps/web:1.0 " + sha, host + "/team/apps/web:latest " + sha},
+			requested:   []string{testDigest},
+		},
+		{
+			description: "pushed chart",
+			event:       harborPushArtifact,
+			digest:      harborChartDigest,
+			tag:         "0.1.0",
+			resourceURL: host,
+			images:      []string{},
+			requested:   []string{harborChartDigest},
+		},
+		{
+			description: "pulled image",
+			event:       "PULL_ARTIFACT",
+			digest:      testDigest,
+			tag:         "1.0",
+			resourceURL: host,
+			images:      []string{},
+			requested:   []string{},
+		},
+		{
+			description: "push to an unknown registry",
+			event:       harborPushArtifact,
+			digest:      testDigest,
+			tag:         "1.0",
+			resourceURL: "harbor.example.com",
+			images:      []string{},
+			requested:   []string{},
+		},
+		{
+			description: "push to a registry whose URL starts with the configured one",
+			event:       harborPushArtifact,
+			digest:      testDigest,
+			tag:         "1.0",
+			resourceURL: host + "0",
+			images:      []string{},
+			requested:   []string{},
+		},
+	}
+
+	for _, tc := range testcases {
+		fp := utils.NewFakePerceptor()
+		perceptor := httptest.NewServer(fp)
+		*requested = []string{}
+		auth := &utils.RegistryAuth{URL: host, User: "admin", Password: "password"}
+		hw := NewHarborWebhook(perceptor.URL, []*utils.RegistryAuth{auth}, "", "")
+
+		body := fmt.Sprintf(harborPush, tc.event, tc.digest, tc.tag, tc.resourceURL)
+		hw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body)))
+		perceptor.Close()
+
+		if images := perceptorImages(fp); !reflect.DeepEqual(images, tc.images) {
+			t.Errorf("[%s] expected images %v, got %v", tc.description, tc.images, images)
+		}
+		if !reflect.DeepEqual(*requested, tc.requested) {
+			t.Errorf("[%s] expected artifacts %v to be asked for, got %v", tc.description, tc.requested, *requested)
+		}
+	}
+}
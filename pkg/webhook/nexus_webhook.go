@@ -0,0 +1,140 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/mapper"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// The actions of the component webhooks of Nexus for pushes, a tag that is
+// pushed again updates its component
+const (
+	nexusCreated = "CREATED"
+	nexusUpdated = "UPDATED"
+)
+
+// NexusWebhook handles watching images and sending them to perceptor
+type NexusWebhook struct {
+	perceptorURL   string
+	registryAuths  []*utils.RegistryAuth
+	certificate    string
+	certificateKey string
+}
+
+// NewNexusWebhook creates a new NexusWebhook object
+func NewNexusWebhook(perceptorURL string, credentials []*utils.RegistryAuth, certificate string, certificateKey string) *NexusWebhook {
+	return &NexusWebhook{
+		perceptorURL:   perceptorURL,
+		registryAuths:  credentials,
+		certificate:    certificate,
+		certificateKey: certificateKey,
+	}
+}
+
+// ServeHTTP handles a component webhook of Nexus
+func (nw *NexusWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		log.Info("Webhook: Nexus hook incoming!")
+		nhs := &utils.NexusHookStruct{}
+		json.NewDecoder(r.Body).Decode(nhs)
+		if nhs.Component.Format != utils.NexusDockerFormat || (nhs.Action != nexusCreated && nhs.Action != nexusUpdated) {
+			log.Debugf("Webhook: Ignoring nexus %s event for %s component", nhs.Action, nhs.Component.Format)
+			return
+		}
+		for _, registry := range nw.registryAuths {
+			cred, err := utils.PingNexusServer("https://"+registry.URL, registry.User, registry.Password)
+			if err != nil {
+				log.Debugf("Webhook: URL %s either not a valid Nexus repository or incorrect credentials: %e", registry.URL, err)
+				continue
+			}
+			nw.webhook(nhs, registry, cred)
+		}
+	}
+}
+
+// Run starts a controller that watches images and sends them to perceptor
+func (nw *NexusWebhook) Run() {
+
+	http.Handle("/webhook", nw)
+
+	if len(nw.certificate) > 0 && len(nw.certificateKey) > 0 {
+		errC := ioutil.WriteFile("cert", []byte(nw.certificate), 0644)
+		errK := ioutil.WriteFile("key", []byte(nw.certificateKey), 0644)
+		if errC != nil || errK != nil {
+			log.Errorf("Webhook: Writing to a certificate file failed %e %e", errC, errK)
+		} else {
+			log.Infof("Webhook: Starting HTTPs webhook with TLS enabled for nexus on :3002 at /webhook")
+			err := http.ListenAndServeTLS(":3002", "cert", "key", nil)
+			if err != nil {
+				log.Errorf("Webhook: HTTPs listener on port 3002 failed: %e", err)
+			}
+		}
+	} else {
+		log.Infof("Webhook: starting HTTP webhook for nexus on :3002 at /webhook")
+		err := http.ListenAndServe(":3002", nil)
+		if err != nil {
+			log.Errorf("Webhook: HTTP listener on port 3002 failed: %e", err)
+		}
+	}
+}
+
+func (nw *NexusWebhook) webhook(nhs *utils.NexusHookStruct, registry *utils.RegistryAuth, cred *utils.RegistryAuth) {
+	component := nhs.Component
+	// The platform manifests of a manifest list are sent with the list
+	if utils.IsNexusDigestVersion(component.Version) {
+		return
+	}
+
+	// The webhook doesn't say which instance sent it, the manifest is only
+	// found in the one that has the repository
+	url := utils.NexusManifestURL(cred.URL, nhs.RepositoryName, component.Name, component.Version)
+	manifest, err := utils.GetManifest(url, cred, "")
+	if err != nil {
+		log.Debugf("Webhook: Manifest of %s:%s not found in nexus instance %s: %e", component.Name, component.Version, registry.URL, err)
+		return
+	}
+
+	repository := fmt.Sprintf("%s/%s/%s", registry.URL, nhs.RepositoryName, component.Name)
+	images, err := mapper.NewPerceptorImagesFromManifest(repository, component.Version, manifest)
+	if err != nil {
+		log.Errorf("Webhook: %v", err)
+		return
+	}
+	imageURL := fmt.Sprintf("%s/%s", nw.perceptorURL, perceptorapi.ImagePath)
+	for _, image := range images {
+		err = communicator.SendPerceptorAddEvent(imageURL, image)
+		if err != nil {
+			log.Errorf("Webhook: Error putting nexus image %v in perceptor queue %e", image, err)
+		} else {
+			log.Infof("Webhook: Successfully put image %s with tag %s in perceptor queue", repository, component.Version)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+)
+
+// nexusComponent is a repository component webhook of Nexus for a
+// component of the repository docker-hosted
+const nexusComponent = `{
+	"timestamp":"2019-10-21T09:04:47.452+0000",
+	"nodeId":"52905B51-085CCABB-CEBBEAAD-16795588-FC927D11",
+	"initiator":"admin/172.17.0.1",
+	"repositoryName":"docker-hosted",
+	"action":%q,
+	"component":{
+		"id":"08909e5b1a3e4e8b5d8f9c6e7a3a1c4d",
+		"componentId":"ZG9ja2VyLWhvc3RlZDowODkwOWU1YjFhM2U0ZThiNWQ4ZjljNmU3YTNhMWM0ZA",
+		"format":%q,
+		"name":"team/app",
+		"group":null,
+		"version":%q}}`
+
+// newFakeNexus is a stand-in for the nexus API of the repository
+// docker-hosted, which has the image team/app:1.0 and the manifest list
+// team/app:2.0.  It records the manifests it was asked for
+func newFakeNexus() (*utils.FakeAPI, *[]string) {
+	fn := &utils.FakeAPI{}
+	requested := []string{}
+	fn.Reply(http.MethodGet, "/service/rest/v1/repositories", []utils.NexusRepository{{Name: "docker-hosted", Format: utils.NexusDockerFormat, Type: "hosted"}})
+	manifests := map[string][]string{
+		"1.0": {registry.MediaTypeManifest, testDigest, fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q}`, registry.MediaTypeManifest)},
+		"2.0": {registry.MediaTypeManifestList, testListDigest, testManifestList},
+	}
+	path := "/repository/docker-hosted/v2/team/app/manifests/"
+	fn.Handle(http.MethodGet, path+"*", func(w http.ResponseWriter, r *http.Request) {
+		tag := strings.TrimPrefix(r.URL.Path, path)
+		requested = append(requested, tag)
+		manifest, ok := manifests[tag]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", manifest[0])
+		w.Header().Set("Docker-Content-Digest", manifest[1])
+		w.Write([]byte(manifest[2]))
+	})
+	return fn, &requested
+}
+
+func TestNexusWebhook(t *testing.T) {
+	fn, requested := newFakeNexus()
+	server := httptest.NewServer(fn)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+	// Nothing listens on a closed server
+	closed := httptest.NewServer(&utils.FakeAPI{})
+	closed.Close()
+	unreachable := strings.TrimPrefix(closed.URL, "http://")
+	sha := strings.TrimPrefix(testDigest, "sha256:")
+
+	testcases := []struct {
+		description string
+		instances   []string
+		action      string
+		format      string
+		version     string
+		images      []string
+		requested   []string
+	}{
+		{
+			description: "pushed image",
+			instances:   []string{host},
+			action:      nexusCreated,
+			format:      utils.NexusDockerFormat,
+			version:     "1.0",
+			images:      []string{host + "/docker-hosted/team/app:1.0 " + sha},
+			requested:   []string{"1.0"},
+		},
+		{
+			description: "tag pushed again as a manifest list",
+			instances:   []string{host},
+			action:      nexusUpdated,
+			format:      utils.NexusDockerFormat,
+			version:     "2.0",
+			images: []string{
+				host + "/docker-hosted/team/app:2.0 linux/amd64 " + strings.TrimPrefix(testAmd64Digest, "sha256:"),
+				host + "/docker-hosted/team/app:2.0 linux/arm64 " + strings.TrimPrefix(testArm64Digest, "sha256:"),
+			},
+			requested: []string{"2.0"},
+		},
+		{
+			description: "platform manifest of a pushed list",
+			instances:   []string{host},
+			action:      nexusCreated,
+			format:      utils.NexusDockerFormat,
+			version:     testAmd64Digest,
+			images:      []string{},
+			requested:   []string{},
+		},
+		{
+			description: "deleted tag",
+			instances:   []string{host},
+			action:      "DELETED",
+			format:      utils.NexusDockerFormat,
+			version:     "1.0",
+			images:      []string{},
+			requested:   []string{},
+		},
+		{
+			description: "pushed maven component",
+			instances:   []string{host},
+			action:      nexusCreated,
+			format:      "maven2",
+			version:     "1.0",
+			images:      []string{},
+			requested:   []string{},
+		},
+		{
+			description: "tag the instance doesn't have",
+			instances:   []string{host},
+			action:      nexusCreated,
+			format:      utils.NexusDockerFormat,
+			version:     "3.0",
+			images:      []string{},
+			requested:   []string{"3.0"},
+		},
+		{
+			description: "unreachable instance",
+			instances:   []string{unreachable, host},
+			action:      nexusCreated,
+			format:      utils.NexusDockerFormat,
+			version:     "1.0",
+			images:      []string{host + "/docker-hosted/team/app:1.0 " + sha},
+			requested:   []string{"1.0"},
+		},
+	}
+
+	for _, tc := range testcases {
+		fp := utils.NewFakePerceptor()
+		perceptor := httptest.NewServer(fp)
+		*requested = []string{}
+		auths := []*utils.RegistryAuth{}
+		for _, instance := range tc.instances {
+			auths = append(auths, &utils.RegistryAuth{URL: instance, User: "admin", Password: "password"})
+		}
+		nw := NewNexusWebhook(perceptor.URL, auths, "", "")
+
+		body := fmt.Sprintf(nexusComponent, tc.action, tc.format, tc.version)
+		nw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body)))
+		perceptor.Close()
+
+		if images := perceptorImages(fp); !reflect.DeepEqual(images, tc.images) {
+			t.Errorf("[%s] expected images %v, got %v", tc.description, tc.images, images)
+		}
+		if !reflect.DeepEqual(*requested, tc.requested) {
+			t.Errorf("[%s] expected manifests %v to be asked for, got %v", tc.description, tc.requested, *requested)
+		}
+	}
+}
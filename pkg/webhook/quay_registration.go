@@ -0,0 +1,181 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/metrics"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// The event and method of the notification of the webhook in a repository
+const (
+	quayPushEvent     = "repo_push"
+	quayWebhookMethod = "webhook"
+)
+
+// QuayRegistration handles the notifications of the quay repositories,
+// which call the webhook when an image is pushed.  A notification that is
+// deleted or changed is created again
+type QuayRegistration struct {
+	client        *http.Client
+	registryAuths []*utils.RegistryAuth
+	webhookURL    string
+	filter        *utils.RepositoryFilter
+	paging        *utils.QuayPaging
+}
+
+// NewQuayRegistration creates a new QuayRegistration object.  Quay can't
+// set headers, so the secret of the webhook is the last element of its URL
+func NewQuayRegistration(credentials []*utils.RegistryAuth, webhookURL string, secret string, filter *utils.RepositoryFilter, paging *utils.QuayPaging) *QuayRegistration {
+	if len(secret) > 0 {
+		webhookURL = fmt.Sprintf("%s/%s", strings.TrimSuffix(webhookURL, "/"), secret)
+	}
+	return &QuayRegistration{
+		client:        newRegistrationClient(),
+		registryAuths: credentials,
+		webhookURL:    webhookURL,
+		filter:        filter,
+		paging:        paging,
+	}
+}
+
+// Run starts registering the webhook in the repositories
+func (qr *QuayRegistration) Run(interval time.Duration, stopCh <-chan struct{}) {
+	log.Infof("Webhook: starting quay webhook registration")
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		start := time.Now()
+		err := qr.register()
+		metrics.RecordReconcile("quay_registration", err == nil, time.Now().Sub(start))
+		if err != nil {
+			log.Errorf("Webhook: failed to register quay webhooks: %v", err)
+		}
+
+		time.Sleep(interval)
+	}
+}
+
+func (qr *QuayRegistration) register() error {
+	failed := 0
+	instances := 0
+	for _, auth := range qr.registryAuths {
+		// The quay API is only reached with an access token
+		if len(auth.Token) == 0 {
+			continue
+		}
+
+		cred, err := utils.PingQuayServer("https://"+auth.URL, auth.User, auth.Password, auth.Token)
+		if err != nil {
+			log.Debugf("Webhook: URL %s either not a valid quay repository or incorrect token: %e", auth.URL, err)
+			continue
+		}
+		instances = instances + 1
+
+		namespaces, err := utils.GetQuayNamespaces(cred)
+		if err != nil {
+			log.Errorf("Webhook: Error in getting namespaces of quay instance %s: %e", auth.URL, err)
+			failed = failed + 1
+			continue
+		}
+
+		incomplete := false
+		for _, namespace := range namespaces {
+			repositories, err := utils.GetQuayRepositories(cred, namespace, qr.paging)
+			if err != nil {
+				log.Errorf("Webhook: Error in getting repositories of quay namespace %s: %e", namespace, err)
+				incomplete = true
+				continue
+			}
+			for _, repository := range repositories {
+				name := fmt.Sprintf("%s/%s", repository.Namespace, repository.Name)
+				if len(repository.Kind) > 0 && repository.Kind != utils.QuayImageKind || !qr.filter.Matches(name) {
+					continue
+				}
+				err = qr.registerRepository(cred, name)
+				if err != nil {
+					log.Errorf("Webhook: Error in registering the webhook in quay repository %s: %v", name, err)
+					incomplete = true
+				}
+			}
+		}
+		if incomplete {
+			failed = failed + 1
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("unable to register the webhook in %d of %d quay instances", failed, instances)
+	}
+	return nil
+}
+
+// registerRepository makes sure the repository has a notification that
+// calls the webhook on pushes.  A notification of the perceiver with
+// another URL, from before the URL or the secret changed, is replaced
+func (qr *QuayRegistration) registerRepository(cred *utils.RegistryAuth, name string) error {
+	notificationsURL := fmt.Sprintf("%s/api/v1/repository/%s/notification/", cred.URL, name)
+	notifications := &utils.QuayNotifications{}
+	err := utils.GetResourceOfType(notificationsURL, nil, cred.Token, notifications)
+	if err != nil {
+		return err
+	}
+
+	for _, notification := range notifications.Notifications {
+		if notification.Method != quayWebhookMethod || notification.Event != quayPushEvent {
+			continue
+		}
+		if notification.Config.URL == qr.webhookURL {
+			return nil
+		}
+		if notification.Title == registrationTitle {
+			err = utils.SendRequest(qr.client, http.MethodDelete, notificationsURL+notification.UUID, nil, cred.Token, nil, http.StatusNoContent)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	notification := &utils.QuayNotification{
+		Title:       registrationTitle,
+		Event:       quayPushEvent,
+		Method:      quayWebhookMethod,
+		Config:      utils.QuayNotificationConfig{URL: qr.webhookURL},
+		EventConfig: map[string]string{},
+	}
+	err = utils.SendRequest(qr.client, http.MethodPost, notificationsURL, nil, cred.Token, notification, http.StatusCreated)
+	if err != nil {
+		return err
+	}
+	log.Infof("Webhook: Registered the webhook in quay repository %s", name)
+	return nil
+}
@@ -26,9 +26,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"strings"
+	"regexp"
 
 	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/mapper"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
 	utils "github.com/blackducksoftware/perceivers/pkg/utils"
 	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
 	log "github.com/sirupsen/logrus"
@@ -44,22 +46,8 @@ type QuayRepo struct {
 	UpdatedTags []string `json:"updated_tags"`
 }
 
-// QuayTagDigest contains Digest for a particular Quay image
-type QuayTagDigest struct {
-	HasAdditional bool `json:"has_additional"`
-	Page          int  `json:"page"`
-	Tags          []struct {
-		Name           string `json:"name"`
-		Reversion      bool   `json:"reversion"`
-		StartTs        int    `json:"start_ts"`
-		ImageID        string `json:"image_id"`
-		LastModified   string `json:"last_modified"`
-		ManifestDigest string `json:"manifest_digest"`
-		DockerImageID  string `json:"docker_image_id"`
-		IsManifestList bool   `json:"is_manifest_list"`
-		Size           int    `json:"size"`
-	} `json:"tags"`
-}
+// quayRepositoryRegexp matches the namespace/name of a quay repository
+var quayRepositoryRegexp = regexp.MustCompile(`^[\w-][\w.-]*/[\w-][\w.-]*$`)
 
 // QuayWebhook handles watching images and sending them to perceptor
 type QuayWebhook struct {
@@ -67,33 +55,55 @@ type QuayWebhook struct {
 	certificateKey string
 	perceptorURL   string
 	registryAuths  []*utils.RegistryAuth
+	paging         *utils.QuayPaging
+	auth           *authenticator
+	filter         *utils.RepositoryFilter
 }
 
-// NewQuayWebhook creates a new QuayWebhook object
-func NewQuayWebhook(perceptorURL string, credentials []*utils.RegistryAuth, certificate string, certificateKey string) *QuayWebhook {
+// NewQuayWebhook creates a new QuayWebhook object.  Only the pushes to the
+// repositories the filter selects are scanned
+func NewQuayWebhook(perceptorURL string, credentials []*utils.RegistryAuth, certificate string, certificateKey string, paging *utils.QuayPaging, auth AuthConfig, filter *utils.RepositoryFilter) *QuayWebhook {
 	return &QuayWebhook{
 		perceptorURL:   perceptorURL,
 		registryAuths:  credentials,
 		certificate:    certificate,
 		certificateKey: certificateKey,
+		paging:         paging,
+		auth:           newAuthenticator("quay", auth),
+		filter:         filter,
 	}
 }
 
 // Run starts a controller that watches images and sends them to perceptor
 func (qw *QuayWebhook) Run() {
 
-	http.HandleFunc("/webhook", func(w http.ResponseWriter, r *http.Request) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
+			if !qw.auth.verifySource(w, r) || !qw.auth.verifySecret(w, r) {
+				return
+			}
+			body, ok := qw.auth.readBody(w, r)
+			if !ok {
+				return
+			}
 			log.Info("Quay webhook incoming!")
 			qr := &QuayRepo{}
-			json.NewDecoder(r.Body).Decode(qr)
-			for _, registry := range qw.registryAuths {
-				if strings.Contains(qr.DockerURL, registry.URL) && len(registry.Token) > 0 {
-					qw.webhook(registry.Token, qr)
-				}
+			json.Unmarshal(body, qr)
+			registry := qw.registryAuth(qr)
+			if registry == nil {
+				qw.auth.reject(w, r, http.StatusForbidden, "registry")
+				return
+			}
+			if !qw.filter.Matches(qr.Repository) {
+				log.Debugf("Webhook: Quay repository %s is filtered out", qr.Repository)
+				return
 			}
+			qw.webhook(registry, qr)
 		}
-	})
+	}
+	http.HandleFunc("/webhook", handler)
+	// Quay can't set headers, so the secret may be the last element of the path
+	http.HandleFunc("/webhook/", handler)
 
 	if len(qw.certificate) > 0 && len(qw.certificateKey) > 0 {
 		errC := ioutil.WriteFile("cert", []byte(qw.certificate), 0644)
@@ -116,27 +126,81 @@ func (qw *QuayWebhook) Run() {
 	}
 }
 
-func (qw *QuayWebhook) webhook(bearerToken string, qr *QuayRepo) {
+// registryAuth returns the configured registry with a token a notification
+// is for, only the repositories of those are scanned
+func (qw *QuayWebhook) registryAuth(qr *QuayRepo) *utils.RegistryAuth {
+	if !quayRepositoryRegexp.MatchString(qr.Repository) {
+		return nil
+	}
+	for _, registry := range qw.registryAuths {
+		if qr.DockerURL == fmt.Sprintf("%s/%s", registry.URL, qr.Repository) && len(registry.Token) > 0 {
+			return registry
+		}
+	}
+	return nil
+}
+
+// webhook sends the updated tags of a notification to perceptor.  The API
+// is the one of the configured registry, never one from the notification,
+// so the token isn't sent anywhere else
+func (qw *QuayWebhook) webhook(quay *utils.RegistryAuth, qr *QuayRepo) {
+	auth, err := utils.PingQuayServer("https://"+quay.URL, quay.User, quay.Password, quay.Token)
+	if err != nil {
+		log.Errorf("Webhook: URL %s either not a valid quay repository or incorrect token: %e", quay.URL, err)
+		return
+	}
 
-	rt := &QuayTagDigest{}
-	url := strings.Replace(qr.Homepage, "repository", "api/v1/repository", -1)
-	url = fmt.Sprintf("%s/tag?onlyActiveTags=true", url)
-	err := utils.GetResourceOfType(url, nil, bearerToken, rt)
+	bearerToken := quay.Token
+	repoURL := fmt.Sprintf("%s/api/v1/repository/%s", auth.URL, qr.Repository)
+	tags, err := qw.updatedTags(repoURL, bearerToken, qr)
 	if err != nil {
 		log.Errorf("Webhook: Error in getting docker repo: %+v", err)
 	}
 
-	for _, tagDigest := range rt.Tags {
-		sha := strings.Replace(tagDigest.ManifestDigest, "sha256:", "", -1)
-		priority := 1
-		quayImage := perceptorapi.NewImage(qr.DockerURL, tagDigest.Name, sha, &priority, qr.DockerURL, tagDigest.Name)
-		imageURL := fmt.Sprintf("%s/%s", qw.perceptorURL, perceptorapi.ImagePath)
-		err = communicator.SendPerceptorAddEvent(imageURL, quayImage)
+	for _, tagDigest := range tags {
+		manifest := &registry.Manifest{Digest: tagDigest.ManifestDigest}
+		if tagDigest.IsManifestList {
+			manifest, err = utils.GetQuayManifest(repoURL, tagDigest.ManifestDigest, bearerToken)
+			if err != nil {
+				log.Errorf("Webhook: Error in getting manifest list for tag %s of %s: %v", tagDigest.Name, qr.DockerURL, err)
+				continue
+			}
+		}
+		quayImages, err := mapper.NewPerceptorImagesFromManifest(qr.DockerURL, tagDigest.Name, manifest)
 		if err != nil {
-			log.Errorf("Webhook: Error putting image %v in perceptor queue %e", quayImage, err)
-		} else {
-			log.Infof("Webhook: Successfully put image %s with tag %s in perceptor queue", url, tagDigest.Name)
+			log.Errorf("Webhook: Invalid digest for tag %s of %s: %v", tagDigest.Name, qr.DockerURL, err)
+			continue
+		}
+		for _, quayImage := range quayImages {
+			imageURL := fmt.Sprintf("%s/%s", qw.perceptorURL, perceptorapi.ImagePath)
+			err = communicator.SendPerceptorAddEvent(imageURL, quayImage)
+			if err != nil {
+				log.Errorf("Webhook: Error putting image %v in perceptor queue %e", quayImage, err)
+			} else {
+				log.Infof("Webhook: Successfully put image %s with tag %s in perceptor queue", qr.DockerURL, tagDigest.Name)
+			}
 		}
 	}
+}
 
+// updatedTags returns the active tags a push notification says were
+// updated.  A notification without updated tags, like one sent by hand from
+// the notifications of a repository, has every active tag scanned
+func (qw *QuayWebhook) updatedTags(repoURL string, bearerToken string, qr *QuayRepo) ([]utils.QuayTag, error) {
+	if len(qr.UpdatedTags) == 0 {
+		return utils.GetQuayTags(repoURL, bearerToken, qw.paging)
+	}
+
+	tags := []utils.QuayTag{}
+	for _, name := range qr.UpdatedTags {
+		updated, err := utils.GetQuayTag(repoURL, name, bearerToken, qw.paging)
+		if err != nil {
+			return nil, err
+		}
+		if len(updated) == 0 {
+			log.Debugf("Webhook: Updated tag %s of %s is no longer active", name, qr.DockerURL)
+		}
+		tags = append(tags, updated...)
+	}
+	return tags, nil
 }
@@ -0,0 +1,141 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+)
+
+func TestQuayUpdatedTags(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = requests + 1
+		rt := utils.QuayTagDigest{}
+		for _, name := range []string{"1.0", "1.1", "latest"} {
+			if specificTag := r.URL.Query().Get("specificTag"); len(specificTag) == 0 || specificTag == name {
+				rt.Tags = append(rt.Tags, utils.QuayTag{Name: name})
+			}
+		}
+		json.NewEncoder(w).Encode(rt)
+	}))
+	defer server.Close()
+	repoURL := server.URL + "/api/v1/repository/team/app"
+	qw := NewQuayWebhook("http://perceptor", nil, "", "", nil, AuthConfig{}, nil)
+
+	testcases := []struct {
+		description string
+		updatedTags []string
+		tags        []string
+		requests    int
+	}{
+		{
+			description: "updated tags",
+			updatedTags: []string{"1.1", "latest"},
+			tags:        []string{"1.1", "latest"},
+			requests:    2,
+		},
+		{
+			description: "updated tag that was removed since",
+			updatedTags: []string{"2.0", "latest"},
+			tags:        []string{"latest"},
+			requests:    2,
+		},
+		{
+			description: "no updated tags",
+			updatedTags: nil,
+			tags:        []string{"1.0", "1.1", "latest"},
+			requests:    1,
+		},
+	}
+
+	for _, tc := range testcases {
+		requests = 0
+		tags, err := qw.updatedTags(repoURL, "token", &QuayRepo{UpdatedTags: tc.updatedTags})
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", tc.description, err)
+			continue
+		}
+		names := []string{}
+		for _, tag := range tags {
+			names = append(names, tag.Name)
+		}
+		if !reflect.DeepEqual(names, tc.tags) {
+			t.Errorf("[%s] expected tags %v, got %v", tc.description, tc.tags, names)
+		}
+		if requests != tc.requests {
+			t.Errorf("[%s] expected %d requests, got %d", tc.description, tc.requests, requests)
+		}
+	}
+}
+
+func TestQuayRegistryAuth(t *testing.T) {
+	quay := &utils.RegistryAuth{URL: "quay.example.com", Token: "token"}
+	qw := NewQuayWebhook("http://perceptor", []*utils.RegistryAuth{{URL: "other.example.com"}, quay}, "", "", nil, AuthConfig{}, nil)
+
+	testcases := []struct {
+		description string
+		qr          *QuayRepo
+		shouldPass  bool
+	}{
+		{
+			description: "repository of the registry",
+			qr:          &QuayRepo{Repository: "team/app", DockerURL: "quay.example.com/team/app", Homepage: "https://quay.example.com/repository/team/app"},
+			shouldPass:  true,
+		},
+		{
+			description: "repository of another registry",
+			qr:          &QuayRepo{Repository: "team/app", DockerURL: "evil.example.com/team/app"},
+			shouldPass:  false,
+		},
+		{
+			description: "registry without a token",
+			qr:          &QuayRepo{Repository: "team/app", DockerURL: "other.example.com/team/app"},
+			shouldPass:  false,
+		},
+		{
+			description: "docker URL of another repository",
+			qr:          &QuayRepo{Repository: "team/app", DockerURL: "quay.example.com/team/other"},
+			shouldPass:  false,
+		},
+		{
+			description: "repository escaping the API path",
+			qr:          &QuayRepo{Repository: "team/..", DockerURL: "quay.example.com/team/.."},
+			shouldPass:  false,
+		},
+	}
+
+	for _, tc := range testcases {
+		registry := qw.registryAuth(tc.qr)
+		if tc.shouldPass && registry != quay {
+			t.Errorf("[%s] expected the registry %s, got %v", tc.description, quay.URL, registry)
+		}
+		if !tc.shouldPass && registry != nil {
+			t.Errorf("[%s] expected no registry, got %v", tc.description, registry)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// RegistrationConfig is how the webhook of a perceiver is registered in the
+// registries.  The URL is the one the registries reach the webhook at,
+// nothing is registered without it
+type RegistrationConfig struct {
+	URL             string
+	IntervalMinutes int
+}
+
+// Interval returns how often the webhook is registered again, which is
+// hourly by default
+func (c *RegistrationConfig) Interval() time.Duration {
+	if c.IntervalMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Minute * time.Duration(c.IntervalMinutes)
+}
+
+// registrationTitle is the name the webhook is registered in the
+// registries with, to find it again
+const registrationTitle = "blackduck-perceiver"
+
+// newRegistrationClient returns the client registrations reach registries
+// with, which like the rest of the perceivers doesn't verify certificates
+func newRegistrationClient() *http.Client {
+	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	return &http.Client{Transport: tr}
+}
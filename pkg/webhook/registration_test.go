@@ -0,0 +1,172 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+)
+
+func TestQuayRegisterRepository(t *testing.T) {
+	notifications := []utils.QuayNotification{}
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notificationsPath := "/api/v1/repository/team/app/notification/"
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == notificationsPath:
+			json.NewEncoder(w).Encode(utils.QuayNotifications{Notifications: notifications})
+		case r.Method == http.MethodPost && r.URL.Path == notificationsPath:
+			requests = append(requests, r.Method)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, notificationsPath):
+			requests = append(requests, r.Method+" "+strings.TrimPrefix(r.URL.Path, notificationsPath))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	qr := NewQuayRegistration(nil, "https://perceiver/webhook/", "secret", nil, nil)
+	cred := &utils.RegistryAuth{URL: server.URL, Token: "token"}
+	webhook := func(uuid string, title string, url string) utils.QuayNotification {
+		return utils.QuayNotification{UUID: uuid, Title: title, Event: quayPushEvent, Method: quayWebhookMethod, Config: utils.QuayNotificationConfig{URL: url}}
+	}
+
+	testcases := []struct {
+		description   string
+		notifications []utils.QuayNotification
+		requests      []string
+	}{
+		{
+			description:   "missing notification",
+			notifications: []utils.QuayNotification{webhook("1", "ci", "https://ci/hook")},
+			requests:      []string{http.MethodPost},
+		},
+		{
+			description:   "registered notification",
+			notifications: []utils.QuayNotification{webhook("1", "ci", "https://ci/hook"), webhook("2", registrationTitle, "https://perceiver/webhook/secret")},
+			requests:      []string{},
+		},
+		{
+			description:   "notification from before the secret changed",
+			notifications: []utils.QuayNotification{webhook("2", registrationTitle, "https://perceiver/webhook/old")},
+			requests:      []string{http.MethodDelete + " 2", http.MethodPost},
+		},
+	}
+
+	for _, tc := range testcases {
+		notifications, requests = tc.notifications, []string{}
+		err := qr.registerRepository(cred, "team/app")
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", tc.description, err)
+		}
+		if !reflect.DeepEqual(requests, tc.requests) {
+			t.Errorf("[%s] expected requests %v, got %v", tc.description, tc.requests, requests)
+		}
+	}
+}
+
+func TestArtifactoryRegisterInstance(t *testing.T) {
+	var subscription *utils.ArtSubscription
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subscriptionsPath := "/event/api/v1/subscriptions"
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == subscriptionsPath+"/"+registrationTitle:
+			if subscription == nil {
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"not found"}})
+				return
+			}
+			json.NewEncoder(w).Encode(subscription)
+		case r.Method == http.MethodPost && r.URL.Path == subscriptionsPath:
+			created := &utils.ArtSubscription{}
+			json.NewDecoder(r.Body).Decode(created)
+			requests = append(requests, r.Method+" "+created.Handlers[0].Secret)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPut && r.URL.Path == subscriptionsPath+"/"+registrationTitle:
+			requests = append(requests, r.Method)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ar := NewArtifactoryRegistration(nil, "https://perceiver/webhook", "secret")
+	cred := &utils.RegistryAuth{URL: server.URL + "/artifactory", User: "admin", Password: "password"}
+	disabled := ar.subscription()
+	disabled.Enabled = false
+	// Artifactory doesn't return the secret of a webhook
+	rotated := NewArtifactoryRegistration(nil, "https://perceiver/webhook", "old secret").subscription()
+	rotated.Handlers[0].Secret = ""
+	unsigned := NewArtifactoryRegistration(nil, "https://perceiver/webhook", "").subscription()
+
+	testcases := []struct {
+		description  string
+		subscription *utils.ArtSubscription
+		requests     []string
+	}{
+		{
+			description:  "missing webhook",
+			subscription: nil,
+			requests:     []string{http.MethodPost + " secret"},
+		},
+		{
+			description:  "registered webhook",
+			subscription: ar.subscription(),
+			requests:     []string{},
+		},
+		{
+			description:  "disabled webhook",
+			subscription: disabled,
+			requests:     []string{http.MethodPut},
+		},
+		{
+			description:  "webhook with a rotated secret",
+			subscription: rotated,
+			requests:     []string{http.MethodPut},
+		},
+		{
+			description:  "webhook that doesn't sign its events",
+			subscription: unsigned,
+			requests:     []string{http.MethodPut},
+		},
+	}
+
+	for _, tc := range testcases {
+		subscription, requests = tc.subscription, []string{}
+		err := ar.registerInstance(cred)
+		if err != nil {
+			t.Errorf("[%s] unexpected error: %v", tc.description, err)
+		}
+		if !reflect.DeepEqual(requests, tc.requests) {
+			t.Errorf("[%s] expected requests %v, got %v", tc.description, tc.requests, requests)
+		}
+	}
+}
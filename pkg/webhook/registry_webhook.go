@@ -0,0 +1,188 @@
+/*
+Copyright (C) 2019 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/blackducksoftware/perceivers/pkg/communicator"
+	"github.com/blackducksoftware/perceivers/pkg/docker"
+	"github.com/blackducksoftware/perceivers/pkg/mapper"
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+	perceptorapi "github.com/blackducksoftware/perceptor/pkg/api"
+	log "github.com/sirupsen/logrus"
+)
+
+// RegistryEnvelope is the body of a notification a docker distribution
+// registry sends, which holds a batch of events
+type RegistryEnvelope struct {
+	Events []RegistryEvent `json:"events"`
+}
+
+// RegistryEvent is an action on a registry, like the push of a manifest
+type RegistryEvent struct {
+	ID     string `json:"id"`
+	Action string `json:"action"`
+	Target struct {
+		MediaType  string `json:"mediaType"`
+		Digest     string `json:"digest"`
+		Repository string `json:"repository"`
+		URL        string `json:"url"`
+		Tag        string `json:"tag"`
+	} `json:"target"`
+	Request struct {
+		Host string `json:"host"`
+	} `json:"request"`
+}
+
+// registryPushAction is the action of the events for pushed manifests and blobs
+const registryPushAction = "push"
+
+// RegistryWebhook handles the notifications of registries and sends the
+// pushed images to perceptor
+type RegistryWebhook struct {
+	perceptorURL   string
+	registryAuths  []*utils.RegistryAuth
+	certificate    string
+	certificateKey string
+	client         *registry.Client
+}
+
+// NewRegistryWebhook creates a new RegistryWebhook object
+func NewRegistryWebhook(perceptorURL string, credentials []*utils.RegistryAuth, certificate string, certificateKey string, client *registry.Client) *RegistryWebhook {
+	return &RegistryWebhook{
+		perceptorURL:   perceptorURL,
+		registryAuths:  credentials,
+		certificate:    certificate,
+		certificateKey: certificateKey,
+		client:         client,
+	}
+}
+
+// ServeHTTP handles a notification of a registry
+func (rw *RegistryWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		log.Info("Webhook: Registry notification incoming!")
+		envelope := &RegistryEnvelope{}
+		err := json.NewDecoder(r.Body).Decode(envelope)
+		if err != nil {
+			log.Errorf("Webhook: unable to decode registry notification: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, event := range envelope.Events {
+			rw.webhook(&event)
+		}
+	}
+}
+
+// Run starts a webhook that receives the notifications of registries and
+// sends the pushed images to perceptor
+func (rw *RegistryWebhook) Run() {
+
+	http.Handle("/webhook", rw)
+
+	if len(rw.certificate) > 0 && len(rw.certificateKey) > 0 {
+		errC := ioutil.WriteFile("cert", []byte(rw.certificate), 0644)
+		errK := ioutil.WriteFile("key", []byte(rw.certificateKey), 0644)
+		if errC != nil || errK != nil {
+			log.Errorf("Webhook: Writing to a certificate file failed %e %e", errC, errK)
+		} else {
+			log.Infof("Webhook: Starting HTTPs webhook with TLS enabled for registry on :3002 at /webhook")
+			err := http.ListenAndServeTLS(":3002", "cert", "key", nil)
+			if err != nil {
+				log.Errorf("Webhook: HTTPs listener on port 3002 failed: %e", err)
+			}
+		}
+	} else {
+		log.Infof("Webhook: starting HTTP webhook for registry on :3002 at /webhook")
+		err := http.ListenAndServe(":3002", nil)
+		if err != nil {
+			log.Errorf("Webhook: HTTP listener on port 3002 failed: %e", err)
+		}
+	}
+}
+
+func (rw *RegistryWebhook) webhook(event *RegistryEvent) {
+	// Blobs are pushed without a tag, and so are the platform manifests of
+	// a manifest list, which are sent when the list is pushed
+	if event.Action != registryPushAction || len(event.Target.Tag) == 0 {
+		return
+	}
+
+	auth := rw.registryAuth(event)
+	if auth == nil {
+		log.Debugf("Webhook: Event %s for %s is not from a configured registry", event.ID, event.Target.URL)
+		return
+	}
+
+	manifest := &registry.Manifest{MediaType: event.Target.MediaType, Digest: event.Target.Digest}
+	if manifest.IsList() {
+		ref := &docker.Reference{Registry: auth.URL, Repository: event.Target.Repository, Tag: event.Target.Tag}
+		ref.DigestAlgorithm, ref.Digest, _ = docker.ParseDigest(event.Target.Digest)
+		var err error
+		manifest, err = rw.client.Manifest(ref, auth.Credentials())
+		if err != nil {
+			log.Errorf("Webhook: Error in getting manifest list of %s: %v", ref, err)
+			return
+		}
+	}
+
+	repository := fmt.Sprintf("%s/%s", auth.URL, event.Target.Repository)
+	images, err := mapper.NewPerceptorImagesFromManifest(repository, event.Target.Tag, manifest)
+	if err != nil {
+		log.Errorf("Webhook: %v", err)
+		return
+	}
+	imageURL := fmt.Sprintf("%s/%s", rw.perceptorURL, perceptorapi.ImagePath)
+	for _, image := range images {
+		err = communicator.SendPerceptorAddEvent(imageURL, image)
+		if err != nil {
+			log.Errorf("Webhook: Error putting image %v in perceptor queue %e", image, err)
+		} else {
+			log.Infof("Webhook: Successfully put image %s with tag %s in perceptor queue", repository, event.Target.Tag)
+		}
+	}
+}
+
+// registryAuth returns the configured registry an event is from, which is
+// the host the image was pushed to
+func (rw *RegistryWebhook) registryAuth(event *RegistryEvent) *utils.RegistryAuth {
+	for _, auth := range rw.registryAuths {
+		if strings.EqualFold(event.Request.Host, auth.URL) {
+			return auth
+		}
+	}
+	// The host of the URL of the target is the one the registry is
+	// configured to be reached at, which differs behind a proxy
+	for _, auth := range rw.registryAuths {
+		if strings.Contains(event.Target.URL, "://"+auth.URL+"/") {
+			return auth
+		}
+	}
+	return nil
+}
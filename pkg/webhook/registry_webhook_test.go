@@ -0,0 +1,212 @@
+/*
+Copyright (C) 2018 Synopsys, Inc.
+
+Licensed to the Apache Software Foundation (ASF) under one
+or more contributor license agreements. See the NOTICE file
+distributed with this work for additional information
+regarding copyright ownership. The ASF licenses this file
+to you under the Apache License, Version 2.0 (the
+"License"); you may not use this file except in compliance
+with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing,
+software distributed under the License is distributed on an
+"AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+KIND, either express or implied. See the License for the
+specific language governing permissions and limitations
+under the License.
+*/
+
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blackducksoftware/perceivers/pkg/registry"
+	utils "github.com/blackducksoftware/perceivers/pkg/utils"
+)
+
+// registryNotification is a notification of a docker distribution registry
+// for a pushed blob and an action on a manifest.  The host the manifest was
+// pushed to and the host of its URL differ behind a proxy
+const registryNotification = `{"events":[{
+	"id":"320678d8-ca14-430f-8bb6-4ca139cd83f7",
+	"timestamp":"2019-03-09T14:44:26.402973972Z",
+	"action":"push",
+	"target":{
+		"mediaType":"application/octet-stream",
+		"size":2727,
+		"digest":"sha256:fea8895f450959fa676bcc1df0611ea93823a735a01205fd8622846041d0c7cf",
+		"length":2727,
+		"repository":"team/app",
+		"url":"https://%[5]s/v2/team/app/blobs/sha256:fea8895f450959fa676bcc1df0611ea93823a735a01205fd8622846041d0c7cf"},
+	"request":{"id":"6df24a34-0959-4923-81ca-14f09767db19","addr":"10.0.0.5:41632","host":%[4]q,"method":"PUT","useragent":"docker/18.09.2"},
+	"actor":{},
+	"source":{"addr":"registry-5d6f9:5000","instanceID":"9c2c6a8d-8f4e-4b36-9a7c-3c1f4f2d5b4e"}},
+	{"id":"6b8c4d2e-0f1a-4c5d-9e8f-7a6b5c4d3e2f",
+	"timestamp":"2019-03-09T14:44:26.502973972Z",
+	"action":%[1]q,
+	"target":{
+		"mediaType":%[2]q,
+		"size":1357,
+		"digest":%[3]q,
+		"length":1357,
+		"repository":"team/app",
+		"url":"https://%[5]s/v2/team/app/manifests/%[3]s",
+		"tag":%[6]q},
+	"request":{"id":"7e1a2b3c-4d5e-4f6a-8b9c-0d1e2f3a4b5c","addr":"10.0.0.5:41632","host":%[4]q,"method":"PUT","useragent":"docker/18.09.2"},
+	"actor":{},
+	"source":{"addr":"registry-5d6f9:5000","instanceID":"9c2c6a8d-8f4e-4b36-9a7c-3c1f4f2d5b4e"}}]}`
+
+func TestRegistryWebhook(t *testing.T) {
+	fr := newFakeRegistry()
+	defer fr.server.Close()
+	host := fr.host()
+	sha := strings.TrimPrefix(testDigest, "sha256:")
+	platformShas := []string{
+		"linux/amd64 " + strings.TrimPrefix(testAmd64Digest, "sha256:"),
+		"linux/arm64 " + strings.TrimPrefix(testArm64Digest, "sha256:"),
+	}
+
+	testcases := []struct {
+		description string
+		registry    string
+		action      string
+		mediaType   string
+		tag         string
+		requestHost string
+		targetHost  string
+		shas        []string
+		paths       []string
+	}{
+		{
+			description: "pushed image",
+			registry:    host,
+			action:      "push",
+			mediaType:   registry.MediaTypeManifest,
+			tag:         "1.0",
+			requestHost: host,
+			targetHost:  host,
+			shas:        []string{sha},
+			paths:       []string{},
+		},
+		{
+			description: "pushed manifest list",
+			registry:    host,
+			action:      "push",
+			mediaType:   registry.MediaTypeManifestList,
+			tag:         "1.0",
+			requestHost: host,
+			targetHost:  host,
+			shas:        platformShas,
+			paths:       []string{"/v2/team/app/manifests/" + testDigest},
+		},
+		{
+			description: "platform manifest of a pushed list, which has no tag",
+			registry:    host,
+			action:      "push",
+			mediaType:   registry.MediaTypeManifest,
+			tag:         "",
+			requestHost: host,
+			targetHost:  host,
+			shas:        []string{},
+			paths:       []string{},
+		},
+		{
+			description: "pull",
+			registry:    host,
+			action:      "pull",
+			mediaType:   registry.MediaTypeManifestList,
+			tag:         "1.0",
+			requestHost: host,
+			targetHost:  host,
+			shas:        []string{},
+			paths:       []string{},
+		},
+		{
+			description: "push to the registry by another case of its host",
+			registry:    "LOCALHOST" + strings.TrimPrefix(host, "127.0.0.1"),
+			action:      "push",
+			mediaType:   registry.MediaTypeManifest,
+			tag:         "1.0",
+			requestHost: "localhost" + strings.TrimPrefix(host, "127.0.0.1"),
+			targetHost:  "localhost" + strings.TrimPrefix(host, "127.0.0.1"),
+			shas:        []string{sha},
+			paths:       []string{},
+		},
+		{
+			description: "push through a proxy to the registry",
+			registry:    host,
+			action:      "push",
+			mediaType:   registry.MediaTypeManifestList,
+			tag:         "1.0",
+			requestHost: "proxy.example.com",
+			targetHost:  host,
+			shas:        platformShas,
+			paths:       []string{"/v2/team/app/manifests/" + testDigest},
+		},
+		{
+			description: "push to an unknown registry",
+			registry:    "registry.example.com",
+			action:      "push",
+			mediaType:   registry.MediaTypeManifestList,
+			tag:         "1.0",
+			requestHost: host,
+			targetHost:  host,
+			shas:        []string{},
+			paths:       []string{},
+		},
+		{
+			description: "push to a registry whose host starts with the configured one",
+			registry:    "127.0.0.1",
+			action:      "push",
+			mediaType:   registry.MediaTypeManifest,
+			tag:         "1.0",
+			requestHost: host,
+			targetHost:  host,
+			shas:        []string{},
+			paths:       []string{},
+		},
+	}
+
+	for _, tc := range testcases {
+		fp := utils.NewFakePerceptor()
+		perceptor := httptest.NewServer(fp)
+		fr.paths = []string{}
+		auth := &utils.RegistryAuth{URL: tc.registry, User: "user", Password: "password"}
+		client := registry.NewClient([]string{tc.registry}, 5*time.Second)
+		rw := NewRegistryWebhook(perceptor.URL, []*utils.RegistryAuth{auth}, "", "", client)
+
+		body := fmt.Sprintf(registryNotification, tc.action, tc.mediaType, testDigest, tc.requestHost, tc.targetHost, tc.tag)
+		rw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body)))
+		perceptor.Close()
+
+		if shas := fp.Shas(); !reflect.DeepEqual(shas, tc.shas) {
+			t.Errorf("[%s] expected images %v, got %v", tc.description, tc.shas, shas)
+		}
+		for _, image := range fp.Images {
+			if image.Repository != tc.registry+"/team/app" || image.Tag != "1.0" {
+				t.Errorf("[%s] expected images of %s/team/app:1.0, got %s:%s", tc.description, tc.registry, image.Repository, image.Tag)
+			}
+		}
+		if !reflect.DeepEqual(fr.paths, tc.paths) {
+			t.Errorf("[%s] expected registry requests %v, got %v", tc.description, tc.paths, fr.paths)
+		}
+	}
+
+	// A notification that isn't json is rejected
+	rw := NewRegistryWebhook("http://perceptor", nil, "", "", nil)
+	recorder := httptest.NewRecorder()
+	rw.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("push")))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("expected a notification that isn't json to be rejected, got %d", recorder.Code)
+	}
+}